@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// findModule returns the root directory and module path of the module containing dir.
+func findModule(dir string) (root, modulePath string, err error) {
+	for root = dir; ; root = filepath.Dir(root) {
+		content, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			modulePath = modfile.ModulePath(content)
+			if modulePath == "" {
+				return "", "", fmt.Errorf("no module path in %s", filepath.Join(root, "go.mod"))
+			}
+			return root, modulePath, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", fmt.Errorf("failed to read go.mod: %w", err)
+		}
+		if filepath.Dir(root) == root {
+			return "", "", fmt.Errorf("no go.mod found in %s or any parent directory", dir)
+		}
+	}
+}
+
+// resolveAdapterPackage resolves the import path of the package adapters are generated into,
+// such as example.com/app/internal/adapters, to its directory and package name. The package
+// must belong to the module containing sourceFile.
+func resolveAdapterPackage(sourceFile, importPath string) (dir, packageName string, err error) {
+	root, modulePath, err := findModule(filepath.Dir(sourceFile))
+	if err != nil {
+		return "", "", err
+	}
+
+	rel, ok := strings.CutPrefix(importPath, modulePath)
+	if !ok || (rel != "" && !strings.HasPrefix(rel, "/")) {
+		return "", "", fmt.Errorf("adapter package %s is not within module %s", importPath, modulePath)
+	}
+	rel = strings.TrimPrefix(rel, "/")
+	if rel != path.Clean(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", "", fmt.Errorf("adapter package %s is not a clean import path", importPath)
+	}
+
+	packageName = path.Base(importPath)
+	if !token.IsIdentifier(packageName) {
+		return "", "", fmt.Errorf("adapter package %s does not end in a valid package name", importPath)
+	}
+	return filepath.Join(root, filepath.FromSlash(rel)), packageName, nil
+}
+
+// outputImportPath returns the import path of the package of outputFile, from the module
+// containing its directory, or an empty string if it is not within a module.
+func outputImportPath(outputFile string) string {
+	dir, err := filepath.Abs(filepath.Dir(outputFile))
+	if err != nil {
+		return ""
+	}
+	root, modulePath, err := findModule(dir)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return modulePath
+	}
+	return path.Join(modulePath, filepath.ToSlash(rel))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/origadmin/adptool/internal/compiler"
+	"github.com/origadmin/adptool/internal/config"
+)
+
+// combineFiles generates a single adapter file at opts.combine from the directives of all files.
+// The directives are parsed into one copy of cfg and compiled together, so the generator
+// deduplicates the imports and assigns the aliases of all adapted packages at once. A package
+// adapted by several files is generated once, with the rules of all of them.
+func combineFiles(files []string, cfg *config.Config, opts *options) error {
+	outputFile, err := filepath.Abs(opts.combine)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	outputDir := filepath.Dir(outputFile)
+
+	timer := newPhaseTimer(opts.verboseTiming)
+	defer timer.log(outputFile)
+
+	merged := cfg.Clone()
+	var sources []string
+	for _, file := range files {
+		opts.stats.processed()
+		pkgConfig, err := parseFileConfig(file, merged)
+		if err != nil {
+			return err
+		}
+		if pkgConfig == nil {
+			opts.stats.skipped()
+			continue
+		}
+		merged = pkgConfig
+		source, err := filepath.Rel(outputDir, file)
+		if err != nil {
+			source = file
+		}
+		sources = append(sources, filepath.ToSlash(source))
+	}
+	merged.MergeRules(opts.sharedRules)
+	timer.mark("parse")
+	if len(sources) == 0 {
+		slog.Info("No //go:adapter directives to combine")
+		return nil
+	}
+
+	compiledCfg, err := compiler.Compile(merged, opts.compileOptions()...)
+	timer.mark("compile")
+	if err != nil {
+		return fmt.Errorf("error compiling combined config: %w", err)
+	}
+
+	if opts.listRules {
+		fmt.Printf("# %s\n", outputFile)
+		return compiler.WriteRuleTable(os.Stdout, compiledCfg)
+	}
+
+	// The package clause of the last parsed file would be arbitrary, so the name is taken
+	// from the configuration file if it sets one, and otherwise from the output directory.
+	packageName := cfg.PackageName
+	if packageName == "" {
+		packageName = filepath.Base(outputDir)
+	}
+
+	return generateAdapter(merged, compiledCfg, outputFile, packageName, strings.Join(sources, ", "), opts, timer)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runGit runs git with the given arguments in dir and returns its standard output.
+// It is a variable so tests can replace git.
+var runGit = func(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd.Output()
+}
+
+// gitChangedFiles returns the absolute paths of the files changed between ref and HEAD in the
+// git repository containing dir. Deleted files are included; callers only see them if they exist.
+func gitChangedFiles(dir, ref string) (map[string]bool, error) {
+	out, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository: %w", err)
+	}
+	root := strings.TrimSpace(string(out))
+
+	out, err = runGit(root, "diff", "--name-only", ref+"...HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("git diff against %s failed: %w", ref, err)
+	}
+
+	changed := make(map[string]bool)
+	for _, name := range strings.Split(string(out), "\n") {
+		if name = strings.TrimSpace(name); name != "" {
+			changed[filepath.Join(root, filepath.FromSlash(name))] = true
+		}
+	}
+	return changed, nil
+}
+
+// filterChangedSince keeps the files that changed between ref and HEAD. If git cannot be
+// used, for example because dir is not in a repository, it warns and keeps all files.
+func filterChangedSince(files []string, dir, ref string) []string {
+	changed, err := gitChangedFiles(dir, ref)
+	if err != nil {
+		slog.Warn("Cannot determine changed files, processing all files", "ref", ref, "error", err)
+		return files
+	}
+
+	var filtered []string
+	for _, file := range files {
+		// git reports paths below the resolved repository root, which may differ from file
+		// when the input path goes through a symlink.
+		resolved, err := filepath.EvalSymlinks(file)
+		if err != nil {
+			resolved = file
+		}
+		if changed[file] || changed[resolved] {
+			filtered = append(filtered, file)
+		} else {
+			slog.Debug("Skipping file unchanged since git ref", "file", file, "ref", ref)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// runHook runs command, the -pre-hook or -post-hook named by stage, through the shell and logs
+// its combined output. An error, which aborts the run, carries the output of a failed command.
+func runHook(stage, command string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if output != "" {
+			return fmt.Errorf("%s hook %q failed: %w: %s", stage, command, err, output)
+		}
+		return fmt.Errorf("%s hook %q failed: %w", stage, command, err)
+	}
+	slog.Info("Ran hook", "stage", stage, "command", command, "output", output)
+	return nil
+}
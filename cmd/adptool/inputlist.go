@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/origadmin/adptool/internal/parser"
+)
+
+// readInputFiles reads the files to process from the list at listPath, or from stdin if it is
+// "-", instead of scanning a directory.
+func readInputFiles(listPath string) ([]string, error) {
+	if listPath == "-" {
+		return parseInputList(os.Stdin)
+	}
+	f, err := os.Open(listPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input list: %w", err)
+	}
+	defer f.Close()
+	return parseInputList(f)
+}
+
+// parseInputList returns the absolute paths of the newline-separated files listed in r, relative
+// paths being relative to the working directory. Blank lines are skipped. Each file must be a Go
+// file with a //go:adapter directive.
+func parseInputList(r io.Reader) ([]string, error) {
+	var files []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		path, err := filepath.Abs(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path of %s: %w", line, err)
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil, fmt.Errorf("listed file %s is not a Go file", line)
+		}
+		hasAdapter, err := parser.FileHasDirective(path)
+		if err != nil {
+			return nil, err
+		}
+		if !hasAdapter {
+			return nil, fmt.Errorf("listed file %s has no //go:adapter directive", line)
+		}
+		files = append(files, path)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input list: %w", err)
+	}
+	return files, nil
+}
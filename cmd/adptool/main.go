@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
+	"go/token"
+	"io"
 	"io/fs"
 	"log/slog"
 	"os"
@@ -12,92 +14,522 @@ import (
 	"github.com/origadmin/adptool/internal/compiler"
 	"github.com/origadmin/adptool/internal/config"
 	"github.com/origadmin/adptool/internal/generator"
+	"github.com/origadmin/adptool/internal/interfaces"
 	"github.com/origadmin/adptool/internal/loader"
 	"github.com/origadmin/adptool/internal/parser"
 )
 
-// processFile processes a single Go file and generates its adapter
-func processFile(filePath string, cfg *config.Config, copyrightHolder string) error {
+// options holds the command-line settings shared by every processed file.
+type options struct {
+	copyrightHolder string
+	// toolVersion, if set, is the version of adptool written in the header of generated files.
+	toolVersion string
+	// sharedRules, if set, holds the rules of -rules-from, merged beneath those of each file.
+	sharedRules *config.Config
+	// baseline is the path to a previously generated adapter whose symbols are skipped.
+	baseline string
+	// lenient reports invalid generated names as warnings instead of failing.
+	lenient bool
+	// sanitizeNames turns explicit rename targets that are not identifiers into identifiers.
+	sanitizeNames bool
+	// excludeGenerated skips files carrying adptool's no-edit banner when scanning a directory.
+	excludeGenerated bool
+	// listRules prints the compiled rule table instead of generating adapters.
+	listRules bool
+	// reportSkipped logs each exported function left out because it uses unexported or internal types.
+	reportSkipped bool
+	// funcVarFallback re-exports functions using unexported or internal types as function variables.
+	funcVarFallback bool
+	// varAccessors re-exports variables with value semantics as getter and setter functions.
+	varAccessors bool
+	// contextHook, if set, names the function that wrappers of functions taking a context.Context first call before forwarding.
+	contextHook string
+	// lineEnding is the line terminator of generated files.
+	lineEnding generator.LineEnding
+	// outPerm is the permission of generated files.
+	outPerm os.FileMode
+	// sinceGit, if set, limits processing to files changed between this git ref and HEAD.
+	sinceGit string
+	// adapterPackage, if set, is the import path of the package all adapters are generated into.
+	adapterPackage string
+	// noFormat writes the generated code as printed, without running goimports on it.
+	noFormat bool
+	// dumpAST prints the AST of each generated file to stderr before it is formatted.
+	dumpAST bool
+	// combine, if set, is the path of a single adapter file generated from the directives of all files.
+	combine string
+	// groupByPackage lists the declarations of each source package together under a heading comment.
+	groupByPackage bool
+	// importVersions annotates the imports of the source packages with the versions of their modules.
+	importVersions bool
+	// splitGenerics writes declarations using generics for Go 1.18 and later only, with a fallback file without them.
+	splitGenerics bool
+	// strictIdentifiers fails generation if a generated name is not an ASCII exported identifier.
+	strictIdentifiers bool
+	// stubDisabledMethods gives embedding types a panicking stub for each disabled method.
+	stubDisabledMethods bool
+	// plainImport imports the package of a single-package adapter by its own name, without alias.
+	plainImport bool
+	// renameTable writes a comment mapping the source names of renamed symbols to their new names.
+	renameTable bool
+	// emitTests writes a test file next to each generated file referencing every generated symbol.
+	emitTests bool
+	// nilInterfaceVars declares a typed nil variable, such as NilReader, for each generated interface.
+	nilInterfaceVars bool
+	// outSuffixByPackage inserts the aliases of the source packages into the names of the adapter files.
+	outSuffixByPackage bool
+	// registerFunc, if set, is called by an init function of each generated file with the name and zero value of each type.
+	registerFunc generator.RegisterFunc
+	// failOnEmptyPackage fails generation if a configured package contributes no declarations.
+	failOnEmptyPackage bool
+	// warnGeneratedSource warns about source packages whose files are marked as generated.
+	warnGeneratedSource bool
+	// flattenInterfaces makes interfaces extracted from interfaces list their full method set.
+	flattenInterfaces bool
+	// verboseTiming logs the duration of the parse, compile, collect and format phases of each file.
+	verboseTiming bool
+	// nolint lists the linters suppressed on each generated declaration by a //nolint comment.
+	nolint []string
+	// constComment and varComment are written above the blocks of generated constants and variables.
+	constComment string
+	varComment   string
+	// stats, if set, accumulates the metrics of the run for an end-of-run summary.
+	stats *runStats
+	// trace, if set, receives an event for each rename, written to the file of --trace-file.
+	trace *compiler.JSONLinesSink
+	// warnings, if set, counts the warnings logged, which fail the run with --werror.
+	warnings *warningCounter
+	// manifest, if set, accumulates the symbols of the generated adapters, written to manifestPath.
+	manifest     *generator.Manifest
+	manifestPath string
+	// goGenerate, if set, is the command of a //go:generate directive written in each generated file.
+	goGenerate string
+	// output, if set, receives the generated code instead of the output file.
+	output io.Writer
+	// emitted, if set, collects the formatted code of each adapter, in the order they are
+	// generated, instead of writing their output files.
+	emitted *strings.Builder
+	// outputs maps the output files generated in the run to the directive files generating them.
+	outputs map[string]string
+}
+
+// manifestFilePath returns the path of a generated file as recorded in the manifest: relative to
+// the working directory, with forward slashes, so that manifests compare across machines.
+func manifestFilePath(outputFile string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, outputFile); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(outputFile)
+}
+
+// loadSharedRules loads the configuration file at path, whose rules compileFile merges beneath
+// those of the configuration and directives of each file. Its packages and other settings are
+// not used.
+func loadSharedRules(path string) (*config.Config, error) {
+	shared, err := loader.LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(shared.Packages) > 0 {
+		slog.Warn("Ignoring the packages of a shared rules file", "file", path)
+	}
+	return shared, nil
+}
+
+// stringsFlag is a flag that may be repeated, collecting the value of each occurrence.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// loadConfigFiles loads the configuration files at paths, each merged over the previous ones,
+// so that e.g. the configuration of a repository overrides an organization-wide base.
+func loadConfigFiles(paths []string) (*config.Config, error) {
+	var cfg *config.Config
+	for _, path := range paths {
+		fileCfg, err := loader.LoadConfigFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if cfg == nil {
+			cfg = fileCfg
+		} else {
+			cfg.Merge(fileCfg)
+		}
+	}
+	return cfg, nil
+}
+
+// parseFileConfig parses the directives of a Go file on top of cfg.
+// It returns a nil configuration if the file has no //go:adapter directive.
+func parseFileConfig(filePath string, cfg *config.Config) (*config.Config, error) {
 	// First check if the file has the adapter directive
-	hasAdapter, err := hasAdapterDirective(filePath)
+	hasAdapter, err := parser.FileHasDirective(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to check adapter directive in %s: %w", filePath, err)
+		return nil, fmt.Errorf("failed to check adapter directive in %s: %w", filePath, err)
 	}
 
 	if !hasAdapter {
 		slog.Debug("Skipping file without //go:adapter directive", "file", filePath)
-		return nil
+		return nil, nil
 	}
 
 	// Parse the Go file to get the AST
 	file, fset, err := loader.LoadGoFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to load Go file %s: %w", filePath, err)
+		return nil, fmt.Errorf("failed to load Go file %s: %w", filePath, err)
 	}
 
 	// Parse file directives using the loaded config
 	pkgConfig, err := parser.ParseFileDirectives(cfg, file, fset)
 	if err != nil {
-		return fmt.Errorf("failed to parse file directives in %s: %w", filePath, err)
+		return nil, fmt.Errorf("failed to parse file directives in %s: %w", filePath, err)
+	}
+	return pkgConfig, nil
+}
+
+// compileOptions returns the options compiling the configurations of the run.
+func (opts *options) compileOptions() []compiler.Option {
+	return []compiler.Option{
+		compiler.WithLenient(opts.lenient),
+		compiler.WithSanitizeNames(opts.sanitizeNames),
+		compiler.WithStrictIdentifiers(opts.strictIdentifiers),
 	}
+}
+
+// compileFile parses the directives of a Go file on top of a copy of cfg, merges the rules of
+// shared, if any, beneath them and compiles the result, recording both phases in timer. It returns
+// nil configurations if the file has no //go:adapter directive.
+func compileFile(filePath string, cfg, shared *config.Config, timer *phaseTimer, compileOpts ...compiler.Option) (*config.Config, *interfaces.CompiledConfig, error) {
+	// Parsing adds the directives of the file to the configuration, which must not carry them
+	// over to the next file.
+	pkgConfig, err := parseFileConfig(filePath, cfg.Clone())
+	timer.mark("parse")
+	if err != nil || pkgConfig == nil {
+		return nil, nil, err
+	}
+	// The shared rules are merged after the directives, so that those win as well.
+	pkgConfig.MergeRules(shared)
 
 	// Compile the configuration
-	compiledCfg, err := compiler.Compile(pkgConfig)
+	compiledCfg, err := compiler.Compile(pkgConfig, compileOpts...)
+	timer.mark("compile")
 	if err != nil {
-		return fmt.Errorf("error compiling config for %s: %w", filePath, err)
+		return nil, nil, fmt.Errorf("error compiling config for %s: %w", filePath, err)
+	}
+	return pkgConfig, compiledCfg, nil
+}
+
+// processFile processes a single Go file and generates its adapter
+func processFile(filePath string, cfg *config.Config, opts *options) error {
+	opts.stats.processed()
+	timer := newPhaseTimer(opts.verboseTiming)
+	defer timer.log(filePath)
+
+	pkgConfig, compiledCfg, err := compileFile(filePath, cfg, opts.sharedRules, timer, opts.compileOptions()...)
+	if err != nil {
+		return err
+	}
+	if compiledCfg == nil {
+		opts.stats.skipped()
+		return nil
 	}
 
-	replacer := compiler.NewReplacer(compiledCfg)
+	if opts.listRules {
+		fmt.Printf("# %s\n", filePath)
+		return compiler.WriteRuleTable(os.Stdout, compiledCfg)
+	}
 
-	// Set output file path (same directory as input file with .adapter.go suffix)
+	// The output file is in the same directory as the input file, with the .adapter.go suffix.
 	dir := filepath.Dir(filePath)
 	baseName := filepath.Base(filePath)
-	ext := filepath.Ext(baseName)
-	outputBase := baseName[:len(baseName)-len(ext)] + ".adapter.go"
-	outputFile := filepath.Join(dir, outputBase)
+	outputDir := dir
+
+	// Determine the package name
+	packageName := pkgConfig.PackageName
+	if packageName == "" {
+		packageName = filepath.Base(dir)
+	}
+
+	// A dedicated adapter package overrides both the output directory and the package clause.
+	if opts.adapterPackage != "" {
+		adapterDir, adapterName, err := resolveAdapterPackage(filePath, opts.adapterPackage)
+		if err != nil {
+			return fmt.Errorf("invalid -adapter-package for %s: %w", filePath, err)
+		}
+		outputDir = adapterDir
+		packageName = adapterName
+	}
+
+	outputBase, err := outputBaseName(baseName, pkgConfig.RenameFile, packageName)
+	if err != nil {
+		return fmt.Errorf("invalid rename_file for %s: %w", filePath, err)
+	}
+	if opts.outSuffixByPackage {
+		outputBase = packageSuffixedName(outputBase, compiledCfg.Packages)
+	}
+	outputFile := filepath.Join(outputDir, outputBase)
+	if err := opts.claimOutput(outputFile, filePath); err != nil {
+		return err
+	}
+
+	return generateAdapter(pkgConfig, compiledCfg, outputFile, packageName, baseName, opts, timer)
+}
+
+// processFiles generates the adapters of files, or a single one from all of them with -combine,
+// logging the errors. It reports whether any file failed.
+func processFiles(files []string, cfg *config.Config, opts *options) (hasErrors bool) {
+	if opts.combine != "" {
+		if err := combineFiles(files, cfg, opts); err != nil {
+			slog.Error("Error generating combined adapter", "output", opts.combine, "error", err)
+			return true
+		}
+		return false
+	}
+	for _, file := range files {
+		if err := processFile(file, cfg, opts); err != nil {
+			slog.Error("Error processing file", "file", file, "error", err)
+			hasErrors = true
+		}
+	}
+	return hasErrors
+}
+
+// outputBaseName returns the name of the adapter file generated from the directive file
+// baseName: by default baseName with the .adapter.go suffix in place of its extension, or with
+// renameFile, packageName or renameFile itself with that suffix.
+func outputBaseName(baseName, renameFile, packageName string) (string, error) {
+	name := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+	switch renameFile {
+	case "":
+	case "package":
+		name = packageName
+	default:
+		if renameFile == "." || renameFile == ".." || strings.ContainsAny(renameFile, `/\`) {
+			return "", fmt.Errorf("%q is not a file name", renameFile)
+		}
+		name = renameFile
+	}
+	return name + ".adapter.go", nil
+}
+
+// packageSuffixedName inserts the aliases of the source packages into the adapter file name
+// outputBase, e.g. source.lib.adapter.go for source.adapter.go adapting a package aliased lib,
+// so that directive files of the same name adapting different packages into one directory, as
+// with -adapter-package, do not collide.
+func packageSuffixedName(outputBase string, packages []*interfaces.CompiledPackage) string {
+	if len(packages) == 0 {
+		return outputBase
+	}
+	aliases := make([]string, len(packages))
+	for i, pkg := range packages {
+		aliases[i] = pkg.ImportAlias
+	}
+	return strings.TrimSuffix(outputBase, ".adapter.go") + "." + strings.Join(aliases, "_") + ".adapter.go"
+}
+
+// claimOutput records that outputFile is generated from filePath, and fails if another file of
+// the run already generates it, as directive files with the same rename_file in one directory do.
+func (opts *options) claimOutput(outputFile, filePath string) error {
+	if opts.outputs == nil {
+		opts.outputs = make(map[string]string)
+	}
+	if other, ok := opts.outputs[outputFile]; ok && other != filePath {
+		return fmt.Errorf("%s and %s both generate %s, set a different rename_file for one of them or use -out-suffix-by-package", other, filePath, outputFile)
+	}
+	opts.outputs[outputFile] = filePath
+	return nil
+}
+
+// generateAdapter generates the adapter of a parsed and compiled configuration into outputFile,
+// recording the collect and format phases in timer. sourceName names the directive file, or
+// files, recorded in the header.
+func generateAdapter(pkgConfig *config.Config, compiledCfg *interfaces.CompiledConfig, outputFile, packageName, sourceName string, opts *options, timer *phaseTimer) error {
+	var replacerOpts []compiler.ReplacerOption
+	if opts.trace != nil {
+		replacerOpts = append(replacerOpts, compiler.WithTraceSink(opts.trace))
+	}
+	replacer := compiler.NewReplacer(compiledCfg, replacerOpts...)
 
 	// Convert PackageConfig to PackageInfo
 	var packageInfos []*generator.PackageInfo
-	for _, pkg := range pkgConfig.Packages {
+	for i, pkg := range pkgConfig.Packages {
 		packageInfos = append(packageInfos, &generator.PackageInfo{
-			ImportPath:  pkg.Import,
-			ImportAlias: pkg.Alias,
+			ImportPath:     pkg.Import,
+			ImportAlias:    pkg.Alias,
+			ForceAlias:     pkg.ForceAlias,
+			Version:        pkg.Version,
+			Registry:       pkg.Registry,
+			Instantiations: compiledCfg.Packages[i].Instantiations,
 		})
 	}
 
-	// Determine the package name
-	packageName := pkgConfig.PackageName
-	if packageName == "" {
-		packageName = filepath.Base(dir)
+	// Generate the adapter file
+	gen := generator.NewGenerator(packageName, outputFile, replacer, opts.copyrightHolder).
+		WithExtractInterfaces(compiledCfg.ExtractInterfaces).
+		WithEmbedTypes(compiledCfg.EmbedTypes).
+		WithDisabledMethods(compiledCfg.DisabledMethods).
+		WithStubDisabledMethods(opts.stubDisabledMethods).
+		WithDefineTypes(compiledCfg.DefineTypes).
+		WithIgnores(compiledCfg.IgnoresByPackageAndType).
+		WithRuleSymbols(compiler.RuleSymbols(compiledCfg)).
+		WithOnlyTagged(compiledCfg.OnlyTagged).
+		WithSkipDeprecated(compiledCfg.SkipDeprecated).
+		WithAllowedImports(compiledCfg.AllowedImports...).
+		WithImporterPath(outputImportPath(outputFile)).
+		WithCollisionStrategy(generator.CollisionStrategy(compiledCfg.CollisionStrategy)).
+		WithOutputOrder(compiledCfg.OutputOrder...).
+		WithFuncVarFallback(opts.funcVarFallback).
+		WithVarAccessors(opts.varAccessors).
+		WithContextHook(opts.contextHook).
+		WithLineEnding(opts.lineEnding).
+		WithFileMode(opts.outPerm).
+		WithGroupByPackage(opts.groupByPackage).
+		WithImportVersions(opts.importVersions).
+		WithStrictIdentifiers(opts.strictIdentifiers).
+		WithLenient(opts.lenient).
+		WithFailOnEmptyPackage(opts.failOnEmptyPackage).
+		WithWarnGeneratedSource(opts.warnGeneratedSource).
+		WithFlattenInterfaces(opts.flattenInterfaces).
+		WithPlainImport(opts.plainImport).
+		WithRenameTable(opts.renameTable).
+		WithEmitTests(opts.emitTests).
+		WithNilInterfaceVars(opts.nilInterfaceVars).
+		WithRegisterFunc(opts.registerFunc).
+		WithSplitGenerics(opts.splitGenerics).
+		WithNolint(opts.nolint...).
+		WithBlockComment(token.CONST, opts.constComment).
+		WithBlockComment(token.VAR, opts.varComment).
+		WithFormatCode(!opts.noFormat)
+
+	if opts.output != nil {
+		gen.WithWriter(opts.output)
+	}
+	if opts.dumpAST {
+		gen.WithASTDump(os.Stderr)
+	}
+	if opts.goGenerate != "" {
+		gen.WithGenerateDirectives(opts.goGenerate)
+	}
+	if opts.toolVersion != "" {
+		gen.WithToolVersion(opts.toolVersion)
 	}
 
-	// Generate the adapter file
-	gen := generator.NewGenerator(packageName, outputFile, replacer, copyrightHolder)
+	if opts.baseline != "" {
+		names, err := generator.LoadBaseline(opts.baseline)
+		if err != nil {
+			return err
+		}
+		gen.WithBaseline(names)
+	}
 
 	// Render the header using the source file's name
-	if err := gen.RenderHeader(baseName); err != nil {
-		return fmt.Errorf("failed to render header for %s: %w", filePath, err)
+	if err := gen.RenderHeader(sourceName); err != nil {
+		return fmt.Errorf("failed to render header for %s: %w", outputFile, err)
 	}
 
-	if err := gen.Generate(packageInfos); err != nil {
+	var err error
+	if opts.emitted != nil {
+		var src string
+		src, err = gen.GenerateToString(packageInfos)
+		opts.emitted.WriteString(src)
+	} else {
+		err = gen.Generate(packageInfos)
+	}
+	if err != nil {
 		return fmt.Errorf("error generating adapter file %s: %w", outputFile, err)
 	}
+	timings := gen.Timings()
+	timer.add("collect", timings.Collect)
+	timer.add("format", timings.Format)
+
+	if opts.reportSkipped {
+		for _, skipped := range gen.SkippedFunctions() {
+			slog.Warn("Skipped function using an unexported or internal type",
+				"package", skipped.ImportPath, "function", skipped.Name, "type", skipped.Type)
+		}
+	}
 
-	slog.Info("Generated adapter file", "path", outputFile)
+	opts.stats.written(gen, replacer)
+	if opts.manifest != nil {
+		adapter := gen.Manifest()
+		adapter.File = manifestFilePath(outputFile)
+		opts.manifest.Adapters = append(opts.manifest.Adapters, adapter)
+	}
+	if opts.output == nil && opts.emitted == nil {
+		slog.Info("Generated adapter file", "path", outputFile)
+	}
 	return nil
 }
 
-// hasAdapterDirective checks if the file contains //go:adapter directive
-func hasAdapterDirective(filePath string) (bool, error) {
+// resolveInputFiles returns the Go files to process for an input path: the file itself, or the
+// files with //go:adapter directives found under a directory.
+func resolveInputFiles(inputPath string, excludeGenerated bool) ([]string, error) {
+	// Get absolute path to the input path
+	abspath, err := filepath.Abs(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	// Check if the input is a directory or a file
+	fileInfo, err := os.Stat(abspath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	if fileInfo.IsDir() {
+		// If it's a directory, find all .go files
+		return findGoFiles(abspath, excludeGenerated)
+	}
+
+	// If it's a single file, just process that file
+	if !strings.HasSuffix(abspath, ".go") {
+		return nil, fmt.Errorf("input file %s is not a Go file", abspath)
+	}
+	return []string{abspath}, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty elements.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// isGeneratedFile checks if the file carries adptool's no-edit banner before its package clause.
+func isGeneratedFile(filePath string) (bool, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return false, fmt.Errorf("failed to read file %s: %w", filePath, err)
 	}
-	return strings.Contains(string(content), parser.DirectivePrefix), nil
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == generator.GeneratedBanner {
+			return true, nil
+		}
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+	}
+	return false, nil
 }
 
-// findGoFiles finds all .go files in the given directory that contain //go:adapter directive
-func findGoFiles(dir string) ([]string, error) {
+// findGoFiles finds all .go files in the given directory that contain //go:adapter directive.
+// If excludeGenerated is set, files generated by adptool are skipped so that the output of a
+// previous run is never processed as input.
+func findGoFiles(dir string, excludeGenerated bool) ([]string, error) {
 	// Handle current directory (.) case
 	if dir == "." {
 		var err error
@@ -121,8 +553,20 @@ func findGoFiles(dir string) ([]string, error) {
 			return nil
 		}
 
+		if excludeGenerated {
+			generated, err := isGeneratedFile(path)
+			if err != nil {
+				slog.Warn("Failed to check generated banner", "file", path, "error", err)
+				return nil
+			}
+			if generated {
+				slog.Debug("Skipping file generated by adptool", "file", path)
+				return nil
+			}
+		}
+
 		// Check if file contains //go:adapter directive
-		hasAdapter, err := hasAdapterDirective(path)
+		hasAdapter, err := parser.FileHasDirective(path)
 		if err != nil {
 			slog.Warn("Failed to check adapter directive", "file", path, "error", err)
 			return nil
@@ -143,83 +587,281 @@ func findGoFiles(dir string) ([]string, error) {
 }
 
 func main() {
-	configFile := flag.String("c", "", "Configuration file (YAML/JSON). If specified, it completely replaces adptool.yaml.")
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		if err := runValidate(os.Args[2:]); err != nil {
+			slog.Error("Validation failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "passthrough" {
+		if err := runPassthrough(os.Args[2:]); err != nil {
+			slog.Error("Passthrough generation failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "preview" {
+		if err := runPreview(os.Args[2:], os.Stdout); err != nil {
+			slog.Error("Preview failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var configFiles stringsFlag
+	flag.Var(&configFiles, "c", "Configuration file (YAML/JSON). If specified, it completely replaces adptool.yaml. Repeat to layer files, each merged over the previous ones.")
+	rulesFrom := flag.String("rules-from", "", "Configuration file whose global rules, ignores and default modes are merged beneath the local configuration and directives.")
 	copyrightHolder := flag.String("copyright-holder", "", "Copyright holder for the generated file header.")
+	headerVersion := flag.Bool("header-version", false, "Record the version of adptool, from its build information, in the header of generated files, e.g. to trace which release produced them.")
+	baseline := flag.String("baseline", "", "Existing adapter file; only symbols it does not already declare are generated.")
+	sanitizeNames := flag.Bool("sanitize-names", false, "Turn explicit rename targets that are not Go identifiers, such as my-type, into identifiers, such as myType, instead of failing.")
+	lenient := flag.Bool("lenient", false, "Warn instead of failing when a rule renames a symbol to a Go keyword or builtin.")
+	excludeGenerated := flag.Bool("exclude-generated", true, "Skip files generated by adptool when scanning a directory.")
+	listRules := flag.Bool("list-rules", false, "Print the compiled rename rules for each file instead of generating adapters.")
+	reportSkipped := flag.Bool("report-skipped", false, "Warn about each exported function skipped because it uses unexported or internal types.")
+	funcVarFallback := flag.Bool("func-var-fallback", false, "Re-export functions using unexported or internal types as function variables instead of skipping them.")
+	varAccessors := flag.Bool("var-accessors", false, "Re-export variables of value types, such as numbers and structs, as GetX/SetX functions that share the source variable's state.")
+	contextHook := flag.String("context-hook", "", "Function of the adapter package, of type func(context.Context, string) (context.Context, func()), that wrappers of functions taking a context.Context first call before forwarding, e.g. to start a trace span.")
+	lineEndings := flag.String("line-endings", string(generator.LineEndingLF), "Line endings of generated files: lf or crlf.")
+	outPerm := flag.String("out-perm", "0644", "Octal permission of generated files, set regardless of the umask.")
+	sinceGit := flag.String("since-git", "", "Only process files changed between this git ref and HEAD.")
+	adapterPackage := flag.String("adapter-package", "", "Import path of a package within the module, such as <module>/internal/adapters, to generate all adapters into.")
+	noFormat := flag.Bool("no-format", false, "Write the generated code as printed, without running goimports, e.g. to inspect output that fails to format.")
+	dumpAST := flag.Bool("dump-ast", false, "Print the AST of each generated file to stderr before it is formatted, to debug the generator.")
+	combine := flag.String("combine", "", "Generate a single adapter file at this path from the directives of all input files.")
+	groupByPackage := flag.Bool("group-by-package", false, "List the declarations of each source package together, under a \"// from <import path>\" comment, instead of by kind.")
+	importVersions := flag.Bool("import-versions", false, "Annotate the import of each source package with the version of its module, e.g. // v1.2.3.")
+	splitGenerics := flag.Bool("split-generics", false, "Constrain generated files using generics with //go:build go1.18, and write a <name>_nogenerics.go fallback without them for older toolchains.")
+	strictIdentifiers := flag.Bool("strict-identifiers", false, "Fail if a generated name is not an ASCII exported identifier, e.g. because a rename target has a smart quote or an accented letter.")
+	stubDisabledMethods := flag.Bool("stub-disabled-methods", false, "Give types re-exported with embed a method panicking with \"not implemented\" for each disabled method, so they keep satisfying the interfaces of the source type.")
+	emitTests := flag.Bool("emit-tests", false, "Write a _test.go file next to each generated file referencing every generated symbol, so that removed symbols fail the build of the tests.")
+	nilInterfaceVars := flag.Bool("nil-interface-vars", false, "Declare a typed nil variable, such as NilReader, for each generated interface, to simplify setting up mocks.")
+	registerFuncName := flag.String("register-func", "", "Function, as <import path>.<name> or a name of the adapter package, that an init function of each generated file calls with the name and zero value of each type, e.g. to register them with a codec or plugin framework without reflection.")
+	outSuffixByPackage := flag.Bool("out-suffix-by-package", false, "Insert the aliases of the source packages into the name of each adapter file, e.g. source.lib.adapter.go, so that directive files of the same name generating into one directory do not collide.")
+	renameTable := flag.Bool("rename-table", false, "Write a comment below the header of each generated file mapping the source name of each renamed symbol to its new name.")
+	plainImport := flag.Bool("plain-import", false, "Import the package of a single-package adapter by its own name, without the configured or generated alias.")
+	flattenInterfaces := flag.Bool("flatten-interfaces", false, "List the full method set in interfaces extracted from interfaces, instead of embedding the interfaces they embed.")
+	warnGeneratedSource := flag.Bool("warn-generated-source", false, "Warn about source packages whose files are marked as generated, as adapters over generated code are fragile.")
+	failOnEmptyPackage := flag.Bool("fail-on-empty-package", false, "Fail if a configured package contributes no declarations, e.g. because all its symbols are ignored.")
+	goGenerate := flag.String("go-generate", "", "Command of a //go:generate directive written in the header of each generated file, e.g. \"adptool .\".")
+	nolint := flag.String("nolint", "", "Comma-separated linters, such as unused,revive, suppressed on each generated declaration by a //nolint comment.")
+	constComment := flag.String("const-comment", "", "Comment written above the block of generated constants, e.g. \"Re-exported constants\".")
+	varComment := flag.String("var-comment", "", "Comment written above the block of generated variables.")
+	verboseTiming := flag.Bool("verbose-timing", false, "Log the duration of the parse, compile, collect and format phases of each file.")
+	emitTo := flag.String("emit-to", "", "Write the code of every generated adapter, one after the other, to this file instead of writing the adapter files, e.g. to inspect or embed it. Works for a single file or a directory.")
+	emitManifest := flag.String("emit-manifest", "", "Write a manifest of the generated API, listing each symbol with its kind, signature and source symbol, to this file: YAML if it ends in .yaml or .yml, JSON otherwise.")
+	traceFilePath := flag.String("trace-file", "", "Write an event for each rename, as a line of JSON with the original and final names, kind, package and rule, to this file.")
+	inputFromFile := flag.String("input-from-file", "", "File listing the Go files to process, one per line, or - for stdin, instead of an input path.")
+	preHook := flag.String("pre-hook", "", "Shell command run before generation, e.g. to update a dependency; the run is aborted if it fails.")
+	postHook := flag.String("post-hook", "", "Shell command run after a successful generation, e.g. \"goimports -w .\"; the run fails if it fails.")
+	werror := flag.Bool("werror", false, "Treat warnings, such as those about skipped functions or symbols named by rules but not found, as errors: the run fails if any is logged.")
+	stats := flag.Bool("stats", false, "Print a summary of the run: files processed, written and skipped, symbols generated by kind, rules applied and unused, and the elapsed time.")
 	flag.Parse()
 
+	lineEnding, err := generator.ParseLineEnding(*lineEndings)
+	if err != nil {
+		slog.Error("Invalid -line-endings value", "error", err)
+		os.Exit(1)
+	}
+	fileMode, err := generator.ParseFileMode(*outPerm)
+	if err != nil {
+		slog.Error("Invalid -out-perm value", "error", err)
+		os.Exit(1)
+	}
+	registerFunc, err := generator.ParseRegisterFunc(*registerFuncName)
+	if err != nil {
+		slog.Error("Invalid -register-func value", "error", err)
+		os.Exit(1)
+	}
+
+	opts := &options{
+		copyrightHolder:     *copyrightHolder,
+		baseline:            *baseline,
+		lenient:             *lenient,
+		sanitizeNames:       *sanitizeNames,
+		excludeGenerated:    *excludeGenerated,
+		listRules:           *listRules,
+		reportSkipped:       *reportSkipped,
+		funcVarFallback:     *funcVarFallback,
+		varAccessors:        *varAccessors,
+		contextHook:         *contextHook,
+		lineEnding:          lineEnding,
+		outPerm:             fileMode,
+		sinceGit:            *sinceGit,
+		adapterPackage:      *adapterPackage,
+		noFormat:            *noFormat,
+		dumpAST:             *dumpAST,
+		combine:             *combine,
+		groupByPackage:      *groupByPackage,
+		splitGenerics:       *splitGenerics,
+		importVersions:      *importVersions,
+		strictIdentifiers:   *strictIdentifiers,
+		failOnEmptyPackage:  *failOnEmptyPackage,
+		warnGeneratedSource: *warnGeneratedSource,
+		flattenInterfaces:   *flattenInterfaces,
+		stubDisabledMethods: *stubDisabledMethods,
+		plainImport:         *plainImport,
+		renameTable:         *renameTable,
+		emitTests:           *emitTests,
+		nilInterfaceVars:    *nilInterfaceVars,
+		registerFunc:        registerFunc,
+		outSuffixByPackage:  *outSuffixByPackage,
+		goGenerate:          *goGenerate,
+		nolint:              splitList(*nolint),
+		constComment:        *constComment,
+		varComment:          *varComment,
+		verboseTiming:       *verboseTiming,
+	}
+	if *stats {
+		opts.stats = newRunStats()
+	}
+	if *werror {
+		opts.warnings = countWarnings()
+	}
+	if *headerVersion {
+		opts.toolVersion = toolVersion()
+	}
+	if *emitTo != "" {
+		opts.emitted = &strings.Builder{}
+	}
+	if *emitManifest != "" {
+		opts.manifest = &generator.Manifest{}
+		opts.manifestPath = *emitManifest
+	}
+	if opts.combine != "" && opts.adapterPackage != "" {
+		slog.Error("-combine and -adapter-package cannot be used together")
+		os.Exit(1)
+	}
+	var traceFile *os.File
+	if *traceFilePath != "" {
+		var err error
+		if traceFile, err = os.Create(*traceFilePath); err != nil {
+			slog.Error("Error creating trace file", "path", *traceFilePath, "error", err)
+			os.Exit(1)
+		}
+		opts.trace = compiler.NewJSONLinesSink(traceFile)
+	}
+
 	// Get the input path from command line arguments
 	args := flag.Args()
-	if len(args) == 0 {
+	if len(args) == 0 && *inputFromFile == "" {
 		slog.Error("No input path specified")
 		os.Exit(1)
 	}
+	if len(args) > 0 && *inputFromFile != "" {
+		slog.Error("An input path and -input-from-file cannot be used together")
+		os.Exit(1)
+	}
 
-	inputPath := args[0]
+	// The files of an input list are relative to the working directory.
+	inputPath := "."
+	if len(args) > 0 {
+		inputPath = args[0]
+	}
 
-	// Get absolute path to the input path
-	abspath, err := filepath.Abs(inputPath)
-	if err != nil {
-		slog.Error("Failed to get absolute path", "error", err)
-		os.Exit(1)
+	if *preHook != "" {
+		if err := runHook("pre", *preHook); err != nil {
+			slog.Error("Aborting generation", "error", err)
+			os.Exit(1)
+		}
 	}
 
 	// Initialize config with defaults
 	cfg := config.New()
 
-	// Load config from file if provided
-	if *configFile != "" {
-		fileCfg, err := loader.LoadConfigFile(*configFile)
+	// Load config from files if provided
+	if len(configFiles) > 0 {
+		fileCfg, err := loadConfigFiles(configFiles)
 		if err != nil {
-			slog.Error("Failed to load config file", "file", *configFile, "error", err)
+			slog.Error("Failed to load config file", "error", err)
 			os.Exit(1)
 		}
 		// Use the loaded config
 		cfg = fileCfg
 	}
-
-	// Check if the input is a directory or a file
-	fileInfo, err := os.Stat(abspath)
-	if err != nil {
-		slog.Error("Failed to get file info", "path", abspath, "error", err)
-		os.Exit(1)
+	if *rulesFrom != "" {
+		if opts.sharedRules, err = loadSharedRules(*rulesFrom); err != nil {
+			slog.Error("Failed to load shared rules", "file", *rulesFrom, "error", err)
+			os.Exit(1)
+		}
 	}
 
 	var filesToProcess []string
-
-	if fileInfo.IsDir() {
-		// If it's a directory, find all .go files
-		files, err := findGoFiles(abspath)
+	if *inputFromFile != "" {
+		filesToProcess, err = readInputFiles(*inputFromFile)
 		if err != nil {
-			slog.Error("Failed to find Go files in directory", "directory", abspath, "error", err)
+			slog.Error("Failed to read input list", "file", *inputFromFile, "error", err)
 			os.Exit(1)
 		}
+	} else {
+		filesToProcess, err = resolveInputFiles(inputPath, opts.excludeGenerated)
+		if err != nil {
+			slog.Error("Failed to resolve input path", "path", inputPath, "error", err)
+			os.Exit(1)
+		}
+	}
+	if opts.sinceGit != "" {
+		gitDir := inputPath
+		if info, err := os.Stat(inputPath); err == nil && !info.IsDir() {
+			gitDir = filepath.Dir(inputPath)
+		}
+		filesToProcess = filterChangedSince(filesToProcess, gitDir, opts.sinceGit)
+	}
+	if len(filesToProcess) == 0 {
+		slog.Info("No Go files to process", "path", inputPath)
+		return
+	}
+
+	hasErrors := processFiles(filesToProcess, cfg, opts)
 
-		if len(files) == 0 {
-			slog.Info("No Go files found in directory", "directory", abspath)
-			return
+	if opts.emitted != nil && !hasErrors {
+		if err := os.WriteFile(*emitTo, []byte(opts.emitted.String()), opts.outPerm); err != nil {
+			slog.Error("Error writing generated code", "path", *emitTo, "error", err)
+			hasErrors = true
 		}
+	}
 
-		filesToProcess = files
-	} else {
-		// If it's a single file, just process that file
-		if !strings.HasSuffix(abspath, ".go") {
-			slog.Error("Input file is not a Go file", "file", abspath)
-			os.Exit(1)
+	if opts.manifest != nil {
+		if err := opts.manifest.WriteFile(opts.manifestPath, opts.outPerm); err != nil {
+			slog.Error("Error writing manifest", "path", opts.manifestPath, "error", err)
+			hasErrors = true
 		}
-		filesToProcess = []string{abspath}
 	}
 
-	// Process each file
-	var hasErrors bool
-	for _, file := range filesToProcess {
-		if err := processFile(file, cfg, *copyrightHolder); err != nil {
-			slog.Error("Error processing file", "file", file, "error", err)
+	if traceFile != nil {
+		err := opts.trace.Err()
+		if closeErr := traceFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			slog.Error("Error writing trace file", "path", *traceFilePath, "error", err)
 			hasErrors = true
 		}
 	}
 
+	if opts.stats != nil {
+		if err := opts.stats.write(os.Stderr); err != nil {
+			slog.Warn("Failed to print the run summary", "error", err)
+		}
+	}
+
 	if hasErrors {
-		slog.Error("Failed to process some files")
+		if opts.combine == "" {
+			slog.Error("Failed to process some files")
+		}
+		os.Exit(1)
+	}
+
+	if err := opts.checkWarnings(); err != nil {
+		slog.Error("Failing the run because of -werror", "error", err)
 		os.Exit(1)
 	}
+
+	if *postHook != "" {
+		if err := runHook("post", *postHook); err != nil {
+			slog.Error("Post-generation hook failed", "error", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -0,0 +1,810 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/origadmin/adptool/internal/compiler"
+	"github.com/origadmin/adptool/internal/config"
+	"github.com/origadmin/adptool/internal/generator"
+)
+
+func TestFindGoFiles_ExcludeGenerated(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "generate.go")
+	generated := filepath.Join(dir, "generate.adapter.go")
+
+	require.NoError(t, os.WriteFile(source, []byte(`package adapter
+
+//go:adapter:package github.com/example/lib
+`), 0644))
+	// A generated adapter that carries the directive of its source over in a comment line.
+	require.NoError(t, os.WriteFile(generated, []byte(`// Code generated by adptool. DO NOT EDIT.
+//
+// This file is generated from generate.go.
+
+//go:adapter:package github.com/example/lib
+
+// Package adapter contains generated code by adptool.
+package adapter
+`), 0644))
+
+	files, err := findGoFiles(dir, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{source}, files)
+
+	files, err = findGoFiles(dir, false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{source, generated}, files)
+}
+
+func TestIsGeneratedFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{
+			name:     "default header",
+			content:  "// Code generated by adptool. DO NOT EDIT.\n\npackage adapter\n",
+			expected: true,
+		},
+		{
+			name:     "banner after copyright",
+			content:  "// Copyright 2025 Example. All rights reserved.\n//\n// Code generated by adptool. DO NOT EDIT.\n\npackage adapter\n",
+			expected: true,
+		},
+		{
+			name:     "other generator",
+			content:  "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage adapter\n",
+			expected: false,
+		},
+		{
+			name:     "banner after package clause",
+			content:  "package adapter\n\n// Code generated by adptool. DO NOT EDIT.\n",
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.go")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0644))
+			got, err := isGeneratedFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	writeFile := func(t *testing.T, path, content string) string {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("valid directives", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "adapter.go"), `package adapter
+
+//go:adapter:package github.com/example/lib
+//go:adapter:type Client
+//go:adapter:type:rename LibClient
+`)
+		require.NoError(t, runValidate([]string{dir}))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "validate must not write any file")
+	})
+
+	t.Run("invalid directives", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "adapter.go"), `package adapter
+
+//go:adapter:package github.com/example/lib
+//go:adapter:type Client
+//go:adapter:type:rename func
+`)
+		err := runValidate([]string{dir})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "keyword")
+		assert.NoError(t, runValidate([]string{"-lenient", dir}), "-lenient accepts it as generation does")
+	})
+
+	t.Run("sanitized names", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "adapter.go"), `package adapter
+
+//go:adapter:package github.com/example/lib
+//go:adapter:type Client
+//go:adapter:type:rename my-client
+`)
+		assert.Error(t, runValidate([]string{dir}))
+		assert.NoError(t, runValidate([]string{"-sanitize-names", dir}))
+	})
+
+	t.Run("colliding rules", func(t *testing.T) {
+		dir := t.TempDir()
+		source := writeFile(t, filepath.Join(dir, "adapter.go"), `package adapter
+
+//go:adapter:package github.com/example/lib
+`)
+		configFile := writeFile(t, filepath.Join(dir, "adptool.yaml"), `packages:
+  - import: github.com/example/lib
+    types:
+      - name: Client
+        explicit:
+          - from: Client
+            to: Conn
+      - name: Connection
+        explicit:
+          - from: Connection
+            to: Conn
+`)
+		err := runValidate([]string{"-f", configFile, source})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `rename both "Client" and "Connection" to "Conn"`)
+	})
+
+	t.Run("strict identifiers", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "adapter.go"), `package adapter
+
+//go:adapter:package github.com/example/lib
+//go:adapter:type Client
+//go:adapter:type:rename Wörker
+`)
+		require.NoError(t, runValidate([]string{dir}), "Go accepts Unicode identifiers")
+		err := runValidate([]string{"-strict-identifiers", dir})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `"Wörker", which is not an ASCII exported identifier`)
+	})
+
+	t.Run("shared rules", func(t *testing.T) {
+		dir := t.TempDir()
+		source := writeFile(t, filepath.Join(dir, "adapter.go"), `package adapter
+
+//go:adapter:package github.com/example/lib
+`)
+		shared := writeFile(t, filepath.Join(dir, "shared.yaml"), `types:
+  - name: Client
+    explicit:
+      - from: Client
+        to: type
+`)
+		require.NoError(t, runValidate([]string{source}))
+		err := runValidate([]string{"-rules-from", shared, source})
+		require.Error(t, err, "the shared rules are merged beneath the directives, as in generation")
+		assert.Contains(t, err.Error(), "keyword")
+	})
+
+	t.Run("layered config files", func(t *testing.T) {
+		dir := t.TempDir()
+		source := writeFile(t, filepath.Join(dir, "adapter.go"), `package adapter
+
+//go:adapter:package github.com/example/lib
+`)
+		base := writeFile(t, filepath.Join(dir, "base.yaml"), `types:
+  - name: Client
+    explicit:
+      - from: Client
+        to: type
+`)
+		overrides := writeFile(t, filepath.Join(dir, "overrides.yaml"), `ignores:
+  - Unused
+`)
+		err := runValidate([]string{"-c", base, "-c", overrides, source})
+		require.Error(t, err, "the base file is merged beneath the overrides, not replaced by them")
+		assert.Contains(t, err.Error(), "keyword")
+	})
+
+	t.Run("invalid config file", func(t *testing.T) {
+		dir := t.TempDir()
+		source := writeFile(t, filepath.Join(dir, "adapter.go"), `package adapter
+
+//go:adapter:package github.com/example/lib
+`)
+		configFile := writeFile(t, filepath.Join(dir, "adptool.yaml"), `types:
+  - name: "*"
+    regex:
+      - pattern: "("
+        replace: "x"
+`)
+		err := runValidate([]string{"-f", configFile, source})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid regex pattern")
+	})
+}
+
+func TestRunPreview(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, runPreview([]string{"-prefix", "Src", "github.com/origadmin/adptool/testdata/pkgs/source3"}, &out))
+
+	got := out.String()
+	assert.Contains(t, got, "package adapters")
+	assert.Regexp(t, `SrcWorker\s+= pkg3\.Worker`, got, "the prefix applies to types")
+	assert.Contains(t, got, "func SrcNewWorker(name string, options ...pkg3.WorkerOption) *SrcWorker {", "the prefix applies to functions")
+	assert.Regexp(t, `SrcMaxRetries\s+= pkg3\.MaxRetries`, got, "the prefix applies to constants")
+
+	assert.Error(t, runPreview(nil, &out), "an import path is required")
+}
+
+func TestRunPassthrough(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "pkg3.go")
+	require.NoError(t, runPassthrough([]string{"-o", output, "github.com/origadmin/adptool/testdata/pkgs/source3"}))
+	src, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "package pkg3\n", "the package keeps its own name")
+	assert.Regexp(t, `Worker\s+= pkg3\.Worker\n`, string(src), "names are not changed")
+
+	// Type-check the generated package as if it were part of this module.
+	dir, err := filepath.Abs(filepath.Join("..", "..", "testdata", "passthrough"))
+	require.NoError(t, err)
+	file := filepath.Join(dir, "pkg3.go")
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:    packages.NeedName | packages.NeedTypes,
+		Overlay: map[string][]byte{file: src},
+	}, "file="+file)
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+	assert.Empty(t, pkgs[0].Errors, "the passthrough package compiles")
+
+	assert.Error(t, runPassthrough(nil), "an import path is required")
+}
+
+func TestFilterChangedSince(t *testing.T) {
+	dir := t.TempDir()
+	changed := filepath.Join(dir, "changed.go")
+	unchanged := filepath.Join(dir, "unchanged.go")
+	for _, file := range []string{changed, unchanged} {
+		require.NoError(t, os.WriteFile(file, []byte("package adapter\n\n//go:adapter:package github.com/example/lib\n"), 0644))
+	}
+	files, err := findGoFiles(dir, true)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	fakeGit := func(t *testing.T, fn func(dir string, args ...string) ([]byte, error)) {
+		original := runGit
+		runGit = fn
+		t.Cleanup(func() { runGit = original })
+	}
+
+	t.Run("changed files only", func(t *testing.T) {
+		var diffArgs []string
+		fakeGit(t, func(_ string, args ...string) ([]byte, error) {
+			if args[0] == "rev-parse" {
+				return []byte(dir + "\n"), nil
+			}
+			diffArgs = args
+			// deleted.go no longer exists and must simply not be processed.
+			return []byte("changed.go\ndeleted.go\nREADME.md\n"), nil
+		})
+		assert.Equal(t, []string{changed}, filterChangedSince(files, dir, "main"))
+		assert.Equal(t, []string{"diff", "--name-only", "main...HEAD"}, diffArgs)
+	})
+
+	t.Run("not a git repository", func(t *testing.T) {
+		fakeGit(t, func(string, ...string) ([]byte, error) {
+			return nil, errors.New("fatal: not a git repository")
+		})
+		assert.Equal(t, files, filterChangedSince(files, dir, "main"))
+	})
+}
+
+func TestRunHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook commands are written for sh")
+	}
+	marker := filepath.Join(t.TempDir(), "hook.out")
+	require.NoError(t, runHook("pre", "echo ran > "+marker))
+	data, err := os.ReadFile(marker)
+	require.NoError(t, err)
+	assert.Equal(t, "ran\n", string(data))
+
+	err = runHook("post", "echo broken; exit 3")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `post hook "echo broken; exit 3" failed`)
+	assert.Contains(t, err.Error(), "broken", "the output of a failed hook is reported")
+}
+
+func TestParseInputList(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	files := map[string]string{
+		"a.go":      "package adapter\n\n//go:adapter:package github.com/example/lib\n",
+		"sub/b.go":  "package adapter\n\n//go:adapter:package github.com/example/other\n",
+		"plain.go":  "package adapter\n",
+		"notes.txt": "//go:adapter:package github.com/example/lib\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	got, err := parseInputList(strings.NewReader("a.go\n\n" + filepath.Join(dir, "sub", "b.go") + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "sub", "b.go")}, got,
+		"relative and absolute paths are listed, blank lines skipped")
+
+	_, err = parseInputList(strings.NewReader("a.go\nplain.go\n"))
+	assert.ErrorContains(t, err, "plain.go has no //go:adapter directive")
+	_, err = parseInputList(strings.NewReader("notes.txt\n"))
+	assert.ErrorContains(t, err, "notes.txt is not a Go file")
+	_, err = parseInputList(strings.NewReader("missing.go\n"))
+	assert.Error(t, err)
+}
+
+// writeModule writes files, keyed by slash-separated path, into the module example.com/app in a
+// temporary directory, which becomes the working directory. The go command loads its packages
+// outside of any workspace and may update its go.mod. It returns the root of the module.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	files["go.mod"] = "module example.com/app\n\ngo 1.24\n"
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	t.Chdir(root)
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	return root
+}
+
+func TestProcessFile_AdapterPackage(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go":         "package lib\n\nfunc Hello() string { return \"hello\" }\n",
+		"cmd/gen/adapter.go": "package gen\n\n//go:adapter:package example.com/app/lib\n",
+	})
+
+	source := filepath.Join(root, "cmd", "gen", "adapter.go")
+	opts := &options{adapterPackage: "example.com/app/internal/adapters"}
+	require.NoError(t, processFile(source, config.New(), opts))
+
+	got, err := os.ReadFile(filepath.Join(root, "internal", "adapters", "adapter.adapter.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(got), "\npackage adapters\n")
+	assert.Contains(t, string(got), "func Hello() string")
+	assert.NoFileExists(t, filepath.Join(root, "cmd", "gen", "adapter.adapter.go"))
+}
+
+func TestProcessFile_OutSuffixByPackage(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go":   "package lib\n\nfunc Hello() string { return \"hello\" }\n",
+		"util/util.go": "package util\n\nfunc Bye() string { return \"bye\" }\n",
+		// Both directive files are named source.go and generate into the adapter package.
+		"cmd/a/source.go": "package a\n\n//go:adapter:package example.com/app/lib\n",
+		"cmd/b/source.go": "package b\n\n//go:adapter:package example.com/app/util\n",
+	})
+	adapters := filepath.Join(root, "internal", "adapters")
+
+	opts := &options{adapterPackage: "example.com/app/internal/adapters"}
+	require.NoError(t, processFile(filepath.Join(root, "cmd", "a", "source.go"), config.New(), opts))
+	err := processFile(filepath.Join(root, "cmd", "b", "source.go"), config.New(), opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "both generate")
+	require.NoError(t, os.Remove(filepath.Join(adapters, "source.adapter.go")))
+
+	opts = &options{adapterPackage: "example.com/app/internal/adapters", outSuffixByPackage: true}
+	require.NoError(t, processFile(filepath.Join(root, "cmd", "a", "source.go"), config.New(), opts))
+	require.NoError(t, processFile(filepath.Join(root, "cmd", "b", "source.go"), config.New(), opts))
+	got, err := os.ReadFile(filepath.Join(adapters, "source.lib.adapter.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(got), "func Hello() string")
+	got, err = os.ReadFile(filepath.Join(adapters, "source.util.adapter.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(got), "func Bye() string")
+	assert.NoFileExists(t, filepath.Join(adapters, "source.adapter.go"))
+}
+
+func TestCombineFiles(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"one/util/util.go":  "package util\n\nfunc Hello() string { return \"hello\" }\n",
+		"two/util/util.go":  "package util\n\nfunc Bye() string { return \"bye\" }\n",
+		"adapters/a.go":     "package adapters\n\n//go:adapter:package example.com/app/one/util\n",
+		"adapters/b.go":     "package adapters\n\n//go:adapter:package example.com/app/two/util\n//go:adapter:package example.com/app/one/util\n",
+		"adapters/c.go":     "package adapters\n",
+		"adapters/notes.md": "//go:adapter:package example.com/app/two/util\n",
+	})
+
+	inputs, err := resolveInputFiles(filepath.Join(root, "adapters"), true)
+	require.NoError(t, err)
+	require.Len(t, inputs, 2)
+
+	output := filepath.Join(root, "adapters", "combined.adapter.go")
+	require.NoError(t, combineFiles(inputs, config.New(), &options{combine: output}))
+
+	got, err := os.ReadFile(output)
+	require.NoError(t, err)
+	content := string(got)
+	assert.Contains(t, content, "// This file is generated from a.go, b.go.")
+	assert.Contains(t, content, "\npackage adapters\n")
+	assert.Contains(t, content, "func Hello() string")
+	assert.Contains(t, content, "func Bye() string")
+	assert.Equal(t, 1, strings.Count(content, `"example.com/app/one/util"`), "imports must be deduplicated")
+	assert.Equal(t, 1, strings.Count(content, `"example.com/app/two/util"`))
+	assert.Regexp(t, `util\d* "example.com/app/two/util"`, content, "packages with the same name get distinct aliases")
+	assert.NoFileExists(t, filepath.Join(root, "adapters", "a.adapter.go"))
+}
+
+func TestProcessFile_NoFormat(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go":         "package lib\n\nimport \"strings\"\n\nfunc Upper(s string) string { return strings.ToUpper(s) }\n",
+		"adapters/source.go": "package adapters\n\n//go:adapter:package example.com/app/lib\n",
+	})
+
+	source := filepath.Join(root, "adapters", "source.go")
+	output := filepath.Join(root, "adapters", "source.adapter.go")
+	generate := func(opts *options) string {
+		t.Helper()
+		require.NoError(t, processFile(source, config.New(), opts))
+		got, err := os.ReadFile(output)
+		require.NoError(t, err)
+		return string(got)
+	}
+
+	// The import of the source file is carried over and only removed by goimports.
+	raw := generate(&options{noFormat: true})
+	assert.Contains(t, raw, "\"strings\"", "raw output must keep what goimports would remove")
+	assert.Contains(t, raw, "func Upper(s string) string")
+
+	formatted := generate(&options{})
+	assert.NotContains(t, formatted, "\"strings\"")
+}
+
+func TestLoadSharedRules(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go": `package lib
+
+type Client struct{}
+
+type Server struct{}
+
+func NewClient() *Client { return &Client{} }
+
+func Close() {}
+
+func TestHelper() {}
+`,
+		"adapters/source.go": `package adapters
+
+//go:adapter:package example.com/app/lib
+//go:adapter:function NewClient
+//go:adapter:function:rename MakeClient
+//go:adapter:type Server
+//go:adapter:type:rename LocalServer
+`,
+		"rules.yaml": `ignores:
+  - "re:^Test"
+types:
+  - name: Server
+    explicit:
+      - from: Server
+        to: SharedServer
+  - name: "*"
+    prefix: Lib
+packages:
+  - import: example.com/app/unused
+`,
+	})
+
+	shared, err := loadSharedRules(filepath.Join(root, "rules.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, processFile(filepath.Join(root, "adapters", "source.go"), config.New(), &options{sharedRules: shared}))
+
+	got, err := os.ReadFile(filepath.Join(root, "adapters", "source.adapter.go"))
+	require.NoError(t, err)
+	assert.Regexp(t, `LibClient\s+= lib\.Client`, string(got), "the shared type prefix applies")
+	assert.Contains(t, string(got), "func MakeClient() *LibClient", "the local rename applies")
+	assert.Regexp(t, `LocalServer\s+= lib\.Server`, string(got), "a directive wins over a shared rule for the same symbol")
+	assert.NotContains(t, string(got), "SharedServer")
+	assert.Contains(t, string(got), "func Close()")
+	assert.NotContains(t, string(got), "TestHelper", "the shared ignores apply")
+	assert.NotContains(t, string(got), "example.com/app/unused", "the packages of a shared rules file are not used")
+
+	_, err = loadSharedRules(filepath.Join(root, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestLoadConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	require.NoError(t, os.WriteFile(base, []byte(`package_name: base
+ignores:
+  - "re:^Test"
+types:
+  - name: "*"
+    prefix: Org
+packages:
+  - import: example.com/lib
+    alias: lib
+`), 0o644))
+	overrides := filepath.Join(dir, "overrides.yaml")
+	require.NoError(t, os.WriteFile(overrides, []byte(`package_name: adapters
+types:
+  - name: "*"
+    prefix: Repo
+packages:
+  - import: example.com/lib
+    alias: core
+`), 0o644))
+
+	cfg, err := loadConfigFiles([]string{base, overrides})
+	require.NoError(t, err)
+	assert.Equal(t, "adapters", cfg.PackageName, "the later file wins")
+	assert.Equal(t, []string{"re:^Test"}, cfg.Ignores, "the earlier file still applies")
+	require.Len(t, cfg.Packages, 1)
+	assert.Equal(t, "core", cfg.Packages[0].Alias)
+
+	require.Len(t, cfg.Types, 2)
+	assert.Equal(t, "Repo", cfg.Types[0].Prefix, "the rules of the later file come first and win")
+
+	_, err = loadConfigFiles([]string{base, filepath.Join(dir, "missing.yaml")})
+	assert.ErrorContains(t, err, "missing.yaml")
+}
+
+func TestProcessFile_Stats(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go": `package lib
+
+const Answer = 42
+
+var Name = "lib"
+
+type Client struct{}
+
+func NewClient() *Client { return &Client{} }
+
+func Close() {}
+`,
+		"adapters/source.go": `package adapters
+
+//go:adapter:package example.com/app/lib
+//go:adapter:function NewClient
+//go:adapter:function:rename MakeClient
+//go:adapter:function Missing
+//go:adapter:function:rename Other
+`,
+		"adapters/plain.go": "package adapters\n",
+	})
+
+	opts := &options{stats: newRunStats()}
+	for _, name := range []string{"source.go", "plain.go"} {
+		require.NoError(t, processFile(filepath.Join(root, "adapters", name), config.New(), opts))
+	}
+
+	stats := opts.stats
+	assert.Equal(t, 2, stats.filesProcessed)
+	assert.Equal(t, 1, stats.filesWritten)
+	assert.Equal(t, 1, stats.filesSkipped, "a file without directives is skipped")
+	assert.Equal(t, generator.Stats{Constants: 1, Variables: 1, Types: 1, Functions: 2}, stats.symbols)
+	assert.Equal(t, 1, stats.rulesApplied, "the rename of NewClient is applied")
+	assert.Equal(t, 1, stats.rulesUnused, "the rename of Missing matches no symbol")
+
+	var summary bytes.Buffer
+	require.NoError(t, stats.write(&summary))
+	assert.Regexp(t, `^Files:   2 processed, 1 written, 1 skipped
+Symbols: 1 types, 2 functions, 1 variables, 1 constants
+Rules:   1 applied, 1 unused
+Time:    \S+
+$`, summary.String())
+}
+
+func TestProcessFile_RenameFile(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go":       "package lib\n\nfunc Hello() string { return \"hello\" }\n",
+		"other/other.go":   "package other\n\nfunc Bye() string { return \"bye\" }\n",
+		"adapters/lib.go":  "package adapters\n\n//go:adapter:rename-file package\n//go:adapter:package example.com/app/lib\n",
+		"adapters/more.go": "package adapters\n\n//go:adapter:rename-file package\n//go:adapter:package example.com/app/other\n",
+		"adapters/bye.go":  "package adapters\n\n//go:adapter:package example.com/app/other\n",
+	})
+
+	opts := &options{}
+	require.NoError(t, processFile(filepath.Join(root, "adapters", "lib.go"), config.New(), opts))
+	assert.FileExists(t, filepath.Join(root, "adapters", "adapters.adapter.go"), "the adapter is named after its package")
+	assert.NoFileExists(t, filepath.Join(root, "adapters", "lib.adapter.go"))
+
+	err := processFile(filepath.Join(root, "adapters", "more.go"), config.New(), opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "both generate")
+	got, err := os.ReadFile(filepath.Join(root, "adapters", "adapters.adapter.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(got), "func Hello()", "the colliding file is not overwritten")
+
+	cfg := config.New()
+	cfg.RenameFile = "farewell"
+	require.NoError(t, processFile(filepath.Join(root, "adapters", "bye.go"), cfg, &options{}))
+	assert.FileExists(t, filepath.Join(root, "adapters", "farewell.adapter.go"), "the configured name applies")
+
+	cfg.RenameFile = "../escape"
+	assert.Error(t, processFile(filepath.Join(root, "adapters", "bye.go"), cfg, &options{}))
+}
+
+func TestProcessFiles_DirectivesStayInTheirFile(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go":     "package lib\n\nfunc Hello() string { return \"hello\" }\n",
+		"other/other.go": "package other\n\nfunc Bye() string { return \"bye\" }\n",
+		"adapters/a.go":  "package adapters\n\n//go:adapter:rename-file package\n//go:adapter:package example.com/app/lib\n",
+		"adapters/b.go":  "package adapters\n\n//go:adapter:package example.com/app/other\n",
+	})
+
+	files, err := resolveInputFiles(filepath.Join(root, "adapters"), true)
+	require.NoError(t, err)
+	cfg := config.New()
+	require.False(t, processFiles(files, cfg, &options{}), "the rename_file of a.go must not apply to b.go")
+	assert.Empty(t, cfg.RenameFile, "the configuration of the run is not modified")
+	assert.Empty(t, cfg.Packages)
+
+	got, err := os.ReadFile(filepath.Join(root, "adapters", "adapters.adapter.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(got), "func Hello()")
+	assert.NotContains(t, string(got), "func Bye()", "the packages of a.go are not adapted for b.go")
+	got, err = os.ReadFile(filepath.Join(root, "adapters", "b.adapter.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(got), "func Bye()")
+	assert.NotContains(t, string(got), "func Hello()")
+}
+
+func TestProcessFile_VerboseTiming(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go":         "package lib\n\nfunc Hello() string { return \"hello\" }\n",
+		"adapters/source.go": "package adapters\n\n//go:adapter:package example.com/app/lib\n",
+	})
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	source := filepath.Join(root, "adapters", "source.go")
+	require.NoError(t, processFile(source, config.New(), &options{}))
+	assert.NotContains(t, logs.String(), "Phase durations", "timings are only logged with -verbose-timing")
+
+	require.NoError(t, processFile(source, config.New(), &options{verboseTiming: true}))
+	assert.Regexp(t, `level=INFO msg="Phase durations" file=\S+source\.go parse=\S+ compile=\S+ collect=\S+ format=\S+\n`, logs.String())
+}
+
+func TestProcessFile_Werror(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go": "package lib\n\nfunc Hello() string { return \"hello\" }\n",
+		// Open is skipped, which -report-skipped warns about.
+		"secret/secret.go":   "package secret\n\ntype secret struct{}\n\nfunc Open() *secret { return nil }\n",
+		"adapters/source.go": "package adapters\n\n//go:adapter:package example.com/app/secret\n",
+		"clean/source.go":    "package clean\n\n//go:adapter:package example.com/app/lib\n",
+	})
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	run := func(file string, werror bool) error {
+		counter := newWarningCounter(slog.NewTextHandler(&logs, nil))
+		slog.SetDefault(slog.New(counter))
+		opts := &options{reportSkipped: true}
+		if werror {
+			opts.warnings = counter
+		}
+		require.NoError(t, processFile(filepath.Join(root, file), config.New(), opts))
+		return opts.checkWarnings()
+	}
+
+	assert.NoError(t, run("adapters/source.go", false), "warnings are not errors without -werror")
+	assert.Contains(t, logs.String(), "level=WARN")
+	assert.EqualError(t, run("adapters/source.go", true), "1 warning treated as an error")
+	assert.NoError(t, run("clean/source.go", true), "a run without warnings passes with -werror")
+
+	counter := newWarningCounter(slog.NewTextHandler(&logs, nil))
+	counter.count.Add(2)
+	assert.EqualError(t, (&options{warnings: counter}).checkWarnings(), "2 warnings treated as errors")
+}
+
+func TestToolVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		info *debug.BuildInfo
+		want string
+	}{
+		{"no build info", nil, ""},
+		{"module version", &debug.BuildInfo{Main: debug.Module{Version: "v1.2.3"}}, "v1.2.3"},
+		{"revision", &debug.BuildInfo{
+			Main:     debug.Module{Version: "(devel)"},
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "0123456789abcdef0123"}, {Key: "vcs.modified", Value: "false"}},
+		}, "0123456789ab"},
+		{"modified tree", &debug.BuildInfo{
+			Main:     debug.Module{Version: "(devel)"},
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "0123456789abcdef0123"}, {Key: "vcs.modified", Value: "true"}},
+		}, "0123456789ab+dirty"},
+		{"unknown", &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}}, "(devel)"},
+	}
+	defaultReadBuildInfo := readBuildInfo
+	t.Cleanup(func() { readBuildInfo = defaultReadBuildInfo })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readBuildInfo = func() (*debug.BuildInfo, bool) { return tt.info, tt.info != nil }
+			assert.Equal(t, tt.want, toolVersion())
+		})
+	}
+}
+
+func TestResolveAdapterPackage(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n"), 0o644))
+	source := filepath.Join(root, "adapter.go")
+
+	dir, name, err := resolveAdapterPackage(source, "example.com/app/internal/adapters")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "internal", "adapters"), dir)
+	assert.Equal(t, "adapters", name)
+
+	for _, importPath := range []string{
+		"example.com/other/adapters",
+		"example.com/application/adapters",
+		"example.com/app/../other",
+		"example.com/app/internal/my-adapters",
+	} {
+		_, _, err := resolveAdapterPackage(source, importPath)
+		assert.Error(t, err, importPath)
+	}
+}
+
+func TestOutputImportPath(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n"), 0o644))
+
+	assert.Equal(t, "example.com/app", outputImportPath(filepath.Join(root, "adapter.go")))
+	assert.Equal(t, "example.com/app/internal/adapters", outputImportPath(filepath.Join(root, "internal", "adapters", "adapter.go")))
+}
+
+func TestProcessFile_TraceFile(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"lib/lib.go": `package lib
+
+type Client struct{}
+
+func NewClient() *Client { return &Client{} }
+`,
+		"adapters/source.go": `package adapters
+
+//go:adapter:package example.com/app/lib
+//go:adapter:function NewClient
+//go:adapter:function:rename MakeClient
+`,
+	})
+
+	tracePath := filepath.Join(root, "trace.jsonl")
+	traceFile, err := os.Create(tracePath)
+	require.NoError(t, err)
+	opts := &options{trace: compiler.NewJSONLinesSink(traceFile)}
+	require.NoError(t, processFile(filepath.Join(root, "adapters", "source.go"), config.New(), opts))
+	require.NoError(t, opts.trace.Err())
+	require.NoError(t, traceFile.Close())
+
+	content, err := os.ReadFile(tracePath)
+	require.NoError(t, err)
+	var events []compiler.TraceEvent
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		var event compiler.TraceEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event), "each line is a JSON event")
+		events = append(events, event)
+	}
+	assert.Contains(t, events, compiler.TraceEvent{
+		Original: "NewClient",
+		Final:    "MakeClient",
+		Kind:     "func",
+		Package:  "example.com/app/lib",
+		Rule:     "explicit: NewClient -> MakeClient",
+	})
+}
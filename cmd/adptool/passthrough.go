@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/origadmin/adptool/internal/compiler"
+	"github.com/origadmin/adptool/internal/config"
+)
+
+// runPassthrough implements `adptool passthrough [-o file] <importPath>`. It generates a package
+// re-exporting every exported symbol of the package at importPath under the package's own name
+// and without renaming, such as a vanity import path or a new home for a moved package. No
+// configuration file or directive is used.
+func runPassthrough(args []string) error {
+	flags := flag.NewFlagSet("passthrough", flag.ContinueOnError)
+	output := flags.String("o", "", "Output file. Defaults to <package name>.go in the current directory.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("passthrough requires exactly one import path, got %d arguments", flags.NArg())
+	}
+	importPath := flags.Arg(0)
+
+	packageName, err := sourcePackageName(importPath)
+	if err != nil {
+		return err
+	}
+	outputFile := *output
+	if outputFile == "" {
+		outputFile = packageName + ".go"
+	}
+
+	cfg := config.New()
+	cfg.PackageName = packageName
+	cfg.Packages = append(cfg.Packages, &config.Package{Import: importPath})
+	compiledCfg, err := compiler.Compile(cfg)
+	if err != nil {
+		return err
+	}
+	// The source package is imported by its own name, which the generated package shares.
+	opts := &options{plainImport: true}
+	return generateAdapter(cfg, compiledCfg, outputFile, packageName, importPath, opts, nil)
+}
+
+// sourcePackageName returns the declared name of the package at importPath, which may differ
+// from the last element of the path.
+func sourcePackageName(importPath string) (string, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName}, importPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load package %s: %w", importPath, err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Name == "" {
+		return "", fmt.Errorf("package %s not found", importPath)
+	}
+	return pkgs[0].Name, nil
+}
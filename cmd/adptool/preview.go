@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"path"
+
+	"github.com/origadmin/adptool/internal/compiler"
+	"github.com/origadmin/adptool/internal/config"
+)
+
+// runPreview implements `adptool preview [-prefix P] [-package name] <importPath>`. It generates
+// the adapter of a single package, without any configuration file or directive, and prints it to
+// out instead of writing a file. With -prefix, every generated name is prefixed.
+func runPreview(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("preview", flag.ContinueOnError)
+	prefix := flags.String("prefix", "", "Prefix added to the name of every generated symbol.")
+	packageName := flags.String("package", "adapters", "Package name of the previewed adapter.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("preview requires exactly one import path, got %d arguments", flags.NArg())
+	}
+	importPath := flags.Arg(0)
+
+	cfg := config.New()
+	cfg.PackageName = *packageName
+	cfg.Packages = append(cfg.Packages, &config.Package{Import: importPath})
+	if *prefix != "" {
+		ruleSet := config.RuleSet{Prefix: *prefix}
+		cfg.Types = append(cfg.Types, &config.TypeRule{Name: "*", RuleSet: ruleSet})
+		cfg.Functions = append(cfg.Functions, &config.FuncRule{Name: "*", RuleSet: ruleSet})
+		cfg.Variables = append(cfg.Variables, &config.VarRule{Name: "*", RuleSet: ruleSet})
+		cfg.Constants = append(cfg.Constants, &config.ConstRule{Name: "*", RuleSet: ruleSet})
+	}
+	compiledCfg, err := compiler.Compile(cfg)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	opts := &options{output: &buf}
+	outputFile := path.Base(importPath) + ".adapter.go"
+	if err := generateAdapter(cfg, compiledCfg, outputFile, *packageName, importPath, opts, nil); err != nil {
+		return err
+	}
+
+	// The code written to a writer is printed as is, so it is formatted here for reading.
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		src = buf.Bytes()
+	}
+	_, err = out.Write(src)
+	return err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/origadmin/adptool/internal/compiler"
+	"github.com/origadmin/adptool/internal/generator"
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
+// runStats accumulates the metrics of a run for the summary printed by -stats.
+// All of its methods are no-ops on a nil receiver, so that they can be called unconditionally.
+type runStats struct {
+	start time.Time
+
+	filesProcessed int
+	filesWritten   int
+	filesSkipped   int
+
+	symbols generator.Stats
+
+	rulesApplied int
+	rulesUnused  int
+}
+
+// newRunStats starts timing a run.
+func newRunStats() *runStats {
+	return &runStats{start: time.Now()}
+}
+
+// processed records a directive file read by the run.
+func (s *runStats) processed() {
+	if s != nil {
+		s.filesProcessed++
+	}
+}
+
+// skipped records a processed file that produced no adapter because it has no directives.
+func (s *runStats) skipped() {
+	if s != nil {
+		s.filesSkipped++
+	}
+}
+
+// written records an adapter file generated by gen, with the rules of replacer.
+func (s *runStats) written(gen *generator.Generator, replacer interfaces.Replacer) {
+	if s == nil {
+		return
+	}
+	s.filesWritten++
+	s.symbols.Add(gen.Stats())
+	applied, unused := compiler.RuleUsage(replacer)
+	s.rulesApplied += applied
+	s.rulesUnused += unused
+}
+
+// write prints the summary of the run to w.
+func (s *runStats) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "Files:   %d processed, %d written, %d skipped\n"+
+		"Symbols: %d types, %d functions, %d variables, %d constants\n"+
+		"Rules:   %d applied, %d unused\n"+
+		"Time:    %s\n",
+		s.filesProcessed, s.filesWritten, s.filesSkipped,
+		s.symbols.Types, s.symbols.Functions, s.symbols.Variables, s.symbols.Constants,
+		s.rulesApplied, s.rulesUnused,
+		time.Since(s.start).Round(time.Millisecond))
+	return err
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// phaseTimer records the durations of the phases of processing a file for -verbose-timing.
+// All of its methods are no-ops on a nil receiver, so that they can be called unconditionally.
+type phaseTimer struct {
+	last  time.Time
+	attrs []any
+}
+
+// newPhaseTimer starts timing the first phase, or returns nil if timing is disabled.
+func newPhaseTimer(enabled bool) *phaseTimer {
+	if !enabled {
+		return nil
+	}
+	return &phaseTimer{last: time.Now()}
+}
+
+// mark records the time since the previous mark, or since the timer started, as phase.
+func (t *phaseTimer) mark(phase string) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.attrs = append(t.attrs, phase, now.Sub(t.last))
+	t.last = now
+}
+
+// add records d as phase and restarts the timing of the next phase.
+func (t *phaseTimer) add(phase string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.attrs = append(t.attrs, phase, d)
+	t.last = time.Now()
+}
+
+// log logs the recorded phases of file at info level.
+func (t *phaseTimer) log(file string) {
+	if t == nil || len(t.attrs) == 0 {
+		return
+	}
+	slog.Info("Phase durations", append([]any{"file", file}, t.attrs...)...)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/origadmin/adptool/internal/config"
+)
+
+// runValidate implements `adptool validate [-f config]... [-rules-from file] [-lenient] [-sanitize-names]
+// [-strict-identifiers] [paths...]`. It loads the configuration, parses the directives of every input file and compiles them
+// as generation does with the same flags, running all of the compiler's validation passes: regular
+// expressions, names that are not identifiers or are reserved, and rules giving different symbols the
+// same name. It does not generate or write any file. Paths default to the current directory.
+func runValidate(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var configFiles stringsFlag
+	flags.Var(&configFiles, "f", "Configuration file (YAML/JSON/TOML) to validate together with the directives. Repeat to layer files, each merged over the previous ones, as generation does with -c.")
+	flags.Var(&configFiles, "c", "Alias for -f.")
+	rulesFrom := flags.String("rules-from", "", "Configuration file whose global rules, ignores and default modes are merged beneath the configuration and directives, as in generation.")
+	opts := &options{}
+	flags.BoolVar(&opts.sanitizeNames, "sanitize-names", false, "Accept explicit rename targets that are not Go identifiers, as generation with -sanitize-names does.")
+	flags.BoolVar(&opts.lenient, "lenient", false, "Accept rules renaming symbols to Go keywords or builtins, as generation with -lenient does.")
+	flags.BoolVar(&opts.strictIdentifiers, "strict-identifiers", false, "Reject rename targets that are not ASCII exported identifiers, as generation with -strict-identifiers does.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.New()
+	if len(configFiles) > 0 {
+		fileCfg, err := loadConfigFiles(configFiles)
+		if err != nil {
+			return fmt.Errorf("failed to load config file %w", err)
+		}
+		cfg = fileCfg
+	}
+	if *rulesFrom != "" {
+		var err error
+		if opts.sharedRules, err = loadSharedRules(*rulesFrom); err != nil {
+			return fmt.Errorf("failed to load shared rules %s: %w", *rulesFrom, err)
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var errs []error
+	var validated int
+	for _, path := range paths {
+		files, err := resolveInputFiles(path, true)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to resolve input path %s: %w", path, err))
+			continue
+		}
+		for _, file := range files {
+			// compileFile parses each file into its own copy of cfg, as generation does.
+			_, compiledCfg, err := compileFile(file, cfg, opts.sharedRules, nil, opts.compileOptions()...)
+			if err != nil {
+				slog.Error("Invalid configuration", "file", file, "error", err)
+				errs = append(errs, err)
+				continue
+			}
+			if compiledCfg != nil {
+				validated++
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	slog.Info("Configuration is valid", "files", validated)
+	return nil
+}
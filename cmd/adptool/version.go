@@ -0,0 +1,40 @@
+package main
+
+import "runtime/debug"
+
+// readBuildInfo reads the build information of the running binary. Tests replace it.
+var readBuildInfo = debug.ReadBuildInfo
+
+// toolVersion returns the version of adptool from its build information: the module version if
+// it was installed with go install, or else the VCS revision it was built from, with "+dirty" if
+// the tree had uncommitted changes. It is "(devel)" if neither is known, and empty without build
+// information.
+func toolVersion() string {
+	info, ok := readBuildInfo()
+	if !ok || info == nil {
+		return ""
+	}
+	if version := info.Main.Version; version != "" && version != "(devel)" {
+		return version
+	}
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "(devel)"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "+dirty"
+	}
+	return revision
+}
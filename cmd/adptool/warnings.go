@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"sync/atomic"
+)
+
+// warningCounter is a slog.Handler counting the warnings it passes on to its handler, so that
+// -werror can fail a run that logged any. The handlers derived from it share its count.
+type warningCounter struct {
+	slog.Handler
+	count *atomic.Int64
+}
+
+// newWarningCounter returns a warningCounter passing records on to h.
+func newWarningCounter(h slog.Handler) *warningCounter {
+	return &warningCounter{Handler: h, count: new(atomic.Int64)}
+}
+
+// Handle counts r if it is a warning and passes it on.
+func (h *warningCounter) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn && r.Level < slog.LevelError {
+		h.count.Add(1)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler with attrs sharing the count of h.
+func (h *warningCounter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &warningCounter{Handler: h.Handler.WithAttrs(attrs), count: h.count}
+}
+
+// WithGroup returns a handler with the group name sharing the count of h.
+func (h *warningCounter) WithGroup(name string) slog.Handler {
+	return &warningCounter{Handler: h.Handler.WithGroup(name), count: h.count}
+}
+
+// warnings returns the number of warnings logged so far.
+func (h *warningCounter) warnings() int64 {
+	return h.count.Load()
+}
+
+// countWarnings makes the default logger count the warnings it logs, and returns the counter.
+func countWarnings() *warningCounter {
+	// slog.SetDefault sends the output of the log package to the new handler, which the default
+	// handler it wraps writes through, so the log package gets its own writer and flags back.
+	w, flags := log.Writer(), log.Flags()
+	counter := newWarningCounter(slog.Default().Handler())
+	slog.SetDefault(slog.New(counter))
+	log.SetOutput(w)
+	log.SetFlags(flags)
+	return counter
+}
+
+// checkWarnings returns an error if warnings are treated as errors and any was logged.
+func (opts *options) checkWarnings() error {
+	if opts.warnings == nil {
+		return nil
+	}
+	switch n := opts.warnings.warnings(); n {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("1 warning treated as an error")
+	default:
+		return fmt.Errorf("%d warnings treated as errors", n)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"path"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
@@ -21,10 +22,24 @@ type realReplacer struct {
 	config         *interfaces.CompiledConfig
 	packageAliases map[string]bool
 	processedNodes map[ast.Node]bool
+	renames        map[int]int // number of renames made by each rule, keyed by rule ID
+	afterRename    interfaces.RenameHook
+	traceSink      TraceSink
+}
+
+// ReplacerOption configures a Replacer created by NewReplacer.
+type ReplacerOption func(*realReplacer)
+
+// WithAfterRename sets a hook called after each rename the replacer makes, e.g. to record a
+// migration map of the renames or to check the new names against an external allowlist.
+func WithAfterRename(hook interfaces.RenameHook) ReplacerOption {
+	return func(r *realReplacer) {
+		r.afterRename = hook
+	}
 }
 
 // NewReplacer creates a new Replacer instance from a compiled configuration.
-func NewReplacer(compiledCfg *interfaces.CompiledConfig) interfaces.Replacer {
+func NewReplacer(compiledCfg *interfaces.CompiledConfig, opts ...ReplacerOption) interfaces.Replacer {
 	if compiledCfg == nil {
 		return nil
 	}
@@ -34,11 +49,85 @@ func NewReplacer(compiledCfg *interfaces.CompiledConfig) interfaces.Replacer {
 		packageAliases[pkg.ImportAlias] = true
 	}
 
-	return &realReplacer{
+	r := &realReplacer{
 		config:         compiledCfg,
 		packageAliases: packageAliases,
 		processedNodes: make(map[ast.Node]bool),
+		renames:        make(map[int]int),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// RuleUsage reports how many of the compiled rules renamed at least one declaration through
+// replacer, and how many renamed none. It reports zero for replacers not created by NewReplacer.
+func RuleUsage(replacer interfaces.Replacer) (applied, unused int) {
+	r, ok := replacer.(*realReplacer)
+	if !ok {
+		return 0, 0
+	}
+	for _, rulesByType := range r.config.RulesByPackageAndType {
+		for _, rules := range rulesByType {
+			for _, rule := range rules {
+				if r.renames[rule.ID] > 0 {
+					applied++
+				} else {
+					unused++
+				}
+			}
+		}
+	}
+	return applied, unused
+}
+
+// RuleSymbols returns, by import path, the source symbols that the package rules name
+// literally: the From names of explicit rules and the ignores without wildcards or regex.
+// Global rules are left out, as they need not name a symbol of every package.
+func RuleSymbols(compiledCfg *interfaces.CompiledConfig) map[string][]string {
+	symbols := make(map[string][]string)
+	add := func(pkgPath, name string) {
+		if !slices.Contains(symbols[pkgPath], name) {
+			symbols[pkgPath] = append(symbols[pkgPath], name)
+		}
+	}
+	topLevel := []interfaces.RuleType{interfaces.RuleTypeConst, interfaces.RuleTypeVar, interfaces.RuleTypeType, interfaces.RuleTypeFunc}
+	for pkgPath, rulesByType := range compiledCfg.RulesByPackageAndType {
+		if pkgPath == "" {
+			continue
+		}
+		for _, ruleType := range topLevel {
+			for _, rule := range rulesByType[ruleType] {
+				if rule.Type == "explicit" && rule.From != "*" {
+					add(pkgPath, rule.From)
+				}
+			}
+		}
+	}
+	for pkgPath, ignoresByType := range compiledCfg.IgnoresByPackageAndType {
+		if pkgPath == "" {
+			continue
+		}
+		for _, ruleType := range topLevel {
+			for _, ignore := range ignoresByType[ruleType] {
+				if ignore.CompiledRegex == nil && !strings.ContainsAny(ignore.Pattern, `*?[\`) {
+					add(pkgPath, ignore.Pattern)
+				}
+			}
+		}
 	}
+	for pkgPath := range symbols {
+		sort.Strings(symbols[pkgPath])
+	}
+	return symbols
+}
+
+// Reset forgets the nodes processed and the renames counted since the replacer was created
+// or last reset, so that it can be reused for another generation.
+func (r *realReplacer) Reset() {
+	r.processedNodes = make(map[ast.Node]bool)
+	r.renames = make(map[int]int)
 }
 
 // Apply applies the transformation rules to the given AST node.
@@ -79,10 +168,12 @@ func (r *realReplacer) applyIdentRule(ctx interfaces.Context, ident *ast.Ident)
 		return
 	}
 
-	// Get package path from context
+	// Get package path and name from context
 	pkgPath, _ := ctx.Value(interfaces.PackagePathContextKey).(string)
+	pkgName, _ := ctx.Value(interfaces.PackageNameContextKey).(string)
+	typeName, _ := ctx.Value(interfaces.TypeNameContextKey).(string)
 
-	if newName, ok := r.findAndApplyRule(ident.Name, ruleType, pkgPath); ok {
+	if newName, ok := r.findAndApplyRule(ident.Name, ruleType, pkgPath, pkgName, typeName); ok {
 		ident.Name = newName
 	}
 }
@@ -120,23 +211,29 @@ func (r *realReplacer) applyTypeSpecRule(ctx interfaces.Context, spec *ast.TypeS
 	r.Apply(ctx, spec.Name) // The context already has RuleTypeType from applyGenDeclRule
 }
 
-func (r *realReplacer) findAndApplyRule(name string, ruleType interfaces.RuleType, pkgName string) (string, bool) {
+// findAndApplyRule applies the first matching rule for name. For methods and fields, typeName
+// is the source name of the type they belong to, and only rules for that type (or "*") apply.
+func (r *realReplacer) findAndApplyRule(name string, ruleType interfaces.RuleType, pkgPath, pkgName, typeName string) (string, bool) {
 	var applicableRules []interfaces.CompiledRenameRule
 
 	// Collect package-specific rules
-	if pkgRules, ok := r.config.RulesByPackageAndType[pkgName]; ok {
+	if pkgRules, ok := r.config.RulesByPackageAndType[pkgPath]; ok {
 		if rules, ok := pkgRules[ruleType]; ok {
 			applicableRules = append(applicableRules, rules...)
 		}
 	}
 
-	// Collect global rules
+	// Collect global rules, except those the package rules replace
 	if globalRules, ok := r.config.RulesByPackageAndType[""]; ok {
 		if rules, ok := globalRules[ruleType]; ok {
-			applicableRules = append(applicableRules, rules...)
+			applicableRules = append(applicableRules, r.unreplacedGlobalRules(pkgPath, applicableRules, rules)...)
 		}
 	}
 
+	if isMemberRuleType(ruleType) {
+		applicableRules = rulesForOwner(applicableRules, typeName)
+	}
+
 	if len(applicableRules) == 0 {
 		return "", false
 	}
@@ -156,10 +253,13 @@ func (r *realReplacer) findAndApplyRule(name string, ruleType interfaces.RuleTyp
 			if rule.From == name || rule.From == "*" {
 				// If it's an explicit rule, and it matches, it's the highest priority.
 				// If there are multiple explicit rules, the one with higher priority (already sorted) or non-wildcard 'From' takes precedence.
-				newName, err := rulesPkg.ApplyRules(name, []interfaces.CompiledRenameRule{rule})
+				newName, err := rulesPkg.ApplyRules(name, pkgName, r.pipeline(rule))
 				if err != nil {
 					return "", false
 				}
+				if newName != name {
+					r.renamed(rule, name, newName, ruleType, pkgPath)
+				}
 				return newName, newName != name
 			}
 		} else { // For prefix, suffix, regex rules
@@ -184,11 +284,12 @@ func (r *realReplacer) findAndApplyRule(name string, ruleType interfaces.RuleTyp
 
 			if nameMatchesRuleScope {
 				// Now, apply the transformation based on the rule's type
-				newName, err := rulesPkg.ApplyRules(name, []interfaces.CompiledRenameRule{rule})
+				newName, err := rulesPkg.ApplyRules(name, pkgName, r.pipeline(rule))
 				if err != nil {
 					return "", false
 				}
 				if newName != name {
+					r.renamed(rule, name, newName, ruleType, pkgPath)
 					return newName, true
 				}
 			}
@@ -198,17 +299,79 @@ func (r *realReplacer) findAndApplyRule(name string, ruleType interfaces.RuleTyp
 	return "", false
 }
 
+// renamed records that rule renamed the symbol name of package pkgPath to newName.
+func (r *realReplacer) renamed(rule interfaces.CompiledRenameRule, name, newName string, ruleType interfaces.RuleType, pkgPath string) {
+	r.renames[rule.ID]++
+	if r.afterRename != nil {
+		r.afterRename(name, newName, ruleType, pkgPath)
+	}
+	if r.traceSink != nil {
+		r.traceSink.Trace(traceEvent(rule, name, newName, ruleType, pkgPath))
+	}
+}
+
+// unreplacedGlobalRules returns the global rules that apply alongside pkgRules, the rules of the
+// package at pkgPath. A global rule is left out if the package replaces its kind of rule and has
+// a rule of that kind itself.
+func (r *realReplacer) unreplacedGlobalRules(pkgPath string, pkgRules, globalRules []interfaces.CompiledRenameRule) []interfaces.CompiledRenameRule {
+	replaced := r.config.ReplacedGlobalRules[pkgPath]
+	if len(replaced) == 0 {
+		return globalRules
+	}
+	declared := make(map[string]bool)
+	for _, rule := range pkgRules {
+		declared[rule.Type] = true
+	}
+
+	var rules []interfaces.CompiledRenameRule
+	for _, rule := range globalRules {
+		if replaced[rule.Type] && declared[rule.Type] {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// pipeline returns the rules to apply for rule: the rule itself, followed by the truncation of
+// the result if a maximum name length is configured.
+func (r *realReplacer) pipeline(rule interfaces.CompiledRenameRule) []interfaces.CompiledRenameRule {
+	if r.config.MaxNameLength <= 0 {
+		return []interfaces.CompiledRenameRule{rule}
+	}
+	return []interfaces.CompiledRenameRule{rule, {Type: "truncate", MaxLength: r.config.MaxNameLength}}
+}
+
 func isApplicableRuleType(ruleType interfaces.RuleType) bool {
 	switch ruleType {
-	case interfaces.RuleTypeConst, interfaces.RuleTypeType, interfaces.RuleTypeVar, interfaces.RuleTypeFunc:
+	case interfaces.RuleTypeConst, interfaces.RuleTypeType, interfaces.RuleTypeVar, interfaces.RuleTypeFunc,
+		interfaces.RuleTypeMethod, interfaces.RuleTypeField:
 		return true
 	default:
 		return false
 	}
 }
 
-// Compile takes a configuration and returns a compiled representation of it.
+func isMemberRuleType(ruleType interfaces.RuleType) bool {
+	return ruleType == interfaces.RuleTypeMethod || ruleType == interfaces.RuleTypeField
+}
 
+// rulesForOwner returns the member rules that belong to typeName. Rules of a specific type
+// come before rules of the wildcard type, so they take precedence at the same priority.
+func rulesForOwner(rules []interfaces.CompiledRenameRule, typeName string) []interfaces.CompiledRenameRule {
+	var specific, wildcard []interfaces.CompiledRenameRule
+	for _, rule := range rules {
+		switch rule.Owner {
+		case typeName:
+			specific = append(specific, rule)
+		case "*":
+			wildcard = append(wildcard, rule)
+		}
+	}
+	return append(specific, wildcard...)
+}
+
+// Compile takes a configuration and returns a compiled representation of it.
 
 func processRule(holder config.RuleHolder, priority int, pkgName string, ruleType interfaces.RuleType) ([]interfaces.CompiledRenameRule, error) {
 	if holder.IsDisabled() {
@@ -222,9 +385,16 @@ func processRule(holder config.RuleHolder, priority int, pkgName string, ruleTyp
 	var compiledRules []interfaces.CompiledRenameRule
 	isWildcard := holder.GetName() == "*"
 
-	// Process explicit rules
+	// Process explicit rules. Identical entries, e.g. left by copy-paste, are collapsed into
+	// the first one.
 	if len(ruleSet.Explicit) > 0 {
+		seen := make(map[config.ExplicitRule]bool)
 		for _, explicit := range ruleSet.Explicit {
+			if seen[*explicit] {
+				slog.Debug("Dropping duplicate explicit rule", "func", "processRule", "rule", holder.GetName(), "from", explicit.From, "to", explicit.To)
+				continue
+			}
+			seen[*explicit] = true
 			compiledRules = append(compiledRules, interfaces.CompiledRenameRule{
 				Type:       "explicit",
 				RuleType:   ruleType,
@@ -237,9 +407,15 @@ func processRule(holder config.RuleHolder, priority int, pkgName string, ruleTyp
 		return compiledRules, nil // Explicit rules override all others
 	}
 
-	// Process regex rules
+	// Process regex rules, collapsing identical entries into the first one
 	if len(ruleSet.Regex) > 0 {
+		seen := make(map[config.RegexRule]bool)
 		for _, regex := range ruleSet.Regex {
+			if seen[*regex] {
+				slog.Debug("Dropping duplicate regex rule", "func", "processRule", "rule", holder.GetName(), "pattern", regex.Pattern, "replace", regex.Replace)
+				continue
+			}
+			seen[*regex] = true
 			re, err := regexp.Compile(regex.Pattern)
 			if err != nil {
 				return nil, fmt.Errorf("invalid regex pattern '%s': %w", regex.Pattern, err)
@@ -249,8 +425,9 @@ func processRule(holder config.RuleHolder, priority int, pkgName string, ruleTyp
 				RuleType:      ruleType,
 				OriginalName:  holder.GetName(),
 				Pattern:       regex.Pattern,
-				Replace:       regex.Replace,
+				Replace:       delimitGroupRefs(regex.Replace),
 				CompiledRegex: re,
+				Qualified:     regex.Qualified,
 				Priority:      priority,
 				IsWildcard:    isWildcard,
 			})
@@ -261,36 +438,246 @@ func processRule(holder config.RuleHolder, priority int, pkgName string, ruleTyp
 	// Process prefix rule
 	if ruleSet.Prefix != "" {
 		compiledRules = append(compiledRules, interfaces.CompiledRenameRule{
-			Type:       "prefix",
-			RuleType:   ruleType,
+			Type:         "prefix",
+			RuleType:     ruleType,
 			OriginalName: holder.GetName(),
-			Value:      ruleSet.Prefix,
-			Priority:   priority,
-			IsWildcard: isWildcard,
+			Value:        ruleSet.Prefix,
+			Separator:    ruleSet.AffixSeparator,
+			Priority:     priority,
+			IsWildcard:   isWildcard,
 		})
 	}
 
 	// Process suffix rule
 	if ruleSet.Suffix != "" {
 		compiledRules = append(compiledRules, interfaces.CompiledRenameRule{
-			Type:       "suffix",
-			RuleType:   ruleType,
+			Type:         "suffix",
+			RuleType:     ruleType,
+			OriginalName: holder.GetName(),
+			Value:        ruleSet.Suffix,
+			Separator:    ruleSet.AffixSeparator,
+			Priority:     priority,
+			IsWildcard:   isWildcard,
+		})
+	}
+
+	// Process suffix template rule
+	if ruleSet.SuffixTemplate != "" {
+		tmpl, err := rulesPkg.ParseSuffixTemplate(ruleSet.SuffixTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid suffix template for '%s': %w", holder.GetName(), err)
+		}
+		compiledRules = append(compiledRules, interfaces.CompiledRenameRule{
+			Type:         "suffix_template",
+			RuleType:     ruleType,
+			OriginalName: holder.GetName(),
+			Value:        ruleSet.SuffixTemplate,
+			Template:     tmpl,
+			Separator:    ruleSet.AffixSeparator,
+			Priority:     priority,
+			IsWildcard:   isWildcard,
+		})
+	}
+
+	// Process transform pipeline
+	if len(ruleSet.Transforms) > 0 {
+		stages := make([]interfaces.CompiledTransform, 0, len(ruleSet.Transforms))
+		for i, transform := range ruleSet.Transforms {
+			if transform.Before == "" {
+				return nil, fmt.Errorf("transform step %d for '%s' has no 'before' pattern", i, holder.GetName())
+			}
+			re, err := regexp.Compile(transform.Before)
+			if err != nil {
+				return nil, fmt.Errorf("invalid transform pattern '%s': %w", transform.Before, err)
+			}
+			stages = append(stages, interfaces.CompiledTransform{
+				Pattern:       transform.Before,
+				Replace:       delimitGroupRefs(transform.After),
+				CompiledRegex: re,
+			})
+		}
+		compiledRules = append(compiledRules, interfaces.CompiledRenameRule{
+			Type:         "transform",
+			RuleType:     ruleType,
 			OriginalName: holder.GetName(),
-			Value:      ruleSet.Suffix,
-			Priority:   priority,
-			IsWildcard: isWildcard,
+			Transforms:   stages,
+			Priority:     priority,
+			IsWildcard:   isWildcard,
 		})
 	}
 
 	return compiledRules, nil
 }
 
+// groupRefPattern matches a numbered group reference such as $1 that is not already delimited.
+var groupRefPattern = regexp.MustCompile(`^\$(\d+)`)
+
+// delimitGroupRefs rewrites numbered group references in a regex replacement to their braced
+// form, so that "$1Processor" expands group 1 followed by "Processor". Go's expansion would
+// otherwise read it as a reference to a group named "1Processor" and produce an empty string.
+// An escaped dollar ("$$") is left untouched.
+func delimitGroupRefs(replace string) string {
+	var b strings.Builder
+	for i := 0; i < len(replace); i++ {
+		if replace[i] != '$' {
+			b.WriteByte(replace[i])
+			continue
+		}
+		if strings.HasPrefix(replace[i:], "$$") {
+			b.WriteString("$$")
+			i++
+			continue
+		}
+		if loc := groupRefPattern.FindStringSubmatchIndex(replace[i:]); loc != nil {
+			b.WriteString("${" + replace[i+loc[2]:i+loc[3]] + "}")
+			i += loc[1] - 1
+			continue
+		}
+		b.WriteByte('$')
+	}
+	return b.String()
+}
+
 // Compile takes a configuration and returns a compiled representation of it.
-func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
+func Compile(cfg *config.Config, opts ...Option) (*interfaces.CompiledConfig, error) {
+	options := &compileOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if cfg.MaxNameLength < 0 || (cfg.MaxNameLength > 0 && cfg.MaxNameLength <= rulesPkg.NameHashLength) {
+		return nil, fmt.Errorf("max_name_length must be greater than %d, got %d", rulesPkg.NameHashLength, cfg.MaxNameLength)
+	}
+	if cfg.TypeMode != "" && cfg.TypeMode != "alias" && cfg.TypeMode != "define" {
+		return nil, fmt.Errorf("type_mode must be \"alias\" or \"define\", got %q", cfg.TypeMode)
+	}
+	switch cfg.CollisionStrategy {
+	case "", "suffix", "error", "skip":
+	default:
+		return nil, fmt.Errorf("collision_strategy must be \"suffix\", \"error\" or \"skip\", got %q", cfg.CollisionStrategy)
+	}
+	if err := validateOutputOrder(cfg.OutputOrder); err != nil {
+		return nil, err
+	}
+
 	compiledCfg := &interfaces.CompiledConfig{
-		PackageName:           cfg.PackageName,
-		Packages:              compilePackages(cfg.Packages),
-		RulesByPackageAndType: make(map[string]map[interfaces.RuleType][]interfaces.CompiledRenameRule),
+		PackageName:             cfg.PackageName,
+		Packages:                compilePackages(cfg.Packages),
+		RulesByPackageAndType:   make(map[string]map[interfaces.RuleType][]interfaces.CompiledRenameRule),
+		ReplacedGlobalRules:     make(map[string]map[string]bool),
+		IgnoresByPackageAndType: make(map[string]map[interfaces.RuleType][]interfaces.CompiledIgnore),
+		ExtractInterfaces:       make(map[string]map[string]string),
+		EmbedTypes:              make(map[string]map[string]bool),
+		DisabledMethods:         make(map[string]map[string][]string),
+		DefineTypes:             make(map[string]map[string]bool),
+		MaxNameLength:           cfg.MaxNameLength,
+		OnlyTagged:              cfg.OnlyTagged,
+		SkipDeprecated:          cfg.SkipDeprecated,
+		AllowedImports:          append([]string(nil), cfg.AllowedImports...),
+		CollisionStrategy:       cfg.CollisionStrategy,
+		OutputOrder:             append([]string(nil), cfg.OutputOrder...),
+	}
+
+	// Helper to compile the ignores of a rule into the ignores of its kind
+	addIgnores := func(pkgName string, rType interfaces.RuleType, holder config.RuleHolder) error {
+		if holder.IsDisabled() || holder.GetRuleSet() == nil {
+			return nil
+		}
+		ignores, err := compileIgnores(holder.GetRuleSet().Ignores)
+		if err != nil {
+			return fmt.Errorf("%s rule %s: %w", rType, holder.GetName(), err)
+		}
+		if len(ignores) == 0 {
+			return nil
+		}
+		if _, ok := compiledCfg.IgnoresByPackageAndType[pkgName]; !ok {
+			compiledCfg.IgnoresByPackageAndType[pkgName] = make(map[interfaces.RuleType][]interfaces.CompiledIgnore)
+		}
+		compiledCfg.IgnoresByPackageAndType[pkgName][rType] = append(compiledCfg.IgnoresByPackageAndType[pkgName][rType], ignores...)
+		return nil
+	}
+
+	// The top-level ignores apply to every kind of symbol, of every package unless qualified
+	// with the alias of one
+	ignoresByPackage, err := splitQualifiedIgnores(cfg.Ignores, compiledCfg.Packages)
+	if err != nil {
+		return nil, err
+	}
+	for pkgName, patterns := range ignoresByPackage {
+		ignores, err := compileIgnores(patterns)
+		if err != nil {
+			return nil, err
+		}
+		if len(ignores) == 0 {
+			continue
+		}
+		if _, ok := compiledCfg.IgnoresByPackageAndType[pkgName]; !ok {
+			compiledCfg.IgnoresByPackageAndType[pkgName] = make(map[interfaces.RuleType][]interfaces.CompiledIgnore)
+		}
+		for _, rType := range []interfaces.RuleType{interfaces.RuleTypeConst, interfaces.RuleTypeVar, interfaces.RuleTypeType, interfaces.RuleTypeFunc} {
+			compiledCfg.IgnoresByPackageAndType[pkgName][rType] = append(compiledCfg.IgnoresByPackageAndType[pkgName][rType], ignores...)
+		}
+	}
+
+	// Helper to record the interfaces to extract from type rules
+	addExtractInterface := func(pkgName string, r *config.TypeRule) {
+		if r.Disabled || r.ExtractInterface == "" {
+			return
+		}
+		if _, ok := compiledCfg.ExtractInterfaces[pkgName]; !ok {
+			compiledCfg.ExtractInterfaces[pkgName] = make(map[string]string)
+		}
+		compiledCfg.ExtractInterfaces[pkgName][r.Name] = r.ExtractInterface
+	}
+
+	// Helper to record the types to re-export as a struct embedding the source type
+	addEmbedType := func(pkgName string, r *config.TypeRule) error {
+		if r.Disabled || !r.Embed {
+			return nil
+		}
+		if r.Pattern != "wrap" {
+			return fmt.Errorf("type rule %s: embed requires the wrap pattern, got %q", r.Name, r.Pattern)
+		}
+		// Disabled methods can be stubbed and field rules rename embedded fields; method rules
+		// have no other effect.
+		renames := false
+		for _, m := range r.Methods {
+			if !m.Disabled {
+				renames = true
+				continue
+			}
+			if _, ok := compiledCfg.DisabledMethods[pkgName]; !ok {
+				compiledCfg.DisabledMethods[pkgName] = make(map[string][]string)
+			}
+			compiledCfg.DisabledMethods[pkgName][r.Name] = append(compiledCfg.DisabledMethods[pkgName][r.Name], m.Name)
+		}
+		if renames {
+			slog.Warn("Method rules do not rename the promoted methods of an embedded type", "type", r.Name)
+		}
+		if _, ok := compiledCfg.EmbedTypes[pkgName]; !ok {
+			compiledCfg.EmbedTypes[pkgName] = make(map[string]bool)
+		}
+		compiledCfg.EmbedTypes[pkgName][r.Name] = true
+		return nil
+	}
+
+	// Helper to record the types re-exported as defined types, or kept as aliases, by their
+	// pattern. Rules without a pattern take the type mode of the configuration.
+	addDefineType := func(pkgName string, r *config.TypeRule) {
+		pattern := r.Pattern
+		if pattern == "" {
+			pattern = cfg.TypeMode
+		}
+		if r.Disabled || (pattern != "alias" && pattern != "define") {
+			return
+		}
+		if _, ok := compiledCfg.DefineTypes[pkgName]; !ok {
+			compiledCfg.DefineTypes[pkgName] = make(map[string]bool)
+		}
+		compiledCfg.DefineTypes[pkgName][r.Name] = pattern == "define"
+	}
+	if cfg.TypeMode == "define" {
+		compiledCfg.DefineTypes[""] = map[string]bool{"*": true}
 	}
 
 	// Helper to add rules to the main map and sort them
@@ -299,7 +686,7 @@ func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
 			compiledCfg.RulesByPackageAndType[pkgName] = make(map[interfaces.RuleType][]interfaces.CompiledRenameRule)
 		}
 		compiledCfg.RulesByPackageAndType[pkgName][rType] = append(compiledCfg.RulesByPackageAndType[pkgName][rType], rules...)
-		sort.Slice(compiledCfg.RulesByPackageAndType[pkgName][rType], func(i, j int) bool {
+		sort.SliceStable(compiledCfg.RulesByPackageAndType[pkgName][rType], func(i, j int) bool {
 			r1 := compiledCfg.RulesByPackageAndType[pkgName][rType][i]
 			r2 := compiledCfg.RulesByPackageAndType[pkgName][rType][j]
 			if r1.Priority != r2.Priority {
@@ -321,6 +708,33 @@ func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
 		})
 	}
 
+	// Helper to add the method and field rules of a type, scoped to that type
+	addMemberRules := func(pkgName string, priority int, t *config.TypeRule) error {
+		if t.Disabled {
+			return nil
+		}
+		members := []struct {
+			rType interfaces.RuleType
+			rules []*config.MemberRule
+		}{
+			{interfaces.RuleTypeMethod, t.Methods},
+			{interfaces.RuleTypeField, t.Fields},
+		}
+		for _, m := range members {
+			for _, member := range m.rules {
+				rules, err := processRule(member, priority, pkgName, m.rType)
+				if err != nil {
+					return err
+				}
+				for i := range rules {
+					rules[i].Owner = t.Name
+				}
+				addAndSortRules(pkgName, m.rType, rules)
+			}
+		}
+		return nil
+	}
+
 	// Process global rules
 	for _, r := range cfg.Types {
 		rules, err := processRule(r, 0, "", interfaces.RuleTypeType)
@@ -328,6 +742,17 @@ func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
 			return nil, err
 		}
 		addAndSortRules("", interfaces.RuleTypeType, rules)
+		if err := addIgnores("", interfaces.RuleTypeType, r); err != nil {
+			return nil, err
+		}
+		addExtractInterface("", r)
+		addDefineType("", r)
+		if err := addEmbedType("", r); err != nil {
+			return nil, err
+		}
+		if err := addMemberRules("", 0, r); err != nil {
+			return nil, err
+		}
 	}
 	for _, r := range cfg.Functions {
 		rules, err := processRule(r, 0, "", interfaces.RuleTypeFunc)
@@ -335,6 +760,9 @@ func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
 			return nil, err
 		}
 		addAndSortRules("", interfaces.RuleTypeFunc, rules)
+		if err := addIgnores("", interfaces.RuleTypeFunc, r); err != nil {
+			return nil, err
+		}
 	}
 	for _, r := range cfg.Variables {
 		rules, err := processRule(r, 0, "", interfaces.RuleTypeVar)
@@ -342,6 +770,9 @@ func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
 			return nil, err
 		}
 		addAndSortRules("", interfaces.RuleTypeVar, rules)
+		if err := addIgnores("", interfaces.RuleTypeVar, r); err != nil {
+			return nil, err
+		}
 	}
 	for _, r := range cfg.Constants {
 		rules, err := processRule(r, 0, "", interfaces.RuleTypeConst)
@@ -349,16 +780,30 @@ func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
 			return nil, err
 		}
 		addAndSortRules("", interfaces.RuleTypeConst, rules)
+		if err := addIgnores("", interfaces.RuleTypeConst, r); err != nil {
+			return nil, err
+		}
 	}
 
 	// Process package-specific rules
 	for _, pkg := range cfg.Packages {
+		if kinds := replacedRuleKinds(pkg.Defaults, cfg.Defaults); len(kinds) > 0 {
+			compiledCfg.ReplacedGlobalRules[pkg.Import] = kinds
+		}
 		for _, r := range pkg.Types {
 			rules, err := processRule(r, 1, pkg.Import, interfaces.RuleTypeType)
 			if err != nil {
 				return nil, err
 			}
 			addAndSortRules(pkg.Import, interfaces.RuleTypeType, rules)
+			if err := addIgnores(pkg.Import, interfaces.RuleTypeType, r); err != nil {
+				return nil, err
+			}
+			addExtractInterface(pkg.Import, r)
+			addDefineType(pkg.Import, r)
+			if err := addEmbedType(pkg.Import, r); err != nil {
+				return nil, err
+			}
 		}
 		for _, r := range pkg.Functions {
 			rules, err := processRule(r, 1, pkg.Import, interfaces.RuleTypeFunc)
@@ -366,6 +811,9 @@ func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
 				return nil, err
 			}
 			addAndSortRules(pkg.Import, interfaces.RuleTypeFunc, rules)
+			if err := addIgnores(pkg.Import, interfaces.RuleTypeFunc, r); err != nil {
+				return nil, err
+			}
 		}
 		for _, r := range pkg.Variables {
 			rules, err := processRule(r, 1, pkg.Import, interfaces.RuleTypeVar)
@@ -373,6 +821,9 @@ func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
 				return nil, err
 			}
 			addAndSortRules(pkg.Import, interfaces.RuleTypeVar, rules)
+			if err := addIgnores(pkg.Import, interfaces.RuleTypeVar, r); err != nil {
+				return nil, err
+			}
 		}
 		for _, r := range pkg.Constants {
 			rules, err := processRule(r, 1, pkg.Import, interfaces.RuleTypeConst)
@@ -380,43 +831,170 @@ func Compile(cfg *config.Config) (*interfaces.CompiledConfig, error) {
 				return nil, err
 			}
 			addAndSortRules(pkg.Import, interfaces.RuleTypeConst, rules)
+			if err := addIgnores(pkg.Import, interfaces.RuleTypeConst, r); err != nil {
+				return nil, err
+			}
 		}
 
 		for _, t := range pkg.Types {
-			if t.Fields != nil {
-				for _, field := range t.Fields {
-					rules, err := processRule(field, 2, pkg.Import, interfaces.RuleTypeVar)
-					if err != nil {
-						return nil, err
-					}
-					addAndSortRules(pkg.Import, interfaces.RuleTypeVar, rules)
-				}
-			}
-			if t.Methods != nil {
-				for _, method := range t.Methods {
-					rules, err := processRule(method, 2, pkg.Import, interfaces.RuleTypeFunc)
-					if err != nil {
-						return nil, err
-					}
-					addAndSortRules(pkg.Import, interfaces.RuleTypeFunc, rules)
-				}
+			if err := addMemberRules(pkg.Import, 1, t); err != nil {
+				return nil, err
 			}
 		}
 	}
 
+	if err := validateRuleNames(compiledCfg, options); err != nil {
+		return nil, err
+	}
+	if err := validateRuleTargets(compiledCfg); err != nil {
+		return nil, err
+	}
+	assignRuleIDs(compiledCfg)
+
 	return compiledCfg, nil
 }
 
+// splitQualifiedIgnores groups the top-level ignores by the import path of the package they
+// apply to. An entry qualified with the alias of a configured package, such as "source.Config",
+// only applies to that package; the others, and regular expressions, apply to all packages and
+// are grouped under "". A qualifier matching no alias is reported, and the entry kept as is.
+func splitQualifiedIgnores(patterns []string, pkgs []*interfaces.CompiledPackage) (map[string][]string, error) {
+	aliases := make(map[string][]string)
+	for _, pkg := range pkgs {
+		if !slices.Contains(aliases[pkg.ImportAlias], pkg.ImportPath) {
+			aliases[pkg.ImportAlias] = append(aliases[pkg.ImportAlias], pkg.ImportPath)
+		}
+	}
+
+	byPackage := make(map[string][]string)
+	for _, pattern := range patterns {
+		alias, name, qualified := strings.Cut(pattern, ".")
+		if !qualified || !token.IsIdentifier(alias) || strings.HasPrefix(pattern, rulesPkg.IgnoreRegexPrefix) {
+			byPackage[""] = append(byPackage[""], pattern)
+			continue
+		}
+		switch importPaths := aliases[alias]; len(importPaths) {
+		case 0:
+			slog.Warn("Ignore entry is qualified with an alias no package has", "ignore", pattern, "alias", alias)
+			byPackage[""] = append(byPackage[""], pattern)
+		case 1:
+			byPackage[importPaths[0]] = append(byPackage[importPaths[0]], name)
+		default:
+			return nil, fmt.Errorf("ignore %q: alias %q is shared by packages %s", pattern, alias, strings.Join(importPaths, ", "))
+		}
+	}
+	return byPackage, nil
+}
+
+// compileIgnores compiles the entries of an ignores list. Entries prefixed with
+// rulesPkg.IgnoreRegexPrefix are regular expressions; any other entry is a glob pattern.
+// Repeated entries are compiled once.
+func compileIgnores(patterns []string) ([]interfaces.CompiledIgnore, error) {
+	var ignores []interfaces.CompiledIgnore
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		if seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		if expr, ok := strings.CutPrefix(pattern, rulesPkg.IgnoreRegexPrefix); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ignore regex %q: %w", expr, err)
+			}
+			ignores = append(ignores, interfaces.CompiledIgnore{Pattern: expr, CompiledRegex: re})
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+		}
+		ignores = append(ignores, interfaces.CompiledIgnore{Pattern: pattern})
+	}
+	return ignores, nil
+}
+
+// ruleModes maps each kind of rule with a configurable mode to its mode in the defaults.
+var ruleModes = map[string]func(*config.Mode) string{
+	"prefix":   func(m *config.Mode) string { return m.Prefix },
+	"suffix":   func(m *config.Mode) string { return m.Suffix },
+	"explicit": func(m *config.Mode) string { return m.Explicit },
+	"regex":    func(m *config.Mode) string { return m.Regex },
+}
+
+// replacedRuleKinds returns the kinds of rules for which the rules of a package replace the global
+// rules. The mode of each kind is taken from the package defaults if set there, and otherwise from
+// the global defaults. Rules merge by default; the "replace" and "override" modes replace.
+func replacedRuleKinds(pkgDefaults, globalDefaults *config.Defaults) map[string]bool {
+	kinds := make(map[string]bool)
+	for kind, modeOf := range ruleModes {
+		mode := ""
+		for _, defaults := range []*config.Defaults{pkgDefaults, globalDefaults} {
+			if defaults != nil && defaults.Mode != nil && modeOf(defaults.Mode) != "" {
+				mode = modeOf(defaults.Mode)
+				break
+			}
+		}
+		if mode == "replace" || mode == "override" {
+			kinds[kind] = true
+		}
+	}
+	return kinds
+}
+
+// assignRuleIDs numbers the compiled rules, in a stable order, so that their use can be tracked.
+func assignRuleIDs(compiledCfg *interfaces.CompiledConfig) {
+	pkgPaths := make([]string, 0, len(compiledCfg.RulesByPackageAndType))
+	for pkgPath := range compiledCfg.RulesByPackageAndType {
+		pkgPaths = append(pkgPaths, pkgPath)
+	}
+	sort.Strings(pkgPaths)
+
+	id := 0
+	for _, pkgPath := range pkgPaths {
+		rulesByType := compiledCfg.RulesByPackageAndType[pkgPath]
+		ruleTypes := make([]interfaces.RuleType, 0, len(rulesByType))
+		for ruleType := range rulesByType {
+			ruleTypes = append(ruleTypes, ruleType)
+		}
+		sort.Slice(ruleTypes, func(i, j int) bool { return ruleTypes[i] < ruleTypes[j] })
+		for _, ruleType := range ruleTypes {
+			rules := rulesByType[ruleType]
+			for i := range rules {
+				id++
+				rules[i].ID = id
+			}
+		}
+	}
+}
+
+func compileInstantiations(insts []*config.Instantiation) []*interfaces.CompiledInstantiation {
+	var compiled []*interfaces.CompiledInstantiation
+	for _, inst := range insts {
+		compiled = append(compiled, &interfaces.CompiledInstantiation{
+			Type: inst.Type,
+			Args: slices.Clone(inst.Args),
+			As:   inst.As,
+			Func: inst.Func,
+		})
+	}
+	return compiled
+}
+
 func compilePackages(pkgs []*config.Package) []*interfaces.CompiledPackage {
 	var compiledPackages []*interfaces.CompiledPackage
 	for _, pkg := range pkgs {
 		finalAlias := pkg.Alias
+		if pkg.ForceAlias != "" {
+			finalAlias = pkg.ForceAlias
+		}
 		if finalAlias == "" {
 			finalAlias = path.Base(pkg.Import)
 		}
 		compiledPackages = append(compiledPackages, &interfaces.CompiledPackage{
-			ImportPath:  pkg.Import,
-			ImportAlias: finalAlias,
+			ImportPath:     pkg.Import,
+			ImportAlias:    finalAlias,
+			Registry:       pkg.Registry,
+			Instantiations: compileInstantiations(pkg.Instantiate),
 		})
 	}
 	return compiledPackages
@@ -0,0 +1,672 @@
+package compiler
+
+import (
+	"bytes"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/origadmin/adptool/internal/config"
+	"github.com/origadmin/adptool/internal/interfaces"
+	"github.com/origadmin/adptool/internal/parser"
+)
+
+func TestCompile_ReservedNames(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           *config.Config
+		errorContains string
+	}{
+		{
+			name: "explicit rename to keyword",
+			cfg: &config.Config{
+				Types: []*config.TypeRule{{
+					Name:    "Kind",
+					RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Kind", To: "type"}}},
+				}},
+			},
+			errorContains: `renames a declaration to "type", which is a Go keyword`,
+		},
+		{
+			name: "explicit rename to predeclared identifier",
+			cfg: &config.Config{
+				Packages: []*config.Package{{
+					Import: "github.com/example/lib",
+					Types: []*config.TypeRule{{
+						Name:    "Anything",
+						RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Anything", To: "any"}}},
+					}},
+				}},
+			},
+			errorContains: `renames a declaration to "any", which is a Go predeclared identifier`,
+		},
+		{
+			name: "literal regex replacement to predeclared identifier",
+			cfg: &config.Config{
+				Functions: []*config.FuncRule{{
+					Name:    "*",
+					RuleSet: config.RuleSet{Regex: []*config.RegexRule{{Pattern: "^Length$", Replace: "len"}}},
+				}},
+			},
+			errorContains: `renames a declaration to "len", which is a Go predeclared identifier`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorContains)
+
+			compiled, err := Compile(tt.cfg, WithLenient(true))
+			require.NoError(t, err, "lenient mode should only warn")
+			assert.NotNil(t, compiled)
+		})
+	}
+}
+
+func TestCompile_SanitizeNames(t *testing.T) {
+	cfg := &config.Config{
+		Types: []*config.TypeRule{{
+			Name:    "MyType",
+			RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "MyType", To: "my-type"}}},
+		}},
+	}
+	_, err := Compile(cfg)
+	assert.ErrorContains(t, err, `renames "MyType" to "my-type", which is not a valid Go identifier`)
+	_, err = Compile(cfg, WithLenient(true))
+	assert.Error(t, err, "lenient mode does not accept invalid identifiers")
+
+	compiled, err := Compile(cfg, WithSanitizeNames(true))
+	require.NoError(t, err)
+	ident := ast.NewIdent("MyType")
+	NewReplacer(compiled).Apply(interfaces.NewContext().Push(interfaces.RuleTypeType), ident)
+	assert.Equal(t, "myType", ident.Name)
+
+	cfg.Types[0].Explicit[0].To = "--"
+	_, err = Compile(cfg, WithSanitizeNames(true))
+	assert.Error(t, err, "a target without letters or digits cannot be sanitized")
+}
+
+func TestCompile_RuleTargetCollisions(t *testing.T) {
+	explicit := func(from, to string) *config.TypeRule {
+		return &config.TypeRule{Name: from, RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: from, To: to}}}}
+	}
+	pkg := func(types ...*config.TypeRule) *config.Config {
+		return &config.Config{Packages: []*config.Package{{Import: "github.com/example/lib", Types: types}}}
+	}
+
+	_, err := Compile(pkg(explicit("Client", "Conn"), explicit("Connection", "Conn")))
+	assert.ErrorContains(t, err, `type rules in package "github.com/example/lib" rename both "Client" and "Connection" to "Conn"`)
+
+	_, err = Compile(pkg(explicit("Client", "Conn"), &config.TypeRule{
+		Name:    "*",
+		RuleSet: config.RuleSet{Regex: []*config.RegexRule{{Pattern: "^Connection$", Replace: "Conn"}}},
+	}))
+	assert.ErrorContains(t, err, `rename both "Client" and "re:^Connection$" to "Conn"`, "anchored regexes give fixed names")
+
+	_, err = Compile(pkg(explicit("Client", "Conn"), explicit("Client", "Conn")))
+	assert.NoError(t, err, "the same rename given twice does not collide")
+
+	cfg := pkg(explicit("Client", "Conn"))
+	cfg.Types = []*config.TypeRule{explicit("Connection", "Conn")}
+	_, err = Compile(cfg)
+	assert.NoError(t, err, "rules of different packages are checked separately")
+
+	_, err = Compile(pkg(&config.TypeRule{Name: "Client", Fields: []*config.MemberRule{
+		{Name: "Base", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Base", To: "Core"}}}},
+	}}, &config.TypeRule{Name: "Server", Fields: []*config.MemberRule{
+		{Name: "Inner", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Inner", To: "Core"}}}},
+	}}))
+	assert.NoError(t, err, "fields of different types do not collide")
+}
+
+func TestCompile_StrictIdentifiers(t *testing.T) {
+	cfg := &config.Config{
+		Types: []*config.TypeRule{{
+			Name:    "Worker",
+			RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Worker", To: "Wörker"}}},
+		}},
+	}
+	_, err := Compile(cfg)
+	assert.NoError(t, err, "Go accepts Unicode identifiers")
+	_, err = Compile(cfg, WithStrictIdentifiers(true))
+	assert.ErrorContains(t, err, `renames a declaration to "Wörker", which is not an ASCII exported identifier`)
+}
+
+func TestSanitizeIdentifier(t *testing.T) {
+	for name, want := range map[string]string{
+		"my-type":     "myType",
+		"My.Type":     "MyType",
+		"v1.client":   "v1Client",
+		"-leading":    "leading",
+		"2fa-code":    "X2faCode",
+		"snake_case":  "snake_case",
+		"with space":  "withSpace",
+		"...":         "",
+		"AlreadyGood": "AlreadyGood",
+	} {
+		assert.Equal(t, want, sanitizeIdentifier(name), "sanitizing %q", name)
+	}
+}
+
+func TestCompile_ValidNames(t *testing.T) {
+	cfg := &config.Config{
+		Types: []*config.TypeRule{{
+			Name:    "Kind",
+			RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Kind", To: "Type"}}},
+		}},
+		Functions: []*config.FuncRule{{
+			Name:    "*",
+			RuleSet: config.RuleSet{Regex: []*config.RegexRule{{Pattern: "^(.*)$", Replace: "any$1"}}},
+		}},
+	}
+	_, err := Compile(cfg)
+	assert.NoError(t, err, "names that only depend on the source symbol cannot be checked statically")
+
+	// A pattern that is not anchored at both ends only replaces part of the name, so ParseError
+	// becomes Parseerror rather than error.
+	_, err = Compile(&config.Config{
+		Types: []*config.TypeRule{{
+			Name:    "*",
+			RuleSet: config.RuleSet{Regex: []*config.RegexRule{{Pattern: "Error$", Replace: "error"}}},
+		}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestMatchesWholeName(t *testing.T) {
+	for pattern, want := range map[string]bool{
+		"^Length$":     true,
+		"^(Foo|Bar)$":  true,
+		`^\w+Error$`:   true,
+		"Error$":       false,
+		"^Error":       false,
+		"^Foo|Bar$":    false,
+		"Length":       false,
+		"^[invalid$":   false,
+		`^Price\$`:     false,
+		"(?m)^Length$": false,
+	} {
+		assert.Equal(t, want, matchesWholeName(pattern), "pattern %q", pattern)
+	}
+}
+
+func TestWriteRuleTable(t *testing.T) {
+	cfg := &config.Config{
+		Types: []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Prefix: "Global"}}},
+		Packages: []*config.Package{{
+			Import: "github.com/example/lib",
+			Functions: []*config.FuncRule{{
+				Name:    "New",
+				RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "New", To: "NewClient"}}},
+			}},
+		}},
+	}
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteRuleTable(&buf, compiled))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"PACKAGE", "KIND", "NAME", "PRIORITY", "RULE", "TRANSFORM"}, strings.Fields(lines[0]))
+	// Global rules are listed first and have priority 0; package rules have priority 1.
+	assert.Equal(t, []string{"*", "type", "*", "0", "prefix", "Global", "+", "name"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"github.com/example/lib", "func", "New", "1", "explicit", "New", "->", "NewClient"}, strings.Fields(lines[2]))
+}
+
+func TestCompile_TransformPipeline(t *testing.T) {
+	cfg := &config.Config{
+		Types: []*config.TypeRule{{
+			Name: "*",
+			RuleSet: config.RuleSet{Transforms: config.Transforms{
+				{Before: "^Legacy(.*)$", After: "$1"},
+				{Before: "^(.*)$", After: "${1}V2"},
+			}},
+		}},
+	}
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+
+	rules := compiled.RulesByPackageAndType[""][interfaces.RuleTypeType]
+	require.Len(t, rules, 1)
+	assert.Equal(t, "transform", rules[0].Type)
+	require.Len(t, rules[0].Transforms, 2)
+
+	ident := ast.NewIdent("LegacyClient")
+	ctx := interfaces.NewContext().Push(interfaces.RuleTypeType)
+	NewReplacer(compiled).Apply(ctx, ident)
+	assert.Equal(t, "ClientV2", ident.Name)
+}
+
+func TestCompile_TransformWithoutPattern(t *testing.T) {
+	cfg := &config.Config{
+		Types: []*config.TypeRule{{
+			Name:    "*",
+			RuleSet: config.RuleSet{Transforms: config.Transforms{{After: "New$1"}}},
+		}},
+	}
+	_, err := Compile(cfg)
+	assert.ErrorContains(t, err, "has no 'before' pattern")
+}
+
+func TestCompile_WildcardMemberRules(t *testing.T) {
+	const importPath = "github.com/example/lib"
+	cfg := &config.Config{
+		Packages: []*config.Package{{
+			Import: importPath,
+			Types: []*config.TypeRule{{
+				Name: "Worker",
+				Methods: []*config.MemberRule{
+					{Name: "*", RuleSet: config.RuleSet{Prefix: "Do"}},
+					{Name: "Close", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Close", To: "Shutdown"}}}},
+				},
+				Fields: []*config.MemberRule{
+					{Name: "*", RuleSet: config.RuleSet{Suffix: "Value"}},
+				},
+			}},
+			Functions: []*config.FuncRule{{Name: "Run", RuleSet: config.RuleSet{Suffix: "Func"}}},
+		}},
+	}
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+	replacer := NewReplacer(compiled)
+
+	apply := func(ruleType interfaces.RuleType, typeName, name string) string {
+		ctx := interfaces.NewContext().
+			WithValue(interfaces.PackagePathContextKey, importPath).
+			WithValue(interfaces.TypeNameContextKey, typeName).
+			Push(ruleType)
+		ident := ast.NewIdent(name)
+		replacer.Apply(ctx, ident)
+		return ident.Name
+	}
+
+	assert.Equal(t, "DoRun", apply(interfaces.RuleTypeMethod, "Worker", "Run"), "the wildcard method rule prefixes every method")
+	assert.Equal(t, "Shutdown", apply(interfaces.RuleTypeMethod, "Worker", "Close"), "a specific method rule overrides the wildcard")
+	assert.Equal(t, "NameValue", apply(interfaces.RuleTypeField, "Worker", "Name"))
+	assert.Equal(t, "Run", apply(interfaces.RuleTypeMethod, "Pool", "Run"), "member rules only apply to their own type")
+	assert.Equal(t, "RunFunc", apply(interfaces.RuleTypeFunc, "", "Run"), "member rules must not leak into function rules")
+	assert.Equal(t, "Stop", apply(interfaces.RuleTypeFunc, "", "Stop"))
+}
+
+func TestCompile_ScopedRegexRename(t *testing.T) {
+	const src = `package adapters
+
+//go:adapter:package github.com/example/lib
+//go:adapter:function MyHandler
+//go:adapter:function:regex ^(.*)Handler$ =$1Processor
+`
+	fset := token.NewFileSet()
+	file, err := goparser.ParseFile(fset, "adapters.go", src, goparser.ParseComments)
+	require.NoError(t, err)
+	cfg, err := parser.ParseFileDirectives(config.New(), file, fset)
+	require.NoError(t, err)
+
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+	replacer := NewReplacer(compiled)
+
+	apply := func(name string) string {
+		ctx := interfaces.NewContext().
+			WithValue(interfaces.PackagePathContextKey, "github.com/example/lib").
+			Push(interfaces.RuleTypeFunc)
+		ident := ast.NewIdent(name)
+		replacer.Apply(ctx, ident)
+		return ident.Name
+	}
+
+	assert.Equal(t, "MyProcessor", apply("MyHandler"), "the capture group is followed by the literal suffix")
+	assert.Equal(t, "OtherHandler", apply("OtherHandler"), "the regex only applies to the rule's own function")
+}
+
+func TestCompile_SuffixTemplate(t *testing.T) {
+	const src = `package adapters
+
+//go:adapter:package github.com/example/bar
+//go:adapter:type *
+//go:adapter:type:suffix_template From{{title .Package}}
+`
+	fset := token.NewFileSet()
+	file, err := goparser.ParseFile(fset, "adapters.go", src, goparser.ParseComments)
+	require.NoError(t, err)
+	cfg, err := parser.ParseFileDirectives(config.New(), file, fset)
+	require.NoError(t, err)
+
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+	replacer := NewReplacer(compiled)
+
+	ctx := interfaces.NewContext().
+		WithValue(interfaces.PackagePathContextKey, "github.com/example/bar").
+		WithValue(interfaces.PackageNameContextKey, "bar").
+		Push(interfaces.RuleTypeType)
+	ident := ast.NewIdent("Foo")
+	replacer.Apply(ctx, ident)
+	assert.Equal(t, "FooFromBar", ident.Name)
+}
+
+func TestCompile_AffixSeparator(t *testing.T) {
+	const src = `package adapters
+
+//go:adapter:package github.com/example/bar
+//go:adapter:type *
+//go:adapter:type:prefix my
+//go:adapter:type:affix_separator _
+`
+	fset := token.NewFileSet()
+	file, err := goparser.ParseFile(fset, "adapters.go", src, goparser.ParseComments)
+	require.NoError(t, err)
+	cfg, err := parser.ParseFileDirectives(config.New(), file, fset)
+	require.NoError(t, err)
+
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+	replacer := NewReplacer(compiled)
+
+	ctx := interfaces.NewContext().
+		WithValue(interfaces.PackagePathContextKey, "github.com/example/bar").
+		WithValue(interfaces.PackageNameContextKey, "bar").
+		Push(interfaces.RuleTypeType)
+	ident := ast.NewIdent("Foo")
+	replacer.Apply(ctx, ident)
+	assert.Equal(t, "My_Foo", ident.Name, "the prefix of an exported name is capitalized")
+}
+
+func TestCompile_InvalidSuffixTemplate(t *testing.T) {
+	cfg := config.New()
+	cfg.Types = []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{SuffixTemplate: "From{{.Package"}}}
+	_, err := Compile(cfg)
+	assert.ErrorContains(t, err, "invalid suffix template")
+}
+
+func TestDelimitGroupRefs(t *testing.T) {
+	tests := map[string]string{
+		"$1Processor":   "${1}Processor",
+		"$2$1":          "${2}${1}",
+		"${1}V2":        "${1}V2",
+		"$name":         "$name",
+		"$$1":           "$$1",
+		"Prefix$10Tail": "Prefix${10}Tail",
+		"Plain":         "Plain",
+		"Trailing$":     "Trailing$",
+	}
+	for replace, want := range tests {
+		assert.Equal(t, want, delimitGroupRefs(replace), replace)
+	}
+}
+
+func TestCompile_MaxNameLength(t *testing.T) {
+	cfg := &config.Config{
+		Functions: []*config.FuncRule{{
+			Name:    "*",
+			RuleSet: config.RuleSet{Prefix: "GeneratedAdapterFor"},
+		}},
+		MaxNameLength: 24,
+	}
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+	replacer := NewReplacer(compiled)
+
+	apply := func(name string) string {
+		ident := ast.NewIdent(name)
+		replacer.Apply(interfaces.NewContext().Push(interfaces.RuleTypeFunc), ident)
+		return ident.Name
+	}
+
+	assert.Equal(t, "GeneratedAdapterForRun", apply("Run"))
+	settings, statistics := apply("ConnectionPoolSettings"), apply("ConnectionPoolStatistics")
+	assert.Len(t, settings, 24)
+	assert.Len(t, statistics, 24)
+	assert.NotEqual(t, settings, statistics)
+
+	for _, invalid := range []int{-1, 8} {
+		cfg.MaxNameLength = invalid
+		_, err := Compile(cfg)
+		assert.ErrorContains(t, err, "max_name_length must be greater than 8")
+	}
+}
+
+func TestCompile_PackageDefaultMode(t *testing.T) {
+	pkgFunctions := []*config.FuncRule{{
+		Name:    "NewClient",
+		RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "NewClient", To: "MakeClient"}}},
+	}}
+	cfg := &config.Config{
+		Defaults: &config.Defaults{Mode: &config.Mode{Explicit: "replace"}},
+		Functions: []*config.FuncRule{{
+			Name:    "Close",
+			RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Close", To: "Shutdown"}}},
+		}},
+		Packages: []*config.Package{
+			{Import: "example.com/replaced", Functions: pkgFunctions},
+			{
+				Import:    "example.com/merged",
+				Defaults:  &config.Defaults{Mode: &config.Mode{Explicit: "merge"}},
+				Functions: pkgFunctions,
+			},
+			{Import: "example.com/unruled"},
+		},
+	}
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+	replacer := NewReplacer(compiled)
+
+	apply := func(pkgPath, name string) string {
+		ctx := interfaces.NewContext().
+			WithValue(interfaces.PackagePathContextKey, pkgPath).
+			Push(interfaces.RuleTypeFunc)
+		ident := ast.NewIdent(name)
+		replacer.Apply(ctx, ident)
+		return ident.Name
+	}
+
+	assert.Equal(t, "MakeClient", apply("example.com/replaced", "NewClient"))
+	assert.Equal(t, "Close", apply("example.com/replaced", "Close"), "the global default replaces the global explicit rules")
+	assert.Equal(t, "MakeClient", apply("example.com/merged", "NewClient"))
+	assert.Equal(t, "Shutdown", apply("example.com/merged", "Close"), "the package default merges the global explicit rules")
+	assert.Equal(t, "Shutdown", apply("example.com/unruled", "Close"), "global rules apply to a package without rules of their kind")
+}
+
+func TestReplacer_AfterRename(t *testing.T) {
+	compiled, err := Compile(&config.Config{
+		Types: []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Prefix: "Lib"}}},
+		Packages: []*config.Package{{
+			Import: "example.com/lib",
+			Functions: []*config.FuncRule{{
+				Name:    "NewClient",
+				RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "NewClient", To: "MakeClient"}}},
+			}},
+		}},
+	})
+	require.NoError(t, err)
+
+	type rename struct {
+		original, final string
+		rt              interfaces.RuleType
+		pkg             string
+	}
+	var renames []rename
+	replacer := NewReplacer(compiled, WithAfterRename(func(original, final string, rt interfaces.RuleType, pkg string) {
+		renames = append(renames, rename{original, final, rt, pkg})
+	}))
+	apply := func(rt interfaces.RuleType, name string) {
+		ctx := interfaces.NewContext().
+			WithValue(interfaces.PackagePathContextKey, "example.com/lib").
+			Push(rt)
+		replacer.Apply(ctx, ast.NewIdent(name))
+	}
+	apply(interfaces.RuleTypeFunc, "NewClient")
+	apply(interfaces.RuleTypeType, "Client")
+	apply(interfaces.RuleTypeFunc, "Close")
+
+	assert.Equal(t, []rename{
+		{"NewClient", "MakeClient", interfaces.RuleTypeFunc, "example.com/lib"},
+		{"Client", "LibClient", interfaces.RuleTypeType, "example.com/lib"},
+	}, renames, "the hook is called for each rename, and not for names left unchanged")
+}
+
+func TestReplacer_TraceSink(t *testing.T) {
+	compiled, err := Compile(&config.Config{
+		Types: []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Prefix: "Lib"}}},
+		Packages: []*config.Package{{
+			Import: "example.com/lib",
+			Functions: []*config.FuncRule{{
+				Name:    "NewClient",
+				RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "NewClient", To: "MakeClient"}}},
+			}},
+		}},
+	})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	sink := NewJSONLinesSink(&out)
+	replacer := NewReplacer(compiled, WithTraceSink(sink))
+	for _, symbol := range []struct {
+		rt   interfaces.RuleType
+		name string
+	}{{interfaces.RuleTypeFunc, "NewClient"}, {interfaces.RuleTypeType, "Client"}, {interfaces.RuleTypeFunc, "Close"}} {
+		ctx := interfaces.NewContext().
+			WithValue(interfaces.PackagePathContextKey, "example.com/lib").
+			Push(symbol.rt)
+		replacer.Apply(ctx, ast.NewIdent(symbol.name))
+	}
+	require.NoError(t, sink.Err())
+
+	assert.Equal(t, `{"original":"NewClient","final":"MakeClient","kind":"func","package":"example.com/lib","rule":"explicit: NewClient -> MakeClient"}
+{"original":"Client","final":"LibClient","kind":"type","package":"example.com/lib","rule":"prefix: Lib + name"}
+`, out.String(), "an event is written for each rename, and none for names left unchanged")
+}
+
+func TestCompile_EmbedRequiresWrap(t *testing.T) {
+	cfg := &config.Config{
+		Types: []*config.TypeRule{{Name: "Config", Pattern: "wrap", Embed: true}},
+	}
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]bool{"": {"Config": true}}, compiled.EmbedTypes)
+
+	cfg.Types[0].Pattern = "copy"
+	_, err = Compile(cfg)
+	assert.ErrorContains(t, err, "embed requires the wrap pattern")
+}
+
+func TestCompile_Ignores(t *testing.T) {
+	cfg := &config.Config{
+		Ignores: []string{"Debug", "re:^Test"},
+		Packages: []*config.Package{{
+			Import:    "example.com/pkg",
+			Functions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"Must*"}}}},
+		}},
+	}
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+
+	global := compiled.IgnoresByPackageAndType[""][interfaces.RuleTypeType]
+	require.Len(t, global, 2)
+	assert.Equal(t, "Debug", global[0].Pattern)
+	assert.Nil(t, global[0].CompiledRegex)
+	assert.Equal(t, "^Test", global[1].Pattern)
+	require.NotNil(t, global[1].CompiledRegex)
+	assert.Len(t, compiled.IgnoresByPackageAndType[""][interfaces.RuleTypeConst], 2, "top-level ignores apply to every kind")
+	assert.Equal(t, []interfaces.CompiledIgnore{{Pattern: "Must*"}}, compiled.IgnoresByPackageAndType["example.com/pkg"][interfaces.RuleTypeFunc])
+
+	cfg.Ignores = []string{"re:(unclosed"}
+	_, err = Compile(cfg)
+	assert.ErrorContains(t, err, `invalid ignore regex "(unclosed"`)
+
+	cfg.Ignores = []string{"[bad"}
+	_, err = Compile(cfg)
+	assert.ErrorContains(t, err, `invalid ignore pattern "[bad"`)
+}
+
+func TestCompile_DuplicateRulesCollapsed(t *testing.T) {
+	compiled, err := Compile(&config.Config{
+		Types: []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{
+			Explicit: []*config.ExplicitRule{
+				{From: "Foo", To: "Bar"},
+				{From: "Baz", To: "Qux"},
+				{From: "Foo", To: "Bar"},
+				{From: "Foo", To: "Other"},
+			},
+			Ignores: []string{"Debug", "re:^Test", "Debug", "re:^Test"},
+		}}},
+		Functions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{
+			Regex: []*config.RegexRule{
+				{Pattern: "^New(.*)", Replace: "Make$1"},
+				{Pattern: "^New(.*)", Replace: "Make$1"},
+				{Pattern: "^New(.*)", Replace: "Make$1", Qualified: true},
+			},
+		}}},
+	})
+	require.NoError(t, err)
+
+	var explicit [][2]string
+	for _, rule := range compiled.RulesByPackageAndType[""][interfaces.RuleTypeType] {
+		explicit = append(explicit, [2]string{rule.From, rule.To})
+	}
+	assert.Equal(t, [][2]string{{"Foo", "Bar"}, {"Baz", "Qux"}, {"Foo", "Other"}}, explicit,
+		"identical entries collapse into the first one, in order; differing ones are kept")
+
+	regex := compiled.RulesByPackageAndType[""][interfaces.RuleTypeFunc]
+	require.Len(t, regex, 2)
+	assert.False(t, regex[0].Qualified)
+	assert.True(t, regex[1].Qualified)
+
+	ignores := compiled.IgnoresByPackageAndType[""][interfaces.RuleTypeType]
+	require.Len(t, ignores, 2)
+	assert.Equal(t, "Debug", ignores[0].Pattern)
+	assert.Equal(t, "^Test", ignores[1].Pattern)
+}
+
+func TestCompile_QualifiedIgnores(t *testing.T) {
+	cfg := &config.Config{
+		Ignores: []string{"Debug", "other.Config", "other.Must*", "unknown.Config", "re:^a.b$"},
+		Packages: []*config.Package{
+			{Import: "example.com/source", Alias: "source"},
+			{Import: "example.com/lib/v2", ForceAlias: "other"},
+		},
+	}
+	compiled, err := Compile(cfg)
+	require.NoError(t, err)
+
+	patterns := func(pkgPath string) []string {
+		var got []string
+		for _, ignore := range compiled.IgnoresByPackageAndType[pkgPath][interfaces.RuleTypeType] {
+			got = append(got, ignore.Pattern)
+		}
+		return got
+	}
+	assert.Equal(t, []string{"Debug", "unknown.Config", "^a.b$"}, patterns(""))
+	assert.Equal(t, []string{"Config", "Must*"}, patterns("example.com/lib/v2"))
+	assert.Len(t, compiled.IgnoresByPackageAndType["example.com/lib/v2"][interfaces.RuleTypeFunc], 2, "qualified ignores apply to every kind")
+	assert.Empty(t, patterns("example.com/source"))
+	assert.Equal(t, []string{"Config"}, RuleSymbols(compiled)["example.com/lib/v2"])
+
+	cfg.Packages = append(cfg.Packages, &config.Package{Import: "example.com/other", Alias: "other"})
+	_, err = Compile(cfg)
+	assert.ErrorContains(t, err, `alias "other" is shared by packages example.com/lib/v2, example.com/other`)
+}
+
+func TestRuleSymbols(t *testing.T) {
+	compiled, err := Compile(&config.Config{
+		Functions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Global", To: "G"}}}}},
+		Packages: []*config.Package{{
+			Import: "example.com/pkg",
+			Types: []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{
+				Explicit: []*config.ExplicitRule{{From: "Client", To: "C"}},
+				Ignores:  []string{"Debug", "Must*", "re:^Test"},
+			}}},
+			Functions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Client", To: "NewC"}}}}},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"example.com/pkg": {"Client", "Debug"}}, RuleSymbols(compiled))
+}
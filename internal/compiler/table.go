@@ -0,0 +1,79 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
+// WriteRuleTable writes the compiled rename rules as a table, one row per rule, in the order
+// in which the replacer considers them: by package (global rules first), by rule type and then
+// by priority.
+func WriteRuleTable(w io.Writer, compiledCfg *interfaces.CompiledConfig) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tKIND\tNAME\tPRIORITY\tRULE\tTRANSFORM")
+
+	pkgNames := make([]string, 0, len(compiledCfg.RulesByPackageAndType))
+	for pkgName := range compiledCfg.RulesByPackageAndType {
+		pkgNames = append(pkgNames, pkgName)
+	}
+	sort.Strings(pkgNames)
+
+	for _, pkgName := range pkgNames {
+		rulesByType := compiledCfg.RulesByPackageAndType[pkgName]
+		ruleTypes := make([]interfaces.RuleType, 0, len(rulesByType))
+		for ruleType := range rulesByType {
+			ruleTypes = append(ruleTypes, ruleType)
+		}
+		sort.Slice(ruleTypes, func(i, j int) bool { return ruleTypes[i] < ruleTypes[j] })
+
+		displayPkg := pkgName
+		if displayPkg == "" {
+			displayPkg = "*"
+		}
+		for _, ruleType := range ruleTypes {
+			for _, rule := range rulesByType[ruleType] {
+				name := rule.OriginalName
+				if rule.Type == "explicit" {
+					name = rule.From
+				}
+				if rule.Owner != "" {
+					name = rule.Owner + "." + name
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+					displayPkg, ruleType, name, rule.Priority, rule.Type, describeTransform(rule))
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// describeTransform returns a short, human-readable description of what a rule does to a name.
+func describeTransform(rule interfaces.CompiledRenameRule) string {
+	switch rule.Type {
+	case "explicit":
+		return fmt.Sprintf("%s -> %s", rule.From, rule.To)
+	case "prefix":
+		return fmt.Sprintf("%s%s + name", rule.Value, rule.Separator)
+	case "suffix", "suffix_template":
+		return fmt.Sprintf("name + %s%s", rule.Separator, rule.Value)
+	case "regex":
+		desc := fmt.Sprintf("s/%s/%s/", rule.Pattern, rule.Replace)
+		if rule.Qualified {
+			desc += " (qualified)"
+		}
+		return desc
+	case "transform":
+		stages := make([]string, 0, len(rule.Transforms))
+		for _, stage := range rule.Transforms {
+			stages = append(stages, fmt.Sprintf("s/%s/%s/", stage.Pattern, stage.Replace))
+		}
+		return strings.Join(stages, " | ")
+	default:
+		return ""
+	}
+}
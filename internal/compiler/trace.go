@@ -0,0 +1,71 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
+// TraceEvent records a rename made by a replacer.
+type TraceEvent struct {
+	Original string `json:"original"` // Source name of the symbol
+	Final    string `json:"final"`    // Generated name of the symbol
+	Kind     string `json:"kind"`     // Kind of the symbol, e.g. "type" or "func"
+	Package  string `json:"package"`  // Import path of the package declaring the symbol
+	Rule     string `json:"rule"`     // The rule that renamed the symbol, e.g. "prefix: Gin + name"
+}
+
+// TraceSink receives the renames made by a replacer, in the order they are made.
+type TraceSink interface {
+	Trace(event TraceEvent)
+}
+
+// WithTraceSink sets a sink the replacer writes an event to for each rename it makes, e.g. a
+// JSONLinesSink to analyze the renames of a large run offline.
+func WithTraceSink(sink TraceSink) ReplacerOption {
+	return func(r *realReplacer) {
+		r.traceSink = sink
+	}
+}
+
+// JSONLinesSink is a TraceSink writing each event as a line of JSON.
+type JSONLinesSink struct {
+	encoder *json.Encoder
+	err     error
+}
+
+// NewJSONLinesSink returns a sink writing newline-delimited JSON events to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	encoder := json.NewEncoder(w)
+	// Rules such as "explicit: A -> B" are kept readable; the events are not embedded in HTML.
+	encoder.SetEscapeHTML(false)
+	return &JSONLinesSink{encoder: encoder}
+}
+
+// Trace writes event as a line of JSON. After a write fails, further events are dropped.
+func (s *JSONLinesSink) Trace(event TraceEvent) {
+	if s.err != nil {
+		return
+	}
+	if err := s.encoder.Encode(event); err != nil {
+		s.err = fmt.Errorf("failed to write trace event: %w", err)
+	}
+}
+
+// Err returns the error of the first write that failed, if any.
+func (s *JSONLinesSink) Err() error {
+	return s.err
+}
+
+// traceEvent returns the event of rule renaming name, of kind ruleType and package pkgPath, to newName.
+func traceEvent(rule interfaces.CompiledRenameRule, name, newName string, ruleType interfaces.RuleType, pkgPath string) TraceEvent {
+	return TraceEvent{
+		Original: name,
+		Final:    newName,
+		Kind:     ruleType.String(),
+		Package:  pkgPath,
+		Rule:     fmt.Sprintf("%s: %s", rule.Type, describeTransform(rule)),
+	}
+}
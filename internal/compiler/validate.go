@@ -0,0 +1,231 @@
+package compiler
+
+import (
+	"fmt"
+	"go/token"
+	"log/slog"
+	"regexp/syntax"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+	"github.com/origadmin/adptool/internal/util"
+)
+
+// Option configures how a configuration is compiled.
+type Option func(*compileOptions)
+
+type compileOptions struct {
+	lenient           bool
+	sanitizeNames     bool
+	strictIdentifiers bool
+}
+
+// WithLenient reports problems in the generated names, such as renaming a symbol
+// to a keyword or predeclared identifier, as warnings instead of errors.
+func WithLenient(lenient bool) Option {
+	return func(o *compileOptions) {
+		o.lenient = lenient
+	}
+}
+
+// WithSanitizeNames turns the targets of explicit rules that are not valid identifiers, such as
+// my-type, into identifiers, such as myType, instead of failing compilation.
+func WithSanitizeNames(sanitize bool) Option {
+	return func(o *compileOptions) {
+		o.sanitizeNames = sanitize
+	}
+}
+
+// WithStrictIdentifiers rejects the names known from the rules alone, such as the targets of
+// explicit rules, that are not ASCII exported identifiers, as generation does with all names.
+func WithStrictIdentifiers(strict bool) Option {
+	return func(o *compileOptions) {
+		o.strictIdentifiers = strict
+	}
+}
+
+// sanitizeIdentifier turns name into a Go identifier. Characters other than letters, digits and
+// underscores are dropped and the letter following them is capitalized, so that my-type becomes
+// myType and v1.client becomes v1Client. A name starting with a digit is prefixed with X. It
+// returns an empty string if name has no letter or digit.
+func sanitizeIdentifier(name string) string {
+	var b strings.Builder
+	capitalize := false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			capitalize = true
+			continue
+		}
+		if capitalize && b.Len() > 0 {
+			r = unicode.ToUpper(r)
+		}
+		capitalize = false
+		b.WriteRune(r)
+	}
+	sanitized := b.String()
+	if first, _ := utf8.DecodeRuneInString(sanitized); unicode.IsDigit(first) {
+		sanitized = "X" + sanitized
+	}
+	return sanitized
+}
+
+// staticTargetName returns the name a rule always renames to, if it can be known
+// without looking at the source symbol. The names given by other rules depend on the source
+// symbol, and are checked by the generator once they are known.
+func staticTargetName(rule interfaces.CompiledRenameRule) (string, bool) {
+	switch rule.Type {
+	case "explicit":
+		return rule.To, true
+	case "regex":
+		// A replacement without capture group references gives a fixed name only if the pattern
+		// matches the whole name; otherwise it only replaces the matched part.
+		if !strings.Contains(rule.Replace, "$") && matchesWholeName(rule.Pattern) {
+			return rule.Replace, true
+		}
+	}
+	return "", false
+}
+
+// matchesWholeName reports whether pattern is anchored at both ends of the name, as in ^Name$,
+// so that any match is the whole name.
+func matchesWholeName(pattern string) bool {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	re = re.Simplify()
+	return re.Op == syntax.OpConcat && len(re.Sub) >= 2 &&
+		re.Sub[0].Op == syntax.OpBeginText && re.Sub[len(re.Sub)-1].Op == syntax.OpEndText
+}
+
+// validateRuleNames checks that no rule renames a declaration to a Go keyword or a
+// predeclared identifier. A keyword never compiles and a predeclared identifier
+// silently shadows the builtin, so both are errors unless lenient is set, in which
+// case they are reported as warnings. The target of an explicit rule that is not an
+// identifier at all is an error, unless sanitizeNames is set, in which case it is
+// replaced by its sanitized form. If strictIdentifiers is set, a name that is not an ASCII
+// exported identifier is an error as well.
+func validateRuleNames(compiledCfg *interfaces.CompiledConfig, options *compileOptions) error {
+	pkgNames := make([]string, 0, len(compiledCfg.RulesByPackageAndType))
+	for pkgName := range compiledCfg.RulesByPackageAndType {
+		pkgNames = append(pkgNames, pkgName)
+	}
+	sort.Strings(pkgNames)
+
+	for _, pkgName := range pkgNames {
+		rulesByType := compiledCfg.RulesByPackageAndType[pkgName]
+		ruleTypes := make([]interfaces.RuleType, 0, len(rulesByType))
+		for ruleType := range rulesByType {
+			ruleTypes = append(ruleTypes, ruleType)
+		}
+		sort.Slice(ruleTypes, func(i, j int) bool { return ruleTypes[i] < ruleTypes[j] })
+
+		for _, ruleType := range ruleTypes {
+			rules := rulesByType[ruleType]
+			for i, rule := range rules {
+				name, ok := staticTargetName(rule)
+				if !ok {
+					continue
+				}
+				if rule.Type == "explicit" && name != "" && !token.IsIdentifier(name) && !token.IsKeyword(name) {
+					sanitized := sanitizeIdentifier(name)
+					if !options.sanitizeNames || sanitized == "" {
+						return fmt.Errorf("%s rule in package %q renames %q to %q, which is not a valid Go identifier (see --sanitize-names)",
+							ruleType, pkgName, rule.From, name)
+					}
+					slog.Info("Sanitized the target of an explicit rule", "package", pkgName, "ruleType", ruleType, "from", name, "to", sanitized)
+					rules[i].To = sanitized
+					name = sanitized
+				}
+				if options.strictIdentifiers && !isMemberRuleType(ruleType) && !util.IsStrictIdentifier(name) {
+					return fmt.Errorf("%s rule in package %q renames a declaration to %q, which is not an ASCII exported identifier (see --strict-identifiers)",
+						ruleType, pkgName, name)
+				}
+				kind := util.ReservedNameKind(name)
+				if kind == "" {
+					continue
+				}
+				if options.lenient {
+					slog.Warn("Rule renames a declaration to a reserved Go name",
+						"package", pkgName, "ruleType", ruleType, "name", name, "kind", kind)
+					continue
+				}
+				return fmt.Errorf("%s rule in package %q renames a declaration to %q, which is a Go %s",
+					ruleType, pkgName, name, kind)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRuleTargets checks that no two rules of a package and rule type give different
+// symbols the same name, as far as it is known from the rules alone: explicit rules and regular
+// expressions anchored at both ends with a fixed replacement. The symbols would collide in the
+// generated file, which only generation would otherwise report. Member rules collide only
+// within the type they belong to.
+func validateRuleTargets(compiledCfg *interfaces.CompiledConfig) error {
+	pkgNames := make([]string, 0, len(compiledCfg.RulesByPackageAndType))
+	for pkgName := range compiledCfg.RulesByPackageAndType {
+		pkgNames = append(pkgNames, pkgName)
+	}
+	sort.Strings(pkgNames)
+
+	for _, pkgName := range pkgNames {
+		rulesByType := compiledCfg.RulesByPackageAndType[pkgName]
+		ruleTypes := make([]interfaces.RuleType, 0, len(rulesByType))
+		for ruleType := range rulesByType {
+			ruleTypes = append(ruleTypes, ruleType)
+		}
+		sort.Slice(ruleTypes, func(i, j int) bool { return ruleTypes[i] < ruleTypes[j] })
+
+		for _, ruleType := range ruleTypes {
+			// sources maps the owner and name given by a rule to the symbol the rule renames.
+			sources := make(map[[2]string]string)
+			for _, rule := range rulesByType[ruleType] {
+				name, ok := staticTargetName(rule)
+				if !ok || name == "" {
+					continue
+				}
+				source := rule.From
+				if rule.Type == "regex" {
+					source = "re:" + rule.Pattern
+				}
+				if source == "*" {
+					continue
+				}
+				key := [2]string{rule.Owner, name}
+				if other, ok := sources[key]; ok && other != source {
+					return fmt.Errorf("%s rules in package %q rename both %q and %q to %q", ruleType, pkgName, other, source, name)
+				}
+				sources[key] = source
+			}
+		}
+	}
+	return nil
+}
+
+// outputSections are the sections of a generated file, in their default order.
+var outputSections = []string{"imports", "consts", "vars", "types", "funcs"}
+
+// validateOutputOrder checks that order lists sections of a generated file at most once each,
+// with the imports first if listed, as Go requires them before any other declaration.
+func validateOutputOrder(order []string) error {
+	seen := make(map[string]bool, len(order))
+	for i, section := range order {
+		if !slices.Contains(outputSections, section) {
+			return fmt.Errorf("output_order has unknown section %q, expected one of %s", section, strings.Join(outputSections, ", "))
+		}
+		if seen[section] {
+			return fmt.Errorf("output_order lists %q more than once", section)
+		}
+		seen[section] = true
+		if section == "imports" && i > 0 {
+			return fmt.Errorf("output_order must list \"imports\" first, as Go requires imports before other declarations")
+		}
+	}
+	return nil
+}
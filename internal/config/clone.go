@@ -0,0 +1,192 @@
+package config
+
+// cloneSlice deep-copies a slice of pointers using the given element cloner.
+// A nil slice stays nil and an empty slice stays empty, so clones compare equal to their source.
+func cloneSlice[T any](src []*T, clone func(*T) *T) []*T {
+	if src == nil {
+		return nil
+	}
+	dst := make([]*T, len(src))
+	for i, v := range src {
+		dst[i] = clone(v)
+	}
+	return dst
+}
+
+// cloneStrings copies a string slice, preserving nil.
+func cloneStrings(src []string) []string {
+	if src == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(src)), src...)
+}
+
+// Clone returns a deep copy of the configuration.
+// Mutating the returned Config never affects the receiver.
+func (c *Config) Clone() *Config {
+	if c == nil {
+		return nil
+	}
+	return &Config{
+		PackageName:       c.PackageName,
+		Ignores:           cloneStrings(c.Ignores),
+		Defaults:          c.Defaults.Clone(),
+		Props:             cloneSlice(c.Props, (*PropsEntry).Clone),
+		Packages:          cloneSlice(c.Packages, (*Package).Clone),
+		Types:             cloneSlice(c.Types, (*TypeRule).Clone),
+		Functions:         cloneSlice(c.Functions, (*FuncRule).Clone),
+		Variables:         cloneSlice(c.Variables, (*VarRule).Clone),
+		Constants:         cloneSlice(c.Constants, (*ConstRule).Clone),
+		MaxNameLength:     c.MaxNameLength,
+		OnlyTagged:        c.OnlyTagged,
+		SkipDeprecated:    c.SkipDeprecated,
+		TypeMode:          c.TypeMode,
+		AllowedImports:    cloneStrings(c.AllowedImports),
+		RenameFile:        c.RenameFile,
+		CollisionStrategy: c.CollisionStrategy,
+		OutputOrder:       cloneStrings(c.OutputOrder),
+	}
+}
+
+// Clone returns a copy of the props entry.
+func (p *PropsEntry) Clone() *PropsEntry {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	return &clone
+}
+
+// Clone returns a deep copy of the type rule, including its member rules.
+func (t *TypeRule) Clone() *TypeRule {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	clone.Methods = cloneSlice(t.Methods, (*MemberRule).Clone)
+	clone.Fields = cloneSlice(t.Fields, (*MemberRule).Clone)
+	clone.RuleSet = t.RuleSet.Clone()
+	return &clone
+}
+
+// Clone returns a deep copy of the function rule.
+func (f *FuncRule) Clone() *FuncRule {
+	if f == nil {
+		return nil
+	}
+	clone := *f
+	clone.RuleSet = f.RuleSet.Clone()
+	return &clone
+}
+
+// Clone returns a deep copy of the variable rule.
+func (v *VarRule) Clone() *VarRule {
+	if v == nil {
+		return nil
+	}
+	clone := *v
+	clone.RuleSet = v.RuleSet.Clone()
+	return &clone
+}
+
+// Clone returns a deep copy of the constant rule.
+func (c *ConstRule) Clone() *ConstRule {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	clone.RuleSet = c.RuleSet.Clone()
+	return &clone
+}
+
+// Clone returns a deep copy of the member rule.
+func (m *MemberRule) Clone() *MemberRule {
+	if m == nil {
+		return nil
+	}
+	clone := *m
+	clone.RuleSet = m.RuleSet.Clone()
+	return &clone
+}
+
+// Clone returns a copy of the transform.
+func (t *Transform) Clone() *Transform {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	return &clone
+}
+
+// Clone returns a deep copy of the rule set.
+// RuleSet is embedded by value in the rule types, so the copy is returned by value as well.
+func (r RuleSet) Clone() RuleSet {
+	clone := r
+	clone.Strategy = cloneStrings(r.Strategy)
+	clone.Explicit = cloneSlice(r.Explicit, (*ExplicitRule).Clone)
+	clone.Regex = cloneSlice(r.Regex, (*RegexRule).Clone)
+	clone.Ignores = cloneStrings(r.Ignores)
+	clone.Transforms = cloneSlice(r.Transforms, (*Transform).Clone)
+	return clone
+}
+
+// Clone returns a copy of the explicit rule.
+func (e *ExplicitRule) Clone() *ExplicitRule {
+	if e == nil {
+		return nil
+	}
+	clone := *e
+	return &clone
+}
+
+// Clone returns a copy of the regex rule.
+func (r *RegexRule) Clone() *RegexRule {
+	if r == nil {
+		return nil
+	}
+	clone := *r
+	return &clone
+}
+
+// Clone returns a deep copy of the package, including all of its rules.
+func (p *Package) Clone() *Package {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	clone.Defaults = p.Defaults.Clone()
+	clone.Props = cloneSlice(p.Props, (*PropsEntry).Clone)
+	clone.Types = cloneSlice(p.Types, (*TypeRule).Clone)
+	clone.Functions = cloneSlice(p.Functions, (*FuncRule).Clone)
+	clone.Variables = cloneSlice(p.Variables, (*VarRule).Clone)
+	clone.Constants = cloneSlice(p.Constants, (*ConstRule).Clone)
+	clone.Instantiate = cloneSlice(p.Instantiate, (*Instantiation).Clone)
+	return &clone
+}
+
+// Clone returns a deep copy of the instantiation.
+func (i *Instantiation) Clone() *Instantiation {
+	if i == nil {
+		return nil
+	}
+	clone := *i
+	clone.Args = cloneStrings(i.Args)
+	return &clone
+}
+
+// Clone returns a deep copy of the defaults.
+func (d *Defaults) Clone() *Defaults {
+	if d == nil {
+		return nil
+	}
+	return &Defaults{Mode: d.Mode.Clone()}
+}
+
+// Clone returns a copy of the mode.
+func (m *Mode) Clone() *Mode {
+	if m == nil {
+		return nil
+	}
+	clone := *m
+	return &clone
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFullConfig builds a configuration in which every nested field is populated.
+func newFullConfig() *Config {
+	ruleSet := func(name string) RuleSet {
+		return RuleSet{
+			Strategy:   []string{"replace"},
+			Prefix:     name + "Prefix",
+			Suffix:     name + "Suffix",
+			Explicit:   []*ExplicitRule{{From: name, To: name + "New"}},
+			Regex:      []*RegexRule{{Pattern: "^" + name + "(.*)$", Replace: "New$1"}},
+			Ignores:    []string{name + "Ignored"},
+			Transforms: Transforms{{Before: "(.*)", After: name + "$1"}},
+		}
+	}
+	return &Config{
+		PackageName: "adapter",
+		Ignores:     []string{"Ignored"},
+		Defaults: &Defaults{
+			Mode: &Mode{Strategy: "replace", Prefix: "append", Explicit: "merge"},
+		},
+		Props: []*PropsEntry{{Name: "Key", Value: "Value"}},
+		Packages: []*Package{
+			{
+				Import:      "github.com/example/lib",
+				Alias:       "lib",
+				ForceAlias:  "exlib",
+				Defaults:    &Defaults{Mode: &Mode{Explicit: "replace"}},
+				Props:       []*PropsEntry{{Name: "PkgKey", Value: "PkgValue"}},
+				Types:       []*TypeRule{{Name: "PkgType", RuleSet: ruleSet("PkgType")}},
+				Functions:   []*FuncRule{{Name: "PkgFunc", RuleSet: ruleSet("PkgFunc")}},
+				Variables:   []*VarRule{{Name: "PkgVar", RuleSet: ruleSet("PkgVar")}},
+				Constants:   []*ConstRule{{Name: "PkgConst", RuleSet: ruleSet("PkgConst")}},
+				Instantiate: []*Instantiation{{Type: "Generic", Args: []string{"string"}, As: "StringGeneric"}},
+			},
+		},
+		Types: []*TypeRule{
+			{
+				Name:    "Type",
+				Kind:    "struct",
+				Pattern: "wrap",
+				Embed:   true,
+				Methods: []*MemberRule{{Name: "Method", RuleSet: ruleSet("Method")}},
+				Fields:  []*MemberRule{{Name: "Field", RuleSet: ruleSet("Field")}},
+				RuleSet: ruleSet("Type"),
+			},
+		},
+		Functions: []*FuncRule{{Name: "Func", RuleSet: ruleSet("Func")}},
+		Variables: []*VarRule{{Name: "Var", RuleSet: ruleSet("Var")}},
+		Constants: []*ConstRule{{Name: "Const", RuleSet: ruleSet("Const")}},
+
+		MaxNameLength:     40,
+		OnlyTagged:        "adapter:export",
+		SkipDeprecated:    true,
+		TypeMode:          "define",
+		AllowedImports:    []string{"github.com/example"},
+		RenameFile:        "package",
+		CollisionStrategy: "skip",
+		OutputOrder:       []string{"imports", "types"},
+	}
+}
+
+func TestConfig_Clone(t *testing.T) {
+	original := newFullConfig()
+	clone := original.Clone()
+	assert.Equal(t, original, clone, "clone should be equal to the original")
+
+	mutateRuleSet := func(rs *RuleSet) {
+		rs.Strategy[0] = "mutated"
+		rs.Prefix = "mutated"
+		rs.Explicit[0].To = "mutated"
+		rs.Regex[0].Replace = "mutated"
+		rs.Ignores[0] = "mutated"
+		rs.Transforms[0].After = "mutated"
+		rs.Explicit = append(rs.Explicit, &ExplicitRule{From: "a", To: "b"})
+	}
+
+	clone.PackageName = "mutated"
+	clone.Ignores[0] = "mutated"
+	clone.Defaults.Mode.Strategy = "mutated"
+	clone.Props[0].Value = "mutated"
+
+	pkg := clone.Packages[0]
+	pkg.Import = "mutated"
+	pkg.Defaults.Mode.Explicit = "mutated"
+	pkg.Props[0].Value = "mutated"
+	mutateRuleSet(&pkg.Types[0].RuleSet)
+	mutateRuleSet(&pkg.Functions[0].RuleSet)
+	mutateRuleSet(&pkg.Variables[0].RuleSet)
+	mutateRuleSet(&pkg.Constants[0].RuleSet)
+	pkg.Instantiate[0].Args[0] = "mutated"
+
+	typ := clone.Types[0]
+	typ.Pattern = "mutated"
+	typ.Methods[0].Name = "mutated"
+	mutateRuleSet(&typ.Methods[0].RuleSet)
+	typ.Fields[0].Disabled = true
+	mutateRuleSet(&typ.Fields[0].RuleSet)
+	mutateRuleSet(&typ.RuleSet)
+
+	mutateRuleSet(&clone.Functions[0].RuleSet)
+	mutateRuleSet(&clone.Variables[0].RuleSet)
+	mutateRuleSet(&clone.Constants[0].RuleSet)
+
+	clone.Types = append(clone.Types, &TypeRule{Name: "Extra"})
+	clone.Packages = append(clone.Packages, &Package{Import: "extra"})
+
+	assert.Equal(t, newFullConfig(), original, "mutating the clone must not affect the original")
+}
+
+func TestConfig_CloneNilAndEmpty(t *testing.T) {
+	var nilCfg *Config
+	assert.Nil(t, nilCfg.Clone())
+
+	empty := New()
+	clone := empty.Clone()
+	assert.Equal(t, empty, clone, "empty slices should stay empty, not become nil")
+	assert.NotNil(t, clone.Types)
+
+	sparse := &Config{Types: []*TypeRule{{Name: "T"}}}
+	assert.Equal(t, sparse, sparse.Clone(), "nil fields should stay nil")
+}
@@ -1,5 +1,10 @@
 package config
 
+import (
+	"bytes"
+	"encoding/json"
+)
+
 // RuleHolder defines the interface for any rule-holding configuration element.
 type RuleHolder interface {
 	IsDisabled() bool
@@ -38,6 +43,33 @@ type Config struct {
 	Functions   []*FuncRule   `yaml:"functions,omitempty" mapstructure:"functions,omitempty" json:"functions,omitempty" toml:"functions,omitempty"`
 	Variables   []*VarRule    `yaml:"variables,omitempty" mapstructure:"variables,omitempty" json:"variables,omitempty" toml:"variables,omitempty"`
 	Constants   []*ConstRule  `yaml:"constants,omitempty" mapstructure:"constants,omitempty" json:"constants,omitempty" toml:"constants,omitempty"`
+	// MaxNameLength, if positive, is the maximum length of a renamed identifier. Longer names are
+	// truncated and suffixed with a hash of the full name to keep them unique. Names given by
+	// explicit rules are kept as written.
+	MaxNameLength int `yaml:"max_name_length,omitempty" mapstructure:"max_name_length,omitempty" json:"max_name_length,omitempty" toml:"max_name_length,omitempty"`
+	// OnlyTagged, if set, restricts generation to source symbols whose doc comment has a line
+	// starting with this tag, such as "adapter:export".
+	OnlyTagged string `yaml:"only_tagged,omitempty" mapstructure:"only_tagged,omitempty" json:"only_tagged,omitempty" toml:"only_tagged,omitempty"`
+	// SkipDeprecated leaves out source symbols whose doc comment has a "Deprecated:" paragraph.
+	SkipDeprecated bool `yaml:"skip_deprecated,omitempty" mapstructure:"skip_deprecated,omitempty" json:"skip_deprecated,omitempty" toml:"skip_deprecated,omitempty"`
+	// TypeMode is the pattern of the type rules that do not set one, and of the types without
+	// a rule: "alias" (the default) re-exports types as aliases, "define" as defined types.
+	TypeMode string `yaml:"type_mode,omitempty" mapstructure:"type_mode,omitempty" json:"type_mode,omitempty" toml:"type_mode,omitempty"`
+	// AllowedImports, if set, lists the import path prefixes of the only packages that may be
+	// re-exported, such as "github.com/example/lib". Adapting any other package is an error.
+	AllowedImports []string `yaml:"allowed_imports,omitempty" mapstructure:"allowed_imports,omitempty" json:"allowed_imports,omitempty" toml:"allowed_imports,omitempty"`
+	// RenameFile, if set, is the base name of the adapter generated from a directive file in place
+	// of the file's own: "package" names it after the adapter's package, any other value is used
+	// as is, so that both give <name>.adapter.go.
+	RenameFile string `yaml:"rename_file,omitempty" mapstructure:"rename_file,omitempty" json:"rename_file,omitempty" toml:"rename_file,omitempty"`
+	// CollisionStrategy is how symbols of different packages given the same name are handled:
+	// "suffix" (the default) numbers the later ones, "error" fails listing them, and "skip" leaves
+	// the later ones out with a warning.
+	CollisionStrategy string `yaml:"collision_strategy,omitempty" mapstructure:"collision_strategy,omitempty" json:"collision_strategy,omitempty" toml:"collision_strategy,omitempty"`
+	// OutputOrder, if set, is the order of the sections of the generated file, among "imports",
+	// "consts", "vars", "types" and "funcs". The sections it leaves out follow in that order.
+	// Imports can only come first, as Go requires.
+	OutputOrder []string `yaml:"output_order,omitempty" mapstructure:"output_order,omitempty" json:"output_order,omitempty" toml:"output_order,omitempty"`
 }
 
 // PropsEntry defines a single variable entry in the config.
@@ -48,13 +80,17 @@ type PropsEntry struct {
 
 // TypeRule defines the set of rules for a single type declaration.
 type TypeRule struct {
-	Name     string        `yaml:"name" mapstructure:"name" json:"name" toml:"name"`
-	Disabled bool          `yaml:"disabled,omitempty" mapstructure:"disabled,omitempty" json:"disabled,omitempty" toml:"disabled,omitempty"`
-	Kind     string        `yaml:"kind,omitempty" mapstructure:"kind,omitempty" json:"kind,omitempty" toml:"kind,omitempty"`
-	Pattern  string        `yaml:"pattern,omitempty" mapstructure:"pattern,omitempty" json:"pattern,omitempty" toml:"pattern,omitempty"`
-	Methods  []*MemberRule `yaml:"methods,omitempty" mapstructure:"methods,omitempty" json:"methods,omitempty" toml:"methods,omitempty"`
-	Fields   []*MemberRule `yaml:"fields,omitempty" mapstructure:"fields,omitempty" json:"fields,omitempty" toml:"fields,omitempty"`
-	RuleSet  `yaml:",inline" mapstructure:",squash" json:",inline" toml:",inline"`
+	Name     string `yaml:"name" mapstructure:"name" json:"name" toml:"name"`
+	Disabled bool   `yaml:"disabled,omitempty" mapstructure:"disabled,omitempty" json:"disabled,omitempty" toml:"disabled,omitempty"`
+	Kind     string `yaml:"kind,omitempty" mapstructure:"kind,omitempty" json:"kind,omitempty" toml:"kind,omitempty"`
+	Pattern  string `yaml:"pattern,omitempty" mapstructure:"pattern,omitempty" json:"pattern,omitempty" toml:"pattern,omitempty"`
+	// Embed, with the "wrap" pattern, re-exports the type as a local struct embedding the source type.
+	Embed bool `yaml:"embed,omitempty" mapstructure:"embed,omitempty" json:"embed,omitempty" toml:"embed,omitempty"`
+	// ExtractInterface, if set, is the name of a local interface generated from the type's exported method set.
+	ExtractInterface string        `yaml:"extract_interface,omitempty" mapstructure:"extract_interface,omitempty" json:"extract_interface,omitempty" toml:"extract_interface,omitempty"`
+	Methods          []*MemberRule `yaml:"methods,omitempty" mapstructure:"methods,omitempty" json:"methods,omitempty" toml:"methods,omitempty"`
+	Fields           []*MemberRule `yaml:"fields,omitempty" mapstructure:"fields,omitempty" json:"fields,omitempty" toml:"fields,omitempty"`
+	RuleSet          `yaml:",inline" mapstructure:",squash" json:",inline" toml:",inline"`
 }
 
 func (t *TypeRule) GetName() string {
@@ -150,11 +186,37 @@ func (m *MemberRule) IsDisabled() bool {
 }
 
 // Transform defines the before and after template strings for renaming.
+// Before is a regular expression matched against the name, and After is its replacement,
+// which may refer to capture groups (e.g. "$1").
 type Transform struct {
 	Before string `yaml:"before,omitempty" mapstructure:"before,omitempty" json:"before,omitempty" toml:"before,omitempty"`
 	After  string `yaml:"after,omitempty" mapstructure:"after,omitempty" json:"after,omitempty" toml:"after,omitempty"`
 }
 
+// Transforms is an ordered pipeline of transform steps; each step is applied to the result
+// of the previous one. For backward compatibility, a single transform object is accepted
+// wherever a list is expected.
+type Transforms []*Transform
+
+// UnmarshalJSON accepts either a list of transforms or a single transform object.
+func (t *Transforms) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var single Transform
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return err
+		}
+		*t = Transforms{&single}
+		return nil
+	}
+	var list []*Transform
+	if err := json.Unmarshal(trimmed, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
 // RuleSet is the fundamental, reusable building block for defining transformation rules.
 type RuleSet struct {
 	//Disabled     bool            `yaml:"disabled,omitempty" mapstructure:"disabled,omitempty" json:"disabled,omitempty" toml:"disabled,omitempty"`
@@ -169,11 +231,17 @@ type RuleSet struct {
 	RegexMode    string          `yaml:"regex_mode,omitempty" mapstructure:"regex_mode,omitempty" json:"regex_mode,omitempty" toml:"regex_mode,omitempty"`
 	Ignores      []string        `yaml:"ignores,omitempty" mapstructure:"ignores,omitempty" json:"ignores,omitempty" toml:"ignores,omitempty"`
 	IgnoresMode  string          `yaml:"ignores_mode,omitempty" mapstructure:"ignores_mode,omitempty" json:"ignores_mode,omitempty" toml:"ignores_mode,omitempty"`
-	Transforms   *Transform      `yaml:"transforms,omitempty" mapstructure:"transforms,omitempty" json:"transforms,omitempty" toml:"transforms,omitempty"`
+	Transforms   Transforms      `yaml:"transforms,omitempty" mapstructure:"transforms,omitempty" json:"transforms,omitempty" toml:"transforms,omitempty"`
 	// Deprecated: use Transforms instead.
 	TransformBefore string `yaml:"transform_before,omitempty" mapstructure:"transform_before,omitempty" json:"transform_before,omitempty" toml:"transform_before,omitempty"`
 	// Deprecated: use Transforms instead.
 	TransformAfter string `yaml:"transform_after,omitempty" mapstructure:"transform_after,omitempty" json:"transform_after,omitempty" toml:"transform_after,omitempty"`
+	// SuffixTemplate is a text/template rendering the suffix from the symbol, e.g.
+	// "From{{title .Package}}". See rules.SuffixTemplateData for the fields available.
+	SuffixTemplate string `yaml:"suffix_template,omitempty" mapstructure:"suffix_template,omitempty" json:"suffix_template,omitempty" toml:"suffix_template,omitempty"`
+	// AffixSeparator is inserted between the name and the prefix, suffix or suffix template of
+	// the rule set, e.g. "_" to turn Foo into My_Foo rather than MyFoo.
+	AffixSeparator string `yaml:"affix_separator,omitempty" mapstructure:"affix_separator,omitempty" json:"affix_separator,omitempty" toml:"affix_separator,omitempty"`
 }
 
 // ExplicitRule defines a direct from/to renaming rule.
@@ -186,21 +254,42 @@ type ExplicitRule struct {
 type RegexRule struct {
 	Pattern string `yaml:"pattern" mapstructure:"pattern" json:"pattern" toml:"pattern"`
 	Replace string `yaml:"replace" mapstructure:"replace" json:"replace" toml:"replace"`
+	// Qualified matches the pattern against "pkg.Name" instead of the bare identifier,
+	// so a global rule can target symbols of a single package.
+	Qualified bool `yaml:"qualified,omitempty" mapstructure:"qualified,omitempty" json:"qualified,omitempty" toml:"qualified,omitempty"`
 }
 
 // Package defines rules and variables for a single package.
 type Package struct {
-	Import    string        `yaml:"import" mapstructure:"import" json:"import" toml:"import"`
-	Path      string        `yaml:"path,omitempty" mapstructure:"path,omitempty" json:"path,omitempty" toml:"path,omitempty"`
-	Alias     string        `yaml:"alias,omitempty" mapstructure:"alias,omitempty" json:"alias,omitempty" toml:"alias,omitempty"`
-	Props     []*PropsEntry `yaml:"props,omitempty" mapstructure:"props,omitempty" json:"props,omitempty" toml:"props,omitempty"`
-	Types     []*TypeRule   `yaml:"types,omitempty" mapstructure:"types,omitempty" json:"types,omitempty" toml:"types,omitempty"`
-	Functions []*FuncRule   `yaml:"functions,omitempty" mapstructure:"functions,omitempty" json:"functions,omitempty" toml:"functions,omitempty"`
-	Variables []*VarRule    `yaml:"variables,omitempty" mapstructure:"variables,omitempty" json:"variables,omitempty" toml:"variables,omitempty"`
-	Constants []*ConstRule  `yaml:"constants,omitempty" mapstructure:"constants,omitempty" json:"constants,omitempty" toml:"constants,omitempty"`
+	Import      string           `yaml:"import" mapstructure:"import" json:"import" toml:"import"`
+	Path        string           `yaml:"path,omitempty" mapstructure:"path,omitempty" json:"path,omitempty" toml:"path,omitempty"`
+	Alias       string           `yaml:"alias,omitempty" mapstructure:"alias,omitempty" json:"alias,omitempty" toml:"alias,omitempty"`
+	Version     string           `yaml:"version,omitempty" mapstructure:"version,omitempty" json:"version,omitempty" toml:"version,omitempty"`
+	ForceAlias  string           `yaml:"force_alias,omitempty" mapstructure:"force_alias,omitempty" json:"force_alias,omitempty" toml:"force_alias,omitempty"`
+	Registry    string           `yaml:"registry,omitempty" mapstructure:"registry,omitempty" json:"registry,omitempty" toml:"registry,omitempty"`
+	Instantiate []*Instantiation `yaml:"instantiate,omitempty" mapstructure:"instantiate,omitempty" json:"instantiate,omitempty" toml:"instantiate,omitempty"`
+	Defaults    *Defaults        `yaml:"defaults,omitempty" mapstructure:"defaults,omitempty" json:"defaults,omitempty" toml:"defaults,omitempty"`
+	Props       []*PropsEntry    `yaml:"props,omitempty" mapstructure:"props,omitempty" json:"props,omitempty" toml:"props,omitempty"`
+	Types       []*TypeRule      `yaml:"types,omitempty" mapstructure:"types,omitempty" json:"types,omitempty" toml:"types,omitempty"`
+	Functions   []*FuncRule      `yaml:"functions,omitempty" mapstructure:"functions,omitempty" json:"functions,omitempty" toml:"functions,omitempty"`
+	Variables   []*VarRule       `yaml:"variables,omitempty" mapstructure:"variables,omitempty" json:"variables,omitempty" toml:"variables,omitempty"`
+	Constants   []*ConstRule     `yaml:"constants,omitempty" mapstructure:"constants,omitempty" json:"constants,omitempty" toml:"constants,omitempty"`
+}
+
+// Instantiation names a generic type of the package, the type arguments to instantiate it
+// with, and the name of the generated alias, e.g. "type StringWorker = source.GenericWorker[string]".
+// Arguments are resolved in the scope of the source package.
+type Instantiation struct {
+	Type string   `yaml:"type" mapstructure:"type" json:"type" toml:"type"`
+	Args []string `yaml:"args" mapstructure:"args" json:"args" toml:"args"`
+	As   string   `yaml:"as" mapstructure:"as" json:"as" toml:"as"`
+	// Func names a generic function instead of Type, which is then re-exported as a non-generic
+	// wrapper named As, e.g. "func MapStringInt(ts []string, fn func(string) int) []int".
+	Func string `yaml:"func,omitempty" mapstructure:"func,omitempty" json:"func,omitempty" toml:"func,omitempty"`
 }
 
 // Defaults defines the global default behaviors for the entire system.
+// A package may declare its own defaults, which take precedence for the rules within that package.
 type Defaults struct {
 	Mode *Mode `yaml:"mode,omitempty" mapstructure:"mode,omitempty" json:"mode,omitempty" toml:"mode,omitempty"`
 }
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransforms_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Transforms
+	}{
+		{
+			name:     "single object",
+			input:    `{"transforms": {"before": "^Old(.*)$", "after": "$1"}}`,
+			expected: Transforms{{Before: "^Old(.*)$", After: "$1"}},
+		},
+		{
+			name:  "list",
+			input: `{"transforms": [{"before": "^Old(.*)$", "after": "$1"}, {"before": "(.*)", "after": "${1}V2"}]}`,
+			expected: Transforms{
+				{Before: "^Old(.*)$", After: "$1"},
+				{Before: "(.*)", After: "${1}V2"},
+			},
+		},
+		{
+			name:     "absent",
+			input:    `{}`,
+			expected: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rs RuleSet
+			require.NoError(t, json.Unmarshal([]byte(tt.input), &rs))
+			assert.Equal(t, tt.expected, rs.Transforms)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+// MergeRules adds the rule-bearing parts of shared beneath those of c: its global type,
+// function, variable and constant rules, its ignores and its default modes. The rules of
+// shared come after those of c, so that an explicit rule of c wins over one of shared
+// for the same name, and a default mode of shared only applies where c sets none.
+// The packages, props and other settings of shared are not used. shared is not modified.
+func (c *Config) MergeRules(shared *Config) {
+	if shared == nil {
+		return
+	}
+	c.Types = append(c.Types, cloneSlice(shared.Types, (*TypeRule).Clone)...)
+	c.Functions = append(c.Functions, cloneSlice(shared.Functions, (*FuncRule).Clone)...)
+	c.Variables = append(c.Variables, cloneSlice(shared.Variables, (*VarRule).Clone)...)
+	c.Constants = append(c.Constants, cloneSlice(shared.Constants, (*ConstRule).Clone)...)
+	c.Ignores = append(c.Ignores, shared.Ignores...)
+
+	if shared.Defaults == nil || shared.Defaults.Mode == nil {
+		return
+	}
+	if c.Defaults == nil {
+		c.Defaults = NewDefaults()
+	}
+	if c.Defaults.Mode == nil {
+		c.Defaults.Mode = &Mode{}
+	}
+	mode, sharedMode := c.Defaults.Mode, shared.Defaults.Mode
+	for _, field := range []struct{ local, shared *string }{
+		{&mode.Strategy, &sharedMode.Strategy},
+		{&mode.Prefix, &sharedMode.Prefix},
+		{&mode.Suffix, &sharedMode.Suffix},
+		{&mode.Explicit, &sharedMode.Explicit},
+		{&mode.Regex, &sharedMode.Regex},
+		{&mode.Ignores, &sharedMode.Ignores},
+	} {
+		if *field.local == "" {
+			*field.local = *field.shared
+		}
+	}
+}
+
+// Merge layers override over c, as for a repository configuration over an organization-wide
+// base. The rules, ignores and default modes of override are merged over those of c as by
+// MergeRules, so its rules win. A package or prop of override replaces the one of c with the
+// same import path or name, and the settings override sets replace those of c. override is
+// not modified.
+func (c *Config) Merge(override *Config) {
+	if override == nil {
+		return
+	}
+	layered := override.Clone()
+	layered.MergeRules(c)
+	c.Types, c.Functions, c.Variables, c.Constants = layered.Types, layered.Functions, layered.Variables, layered.Constants
+	c.Ignores = layered.Ignores
+	if layered.Defaults != nil {
+		c.Defaults = layered.Defaults
+	}
+
+	c.Packages = mergeByKey(c.Packages, layered.Packages, func(p *Package) string { return p.Import })
+	c.Props = mergeByKey(c.Props, layered.Props, func(p *PropsEntry) string { return p.Name })
+
+	if override.PackageName != "" {
+		c.PackageName = override.PackageName
+	}
+	if override.MaxNameLength != 0 {
+		c.MaxNameLength = override.MaxNameLength
+	}
+	if override.OnlyTagged != "" {
+		c.OnlyTagged = override.OnlyTagged
+	}
+	if override.SkipDeprecated {
+		c.SkipDeprecated = true
+	}
+	if override.TypeMode != "" {
+		c.TypeMode = override.TypeMode
+	}
+	if len(override.AllowedImports) > 0 {
+		c.AllowedImports = layered.AllowedImports
+	}
+	if override.RenameFile != "" {
+		c.RenameFile = override.RenameFile
+	}
+	if override.CollisionStrategy != "" {
+		c.CollisionStrategy = override.CollisionStrategy
+	}
+	if len(override.OutputOrder) > 0 {
+		c.OutputOrder = layered.OutputOrder
+	}
+}
+
+// mergeByKey returns base with each element replaced by the element of override with the same
+// key, followed by the elements of override whose key is not in base.
+func mergeByKey[T any](base, override []T, key func(T) string) []T {
+	index := make(map[string]int, len(base))
+	for i, elem := range base {
+		index[key(elem)] = i
+	}
+	for _, elem := range override {
+		if i, ok := index[key(elem)]; ok {
+			base[i] = elem
+		} else {
+			index[key(elem)] = len(base)
+			base = append(base, elem)
+		}
+	}
+	return base
+}
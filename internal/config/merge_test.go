@@ -0,0 +1,76 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_MergeRules(t *testing.T) {
+	local := New()
+	local.PackageName = "adapters"
+	local.Packages = []*Package{{Import: "example.com/local"}}
+	local.Types = []*TypeRule{{Name: "Client", RuleSet: RuleSet{Explicit: []*ExplicitRule{{From: "Client", To: "LocalClient"}}}}}
+	local.Defaults = &Defaults{Mode: &Mode{Prefix: "append"}}
+
+	shared := &Config{
+		PackageName: "shared",
+		Packages:    []*Package{{Import: "example.com/shared"}},
+		Ignores:     []string{"re:^Test"},
+		Defaults:    &Defaults{Mode: &Mode{Prefix: "replace", Explicit: "merge"}},
+		Types:       []*TypeRule{{Name: "*", RuleSet: RuleSet{Prefix: "Shared"}}},
+		Functions:   []*FuncRule{{Name: "*", RuleSet: RuleSet{Suffix: "Func"}}},
+		Variables:   []*VarRule{{Name: "*", RuleSet: RuleSet{Prefix: "Var"}}},
+		Constants:   []*ConstRule{{Name: "*", RuleSet: RuleSet{Prefix: "Const"}}},
+	}
+	local.MergeRules(shared)
+
+	assert.Equal(t, "adapters", local.PackageName, "only rules are merged")
+	require.Len(t, local.Packages, 1)
+	assert.Equal(t, "example.com/local", local.Packages[0].Import, "only rules are merged")
+	require.Len(t, local.Types, 2)
+	assert.Equal(t, "Client", local.Types[0].Name, "local rules come first")
+	assert.Equal(t, "Shared", local.Types[1].Prefix)
+	assert.Len(t, local.Functions, 1)
+	assert.Len(t, local.Variables, 1)
+	assert.Len(t, local.Constants, 1)
+	assert.Equal(t, []string{"re:^Test"}, local.Ignores)
+	assert.Equal(t, &Mode{Prefix: "append", Explicit: "merge"}, local.Defaults.Mode, "local default modes win")
+
+	local.Types[1].Prefix = "mutated"
+	assert.Equal(t, "Shared", shared.Types[0].Prefix, "shared must not be modified")
+}
+
+func TestConfig_Merge(t *testing.T) {
+	base := New()
+	base.PackageName = "base"
+	base.OnlyTagged = "adapter:export"
+	base.Ignores = []string{"re:^Test"}
+	base.Packages = []*Package{{Import: "example.com/lib", Alias: "lib"}, {Import: "example.com/util"}}
+	base.Types = []*TypeRule{{Name: "*", RuleSet: RuleSet{Prefix: "Org"}}}
+	base.Defaults = &Defaults{Mode: &Mode{Prefix: "replace", Explicit: "merge"}}
+
+	override := &Config{
+		PackageName: "adapters",
+		Packages:    []*Package{{Import: "example.com/lib", Alias: "core"}, {Import: "example.com/extra"}},
+		Types:       []*TypeRule{{Name: "*", RuleSet: RuleSet{Prefix: "Repo"}}},
+		Defaults:    &Defaults{Mode: &Mode{Prefix: "append"}},
+	}
+	base.Merge(override)
+
+	assert.Equal(t, "adapters", base.PackageName, "the settings of the override win")
+	assert.Equal(t, "adapter:export", base.OnlyTagged, "settings the override leaves unset are kept")
+	assert.Equal(t, []string{"re:^Test"}, base.Ignores)
+	require.Len(t, base.Packages, 3)
+	assert.Equal(t, "core", base.Packages[0].Alias, "a package of the override replaces the base one")
+	assert.Equal(t, "example.com/util", base.Packages[1].Import)
+	assert.Equal(t, "example.com/extra", base.Packages[2].Import)
+	require.Len(t, base.Types, 2)
+	assert.Equal(t, "Repo", base.Types[0].Prefix, "the rules of the override come first and win")
+	assert.Equal(t, "Org", base.Types[1].Prefix)
+	assert.Equal(t, &Mode{Prefix: "append", Explicit: "merge"}, base.Defaults.Mode, "the default modes of the override win")
+
+	base.Packages[0].Alias = "mutated"
+	assert.Equal(t, "core", override.Packages[0].Alias, "override must not be modified")
+}
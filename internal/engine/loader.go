@@ -1,7 +1,6 @@
 package engine
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"go/ast"
@@ -10,7 +9,6 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/origadmin/adptool/internal/config"
 	"github.com/origadmin/adptool/internal/loader"
@@ -115,7 +113,7 @@ func (l *Loader) Load(ctx context.Context, paths []string) (*LoadContext, error)
 				}
 
 				// Check if file contains //go:adapter directive
-				hasAdapter, err := hasAdapterDirective(filePath)
+				hasAdapter, err := adpparser.FileHasDirective(filePath)
 				if err != nil {
 					l.logger.Warn("Failed to check adapter directive", "file", filePath, "error", err)
 					return nil
@@ -158,7 +156,8 @@ func (l *Loader) LoadConfig(path string) (*config.Config, error) {
 	return loader.LoadConfigFile(path)
 }
 
-// hasAdapterDirective checks if a file contains the //go:adapter directive.
+// hasAdapterDirective checks if a file of the loader's filesystem contains a //go:adapter
+// directive or the bare marker, as adpparser.FileHasDirective does for files on disk.
 func (l *Loader) hasAdapterDirective(filePath string) (bool, error) {
 	// When using a mock filesystem, we need to read from the fs.FS
 	file, err := l.fs.Open(filePath)
@@ -167,34 +166,7 @@ func (l *Loader) hasAdapterDirective(filePath string) (bool, error) {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "//go:adapter") {
-			return true, nil
-		}
-	}
-
-	return false, scanner.Err()
-}
-
-// hasAdapterDirective checks if a file contains the //go:adapter directive.
-func hasAdapterDirective(filePath string) (bool, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return false, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "//go:adapter") {
-			return true, nil
-		}
-	}
-
-	return false, scanner.Err()
+	return adpparser.HasDirective(file)
 }
 
 // FileSystemParser implements the Parser interface using the file system.
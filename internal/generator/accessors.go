@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log/slog"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
+// hasValueSemantics reports whether assigning a value of type t copies its state, so that a
+// re-exported `var X = pkg.X` would not see later changes made through the source variable.
+// Pointers, slices, maps, channels, functions and interfaces share their referent and are
+// safe to re-export as variables, as long as the source variable itself is not reassigned.
+func hasValueSemantics(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Basic, *types.Struct, *types.Array:
+		return true
+	default:
+		return false
+	}
+}
+
+// collectVariables re-exports the exported variables of genDecl. Variables with value
+// semantics get accessor functions; the others are re-exported as variables.
+func (c *Collector) collectVariables(genDecl *ast.GenDecl, typesPkg *types.Package, importPath, importAlias string) {
+	for _, spec := range genDecl.Specs {
+		valueSpec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		for _, name := range valueSpec.Names {
+			if !name.IsExported() || c.isIgnored(interfaces.RuleTypeVar, importPath, name.Name) {
+				continue
+			}
+			if v, ok := typesPkg.Scope().Lookup(name.Name).(*types.Var); ok && c.collectVarAccessors(v, typesPkg, importPath, importAlias) {
+				continue
+			}
+			c.collectValueDeclaration(valueDeclFromTypes(token.VAR, name.Name), importPath, importAlias, token.VAR)
+		}
+	}
+}
+
+// collectVarAccessors adds GetX and SetX functions forwarding to the source variable X, which
+// keep sharing its state where a copy would not. It returns false if v has no value semantics
+// or its type cannot be spelled in the generated file.
+func (c *Collector) collectVarAccessors(v *types.Var, typesPkg *types.Package, importPath, importAlias string) bool {
+	if !hasValueSemantics(v.Type()) {
+		return false
+	}
+	b := newTypeExprBuilder(typesPkg, importAlias, c.importerPath)
+	getterType, setterType := b.expr(v.Type()), b.expr(v.Type())
+	if b.invalid {
+		slog.Debug("Re-exporting variable as a copy because its type cannot be spelled", "func", "Collector.collectVarAccessors", "variable", v.Name())
+		return false
+	}
+	c.addImports(b.imports)
+
+	source := func() ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent(importAlias), Sel: ast.NewIdent(v.Name())}
+	}
+	getter := &ast.FuncDecl{
+		Name: ast.NewIdent("Get" + v.Name()),
+		Type: &ast.FuncType{
+			Params:  &ast.FieldList{},
+			Results: &ast.FieldList{List: []*ast.Field{{Type: getterType}}},
+		},
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ReturnStmt{Results: []ast.Expr{source()}}}},
+	}
+	setter := &ast.FuncDecl{
+		Name: ast.NewIdent("Set" + v.Name()),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{ast.NewIdent("v")}, Type: setterType}}},
+		},
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.AssignStmt{
+			Lhs: []ast.Expr{source()},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{ast.NewIdent("v")},
+		}}},
+	}
+
+	if c.allPackageDecls[importPath] == nil {
+		c.allPackageDecls[importPath] = &packageDecls{}
+	}
+	c.allPackageDecls[importPath].funcDecls = append(c.allPackageDecls[importPath].funcDecls, getter, setter)
+	return true
+}
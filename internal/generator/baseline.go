@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// LoadBaseline parses a previously generated adapter file and returns the set of
+// top-level names it already declares. Methods are not included, as the generator
+// only re-exports package-level declarations.
+func LoadBaseline(filePath string) (map[string]bool, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse baseline file %s: %w", filePath, err)
+	}
+
+	return declaredNames(file), nil
+}
+
+// declaredNames returns the top-level names declared in file, excluding methods.
+func declaredNames(file *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names[s.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						names[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return names
+}
@@ -4,27 +4,36 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
+	"go/format"
+	"go/parser"
 	"go/printer"
 	"go/token"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
 	"github.com/origadmin/adptool/internal/util"
 )
 
+// GeneratedBanner is the no-edit line written at the top of every file generated with the
+// default header. It follows the "Code generated ... DO NOT EDIT." convention recognized by Go tools.
+const GeneratedBanner = "// Code generated by adptool. DO NOT EDIT."
+
 // DefaultHeaderTemplate is the built-in template for the generated file header.
-// It includes the standard "Code generated by ... DO NOT EDIT." line recognized by Go tools.
+// It includes the GeneratedBanner line.
 const DefaultHeaderTemplate = `{{if .CopyrightHolder}}// Copyright {{.Year}} {{.CopyrightHolder}}. All rights reserved.
 //
-{{end}}// Code generated by adptool. DO NOT EDIT.
+{{end}}` + GeneratedBanner + `
 //
-// This file is generated from {{.SourceFile}}.
+// This file is generated from {{.SourceFile}}{{if .ToolVersion}} by adptool {{.ToolVersion}}{{end}}.
 `
 
 // Builder is responsible for building the output file from collected declarations.
@@ -37,6 +46,120 @@ type Builder struct {
 	headerTemplate  string // Header template string
 	copyrightHolder string
 	writer          io.Writer
+	// baseline holds names already present in a previous adapter; they are skipped when building.
+	baseline map[string]bool
+	// foldDecls groups consecutive const, var and type declarations into blocks.
+	foldDecls bool
+	// lineEnding is the line terminator used in the written output.
+	lineEnding LineEnding
+	// fileMode is the permission of the written output file.
+	fileMode os.FileMode
+	// nolint lists the linters suppressed on each declaration by a //nolint comment; none if empty.
+	nolint []string
+	// blockComments maps a declaration token (const, var or type) to the comment written above
+	// the first declaration of that kind, i.e. above the whole block when declarations are folded.
+	blockComments map[token.Token]string
+	// groupByPackage lists the declarations of each source package together, under a heading
+	// comment, instead of listing all constants, variables, types and functions in turn.
+	groupByPackage bool
+	// importVersions annotates the imports of the source packages with their versions.
+	importVersions bool
+	// splitGenerics writes the declarations using generics to a file constrained to Go 1.18 and
+	// later, next to a fallback file without them for older toolchains.
+	splitGenerics bool
+	// buildConstraint, if set, is the //go:build expression written at the top of the output.
+	buildConstraint string
+	// renameTable writes a comment mapping the source names of renamed symbols to their new names.
+	renameTable bool
+	// renames lists the lines of the rename table of the last call to Build.
+	renames []string
+	// emitTests writes a test file next to the output file, referencing every generated symbol.
+	emitTests bool
+	// testRefs lists the references of the test file of the last call to Build.
+	testRefs []testReference
+	// astDump, if set, receives the node structure of the generated file before it is printed.
+	astDump io.Writer
+	// nilInterfaceVars declares a typed nil variable, such as NilReader, for each generated interface.
+	nilInterfaceVars bool
+	// toolVersion, if set, is the version of adptool written in the header.
+	toolVersion string
+	// registerFunc, if set, is called by an init function with the name and zero value of each generated type.
+	registerFunc RegisterFunc
+	// generateDirectives are the commands of the //go:generate directives written in the header.
+	generateDirectives []string
+	// strictIdentifiers rejects generated names that are not ASCII exported identifiers.
+	strictIdentifiers bool
+	// lenient reports generated names that are Go keywords or predeclared identifiers as
+	// warnings instead of errors.
+	lenient bool
+	// outputOrder lists the sections of the output file ("consts", "vars", "types" and "funcs")
+	// in the order they are written; the sections it leaves out follow in the default order.
+	outputOrder []string
+	// collisionStrategy is how symbols given the same name are handled; "" is CollisionSuffix.
+	collisionStrategy CollisionStrategy
+	// collisions lists the names given to more than one symbol by the last call to Build, with
+	// the import paths of the symbols, if the collision strategy is CollisionError.
+	collisions []string
+	// manifest lists the symbols declared by the last call to Build.
+	manifest []ManifestSymbol
+	// packageHeadings maps the first declaration of each source package, if grouped by package,
+	// to its import path.
+	packageHeadings map[ast.Decl]string
+	// importComments maps import path to the comment written after its import, if any.
+	importComments map[string]string
+}
+
+// LineEnding selects the line terminator of the generated file.
+type LineEnding string
+
+const (
+	// LineEndingLF terminates lines with "\n". It is the default.
+	LineEndingLF LineEnding = "lf"
+	// LineEndingCRLF terminates lines with "\r\n".
+	LineEndingCRLF LineEnding = "crlf"
+)
+
+// ParseLineEnding parses a line ending name, "lf" or "crlf", case-insensitively.
+func ParseLineEnding(s string) (LineEnding, error) {
+	switch ending := LineEnding(strings.ToLower(s)); ending {
+	case LineEndingLF, LineEndingCRLF:
+		return ending, nil
+	default:
+		return "", fmt.Errorf("unknown line ending %q, expected \"lf\" or \"crlf\"", s)
+	}
+}
+
+// convert rewrites the line terminators of data. Existing CRLF pairs are kept as a single terminator.
+func (e LineEnding) convert(data []byte) []byte {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if e == LineEndingCRLF {
+		data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+	}
+	return data
+}
+
+// CollisionStrategy selects how symbols of different packages given the same name are handled.
+type CollisionStrategy string
+
+const (
+	// CollisionSuffix numbers the later symbols of a name, such as Worker1. It is the default.
+	CollisionSuffix CollisionStrategy = "suffix"
+	// CollisionError fails the build, listing the names given to more than one symbol.
+	CollisionError CollisionStrategy = "error"
+	// CollisionSkip leaves the later symbols of a name out, with a warning.
+	CollisionSkip CollisionStrategy = "skip"
+)
+
+// DefaultFileMode is the permission of generated files unless set with WithFileMode.
+const DefaultFileMode os.FileMode = 0644
+
+// ParseFileMode parses an octal permission, such as "0644" or "640", for a generated file.
+func ParseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid file mode %q, expected octal permission bits such as 0644", s)
+	}
+	return os.FileMode(mode), nil
 }
 
 // NewBuilder creates a new Builder.
@@ -49,17 +172,193 @@ func NewBuilder(packageName string, outputFilePath string, copyrightHolder strin
 			Decls: []ast.Decl{},
 		},
 		formatCode:      true,
+		foldDecls:       true,
+		lineEnding:      LineEndingLF,
+		fileMode:        DefaultFileMode,
 		headerTemplate:  DefaultHeaderTemplate, // Use the built-in default template
 		copyrightHolder: copyrightHolder,
 	}
 }
 
+// Reset clears the header and declarations of the last build, keeping the configuration.
+func (b *Builder) Reset() {
+	b.fset = token.NewFileSet()
+	b.aliasFile = &ast.File{Name: b.aliasFile.Name, Decls: []ast.Decl{}}
+	b.header = ""
+}
+
 // WithFormatCode sets whether to automatically format after generating code
 func (b *Builder) WithFormatCode(format bool) *Builder {
 	b.formatCode = format
 	return b
 }
 
+// WithFoldDecls sets whether consecutive const, var and type declarations are grouped
+// into a single parenthesized block. It is enabled by default.
+func (b *Builder) WithFoldDecls(fold bool) *Builder {
+	b.foldDecls = fold
+	return b
+}
+
+// WithLineEnding sets the line terminator of the written output. It is applied after
+// formatting, as the last step before the output is final.
+func (b *Builder) WithLineEnding(ending LineEnding) *Builder {
+	b.lineEnding = ending
+	return b
+}
+
+// WithGroupByPackage sets whether the declarations of each source package are listed together,
+// preceded by a "// from <import path>" comment. By default, all constants are listed first,
+// then all variables, types and functions, each sorted by import path and name.
+func (b *Builder) WithGroupByPackage(group bool) *Builder {
+	b.groupByPackage = group
+	return b
+}
+
+// WithImportVersions sets whether the import of each source package is annotated with the version
+// of its module, as in `source "github.com/example/lib" // v1.2.3`, so reviewers see where the
+// re-exported code comes from. Packages of the main module have no version and no comment.
+func (b *Builder) WithImportVersions(annotate bool) *Builder {
+	b.importVersions = annotate
+	return b
+}
+
+// WithRenameTable sets whether a comment below the header maps the source name of each renamed
+// symbol to its generated name, such as `source.Worker -> Processor`, for readers to see the
+// renames without comparing with the source. Symbols keeping their source name are not listed.
+func (b *Builder) WithRenameTable(table bool) *Builder {
+	b.renameTable = table
+	return b
+}
+
+// WithEmitTests sets whether a test file is written next to the output file, such as
+// source.adapter_test.go, referencing every generated symbol so that `go test` fails to compile
+// if one is removed or changes kind. Generic symbols are instantiated with type arguments
+// satisfying their constraints.
+func (b *Builder) WithEmitTests(emit bool) *Builder {
+	b.emitTests = emit
+	return b
+}
+
+// WithGenerateDirectives sets the commands written as //go:generate directives in the header of
+// the output file, e.g. to regenerate it with `go generate`.
+func (b *Builder) WithGenerateDirectives(commands ...string) *Builder {
+	b.generateDirectives = commands
+	return b
+}
+
+// WithSplitGenerics sets whether the output file is constrained with //go:build go1.18 when some
+// declarations use generics, and a fallback file, named by inserting "_nogenerics" before the
+// ".go" extension, is written without them under //go:build !go1.18. It has no effect on the
+// output written to a writer.
+func (b *Builder) WithSplitGenerics(split bool) *Builder {
+	b.splitGenerics = split
+	return b
+}
+
+// WithWriter sets a writer that receives the generated code instead of the output file. The
+// code written to it is not formatted with goimports.
+func (b *Builder) WithWriter(w io.Writer) *Builder {
+	b.writer = w
+	return b
+}
+
+// WithNilInterfaceVars sets whether a typed nil variable is declared for each generated
+// interface, such as `var NilReader Reader`, e.g. to pass for an unused dependency when setting
+// up mocks in tests. Generic interfaces and constraints get none.
+func (b *Builder) WithNilInterfaceVars(nilVars bool) *Builder {
+	b.nilInterfaceVars = nilVars
+	return b
+}
+
+// WithRegisterFunc sets the function that an init function of the generated file calls with
+// the name and zero value of each generated type, such as `codec.Register("Client", Client{})`.
+// Generic types and interfaces are not registered. The zero RegisterFunc emits no init function.
+func (b *Builder) WithRegisterFunc(fn RegisterFunc) *Builder {
+	b.registerFunc = fn
+	return b
+}
+
+// WithASTDump sets a writer that receives the *ast.File of the generated code, printed with
+// ast.Fprint before it is printed as code and formatted, to debug the nodes the generator builds.
+func (b *Builder) WithASTDump(w io.Writer) *Builder {
+	b.astDump = w
+	return b
+}
+
+// WithStrictIdentifiers sets whether generated names must be ASCII exported identifiers. Go
+// accepts Unicode identifiers, so without it a rename to, e.g., "Wörker" is generated as is.
+func (b *Builder) WithStrictIdentifiers(strict bool) *Builder {
+	b.strictIdentifiers = strict
+	return b
+}
+
+// WithLenient sets whether a rule renaming a symbol to a Go keyword or predeclared identifier is
+// reported as a warning instead of failing the build.
+func (b *Builder) WithLenient(lenient bool) *Builder {
+	b.lenient = lenient
+	return b
+}
+
+// WithOutputOrder sets the order of the sections of the output file, among "imports", "consts",
+// "vars", "types" and "funcs"; by default, constants come first, then variables, types and
+// functions. The sections left out follow in the default order. The imports always come first.
+func (b *Builder) WithOutputOrder(sections ...string) *Builder {
+	b.outputOrder = b.outputOrder[:0]
+	for _, section := range sections {
+		if section != "imports" {
+			b.outputOrder = append(b.outputOrder, section)
+		}
+	}
+	return b
+}
+
+// WithCollisionStrategy sets how symbols given the same name, usually by different packages, are
+// handled: numbered (the default), reported as an error, or left out after the first one, in
+// the order of their import paths.
+func (b *Builder) WithCollisionStrategy(strategy CollisionStrategy) *Builder {
+	b.collisionStrategy = strategy
+	return b
+}
+
+// WithFileMode sets the permission of the written output file. It is set explicitly, so it is
+// not narrowed by the process umask. A zero mode keeps DefaultFileMode.
+func (b *Builder) WithFileMode(mode os.FileMode) *Builder {
+	if mode == 0 {
+		mode = DefaultFileMode
+	}
+	b.fileMode = mode
+	return b
+}
+
+// WithNolint sets the linters, such as "unused" or "revive", suppressed on each generated
+// declaration by a //nolint:<linters> comment. No comment is written if linters is empty.
+func (b *Builder) WithNolint(linters ...string) *Builder {
+	b.nolint = linters
+	return b
+}
+
+// WithBlockComment sets a comment, such as "Re-exported constants from source", written above
+// the block of tok declarations (token.CONST, token.VAR or token.TYPE). Each line of comment
+// is prefixed with "// " unless it is already a comment. An empty comment removes it.
+func (b *Builder) WithBlockComment(tok token.Token, comment string) *Builder {
+	if b.blockComments == nil {
+		b.blockComments = make(map[token.Token]string)
+	}
+	if comment == "" {
+		delete(b.blockComments, tok)
+		return b
+	}
+	lines := strings.Split(strings.TrimRight(comment, "\n"), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "//") {
+			lines[i] = strings.TrimRight("// "+line, " ")
+		}
+	}
+	b.blockComments[tok] = strings.Join(lines, "\n")
+	return b
+}
+
 // WithHeaderTemplate sets a custom header template.
 func (b *Builder) WithHeaderTemplate(headerTemplate string) *Builder {
 	if headerTemplate != "" {
@@ -68,6 +367,20 @@ func (b *Builder) WithHeaderTemplate(headerTemplate string) *Builder {
 	return b
 }
 
+// WithToolVersion sets the version of adptool that the header records as having generated the
+// file, available to header templates as {{.ToolVersion}}. An empty version is not written.
+func (b *Builder) WithToolVersion(version string) *Builder {
+	b.toolVersion = version
+	return b
+}
+
+// WithBaseline sets the names already declared by an existing adapter.
+// Declarations whose final name is in the baseline are omitted from the output.
+func (b *Builder) WithBaseline(names map[string]bool) *Builder {
+	b.baseline = names
+	return b
+}
+
 // RenderHeader executes the header template with the given source file name.
 func (b *Builder) RenderHeader(sourceFile string) error {
 	tmpl, err := template.New("header").Parse(b.headerTemplate)
@@ -79,10 +392,12 @@ func (b *Builder) RenderHeader(sourceFile string) error {
 		Year            int
 		SourceFile      string
 		CopyrightHolder string
+		ToolVersion     string
 	}{
 		Year:            time.Now().Year(),
 		SourceFile:      sourceFile,
 		CopyrightHolder: b.copyrightHolder,
+		ToolVersion:     b.toolVersion,
 	}
 
 	var buf bytes.Buffer
@@ -108,6 +423,25 @@ type sortedDecl struct {
 	name       string
 }
 
+// versionComments returns the package doc lines recording the version of each source package,
+// sorted by import path.
+func versionComments(versions map[string]string) []*ast.Comment {
+	if len(versions) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(versions))
+	for importPath := range versions {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+
+	comments := []*ast.Comment{{Text: "//"}}
+	for _, importPath := range paths {
+		comments = append(comments, &ast.Comment{Text: fmt.Sprintf("// Source: %s@%s", importPath, versions[importPath])})
+	}
+	return comments
+}
+
 // Build builds the output file structure from the collected data.
 func (b *Builder) Build(c *Collector) {
 	var orderedDecls []ast.Decl
@@ -120,21 +454,40 @@ func (b *Builder) Build(c *Collector) {
 				{Text: commentText},
 			},
 		}
+		b.aliasFile.Doc.List = append(b.aliasFile.Doc.List, versionComments(c.versions)...)
 	}
 
 	importDecl := b.buildImportDeclaration(c.importSpecs)
+	b.importComments = nil
+	if b.importVersions {
+		b.importComments = c.versions
+	}
 	if len(importDecl.(*ast.GenDecl).Specs) > 0 {
 		orderedDecls = append(orderedDecls, importDecl)
 	}
 
 	// Generate the map of original identifiers to their new, unique names.
 	nameMap := b.collectAndResolveNames(c.allPackageDecls)
+	// References to generated types, such as the results of constructors, follow their names.
+	// A type left out as a collision is referred to by its source type instead, and the stubs
+	// of its methods, whose receivers have no source type to name, are left out with it.
+	for _, pkgDecls := range c.allPackageDecls {
+		for ref, target := range pkgDecls.localTypeRefs {
+			if name, ok := nameMap[target]; ok {
+				ref.Name = name
+			} else {
+				ref.Name = pkgDecls.localTypeSources[ref]
+			}
+		}
+	}
 
 	// Create intermediate lists to hold declarations with their metadata for sorting.
 	var constsToSort []sortedSpec
 	var varsToSort []sortedSpec
 	var typesToSort []sortedSpec
 	var funcsToSort []sortedDecl
+	// registryTypes lists, by import path, the generated names of the types of the packages with a registry.
+	registryTypes := make(map[string][]string)
 
 	// Iterate through all packages to populate the intermediate lists.
 	for importPath, pkgDecls := range c.allPackageDecls {
@@ -144,7 +497,10 @@ func (b *Builder) Build(c *Collector) {
 				for _, spec := range genDecl.Specs {
 					if valSpec, ok := spec.(*ast.ValueSpec); ok {
 						for _, name := range valSpec.Names {
-							newName := nameMap[name]
+							newName, ok := nameMap[name]
+							if !ok || b.baseline[newName] {
+								continue
+							}
 							newSpec := *valSpec // copy
 							newSpec.Names = []*ast.Ident{ast.NewIdent(newName)}
 							constsToSort = append(constsToSort, sortedSpec{spec: &newSpec, importPath: importPath, name: newName})
@@ -159,7 +515,10 @@ func (b *Builder) Build(c *Collector) {
 				for _, spec := range genDecl.Specs {
 					if valSpec, ok := spec.(*ast.ValueSpec); ok {
 						for _, name := range valSpec.Names {
-							newName := nameMap[name]
+							newName, ok := nameMap[name]
+							if !ok || b.baseline[newName] {
+								continue
+							}
 							newSpec := *valSpec // copy
 							newSpec.Names = []*ast.Ident{ast.NewIdent(newName)}
 							varsToSort = append(varsToSort, sortedSpec{spec: &newSpec, importPath: importPath, name: newName})
@@ -171,21 +530,51 @@ func (b *Builder) Build(c *Collector) {
 		// Populate types
 		for _, spec := range pkgDecls.typeSpecs {
 			if typeSpec, ok := spec.(*ast.TypeSpec); ok {
-				newName := nameMap[typeSpec.Name]
+				newName, ok := nameMap[typeSpec.Name]
+				if !ok || b.baseline[newName] {
+					continue
+				}
 				newSpec := *typeSpec // copy
 				newSpec.Name = ast.NewIdent(newName)
 				typesToSort = append(typesToSort, sortedSpec{spec: &newSpec, importPath: importPath, name: newName})
+				// A generic type has no reflect.Type until it is instantiated.
+				if c.registries[importPath] != "" && typeSpec.TypeParams == nil {
+					registryTypes[importPath] = append(registryTypes[importPath], newName)
+				}
 			}
 		}
 		// Populate funcs
 		for _, decl := range pkgDecls.funcDecls {
 			if funcDecl, ok := decl.(*ast.FuncDecl); ok {
-				newName := nameMap[funcDecl.Name]
+				newName, ok := nameMap[funcDecl.Name]
+				if !ok || b.baseline[newName] {
+					continue
+				}
 				newDecl := *funcDecl // copy
 				newDecl.Name = ast.NewIdent(newName)
 				funcsToSort = append(funcsToSort, sortedDecl{decl: &newDecl, importPath: importPath, name: newName})
 			}
 		}
+		// Populate the methods of embedding types, which are declared with the type they belong to.
+		for _, method := range pkgDecls.embedMethods {
+			typeName := receiverTypeName(method)
+			if typeName == "" || b.baseline[typeName] {
+				continue
+			}
+			funcsToSort = append(funcsToSort, sortedDecl{decl: method, importPath: importPath, name: typeName + "." + method.Name.Name})
+		}
+	}
+
+	varsToSort = append(varsToSort, b.buildRegistries(c.registries, registryTypes, nameMap)...)
+	if b.nilInterfaceVars {
+		taken := make(map[string]bool, len(nameMap)+len(varsToSort))
+		for _, name := range nameMap {
+			taken[name] = true
+		}
+		for _, s := range varsToSort {
+			taken[s.name] = true
+		}
+		varsToSort = append(varsToSort, b.buildNilVars(typesToSort, c, taken)...)
 	}
 
 	// Sort each list by import path, then by name.
@@ -214,62 +603,306 @@ func (b *Builder) Build(c *Collector) {
 		return funcsToSort[i].name < funcsToSort[j].name
 	})
 
-	// Extract the sorted specs and decls into the final lists.
-	var allConstSpecs []ast.Spec
+	b.manifest = b.buildManifest(constsToSort, varsToSort, typesToSort, funcsToSort, c.pathToAlias)
+	b.renames = nil
+	if b.renameTable {
+		b.renames = renameTableLines(b.manifest, c.pathToAlias)
+	}
+	b.testRefs = nil
+	if b.emitTests {
+		b.testRefs = testReferences(constsToSort, varsToSort, typesToSort, funcsToSort, c)
+	}
+	// The init function registering the types follows all other declarations. It may need an
+	// import, which is added to the imports built above, the only declarations so far.
+	var registerInit *ast.FuncDecl
+	if b.registerFunc.Name != "" {
+		var importKey string
+		var importSpec *ast.ImportSpec
+		if registerInit, importKey, importSpec = b.buildRegisterInit(typesToSort, c); importSpec != nil {
+			importSpecs := maps.Clone(c.importSpecs)
+			importSpecs[importKey] = importSpec
+			orderedDecls = []ast.Decl{b.buildImportDeclaration(importSpecs)}
+		}
+	}
+	b.packageHeadings = make(map[ast.Decl]string)
+	if !b.groupByPackage {
+		orderedDecls = append(orderedDecls, b.orderDecls(constsToSort, varsToSort, typesToSort, funcsToSort)...)
+		if registerInit != nil {
+			orderedDecls = append(orderedDecls, registerInit)
+		}
+		b.aliasFile.Decls = orderedDecls
+		return
+	}
+
+	// Group the declarations by source package, in the order of their import paths. Each list is
+	// sorted by import path, so the declarations of a package are consecutive.
+	importPaths := make(map[string]bool)
 	for _, s := range constsToSort {
-		allConstSpecs = append(allConstSpecs, s.spec)
+		importPaths[s.importPath] = true
 	}
-	var allVarSpecs []ast.Spec
 	for _, s := range varsToSort {
-		allVarSpecs = append(allVarSpecs, s.spec)
+		importPaths[s.importPath] = true
 	}
-	var allTypeSpecs []ast.Spec
 	for _, s := range typesToSort {
-		allTypeSpecs = append(allTypeSpecs, s.spec)
+		importPaths[s.importPath] = true
 	}
-	var allFuncDecls []ast.Decl
 	for _, s := range funcsToSort {
-		allFuncDecls = append(allFuncDecls, s.decl)
+		importPaths[s.importPath] = true
 	}
+	sortedPaths := make([]string, 0, len(importPaths))
+	for importPath := range importPaths {
+		sortedPaths = append(sortedPaths, importPath)
+	}
+	sort.Strings(sortedPaths)
+	ofPackage := func(specs []sortedSpec, importPath string) []sortedSpec {
+		var filtered []sortedSpec
+		for _, s := range specs {
+			if s.importPath == importPath {
+				filtered = append(filtered, s)
+			}
+		}
+		return filtered
+	}
+	for _, importPath := range sortedPaths {
+		var funcs []sortedDecl
+		for _, s := range funcsToSort {
+			if s.importPath == importPath {
+				funcs = append(funcs, s)
+			}
+		}
+		decls := b.orderDecls(ofPackage(constsToSort, importPath), ofPackage(varsToSort, importPath), ofPackage(typesToSort, importPath), funcs)
+		b.packageHeadings[decls[0]] = importPath
+		orderedDecls = append(orderedDecls, decls...)
+	}
+	if registerInit != nil {
+		orderedDecls = append(orderedDecls, registerInit)
+	}
+	b.aliasFile.Decls = orderedDecls
+}
 
-	// Build the final orderedDecls list.
-	if len(allConstSpecs) > 0 {
-		orderedDecls = append(orderedDecls, &ast.GenDecl{Tok: token.CONST, Specs: allConstSpecs})
+// orderDecls returns the declarations of the given specs, one declaration per spec, folded into
+// blocks if enabled: consts, vars, types, then funcs, or in the order of outputOrder.
+func (b *Builder) orderDecls(consts, vars, types []sortedSpec, funcs []sortedDecl) []ast.Decl {
+	var decls []ast.Decl
+	addSpecs := func(tok token.Token, specs []sortedSpec) {
+		for _, s := range specs {
+			decls = append(decls, &ast.GenDecl{Tok: tok, Specs: []ast.Spec{s.spec}})
+		}
 	}
-	if len(allVarSpecs) > 0 {
-		orderedDecls = append(orderedDecls, &ast.GenDecl{Tok: token.VAR, Specs: allVarSpecs})
+	for _, section := range b.sectionOrder() {
+		switch section {
+		case "consts":
+			addSpecs(token.CONST, consts)
+		case "vars":
+			addSpecs(token.VAR, vars)
+		case "types":
+			addSpecs(token.TYPE, types)
+		case "funcs":
+			for _, s := range funcs {
+				decls = append(decls, s.decl)
+			}
+		}
 	}
-	if len(allTypeSpecs) > 0 {
-		orderedDecls = append(orderedDecls, &ast.GenDecl{Tok: token.TYPE, Specs: allTypeSpecs})
+	if b.foldDecls {
+		decls = foldGenDecls(decls)
 	}
-	orderedDecls = append(orderedDecls, allFuncDecls...)
+	return decls
+}
 
-	b.aliasFile.Decls = orderedDecls
+// sectionOrder returns the sections of declarations in the order they are written: those of
+// outputOrder, then the others in the default order.
+func (b *Builder) sectionOrder() []string {
+	order := append([]string(nil), b.outputOrder...)
+	for _, section := range []string{"consts", "vars", "types", "funcs"} {
+		if !slices.Contains(order, section) {
+			order = append(order, section)
+		}
+	}
+	return order
+}
+
+// buildRegistries builds, for each package with a registry, a variable mapping the names of its
+// generated types to their reflect.Type. A registry whose name is taken by a generated symbol is
+// skipped with a warning.
+func (b *Builder) buildRegistries(registries map[string]string, registryTypes map[string][]string, nameMap map[*ast.Ident]string) []sortedSpec {
+	taken := make(map[string]bool, len(nameMap))
+	for _, name := range nameMap {
+		taken[name] = true
+	}
+	var specs []sortedSpec
+	for importPath, name := range registries {
+		if taken[name] {
+			slog.Warn("Registry name is taken by a generated symbol, skipping registry", "registry", name, "package", importPath)
+			continue
+		}
+		if b.baseline[name] {
+			continue
+		}
+		taken[name] = true
+
+		typeNames := registryTypes[importPath]
+		sort.Strings(typeNames)
+		var src strings.Builder
+		src.WriteString("map[string]reflect.Type{\n")
+		for _, typeName := range typeNames {
+			fmt.Fprintf(&src, "\t%q: reflect.TypeOf((*%s)(nil)).Elem(),\n", typeName, typeName)
+		}
+		src.WriteString("}")
+		// The literal is parsed rather than built, so that its positions put one entry per line.
+		value, err := parser.ParseExprFrom(b.fset, "", src.String(), 0)
+		if err != nil {
+			slog.Error("Failed to build registry", "registry", name, "package", importPath, "error", err)
+			continue
+		}
+		specs = append(specs, sortedSpec{
+			spec:       &ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent(name)}, Values: []ast.Expr{value}},
+			importPath: importPath,
+			name:       name,
+		})
+	}
+	return specs
+}
+
+// stats counts the declarations built by the last call to Build.
+func (b *Builder) stats() Stats {
+	var stats Stats
+	for _, decl := range b.aliasFile.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			// The init function registering the types is no re-exported function.
+			if d.Name.Name != "init" {
+				stats.Functions++
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch d.Tok {
+				case token.CONST:
+					stats.Constants += len(spec.(*ast.ValueSpec).Names)
+				case token.VAR:
+					stats.Variables += len(spec.(*ast.ValueSpec).Names)
+				case token.TYPE:
+					stats.Types++
+				}
+			}
+		}
+	}
+	return stats
+}
+
+// foldGenDecls merges consecutive const, var and type declarations with the same token
+// into a single grouped declaration, so that they are printed as one parenthesized block.
+func foldGenDecls(decls []ast.Decl) []ast.Decl {
+	folded := make([]ast.Decl, 0, len(decls))
+	var current *ast.GenDecl
+	for _, decl := range decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok == token.IMPORT {
+			current = nil
+			folded = append(folded, decl)
+			continue
+		}
+		if current != nil && current.Tok == genDecl.Tok {
+			current.Specs = append(current.Specs, genDecl.Specs...)
+			continue
+		}
+		current = &ast.GenDecl{Tok: genDecl.Tok, Specs: append([]ast.Spec(nil), genDecl.Specs...)}
+		folded = append(folded, current)
+	}
+	return folded
 }
 
 // Write writes the generated code to the output file or to the configured writer.
 func (b *Builder) Write() error {
+	if err := b.dumpAST(); err != nil {
+		return err
+	}
+
 	// If a writer is configured, write to it and bypass file operations.
 	if b.writer != nil {
-		return b.writeToWriter(b.writer)
+		if b.lineEnding != LineEndingCRLF {
+			return b.writeToWriter(b.writer)
+		}
+		var buf bytes.Buffer
+		if err := b.writeToWriter(&buf); err != nil {
+			return err
+		}
+		_, err := b.writer.Write(b.lineEnding.convert(buf.Bytes()))
+		return err
+	}
+
+	var err error
+	if b.splitGenerics {
+		err = b.writeSplitGenerics()
+	} else {
+		err = b.writeToFile()
+	}
+	if err != nil || !b.emitTests {
+		return err
 	}
+	return b.writeTestFile()
+}
 
-	// Original file writing logic
-	return b.writeToFile()
+// dumpAST prints the AST of the generated file to astDump, if set.
+func (b *Builder) dumpAST() error {
+	if b.astDump == nil {
+		return nil
+	}
+	if err := ast.Fprint(b.astDump, b.fset, b.aliasFile, ast.NotNilFilter); err != nil {
+		return fmt.Errorf("failed to dump the AST of the generated file: %w", err)
+	}
+	return nil
 }
 
-func (b *Builder) writeToWriter(w io.Writer) error {
-	// Write the rendered header.
+// writeHeader writes everything up to and including the package clause, in this order:
+//
+//  1. the //go:build constraint, which must precede the package clause and be followed by a
+//     blank line;
+//  2. the rendered header, with the no-edit banner, which must come before the first
+//     non-comment text;
+//  3. the table of renamed symbols, if enabled;
+//  4. the //go:generate directives;
+//  5. the package doc comment, directly above the package clause.
+//
+// Each part is separated from the next by a blank line, so that only the package doc comment is
+// the doc comment of the package.
+func (b *Builder) writeHeader(w io.Writer) error {
+	if b.buildConstraint != "" {
+		if _, err := fmt.Fprintf(w, "//go:build %s\n\n", b.buildConstraint); err != nil {
+			return fmt.Errorf("failed to write build constraint: %w", err)
+		}
+	}
+
 	if b.header != "" {
-		if _, err := w.Write([]byte(b.header)); err != nil {
+		if _, err := fmt.Fprintf(w, "%s\n", b.header); err != nil {
 			return fmt.Errorf("failed to write header to writer: %w", err)
 		}
+	}
+
+	if len(b.renames) > 0 {
+		if _, err := fmt.Fprintf(w, "// Renamed symbols:\n//\n"); err != nil {
+			return fmt.Errorf("failed to write rename table: %w", err)
+		}
+		for _, line := range b.renames {
+			if _, err := fmt.Fprintf(w, "//\t%s\n", line); err != nil {
+				return fmt.Errorf("failed to write rename table: %w", err)
+			}
+		}
+		if _, err := w.Write([]byte("\n")); err != nil {
+			return err
+		}
+	}
+
+	if len(b.generateDirectives) > 0 {
+		for _, command := range b.generateDirectives {
+			if _, err := fmt.Fprintf(w, "//go:generate %s\n", command); err != nil {
+				return fmt.Errorf("failed to write go:generate directive: %w", err)
+			}
+		}
 		if _, err := w.Write([]byte("\n")); err != nil {
 			return err
 		}
 	}
 
-	// Manually write the package comment.
 	if b.aliasFile.Doc != nil {
 		for _, comment := range b.aliasFile.Doc.List {
 			if _, err := w.Write([]byte(comment.Text + "\n")); err != nil {
@@ -278,14 +911,41 @@ func (b *Builder) writeToWriter(w io.Writer) error {
 		}
 	}
 
-	// Manually write the package declaration.
 	if _, err := fmt.Fprintf(w, "package %s\n\n", b.aliasFile.Name.Name); err != nil {
 		return fmt.Errorf("failed to write package declaration: %w", err)
 	}
+	return nil
+}
+
+func (b *Builder) writeToWriter(w io.Writer) error {
+	if err := b.writeHeader(w); err != nil {
+		return err
+	}
 
 	// Print the declarations one by one.
+	commented := make(map[token.Token]bool)
 	for i, decl := range b.aliasFile.Decls {
-		if err := printer.Fprint(w, b.fset, decl); err != nil {
+		if importPath, ok := b.packageHeadings[decl]; ok {
+			if _, err := fmt.Fprintf(w, "// from %s\n\n", importPath); err != nil {
+				return fmt.Errorf("failed to write package heading: %w", err)
+			}
+		}
+		if genDecl, ok := decl.(*ast.GenDecl); ok && b.blockComments[genDecl.Tok] != "" && !commented[genDecl.Tok] {
+			commented[genDecl.Tok] = true
+			if _, err := fmt.Fprintf(w, "%s\n", b.blockComments[genDecl.Tok]); err != nil {
+				return fmt.Errorf("failed to write block comment: %w", err)
+			}
+		}
+		if len(b.nolint) > 0 {
+			if _, err := fmt.Fprintf(w, "//nolint:%s\n", strings.Join(b.nolint, ",")); err != nil {
+				return fmt.Errorf("failed to write nolint comment: %w", err)
+			}
+		}
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT && len(b.importComments) > 0 {
+			if err := b.writeImports(w, genDecl); err != nil {
+				return err
+			}
+		} else if err := printer.Fprint(w, b.fset, decl); err != nil {
 			return fmt.Errorf("failed to print declaration: %w", err)
 		}
 		// Add two newlines after each declaration, except for the last one.
@@ -299,7 +959,84 @@ func (b *Builder) writeToWriter(w io.Writer) error {
 	return nil
 }
 
-func (b *Builder) writeToFile() error {
+// writeImports writes the import declaration spec by spec, each followed by the comment of its
+// import path, as go/printer places comments by their position, which generated specs lack.
+func (b *Builder) writeImports(w io.Writer, importDecl *ast.GenDecl) error {
+	if _, err := w.Write([]byte("import (\n")); err != nil {
+		return err
+	}
+	for _, spec := range importDecl.Specs {
+		if _, err := w.Write([]byte("\t")); err != nil {
+			return err
+		}
+		if err := printer.Fprint(w, b.fset, spec); err != nil {
+			return fmt.Errorf("failed to print import: %w", err)
+		}
+		comment := ""
+		if importSpec, ok := spec.(*ast.ImportSpec); ok && importSpec.Path != nil {
+			if text, ok := b.importComments[strings.Trim(importSpec.Path.Value, `"`)]; ok {
+				comment = " // " + text
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", comment); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte(")"))
+	return err
+}
+
+// source returns the code of the output file: printed, formatted with goimports as if it were
+// at outputFilePath unless formatCode is unset, and with the configured line endings.
+func (b *Builder) source() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := b.writeToWriter(&buf); err != nil {
+		return nil, err
+	}
+	src := buf.Bytes()
+
+	if b.formatCode {
+		// Invalid code is reported before formatting, as goimports would fail on it without
+		// showing the code.
+		if err := checkSource(src, b.outputFilePath); err != nil {
+			return nil, err
+		}
+		formatted, err := util.FormatGoImports(src, b.outputFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format generated code with goimports: %w", err)
+		}
+		src = formatted
+	}
+
+	// Formatting always produces LF line endings, so convert them last.
+	if b.lineEnding == LineEndingCRLF {
+		src = b.lineEnding.convert(src)
+	}
+	return src, nil
+}
+
+// outputSource returns the code Write writes to the output file, without writing any file. With
+// splitGenerics, it is the code of the output file for Go 1.18 and later: neither the fallback
+// file nor the test file of emitTests is part of it.
+func (b *Builder) outputSource() ([]byte, error) {
+	if err := b.dumpAST(); err != nil {
+		return nil, err
+	}
+	if b.splitGenerics {
+		if _, _, split := b.nonGenericDecls(); split {
+			b.buildConstraint = "go1.18"
+			defer func() { b.buildConstraint = "" }()
+		}
+	}
+	return b.source()
+}
+
+func (b *Builder) writeToFile() (err error) {
+	src, err := b.source()
+	if err != nil {
+		return err
+	}
+
 	outputDir := filepath.Dir(b.outputFilePath)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
@@ -317,31 +1054,105 @@ func (b *Builder) writeToFile() error {
 		}
 	}()
 
-	// Use the same writing logic as writeToWriter
-	if err := b.writeToWriter(tempFile); err != nil {
-		return err
+	if _, err = tempFile.Write(src); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	// Temporary files are created with mode 0600, which the renamed file would keep.
+	if err = tempFile.Chmod(b.fileMode); err != nil {
+		return fmt.Errorf("failed to set mode of temporary file: %w", err)
 	}
 
 	// Close the temp file before renaming
-	if err := tempFile.Close(); err != nil {
+	if err = tempFile.Close(); err != nil {
 		return fmt.Errorf("failed to close temporary file: %w", err)
 	}
 
 	// Atomically replace the target file
-	if err := os.Rename(tempFile.Name(), b.outputFilePath); err != nil {
+	if err = os.Rename(tempFile.Name(), b.outputFilePath); err != nil {
 		return fmt.Errorf("failed to rename temporary file: %w", err)
 	}
+	return nil
+}
 
-	// According to formatCode option, decide whether to run goimports
-	if b.formatCode {
-		if err := util.RunGoImports(b.outputFilePath); err != nil {
-			return fmt.Errorf("failed to format generated code with goimports: %w", err)
+// checkIdentifiers returns an error listing the names declared by the last call to Build that
+// are not ASCII exported identifiers, if strictIdentifiers is set.
+func (b *Builder) checkIdentifiers() error {
+	if !b.strictIdentifiers {
+		return nil
+	}
+	var invalid []string
+	for _, symbol := range b.manifest {
+		if util.IsStrictIdentifier(symbol.Name) {
+			continue
 		}
+		desc := fmt.Sprintf("%s %q", symbol.Kind, symbol.Name)
+		if symbol.Source != "" {
+			desc += fmt.Sprintf(" (from %s.%s)", symbol.SourcePackage, symbol.Source)
+		}
+		invalid = append(invalid, desc)
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("generated names are not ASCII exported identifiers: %s", strings.Join(invalid, ", "))
 	}
+	return nil
+}
 
+// checkReservedNames returns an error listing the names declared by the last call to Build that
+// rules renamed to a Go keyword or predeclared identifier. The compiler only checks the names
+// known from the rules alone; this checks those given by prefixes, templates, transforms and
+// partial regular expressions as well. If lenient is set, they are reported as warnings instead.
+func (b *Builder) checkReservedNames() error {
+	var reserved []string
+	for _, symbol := range b.manifest {
+		if symbol.Name == symbol.Source {
+			continue // Not renamed: the source declares the same name.
+		}
+		kind := util.ReservedNameKind(symbol.Name)
+		if kind == "" {
+			continue
+		}
+		if b.lenient {
+			slog.Warn("Rule renames a declaration to a reserved Go name", "name", symbol.Name, "kind", kind, "source", symbol.SourcePackage+"."+symbol.Source)
+			continue
+		}
+		reserved = append(reserved, fmt.Sprintf("%s %q (from %s.%s) is a Go %s", symbol.Kind, symbol.Name, symbol.SourcePackage, symbol.Source, kind))
+	}
+	if len(reserved) > 0 {
+		return fmt.Errorf("generated names are reserved: %s (see --lenient)", strings.Join(reserved, ", "))
+	}
 	return nil
 }
 
+// checkCollisions returns an error listing the names given to more than one symbol by the last
+// call to Build, if the collision strategy is CollisionError.
+func (b *Builder) checkCollisions() error {
+	if len(b.collisions) == 0 {
+		return nil
+	}
+	return fmt.Errorf("names are given to more than one symbol: %s", strings.Join(b.collisions, ", "))
+}
+
+// checkSource returns an error if src, generated for outputFilePath, is not valid Go. The
+// unformatted source is then saved to a temporary file named in the error, so that the positions
+// reported by the parser point at the malformed construct.
+func checkSource(src []byte, outputFilePath string) error {
+	_, formatErr := format.Source(src)
+	if formatErr == nil {
+		return nil
+	}
+	dump, err := os.CreateTemp("", "adptool-*.go")
+	if err != nil {
+		return fmt.Errorf("generated code for %s is not valid Go: %w", outputFilePath, formatErr)
+	}
+	defer dump.Close()
+	if _, err := dump.Write(src); err != nil {
+		return fmt.Errorf("generated code for %s is not valid Go: %w", outputFilePath, formatErr)
+	}
+	return fmt.Errorf("generated code for %s is not valid Go: %w (unformatted source written to %s)",
+		outputFilePath, formatErr, dump.Name())
+}
+
 func (b *Builder) buildImportDeclaration(importSpecs map[string]*ast.ImportSpec) ast.Decl {
 	// First, collect and sort the import paths to ensure deterministic order
 	importPaths := make([]string, 0, len(importSpecs))
@@ -434,6 +1245,7 @@ func (b *Builder) collectAndResolveNames(allPackageDecls map[string]*packageDecl
 
 	// Pass 1, Step C: Generate unique names using the grouping strategy.
 	nameMap := make(map[*ast.Ident]string)
+	b.collisions = nil
 	usedNames := make(map[string]bool)
 
 	// Group symbols by their original name.
@@ -454,8 +1266,20 @@ func (b *Builder) collectAndResolveNames(allPackageDecls map[string]*packageDecl
 	for _, originalName := range sortedOriginalNames {
 		group := groupedSymbols[originalName]
 
+		if len(group) > 1 && b.collisionStrategy == CollisionError {
+			paths := make([]string, len(group))
+			for i, symbol := range group {
+				paths[i] = symbol.originalImportPath
+			}
+			b.collisions = append(b.collisions, fmt.Sprintf("%s (%s)", originalName, strings.Join(paths, ", ")))
+		}
+
 		// The symbols within the group are already sorted by import path.
 		for i, symbol := range group {
+			if i > 0 && b.collisionStrategy == CollisionSkip {
+				slog.Warn("Name collision, skipping symbol", "name", originalName, "package", symbol.originalImportPath, "kept_package", group[0].originalImportPath)
+				continue
+			}
 			var finalName string
 			// The first symbol in a group (i=0) tries to get the clean, unsuffixed name.
 			// Subsequent symbols (i>0) get a numeric suffix.
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
 	"log/slog"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"unicode"
@@ -12,6 +15,7 @@ import (
 	"golang.org/x/tools/go/packages"
 
 	"github.com/origadmin/adptool/internal/interfaces"
+	"github.com/origadmin/adptool/internal/rules"
 )
 
 // packageDecls holds declarations for a single package.
@@ -20,16 +24,104 @@ type packageDecls struct {
 	varDecls   []ast.Decl // Changed from varSpecs to store GenDecls
 	constDecls []ast.Decl // Changed from constSpecs to store GenDecls
 	funcDecls  []ast.Decl
+	// namedSpecs holds interfaces extracted from method sets and aliases of generic
+	// instantiations. They are named by the user, so they are added to typeSpecs only
+	// after the replacer has run.
+	namedSpecs []ast.Spec
+	// namedFuncs holds the wrappers of instantiations of generic functions, which are named by the
+	// user like namedSpecs and added to funcDecls after the replacer has run
+	namedFuncs []ast.Decl
+	// constructors holds the wrappers of New* functions, whose results are made to name the
+	// local aliases of the types they construct
+	constructors []*ast.FuncDecl
+	// localTypeRefs maps references to generated types to the names of their specs, whose
+	// final names they take when the builder resolves names
+	localTypeRefs map[*ast.Ident]*ast.Ident
+	// localTypeSources maps the references to generated types in constructor results to the
+	// source types they replaced, which they name again if the generated type is left out
+	localTypeSources map[*ast.Ident]string
+	// embedSpecs maps the source names of the types re-exported as a struct embedding the
+	// source type to their specs
+	embedSpecs map[string]*ast.TypeSpec
+	// embedMethods holds the methods added to embedding types: stubs panicking with "not
+	// implemented" in place of their disabled methods, and accessors of renamed embedded fields
+	embedMethods []*ast.FuncDecl
+	// declared holds the names of the package-level declarations collected from the syntax,
+	// so that a name declared again, e.g. by a file the loader lists twice, is collected once
+	declared map[string]bool
 }
 
 // Collector is responsible for collecting declarations from source packages.
 type Collector struct {
 	// allPackageDecls is keyed by import path
 	allPackageDecls map[string]*packageDecls
-	importSpecs     map[string]*ast.ImportSpec
-	replacer        interfaces.Replacer
+	// importSpecs is keyed by import path, or by "name path" for an import renamed in the source
+	importSpecs map[string]*ast.ImportSpec
+	replacer    interfaces.Replacer
+	// loader loads the source packages
+	loader PackageLoader
 	// pathToAlias maps import path to its generated alias
 	pathToAlias map[string]string
+	// pathToName maps import path to the package name declared in its source
+	pathToName map[string]string
+	// pathToTypes maps import path to the type-checked source package, if available
+	pathToTypes map[string]*types.Package
+	// configPaths maps the canonical import path of a package requested by another path, such as
+	// a directory, to the requested path, which keys its rules, ignores and other settings
+	configPaths map[string]string
+	// extractInterfaces maps import path ("" for all packages) to type name to the
+	// name of the interface extracted from its method set
+	extractInterfaces map[string]map[string]string
+	// embedTypes maps import path ("" for all packages) to the names of the types re-exported
+	// as a struct embedding the source type instead of an alias
+	embedTypes map[string]map[string]bool
+	// defineTypes maps import path ("" for all packages) to the names of the types ("*" for
+	// all of them) re-exported as a defined type, or kept as an alias if false
+	defineTypes map[string]map[string]bool
+	// ignores maps import path ("" for all packages) to rule type to the ignores matched
+	// against the source names of the symbols, which are then not collected
+	ignores map[string]map[interfaces.RuleType][]interfaces.CompiledIgnore
+	// registries maps import path to the name of the generated map from its type names to their
+	// reflect.Type, for the packages that have one
+	registries map[string]string
+	// ruleSymbols maps import path to the symbols named by its rules, which are reported
+	// if the loaded package does not declare them
+	ruleSymbols map[string][]string
+	// skipped lists the exported functions left out because their signature cannot be spelled
+	skipped []SkippedFunction
+	// funcVarFallback re-exports functions using unexported or internal types as function variables
+	funcVarFallback bool
+	// versions maps import path to the version of its source, if known
+	versions map[string]string
+	// onlyTagged, if set, is the doc-comment tag a source symbol must carry to be collected
+	onlyTagged string
+	// skipDeprecated leaves out source symbols whose doc comment has a "Deprecated:" line
+	skipDeprecated bool
+	// varAccessors re-exports variables with value semantics as getter and setter functions
+	varAccessors bool
+	// contextHook, if set, names a function of the adapter package that wrappers of functions
+	// taking a context.Context first call before forwarding
+	contextHook string
+	// disabledMethods maps import path ("" for all packages) to type name to the methods disabled
+	// by its member rules
+	disabledMethods map[string]map[string][]string
+	// stubDisabledMethods gives embedding types a method panicking with "not implemented" for
+	// each disabled method of the embedded type
+	stubDisabledMethods bool
+	// plainImport imports the package of a single-package adapter by its own name, without alias
+	plainImport bool
+	// failOnEmptyPackage fails collection if a package contributes no declarations
+	failOnEmptyPackage bool
+	// flattenInterfaces makes interfaces extracted from interfaces list their full method set
+	// rather than embed the interfaces of the source
+	flattenInterfaces bool
+	// warnGeneratedSource warns about source packages with generated files
+	warnGeneratedSource bool
+	// allowedImports, if set, lists the import path prefixes of the only packages that may be collected
+	allowedImports []string
+	// importerPath, if set, is the import path of the generated package, which may then refer to
+	// the types of the internal packages of its own tree
+	importerPath string
 }
 
 // NewCollector creates a new Collector.
@@ -38,28 +130,49 @@ func NewCollector(replacer interfaces.Replacer) *Collector {
 		allPackageDecls: make(map[string]*packageDecls),
 		importSpecs:     make(map[string]*ast.ImportSpec),
 		replacer:        replacer,
+		loader:          DefaultPackageLoader{},
 		pathToAlias:     make(map[string]string),
+		pathToName:      make(map[string]string),
+		pathToTypes:     make(map[string]*types.Package),
+		configPaths:     make(map[string]string),
+		versions:        make(map[string]string),
+		registries:      make(map[string]string),
 	}
 }
 
-func (c *Collector) loadPackage(importPath string) (*packages.Package, error) {
-	loadCfg := &packages.Config{
-		Mode: packages.LoadSyntax | packages.LoadTypes,
+// Reset clears the declarations, imports and packages collected by the last run, keeping the
+// configuration, so that the collector can be reused. It also resets the replacer if it has a
+// Reset method.
+func (c *Collector) Reset() {
+	c.allPackageDecls = make(map[string]*packageDecls)
+	c.importSpecs = make(map[string]*ast.ImportSpec)
+	c.pathToAlias = make(map[string]string)
+	c.pathToName = make(map[string]string)
+	c.pathToTypes = make(map[string]*types.Package)
+	c.configPaths = make(map[string]string)
+	c.versions = make(map[string]string)
+	c.registries = make(map[string]string)
+	c.skipped = nil
+	if r, ok := c.replacer.(interface{ Reset() }); ok {
+		r.Reset()
 	}
-	pkgs, err := packages.Load(loadCfg, importPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load package %s: %w", importPath, err)
-	}
-	if len(pkgs) == 0 {
-		return nil, nil // Package not found
-	}
-	if len(pkgs[0].Errors) > 0 {
-		return nil, fmt.Errorf("errors while loading package %s: %v", importPath, pkgs[0].Errors)
-	}
-	return pkgs[0], nil
+}
+
+// SkippedFunctions returns the exported functions that were not re-exported because their
+// signatures use unexported or internal types.
+func (c *Collector) SkippedFunctions() []SkippedFunction {
+	return c.skipped
 }
 
 func (c *Collector) collectImports(sourcePkg *packages.Package) {
+	// Declared names of the imported packages, to tell renaming imports from redundant names.
+	declaredNames := make(map[string]string)
+	if sourcePkg.Types != nil {
+		for _, imported := range sourcePkg.Types.Imports() {
+			declaredNames[imported.Path()] = imported.Name()
+		}
+	}
+
 	for _, file := range sourcePkg.Syntax {
 		for _, importSpec := range file.Imports {
 			// 如果是空导入 (import _ "path")，则跳过
@@ -67,8 +180,20 @@ func (c *Collector) collectImports(sourcePkg *packages.Package) {
 				continue
 			}
 			importPath := strings.Trim(importSpec.Path.Value, "\"")
-			if _, exists := c.importSpecs[importPath]; !exists {
-				c.importSpecs[importPath] = importSpec
+			// Source files may import the same package under different names, and declarations
+			// keep the qualifier of their own file, so each name gets its own import.
+			key := importPath
+			if importSpec.Name != nil {
+				declaredName, ok := declaredNames[importPath]
+				if !ok {
+					declaredName = path.Base(importPath)
+				}
+				if importSpec.Name.Name != declaredName {
+					key = importSpec.Name.Name + " " + importPath
+				}
+			}
+			if _, exists := c.importSpecs[key]; !exists {
+				c.importSpecs[key] = importSpec
 			}
 		}
 	}
@@ -79,7 +204,7 @@ func (c *Collector) collectTypeDeclarations(sourcePkg *packages.Package, importP
 		for _, decl := range file.Decls {
 			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
 				for _, spec := range genDecl.Specs {
-					if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.IsExported() {
+					if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.IsExported() && c.isTagged(genDecl.Doc, typeSpec.Doc) && !c.isDeprecated(genDecl.Doc, typeSpec.Doc) && c.declareName(importPath, typeSpec.Name.Name) {
 						c.collectTypeDeclaration(typeSpec, importPath, importAlias)
 					}
 				}
@@ -89,7 +214,7 @@ func (c *Collector) collectTypeDeclarations(sourcePkg *packages.Package, importP
 }
 
 func (c *Collector) collectTypeDeclaration(typeSpec *ast.TypeSpec, importPath, importAlias string) {
-	if !typeSpec.Name.IsExported() {
+	if !typeSpec.Name.IsExported() || c.isIgnored(interfaces.RuleTypeType, importPath, typeSpec.Name.Name) {
 		return
 	}
 
@@ -101,14 +226,23 @@ func (c *Collector) collectTypeDeclaration(typeSpec *ast.TypeSpec, importPath, i
 
 	// Handle generics in type declarations
 	if typeSpec.TypeParams != nil {
-		newSpec.TypeParams = typeSpec.TypeParams
-
+		// Constraints may refer to other types of the source package, so they are qualified
+		// with its alias. The type parameters themselves stay as they are.
+		typeParams := make(map[string]bool)
 		var indices []ast.Expr
 		for _, list := range typeSpec.TypeParams.List {
 			for _, name := range list.Names {
+				typeParams[name.Name] = true
 				indices = append(indices, ast.NewIdent(name.Name))
 			}
 		}
+		newSpec.TypeParams = &ast.FieldList{}
+		for _, list := range typeSpec.TypeParams.List {
+			newSpec.TypeParams.List = append(newSpec.TypeParams.List, &ast.Field{
+				Names: list.Names,
+				Type:  qualifyConstraint(list.Type, importAlias, typeParams),
+			})
+		}
 
 		baseType := &ast.SelectorExpr{
 			X:   ast.NewIdent(importAlias),
@@ -133,10 +267,46 @@ func (c *Collector) collectTypeDeclaration(typeSpec *ast.TypeSpec, importPath, i
 		}
 	}
 
+	if c.isDefinedType(importPath, originalName) {
+		// A defined type has the underlying type of the source type, but none of its methods.
+		newSpec.Assign = token.NoPos
+	}
+
+	if c.embedTypes[""][originalName] || c.embedTypes[c.configPath(importPath)][originalName] {
+		if _, isPointer := typeSpec.Type.(*ast.StarExpr); isPointer {
+			slog.Warn("Re-exporting type as an alias because a pointer type cannot be embedded", "type", originalName, "package", importPath)
+		} else {
+			// A defined struct type embedding the source type promotes its fields and methods,
+			// and can be given methods of its own.
+			newSpec.Assign = token.NoPos
+			newSpec.Type = &ast.StructType{Fields: &ast.FieldList{List: []*ast.Field{{Type: newSpec.Type}}}}
+		}
+	}
+
 	if c.allPackageDecls[importPath] == nil {
 		c.allPackageDecls[importPath] = &packageDecls{}
 	}
-	c.allPackageDecls[importPath].typeSpecs = append(c.allPackageDecls[importPath].typeSpecs, newSpec)
+	pkgDecls := c.allPackageDecls[importPath]
+	pkgDecls.typeSpecs = append(pkgDecls.typeSpecs, newSpec)
+	if _, isStruct := newSpec.Type.(*ast.StructType); isStruct {
+		if pkgDecls.embedSpecs == nil {
+			pkgDecls.embedSpecs = make(map[string]*ast.TypeSpec)
+		}
+		pkgDecls.embedSpecs[originalName] = newSpec
+	}
+}
+
+// isDefinedType reports whether a type is re-exported as a defined type rather than an alias.
+// A rule naming the type takes precedence over a wildcard, and a package rule over a global one.
+func (c *Collector) isDefinedType(importPath, name string) bool {
+	for _, key := range []string{name, "*"} {
+		for _, path := range []string{c.configPath(importPath), ""} {
+			if define, ok := c.defineTypes[path][key]; ok {
+				return define
+			}
+		}
+	}
+	return false
 }
 
 func (c *Collector) collectOtherDeclarations(sourcePkg *packages.Package, importPath, importAlias string) {
@@ -144,135 +314,337 @@ func (c *Collector) collectOtherDeclarations(sourcePkg *packages.Package, import
 		for _, decl := range file.Decls {
 			switch d := decl.(type) {
 			case *ast.FuncDecl:
-				c.collectFunctionDeclaration(d, sourcePkg, importPath, importAlias)
+				// Methods are in the scope of their receiver type, so they are never duplicates.
+				if c.isTagged(d.Doc) && !c.isDeprecated(d.Doc) && (d.Recv != nil || c.declareName(importPath, d.Name.Name)) {
+					c.collectFunctionDeclaration(d, sourcePkg, importPath, importAlias)
+				}
 			case *ast.GenDecl:
+				if d = c.taggedSpecs(d); d == nil {
+					continue
+				}
+				if d = c.currentSpecs(d); d == nil {
+					continue
+				}
+				if d = c.undeclaredSpecs(d, importPath); d == nil {
+					continue
+				}
 				switch d.Tok {
 				case token.CONST:
 					c.collectValueDeclaration(d, importPath, importAlias, token.CONST)
 				case token.VAR:
-					c.collectValueDeclaration(d, importPath, importAlias, token.VAR)
+					if c.varAccessors {
+						c.collectVariables(d, sourcePkg.Types, importPath, importAlias)
+					} else {
+						c.collectValueDeclaration(d, importPath, importAlias, token.VAR)
+					}
 				}
 			}
 		}
 	}
 }
 
-func (c *Collector) collectFunctionDeclaration(funcDecl *ast.FuncDecl, sourcePkg *packages.Package, importPath, importAlias string) {
-	if funcDecl.Recv == nil && funcDecl.Name.IsExported() {
-		if containsInvalidTypes(sourcePkg.TypesInfo, sourcePkg.Types, funcDecl.Type) {
-			slog.Debug("Skipping function because it uses unexported or internal types", "func", "Collector.collectFunctionDeclaration", "function", funcDecl.Name.Name)
-			return
+// declareName records that the package-level name of importPath is collected. It returns false
+// if the name was already collected from another declaration, which is then skipped.
+func (c *Collector) declareName(importPath, name string) bool {
+	if c.allPackageDecls[importPath] == nil {
+		c.allPackageDecls[importPath] = &packageDecls{}
+	}
+	pkgDecls := c.allPackageDecls[importPath]
+	if pkgDecls.declared[name] {
+		slog.Debug("Skipping name already declared in package", "func", "Collector.declareName", "package", importPath, "name", name)
+		return false
+	}
+	if pkgDecls.declared == nil {
+		pkgDecls.declared = make(map[string]bool)
+	}
+	pkgDecls.declared[name] = true
+	return true
+}
+
+// undeclaredSpecs returns genDecl with only the names of its value specs that were not already
+// collected, recording them, or nil if there is none.
+func (c *Collector) undeclaredSpecs(genDecl *ast.GenDecl, importPath string) *ast.GenDecl {
+	filtered := *genDecl
+	filtered.Specs = nil
+	for _, spec := range genDecl.Specs {
+		valueSpec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			filtered.Specs = append(filtered.Specs, spec)
+			continue
 		}
-		originalName := funcDecl.Name.Name
+		var names []*ast.Ident
+		for _, name := range valueSpec.Names {
+			if name.Name == "_" || c.declareName(importPath, name.Name) {
+				names = append(names, name)
+			}
+		}
+		if len(names) == len(valueSpec.Names) {
+			filtered.Specs = append(filtered.Specs, spec)
+		} else if len(names) > 0 {
+			// Values are not kept, as the collected declarations refer to the source by name.
+			filtered.Specs = append(filtered.Specs, &ast.ValueSpec{Doc: valueSpec.Doc, Names: names, Type: valueSpec.Type})
+		}
+	}
+	if len(filtered.Specs) == 0 {
+		return nil
+	}
+	return &filtered
+}
 
-		var args []ast.Expr
-		if funcDecl.Type.Params != nil {
-			// Collect all existing parameter names to avoid collisions.
-			existingNames := make(map[string]bool)
-			for _, param := range funcDecl.Type.Params.List {
-				for _, name := range param.Names {
-					if name.Name != "_" {
-						existingNames[name.Name] = true
-					}
-				}
+// isTagged reports whether a symbol with the given doc comments is collected. Without an
+// onlyTagged tag every symbol is; otherwise one of the comments must have a line starting with it.
+func (c *Collector) isTagged(docs ...*ast.CommentGroup) bool {
+	if c.onlyTagged == "" {
+		return true
+	}
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		// The raw comments are used, as CommentGroup.Text drops directive-like lines such as "//adapter:export".
+		for _, comment := range doc.List {
+			text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+			if rest, ok := strings.CutPrefix(text, c.onlyTagged); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+				return true
 			}
+		}
+	}
+	return false
+}
 
-			unnamedParamCounter := 0
-			// generateUniqueName creates a unique parameter name that doesn't conflict with existing ones.
-			generateUniqueName := func() string {
-				for {
-					newName := fmt.Sprintf("p%d", unnamedParamCounter)
-					unnamedParamCounter++
-					if !existingNames[newName] {
-						// Add to existing names to prevent future collisions in the same function.
-						existingNames[newName] = true
-						return newName
-					}
-				}
+// isDeprecated reports whether a symbol with the given doc comments is left out because one of
+// the comments has a line starting with "Deprecated:". It is always false without skipDeprecated.
+func (c *Collector) isDeprecated(docs ...*ast.CommentGroup) bool {
+	if !c.skipDeprecated {
+		return false
+	}
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		for _, line := range strings.Split(doc.Text(), "\n") {
+			if strings.HasPrefix(line, "Deprecated:") {
+				return true
 			}
+		}
+	}
+	return false
+}
 
-			for _, param := range funcDecl.Type.Params.List {
-				if len(param.Names) == 0 {
-					// This is an unnamed parameter, generate a unique name.
-					newName := generateUniqueName()
-					newIdent := ast.NewIdent(newName)
-					param.Names = []*ast.Ident{newIdent}
-					args = append(args, newIdent)
-				} else {
-					for i, name := range param.Names {
-						if name.Name == "_" {
-							// Parameter name is _, generate a unique name.
-							newName := generateUniqueName()
-							newIdent := ast.NewIdent(newName)
-							param.Names[i] = newIdent
-							args = append(args, newIdent)
-						} else {
-							args = append(args, name)
-						}
-					}
-				}
+// currentSpecs returns genDecl without its deprecated specs, or nil if the declaration itself,
+// or each of its specs, is deprecated.
+func (c *Collector) currentSpecs(genDecl *ast.GenDecl) *ast.GenDecl {
+	if !c.skipDeprecated {
+		return genDecl
+	}
+	if c.isDeprecated(genDecl.Doc) {
+		return nil
+	}
+	filtered := *genDecl
+	filtered.Specs = nil
+	for _, spec := range genDecl.Specs {
+		if valueSpec, ok := spec.(*ast.ValueSpec); !ok || !c.isDeprecated(valueSpec.Doc) {
+			filtered.Specs = append(filtered.Specs, spec)
+		}
+	}
+	if len(filtered.Specs) == 0 {
+		return nil
+	}
+	return &filtered
+}
+
+// configPath returns the import path that keys the rules, ignores and other settings of the
+// package imported by importPath: the path it was requested by, if it differs from importPath.
+func (c *Collector) configPath(importPath string) string {
+	if requested, ok := c.configPaths[importPath]; ok {
+		return requested
+	}
+	return importPath
+}
+
+// isIgnored reports whether the symbol name of the given kind in importPath matches one of the ignores.
+func (c *Collector) isIgnored(ruleType interfaces.RuleType, importPath, name string) bool {
+	for _, pkgPath := range []string{"", c.configPath(importPath)} {
+		for _, ignore := range c.ignores[pkgPath][ruleType] {
+			if rules.MatchIgnore(name, ignore) {
+				slog.Debug("Ignoring symbol", "func", "Collector.isIgnored", "package", importPath, "name", name)
+				return true
 			}
 		}
+	}
+	return false
+}
 
-		var callFun ast.Expr = &ast.SelectorExpr{
-			X:   ast.NewIdent(importAlias),
-			Sel: ast.NewIdent(originalName),
+// taggedSpecs returns genDecl with only the specs that are collected: all of them if the
+// declaration itself is tagged, otherwise the tagged ones. It returns nil if none is.
+func (c *Collector) taggedSpecs(genDecl *ast.GenDecl) *ast.GenDecl {
+	if c.isTagged(genDecl.Doc) {
+		return genDecl
+	}
+	filtered := *genDecl
+	filtered.Specs = nil
+	for _, spec := range genDecl.Specs {
+		if valueSpec, ok := spec.(*ast.ValueSpec); ok && c.isTagged(valueSpec.Doc) {
+			filtered.Specs = append(filtered.Specs, spec)
 		}
+	}
+	if len(filtered.Specs) == 0 {
+		return nil
+	}
+	return &filtered
+}
 
-		// Handle generic function calls
-		if funcDecl.Type.TypeParams != nil {
-			var typeArgs []ast.Expr
-			for _, param := range funcDecl.Type.TypeParams.List {
-				for _, name := range param.Names {
-					typeArgs = append(typeArgs, ast.NewIdent(name.Name))
-				}
+func (c *Collector) collectFunctionDeclaration(funcDecl *ast.FuncDecl, sourcePkg *packages.Package, importPath, importAlias string) {
+	if funcDecl.Recv == nil && funcDecl.Name.IsExported() && !c.isIgnored(interfaces.RuleTypeFunc, importPath, funcDecl.Name.Name) {
+		if invalid := findInvalidType(sourcePkg.TypesInfo, c.importerPath, funcDecl.Type); invalid != nil {
+			// A function variable never spells out its type, so it can re-export the function
+			// as long as it needs no instantiation.
+			if c.funcVarFallback && funcDecl.Type.TypeParams == nil {
+				slog.Debug("Re-exporting function as a variable because it uses unexported or internal types", "func", "Collector.collectFunctionDeclaration", "function", funcDecl.Name.Name)
+				c.collectValueDeclaration(valueDeclFromTypes(token.VAR, funcDecl.Name.Name), importPath, importAlias, token.VAR)
+				return
 			}
-			if len(typeArgs) > 0 {
-				if len(typeArgs) == 1 {
-					callFun = &ast.IndexExpr{
-						X:     callFun,
-						Index: typeArgs[0],
-					}
-				} else {
-					callFun = &ast.IndexListExpr{
-						X:       callFun,
-						Indices: typeArgs,
-					}
-				}
+			slog.Debug("Skipping function because it uses unexported or internal types", "func", "Collector.collectFunctionDeclaration", "function", funcDecl.Name.Name)
+			c.skipped = append(c.skipped, SkippedFunction{
+				ImportPath: importPath,
+				Name:       funcDecl.Name.Name,
+				Type:       invalid.Pkg().Path() + "." + invalid.Name(),
+			})
+			return
+		}
+		takesContext := false
+		if params := funcDecl.Type.Params; params != nil && len(params.List) > 0 && sourcePkg.TypesInfo != nil {
+			takesContext = isContextType(sourcePkg.TypesInfo.TypeOf(params.List[0].Type))
+		}
+		c.collectFunctionWrapper(funcDecl, importPath, importAlias, takesContext)
+	}
+}
+
+// collectFunctionWrapper adds a wrapper that forwards to the given exported function.
+// Unnamed parameters of funcDecl are named in place so the wrapper can pass them on.
+// If takesContext is set, the first parameter is a context.Context, which is passed
+// through the context hook, if any, before being forwarded first.
+func (c *Collector) collectFunctionWrapper(funcDecl *ast.FuncDecl, importPath, importAlias string, takesContext bool) {
+	originalName := funcDecl.Name.Name
+
+	// A generic function is forwarded its own type parameters.
+	var typeArgs []ast.Expr
+	if funcDecl.Type.TypeParams != nil {
+		for _, param := range funcDecl.Type.TypeParams.List {
+			for _, name := range param.Names {
+				typeArgs = append(typeArgs, ast.NewIdent(name.Name))
 			}
 		}
+	}
+	newFuncDecl := c.functionWrapper(funcDecl, importPath, importAlias, originalName, typeArgs, takesContext)
 
-		callExpr := &ast.CallExpr{
-			Fun:  callFun,
-			Args: args,
+	if c.allPackageDecls[importPath] == nil {
+		c.allPackageDecls[importPath] = &packageDecls{}
+	}
+	c.allPackageDecls[importPath].funcDecls = append(c.allPackageDecls[importPath].funcDecls, newFuncDecl)
+	if isConstructorName(originalName) && funcDecl.Type.TypeParams == nil {
+		c.allPackageDecls[importPath].constructors = append(c.allPackageDecls[importPath].constructors, newFuncDecl)
+	}
+}
+
+// functionWrapper returns a function declared as funcDecl that forwards to the function sourceName
+// of the source package, instantiated with typeArgs, if any.
+func (c *Collector) functionWrapper(funcDecl *ast.FuncDecl, importPath, importAlias, sourceName string, typeArgs []ast.Expr, takesContext bool) *ast.FuncDecl {
+	var args []ast.Expr
+	// Collect all existing parameter names to avoid collisions.
+	existingNames := make(map[string]bool)
+	if funcDecl.Type.Params != nil {
+		for _, param := range funcDecl.Type.Params.List {
+			for _, name := range param.Names {
+				if name.Name != "_" {
+					existingNames[name.Name] = true
+				}
+			}
 		}
 
-		// Check if the original function is variadic
-		if funcDecl.Type.Params != nil && len(funcDecl.Type.Params.List) > 0 {
-			lastParam := funcDecl.Type.Params.List[len(funcDecl.Type.Params.List)-1]
-			if _, ok := lastParam.Type.(*ast.Ellipsis); ok {
-				// The original function is variadic, so set Ellipsis for the call
-				callExpr.Ellipsis = callExpr.Rparen - 1 // A valid position, just before Rparen
+		unnamedParamCounter := 0
+		// generateUniqueName creates a unique parameter name that doesn't conflict with existing ones.
+		generateUniqueName := func() string {
+			for {
+				newName := fmt.Sprintf("p%d", unnamedParamCounter)
+				unnamedParamCounter++
+				if !existingNames[newName] {
+					// Add to existing names to prevent future collisions in the same function.
+					existingNames[newName] = true
+					return newName
+				}
 			}
 		}
 
-		var results []ast.Stmt
-		if funcDecl.Type.Results != nil && len(funcDecl.Type.Results.List) > 0 {
-			results = []ast.Stmt{&ast.ReturnStmt{Results: []ast.Expr{callExpr}}}
-		} else {
-			results = []ast.Stmt{&ast.ExprStmt{X: callExpr}}
+		for _, param := range funcDecl.Type.Params.List {
+			if len(param.Names) == 0 {
+				// This is an unnamed parameter, generate a unique name.
+				newName := generateUniqueName()
+				newIdent := ast.NewIdent(newName)
+				param.Names = []*ast.Ident{newIdent}
+				args = append(args, newIdent)
+			} else {
+				for i, name := range param.Names {
+					if name.Name == "_" {
+						// Parameter name is _, generate a unique name.
+						newName := generateUniqueName()
+						newIdent := ast.NewIdent(newName)
+						param.Names[i] = newIdent
+						args = append(args, newIdent)
+					} else {
+						args = append(args, name)
+					}
+				}
+			}
 		}
+	}
 
-		newFuncDecl := &ast.FuncDecl{
-			Name: funcDecl.Name,
-			Type: qualifyType(funcDecl.Type, importAlias, nil, nil).(*ast.FuncType),
-			Body: &ast.BlockStmt{List: results},
+	var callFun ast.Expr = &ast.SelectorExpr{
+		X:   ast.NewIdent(importAlias),
+		Sel: ast.NewIdent(sourceName),
+	}
+
+	// Handle generic function calls
+	if len(typeArgs) == 1 {
+		callFun = &ast.IndexExpr{
+			X:     callFun,
+			Index: typeArgs[0],
+		}
+	} else if len(typeArgs) > 1 {
+		callFun = &ast.IndexListExpr{
+			X:       callFun,
+			Indices: typeArgs,
 		}
+	}
 
-		if c.allPackageDecls[importPath] == nil {
-			c.allPackageDecls[importPath] = &packageDecls{}
+	callExpr := &ast.CallExpr{
+		Fun:  callFun,
+		Args: args,
+	}
+
+	// Check if the original function is variadic
+	if funcDecl.Type.Params != nil && len(funcDecl.Type.Params.List) > 0 {
+		lastParam := funcDecl.Type.Params.List[len(funcDecl.Type.Params.List)-1]
+		if _, ok := lastParam.Type.(*ast.Ellipsis); ok {
+			// The original function is variadic, so set Ellipsis for the call
+			callExpr.Ellipsis = callExpr.Rparen - 1 // A valid position, just before Rparen
 		}
-		c.allPackageDecls[importPath].funcDecls = append(c.allPackageDecls[importPath].funcDecls, newFuncDecl)
+	}
+
+	var results []ast.Stmt
+	if takesContext && c.contextHook != "" && len(args) > 0 {
+		results = contextHookStmts(c.contextHook, args[0], importPath+"."+sourceName, existingNames)
+	}
+	if funcDecl.Type.Results != nil && len(funcDecl.Type.Results.List) > 0 {
+		results = append(results, &ast.ReturnStmt{Results: []ast.Expr{callExpr}})
+	} else {
+		results = append(results, &ast.ExprStmt{X: callExpr})
+	}
+
+	return &ast.FuncDecl{
+		Name: funcDecl.Name,
+		Type: qualifyType(funcDecl.Type, importAlias, nil).(*ast.FuncType),
+		Body: &ast.BlockStmt{List: results},
 	}
 }
 
@@ -280,7 +652,7 @@ func (c *Collector) collectValueDeclaration(genDecl *ast.GenDecl, importPath, im
 	for _, spec := range genDecl.Specs {
 		if valueSpec, ok := spec.(*ast.ValueSpec); ok {
 			for _, name := range valueSpec.Names {
-				if name.IsExported() {
+				if name.IsExported() && !c.isIgnored(valueRuleType(tok), importPath, name.Name) {
 					originalName := name.Name
 
 					newSpec := &ast.ValueSpec{
@@ -309,10 +681,86 @@ func (c *Collector) collectValueDeclaration(genDecl *ast.GenDecl, importPath, im
 	}
 }
 
+// typeConstants spells out the types of the typed constants collected from typesPkg, as in
+// `const DefaultTimeout time.Duration = source.DefaultTimeout`. The value alone would keep the
+// type, but the declaration then states it as the source does. Untyped constants stay untyped,
+// and constants whose type cannot be spelled, e.g. an unexported one, are left as they are.
+func (c *Collector) typeConstants(typesPkg *types.Package, importPath, importAlias string) {
+	pkgDecls := c.allPackageDecls[importPath]
+	if pkgDecls == nil {
+		return
+	}
+	for _, decl := range pkgDecls.constDecls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok || len(valueSpec.Names) != 1 || valueSpec.Type != nil {
+				continue
+			}
+			obj, ok := typesPkg.Scope().Lookup(valueSpec.Names[0].Name).(*types.Const)
+			if !ok {
+				continue
+			}
+			if basic, ok := obj.Type().(*types.Basic); ok && basic.Info()&types.IsUntyped != 0 {
+				continue
+			}
+			exprBuilder := newTypeExprBuilder(typesPkg, importAlias, c.importerPath)
+			typeExpr := exprBuilder.expr(obj.Type())
+			if exprBuilder.invalid {
+				slog.Debug("Keeping constant without its type, which cannot be spelled", "func", "Collector.typeConstants", "constant", obj.Name(), "type", obj.Type())
+				continue
+			}
+			c.addImports(exprBuilder.imports)
+			valueSpec.Type = typeExpr
+		}
+	}
+}
+
+// checkRuleSymbols warns about the symbols named by the rules of importPath that sourcePkg does
+// not declare. A symbol may be missing because it is declared in a file excluded by build tags.
+func (c *Collector) checkRuleSymbols(sourcePkg *packages.Package, importPath string) {
+	if sourcePkg.Types == nil {
+		return
+	}
+	for _, name := range c.ruleSymbols[c.configPath(importPath)] {
+		if sourcePkg.Types.Scope().Lookup(name) == nil {
+			slog.Warn("Symbol named by a rule not found in package (check build tags)", "symbol", name, "package", importPath)
+		}
+	}
+}
+
+// checkGeneratedSource warns if files of sourcePkg carry a "Code generated ... DO NOT EDIT."
+// banner. Adapters over generated code break whenever it is regenerated, so adapting the source
+// it is generated from is usually better.
+func checkGeneratedSource(sourcePkg *packages.Package, importPath string) {
+	var files []string
+	for _, file := range sourcePkg.Syntax {
+		if ast.IsGenerated(file) {
+			files = append(files, filepath.Base(sourcePkg.Fset.Position(file.Package).Filename))
+		}
+	}
+	if len(files) > 0 {
+		slog.Warn("Adapting generated code, consider adapting the source it is generated from", "package", importPath, "files", files)
+	}
+}
+
+// valueRuleType returns the rule type of the values declared with tok.
+func valueRuleType(tok token.Token) interfaces.RuleType {
+	if tok == token.CONST {
+		return interfaces.RuleTypeConst
+	}
+	return interfaces.RuleTypeVar
+}
+
 func (c *Collector) applyReplacements() {
 	for importPath, pkgDecls := range c.allPackageDecls {
 		alias := c.pathToAlias[importPath]
-		pkgCtx := interfaces.NewContext().WithValue(interfaces.PackagePathContextKey, importPath)
+		pkgCtx := interfaces.NewContext().
+			WithValue(interfaces.PackagePathContextKey, c.configPath(importPath)).
+			WithValue(interfaces.PackageNameContextKey, c.pathToName[importPath])
 
 		// First, process all type declarations.
 		for i, spec := range pkgDecls.typeSpecs {
@@ -344,7 +792,7 @@ func (c *Collector) applyReplacements() {
 		for i, decl := range pkgDecls.funcDecls {
 			replaced := c.replacer.Apply(pkgCtx, decl)
 			if replacedDecl, ok := replaced.(*ast.FuncDecl); ok {
-				replacedDecl.Type = qualifyType(replacedDecl.Type, alias, nil, nil).(*ast.FuncType)
+				replacedDecl.Type = qualifyType(replacedDecl.Type, alias, nil).(*ast.FuncType)
 				pkgDecls.funcDecls[i] = replacedDecl
 			}
 		}
@@ -362,6 +810,25 @@ func newAliasManager() *aliasManager {
 	}
 }
 
+// reserve seeds the manager with the forced aliases of packages before any alias is derived, so
+// that derived aliases give way to them.
+func (m *aliasManager) reserve(packages []*PackageInfo) error {
+	for _, pkg := range packages {
+		alias := pkg.ForceAlias
+		if alias == "" {
+			continue
+		}
+		if !token.IsIdentifier(alias) {
+			return fmt.Errorf("forced alias %q of package %s is not a valid identifier", alias, pkg.ImportPath)
+		}
+		if existingPath, exists := m.usedAliases[alias]; exists && existingPath != pkg.ImportPath {
+			return fmt.Errorf("forced alias %q is used by both %s and %s", alias, existingPath, pkg.ImportPath)
+		}
+		m.usedAliases[alias] = pkg.ImportPath
+	}
+	return nil
+}
+
 func (m *aliasManager) generateAlias(importPath, baseName string) string {
 	// Sanitize the name to make sure it's a valid Go identifier.
 	alias := sanitizePackageName(baseName)
@@ -449,7 +916,24 @@ func sanitizePackageName(name string) string {
 // Collect method to use the new alias manager
 func (c *Collector) Collect(packages []*PackageInfo) error {
 	aliasMgr := newAliasManager()
+	if err := aliasMgr.reserve(packages); err != nil {
+		return err
+	}
+	for _, pkg := range packages {
+		if pkg.Registry != "" && !token.IsIdentifier(pkg.Registry) {
+			return fmt.Errorf("registry name %q of package %s is not a valid identifier", pkg.Registry, pkg.ImportPath)
+		}
+	}
+	if c.contextHook != "" && !token.IsIdentifier(c.contextHook) {
+		return fmt.Errorf("context hook %q is not a valid identifier", c.contextHook)
+	}
+	for _, pkg := range packages {
+		if !isAllowedImport(pkg.ImportPath, c.allowedImports) {
+			return fmt.Errorf("package %s is not allowed by allowed_imports %v", pkg.ImportPath, c.allowedImports)
+		}
+	}
 	processedPaths := make(map[string]bool) // Keep track of processed package paths
+	plainImport := c.plainImport && isSinglePackage(packages)
 
 	for _, pkg := range packages {
 		// If we have already processed this package path, skip it.
@@ -457,46 +941,213 @@ func (c *Collector) Collect(packages []*PackageInfo) error {
 			continue
 		}
 
-		sourcePkg, err := c.loadPackage(pkg.ImportPath)
+		requestedPath := pkg.ImportPath
+		sourcePkg, err := c.loader.Load(requestedPath)
 		if err != nil {
 			return err
 		}
 		if sourcePkg == nil {
-			slog.Warn("package not found, skipping", "path", pkg.ImportPath)
+			slog.Warn("package not found, skipping", "path", requestedPath)
+			continue
+		}
+		if processedPaths[sourcePkg.PkgPath] {
 			continue
 		}
 
-		// Determine the base name for the alias, in order of priority:
-		// 1. Alias from config.
-		// 2. Actual package name from source.
-		// 3. Base of the import path.
-		var baseName string
-		if pkg.ImportAlias != "" {
-			baseName = pkg.ImportAlias
-		} else {
-			baseName = sourcePkg.Name
+		if plainImport {
+			// The package is referred to by its own name, whatever alias is configured.
+			pkg.ImportAlias, pkg.ForceAlias = "", ""
+		}
+		c.collectPackage(pkg, sourcePkg, aliasMgr)
+		if spec := c.importSpecs[pkg.ImportPath]; plainImport && spec.Name.Name == path.Base(pkg.ImportPath) {
+			spec.Name = nil
+		}
+		if err := c.collectInstantiations(pkg, sourcePkg.Types); err != nil {
+			return err
+		}
+
+		// Mark this path as processed, under both the requested and the canonical path.
+		processedPaths[requestedPath] = true
+		processedPaths[pkg.ImportPath] = true
+	}
+
+	if c.replacer != nil {
+		c.applyReplacements()
+	}
+	c.localizeConstructorResults()
+
+	for _, pkgDecls := range c.allPackageDecls {
+		pkgDecls.typeSpecs = append(pkgDecls.typeSpecs, pkgDecls.namedSpecs...)
+		pkgDecls.funcDecls = append(pkgDecls.funcDecls, pkgDecls.namedFuncs...)
+	}
+
+	if c.failOnEmptyPackage {
+		if empty := c.emptyPackages(packages); len(empty) > 0 {
+			return fmt.Errorf("packages contribute no declarations, check their rules and ignores: %s", strings.Join(empty, ", "))
 		}
+	}
 
-		importAlias := aliasMgr.generateAlias(pkg.ImportPath, baseName)
+	return nil
+}
 
-		c.pathToAlias[pkg.ImportPath] = importAlias
-		c.importSpecs[pkg.ImportPath] = &ast.ImportSpec{
-			Path: &ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("\"%s\"", pkg.ImportPath)},
-			Name: &ast.Ident{Name: importAlias},
+// isSinglePackage reports whether packages request a single package.
+func isSinglePackage(packages []*PackageInfo) bool {
+	for _, pkg := range packages {
+		if pkg.ImportPath != packages[0].ImportPath {
+			return false
 		}
-		pkg.ImportAlias = importAlias
+	}
+	return len(packages) > 0
+}
 
-		// Mark this path as processed.
-		processedPaths[pkg.ImportPath] = true
+// isAllowedImport reports whether importPath is, or is below, one of the prefixes. Prefixes match
+// whole path elements, so github.com/example/lib allows github.com/example/lib/sub but not
+// github.com/example/library. Without prefixes, every import path is allowed.
+func isAllowedImport(importPath string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if importPath == prefix || strings.HasPrefix(importPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// emptyPackages returns the import paths of the packages that contribute no declarations,
+// because they were not found, export nothing, or all their symbols are ignored.
+func (c *Collector) emptyPackages(packages []*PackageInfo) []string {
+	var empty []string
+	seen := make(map[string]bool)
+	for _, pkg := range packages {
+		if seen[pkg.ImportPath] {
+			continue
+		}
+		seen[pkg.ImportPath] = true
+		if c.declCount(pkg.ImportPath) == 0 {
+			empty = append(empty, pkg.ImportPath)
+		}
+	}
+	return empty
+}
+
+// declCount returns the number of declarations collected from the package at importPath.
+func (c *Collector) declCount(importPath string) int {
+	pkgDecls := c.allPackageDecls[importPath]
+	if pkgDecls == nil {
+		return 0
+	}
+	return len(pkgDecls.typeSpecs) + len(pkgDecls.varDecls) + len(pkgDecls.constDecls) + len(pkgDecls.funcDecls)
+}
+
+// sourceVersion returns the version pinned for pkg, or else the version of the module providing
+// sourcePkg. Packages of the main module, and of modules replaced by a directory, have no version.
+func sourceVersion(pkg *PackageInfo, sourcePkg *packages.Package) string {
+	if pkg.Version != "" {
+		return pkg.Version
+	}
+	module := sourcePkg.Module
+	if module == nil {
+		return ""
+	}
+	if module.Replace != nil {
+		return module.Replace.Version
+	}
+	return module.Version
+}
+
+// collectPackage collects the declarations of a loaded source package.
+// Packages without syntax, such as those only available as export data, are
+// collected from their type information instead.
+func (c *Collector) collectPackage(pkg *PackageInfo, sourcePkg *packages.Package, aliasMgr *aliasManager) {
+	// Determine the base name for the alias, in order of priority:
+	// 1. Alias from config.
+	// 2. Actual package name from source.
+	// 3. Base of the import path.
+	var baseName string
+	if pkg.ImportAlias != "" {
+		baseName = pkg.ImportAlias
+	} else {
+		baseName = sourcePkg.Name
+	}
+
+	// Import the package by its canonical path as reported by the loader, which is the
+	// module path even when the package was requested by directory, e.g. one that is the
+	// target of a replace directive in go.mod.
+	if sourcePkg.PkgPath != "" && sourcePkg.PkgPath != pkg.ImportPath {
+		slog.Debug("Using canonical import path", "func", "Collector.collectPackage", "requested", pkg.ImportPath, "canonical", sourcePkg.PkgPath)
+		c.configPaths[sourcePkg.PkgPath] = pkg.ImportPath
+		pkg.ImportPath = sourcePkg.PkgPath
+	}
+
+	importAlias := pkg.ForceAlias
+	if importAlias != "" {
+		// The alias was reserved under the requested path, which may differ from the canonical one.
+		aliasMgr.usedAliases[importAlias] = pkg.ImportPath
+	} else {
+		importAlias = aliasMgr.generateAlias(pkg.ImportPath, baseName)
+	}
+
+	c.pathToAlias[pkg.ImportPath] = importAlias
+	c.pathToName[pkg.ImportPath] = sourcePkg.Name
+	if sourcePkg.Types != nil {
+		c.pathToTypes[pkg.ImportPath] = sourcePkg.Types
+	}
+	c.importSpecs[pkg.ImportPath] = &ast.ImportSpec{
+		Path: &ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("\"%s\"", pkg.ImportPath)},
+		Name: &ast.Ident{Name: importAlias},
+	}
+	pkg.ImportAlias = importAlias
+
+	if pkg.Registry != "" {
+		c.registries[pkg.ImportPath] = pkg.Registry
+		c.addImports(map[string]*ast.ImportSpec{
+			"reflect": {Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("reflect")}},
+		})
+	}
 
+	if version := sourceVersion(pkg, sourcePkg); version != "" {
+		c.versions[pkg.ImportPath] = version
+	}
+
+	c.checkRuleSymbols(sourcePkg, pkg.ImportPath)
+	if c.warnGeneratedSource {
+		checkGeneratedSource(sourcePkg, pkg.ImportPath)
+	}
+
+	if len(sourcePkg.Syntax) == 0 && sourcePkg.Types != nil {
+		if c.onlyTagged != "" {
+			// Export data has no doc comments, so none of its symbols can carry the tag.
+			slog.Warn("No syntax available to find tagged symbols, skipping package", "package", pkg.ImportPath, "tag", c.onlyTagged)
+		} else {
+			slog.Debug("No syntax available, collecting from export data", "func", "Collector.collectPackage", "package", pkg.ImportPath)
+			if c.skipDeprecated {
+				// Export data has no doc comments either, so deprecated symbols cannot be told apart.
+				slog.Warn("No syntax available to find deprecated symbols, collecting all of them", "package", pkg.ImportPath)
+			}
+			c.collectFromTypes(sourcePkg.Types, pkg.ImportPath, importAlias)
+		}
+	} else {
 		c.collectImports(sourcePkg)
 		c.collectTypeDeclarations(sourcePkg, pkg.ImportPath, importAlias)
 		c.collectOtherDeclarations(sourcePkg, pkg.ImportPath, importAlias)
 	}
 
-	if c.replacer != nil {
-		c.applyReplacements()
+	if sourcePkg.Types != nil {
+		c.typeConstants(sourcePkg.Types, pkg.ImportPath, importAlias)
 	}
 
-	return nil
+	if sourcePkg.Types != nil && c.stubDisabledMethods {
+		c.collectMethodStubs(sourcePkg.Types, pkg.ImportPath, importAlias)
+	}
+
+	if sourcePkg.Types != nil && c.replacer != nil {
+		c.collectEmbeddedFieldAccessors(sourcePkg.Types, pkg.ImportPath, importAlias)
+	}
+
+	if sourcePkg.Types != nil && len(c.extractInterfaces) > 0 {
+		c.collectExtractedInterfaces(sourcePkg.Types, pkg.ImportPath, importAlias)
+	}
 }
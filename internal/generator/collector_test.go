@@ -1,7 +1,27 @@
 package generator
 
 import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/origadmin/adptool/internal/compiler"
+	"github.com/origadmin/adptool/internal/config"
+	"github.com/origadmin/adptool/internal/testutil"
+	"github.com/origadmin/adptool/internal/util"
 )
 
 func TestSanitizePackageName(t *testing.T) {
@@ -55,4 +75,781 @@ func TestSanitizePackageName(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestCollector_ExportDataFallback(t *testing.T) {
+	const importPath = "github.com/origadmin/adptool/testdata/pkgs/source3"
+
+	// Loading without NeedSyntax yields types from export data only, as for a binary-only package.
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedTypes}, importPath)
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+	require.Empty(t, pkgs[0].Errors)
+	require.Empty(t, pkgs[0].Syntax, "the package must not have syntax for this test")
+	require.NotNil(t, pkgs[0].Types)
+
+	collector := NewCollector(nil)
+	collector.collectPackage(&PackageInfo{ImportPath: importPath, ImportAlias: "source"}, pkgs[0], newAliasManager())
+
+	outputBuffer := &bytes.Buffer{}
+	builder := NewBuilder("exportdatatest", "", "").WithFormatCode(false)
+	builder.writer = outputBuffer
+	builder.Build(collector)
+	require.NoError(t, builder.Write())
+
+	got, err := format.Source(outputBuffer.Bytes())
+	require.NoError(t, err, "generated code could not be formatted")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+
+	// The export data path should re-export the same symbols as the source path.
+	fromSource := generateForTest(t, &config.Config{
+		PackageName: "exportdatatest",
+		Packages:    []*config.Package{{Import: importPath, Alias: "source"}},
+	})
+	assert.Equal(t, declaredNamesOf(t, fromSource), declaredNamesOf(t, got))
+}
+
+func TestCollector_PackageLoader(t *testing.T) {
+	const importPath = "example.com/fake"
+	const src = `package fake
+
+type Widget struct{ Name string }
+
+func NewWidget(name string) *Widget { return &Widget{Name: name} }
+
+const Size = 3
+
+var Default = NewWidget("default")
+`
+	// A synthetic package, type-checked in memory, stands in for one loaded from the module graph.
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "fake.go", src, parser.ParseComments)
+	require.NoError(t, err)
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}, Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}}
+	typesPkg, err := new(types.Config).Check(importPath, fset, []*ast.File{file}, info)
+	require.NoError(t, err)
+
+	var requested []string
+	loader := PackageLoaderFunc(func(path string) (*packages.Package, error) {
+		requested = append(requested, path)
+		return &packages.Package{
+			ID:        path,
+			Name:      typesPkg.Name(),
+			PkgPath:   path,
+			Fset:      fset,
+			Syntax:    []*ast.File{file},
+			Types:     typesPkg,
+			TypesInfo: info,
+		}, nil
+	})
+
+	compiledCfg, err := compiler.Compile(&config.Config{
+		PackageName: "adapters",
+		Packages:    []*config.Package{{Import: importPath}},
+		Types:       []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Prefix: "Fake"}}},
+	})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	generator := NewGenerator("adapters", "", compiler.NewReplacer(compiledCfg), "").
+		WithPackageLoader(loader).
+		WithFormatCode(false)
+	generator.builder.writer = &buf
+	require.NoError(t, generator.Generate([]*PackageInfo{{ImportPath: importPath}}))
+
+	assert.Equal(t, []string{importPath}, requested)
+	got := buf.String()
+	assert.Contains(t, got, `fake "example.com/fake"`)
+	assert.Regexp(t, `FakeWidget\s+= fake\.Widget`, got)
+	assert.Contains(t, got, "func NewWidget(name string) *FakeWidget {", "the constructor returns the renamed alias")
+	assert.Regexp(t, `Size\s+= fake\.Size`, got)
+	assert.Regexp(t, `Default\s+= fake\.Default`, got)
+}
+
+func TestCollector_ReplacedModuleImportPath(t *testing.T) {
+	// A module that consumes example.com/lib through a replace directive pointing at a local directory.
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"go.mod":                 "module example.com/app\n\ngo 1.24\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ./third_party/lib\n",
+		"third_party/lib/go.mod": "module example.com/lib\n\ngo 1.24\n",
+		"third_party/lib/lib.go": "package lib\n\ntype Client struct{}\n\nfunc NewClient() *Client { return &Client{} }\n",
+	})
+	t.Chdir(dir)
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+
+	got := generateForTest(t, &config.Config{
+		PackageName: "adapters",
+		Packages:    []*config.Package{{Import: "example.com/lib"}},
+	})
+	file, err := parser.ParseFile(token.NewFileSet(), "", got, parser.ImportsOnly)
+	require.NoError(t, err)
+	require.Len(t, file.Imports, 1)
+	assert.Equal(t, `"example.com/lib"`, file.Imports[0].Path.Value, "the import must use the module path, not the replacement directory")
+	assert.Contains(t, string(got), "NewClient()")
+}
+
+func TestCollector_SkippedFunctions(t *testing.T) {
+	const importPath = "github.com/origadmin/adptool/testdata/pkgs/source3"
+
+	var gen *Generator
+	got := generateForTest(t, &config.Config{
+		PackageName: "skippedtest",
+		Packages:    []*config.Package{{Import: importPath, Alias: "source"}},
+	}, func(g *Generator) { gen = g })
+
+	assert.NotContains(t, string(got), "ExportedFunctionWithUnexportedParam")
+	assert.Contains(t, gen.SkippedFunctions(), SkippedFunction{
+		ImportPath: importPath,
+		Name:       "ExportedFunctionWithUnexportedParam",
+		Type:       importPath + ".unexportedStruct",
+	})
+}
+
+func TestCollector_FuncVarFallback(t *testing.T) {
+	const importPath = "github.com/origadmin/adptool/testdata/pkgs/source3"
+
+	var gen *Generator
+	got := generateForTest(t, &config.Config{
+		PackageName: "fallbacktest",
+		Packages:    []*config.Package{{Import: importPath, Alias: "source"}},
+	}, func(g *Generator) { gen = g.WithFuncVarFallback(true) })
+
+	assert.Regexp(t, `ExportedFunctionWithUnexportedParam\s+= source\.ExportedFunctionWithUnexportedParam`, string(got))
+	for _, skipped := range gen.SkippedFunctions() {
+		assert.NotEqual(t, "ExportedFunctionWithUnexportedParam", skipped.Name)
+	}
+}
+
+func TestCollector_SameNameDeclarations(t *testing.T) {
+	const importPath = "github.com/origadmin/adptool/testdata/pkgs/samename"
+	// The package declares Client as a type and as a method of it, and Name as a method and as
+	// a function, in two files.
+	collect := func(t *testing.T, loader PackageLoader) map[string]int {
+		collector := NewCollector(nil)
+		collector.loader = loader
+		require.NoError(t, collector.Collect([]*PackageInfo{{ImportPath: importPath}}))
+		pkgDecls := collector.allPackageDecls[importPath]
+		require.NotNil(t, pkgDecls)
+
+		counts := make(map[string]int)
+		for _, spec := range pkgDecls.typeSpecs {
+			counts[spec.(*ast.TypeSpec).Name.Name]++
+		}
+		for _, decl := range append(pkgDecls.varDecls, pkgDecls.constDecls...) {
+			for _, spec := range decl.(*ast.GenDecl).Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					counts[name.Name]++
+				}
+			}
+		}
+		for _, decl := range pkgDecls.funcDecls {
+			counts[decl.(*ast.FuncDecl).Name.Name]++
+		}
+		return counts
+	}
+	want := map[string]int{"Client": 1, "Default": 1, "Name": 1, "Version": 1}
+
+	t.Run("methods", func(t *testing.T) {
+		assert.Equal(t, want, collect(t, DefaultPackageLoader{}), "methods must not be collected as functions")
+	})
+
+	t.Run("file listed twice", func(t *testing.T) {
+		loader := PackageLoaderFunc(func(path string) (*packages.Package, error) {
+			pkg, err := DefaultPackageLoader{}.Load(path)
+			if pkg != nil {
+				pkg.Syntax = append(pkg.Syntax, pkg.Syntax...)
+			}
+			return pkg, err
+		})
+		assert.Equal(t, want, collect(t, loader), "each name must be collected once")
+	})
+}
+
+func TestCollector_InternalTypes(t *testing.T) {
+	const importPath = "github.com/origadmin/adptool/testdata/pkgs/withinternal"
+	generate := func(importerPath string) (string, *Generator) {
+		var gen *Generator
+		got := generateForTest(t, &config.Config{
+			PackageName: "adapters",
+			Packages:    []*config.Package{{Import: importPath, Alias: "source"}},
+		}, func(g *Generator) { gen = g.WithImporterPath(importerPath) })
+		return string(got), gen
+	}
+	skippedOpen := SkippedFunction{ImportPath: importPath, Name: "Open", Type: importPath + "/internal/store.Store"}
+
+	t.Run("same module", func(t *testing.T) {
+		got, gen := generate(importPath + "/adapters")
+		assert.Contains(t, got, "func Open(name string) *store.Store {")
+		assert.Contains(t, got, `"`+importPath+`/internal/store"`)
+		assert.Empty(t, gen.SkippedFunctions())
+	})
+
+	t.Run("other module", func(t *testing.T) {
+		got, gen := generate("example.com/other/adapters")
+		assert.NotContains(t, got, "func Open(")
+		assert.Contains(t, got, "func Version() string {")
+		assert.Equal(t, []SkippedFunction{skippedOpen}, gen.SkippedFunctions())
+	})
+
+	t.Run("sibling of the internal tree", func(t *testing.T) {
+		_, gen := generate("github.com/origadmin/adptool/testdata/pkgs/withinternalx")
+		assert.Equal(t, []SkippedFunction{skippedOpen}, gen.SkippedFunctions(), "a shared path prefix is not the same tree")
+	})
+
+	t.Run("unknown importer", func(t *testing.T) {
+		_, gen := generate("")
+		assert.Equal(t, []SkippedFunction{skippedOpen}, gen.SkippedFunctions())
+	})
+}
+
+func TestCanImportInternal(t *testing.T) {
+	tests := []struct {
+		pkgPath, importerPath string
+		want                  bool
+	}{
+		{"example.com/app/lib", "", true},
+		{"example.com/app/internal/db", "example.com/app", true},
+		{"example.com/app/internal/db", "example.com/app/adapters", true},
+		{"example.com/app/internal", "example.com/app/adapters", true},
+		{"example.com/app/internal/db", "example.com/other", false},
+		{"example.com/app/internal/db", "example.com/application", false},
+		{"example.com/app/internal/db", "", false},
+		{"example.com/app/internal/db/internal/conn", "example.com/app/adapters", false},
+		{"example.com/app/internal/db/internal/conn", "example.com/app/internal/db/pool", true},
+		{"internal/poll", "example.com/app", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, canImportInternal(tt.pkgPath, tt.importerPath), "canImportInternal(%q, %q)", tt.pkgPath, tt.importerPath)
+	}
+}
+
+func TestCollector_ForceAlias(t *testing.T) {
+	const (
+		hyphenPath = "github.com/origadmin/adptool/testdata/pkgs/source-pkg4"
+		dotPath    = "github.com/origadmin/adptool/testdata/pkgs/source.pkg4"
+	)
+
+	// Both packages are named sourcepkg4. The forced alias of the second one is reserved before
+	// collection, so the first one, collected earlier, gets a derived alias instead.
+	collector := NewCollector(nil)
+	require.NoError(t, collector.Collect([]*PackageInfo{
+		{ImportPath: hyphenPath},
+		{ImportPath: dotPath, ForceAlias: "sourcepkg4"},
+	}))
+	assert.Equal(t, "sourcepkg4", collector.pathToAlias[dotPath])
+	assert.Equal(t, "sourcepkg41", collector.pathToAlias[hyphenPath])
+	assert.Equal(t, "sourcepkg4", collector.importSpecs[dotPath].Name.Name)
+
+	err := NewCollector(nil).Collect([]*PackageInfo{
+		{ImportPath: hyphenPath, ForceAlias: "pkg4"},
+		{ImportPath: dotPath, ForceAlias: "pkg4"},
+	})
+	assert.ErrorContains(t, err, `forced alias "pkg4" is used by both`)
+
+	err = NewCollector(nil).Collect([]*PackageInfo{{ImportPath: dotPath, ForceAlias: "source.pkg4"}})
+	assert.ErrorContains(t, err, "not a valid identifier")
+}
+
+func TestCollector_AllowedImports(t *testing.T) {
+	const source1 = "github.com/origadmin/adptool/testdata/pkgs/source1"
+
+	allowed := NewCollector(nil)
+	allowed.allowedImports = []string{"github.com/origadmin/adptool/testdata/pkgs/"}
+	require.NoError(t, allowed.Collect([]*PackageInfo{{ImportPath: source1}}))
+	assert.NotEmpty(t, allowed.allPackageDecls[source1].typeSpecs)
+
+	disallowed := NewCollector(nil)
+	disallowed.allowedImports = []string{"github.com/origadmin/adptool/testdata/pkgs/source2"}
+	err := disallowed.Collect([]*PackageInfo{{ImportPath: source1}})
+	assert.ErrorContains(t, err, "package "+source1+" is not allowed by allowed_imports")
+	assert.Empty(t, disallowed.allPackageDecls, "nothing is collected from a disallowed package")
+}
+
+func TestIsAllowedImport(t *testing.T) {
+	prefixes := []string{"github.com/example/lib", "example.org/tools/"}
+	for importPath, want := range map[string]bool{
+		"github.com/example/lib":         true,
+		"github.com/example/lib/sub":     true,
+		"github.com/example/library":     false,
+		"github.com/example":             false,
+		"example.org/tools/x":            true,
+		"example.org/tools":              true,
+		"golang.org/x/tools/go/packages": false,
+	} {
+		assert.Equal(t, want, isAllowedImport(importPath, prefixes), importPath)
+	}
+	assert.True(t, isAllowedImport("anything", nil), "all packages are allowed without prefixes")
+}
+
+func TestCollector_RelativeImportPackageRules(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"go.mod":     "module example.com/app\n\ngo 1.24\n",
+		"lib/lib.go": "package lib\n\ntype Client struct{}\n\nfunc NewClient() *Client { return &Client{} }\n\nfunc Close() {}\n",
+	})
+	t.Chdir(dir)
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "adapters",
+		Packages: []*config.Package{{
+			Import:    "./lib",
+			Types:     []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Prefix: "Pkg"}}},
+			Functions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"Close"}}}},
+		}},
+	}))
+	assert.Contains(t, got, `"example.com/app/lib"`, "the package is imported by its canonical path")
+	assert.Regexp(t, `PkgClient\s+= lib\.Client`, got, "the rules of the package requested by directory apply")
+	assert.Contains(t, got, "func NewClient() *PkgClient")
+	assert.NotContains(t, got, "Close", "the ignores of the package requested by directory apply")
+}
+
+// writeTestFiles writes files, keyed by slash-separated path relative to dir.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func declaredNamesOf(t *testing.T, src []byte) map[string]bool {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution)
+	require.NoError(t, err)
+	return declaredNames(file)
+}
+
+func TestCollector_OnlyTagged(t *testing.T) {
+	names := declaredNamesOf(t, generateForTest(t, &config.Config{
+		PackageName: "taggedtest",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/tagged"}},
+		OnlyTagged:  "adapter:export",
+	}))
+	for _, name := range []string{"Client", "NewClient", "DefaultPort", "DefaultHost", "Version"} {
+		assert.True(t, names[name], "tagged symbol %s must be generated", name)
+	}
+	for _, name := range []string{"Server", "NewServer", "Helper", "Build"} {
+		assert.False(t, names[name], "untagged symbol %s must not be generated", name)
+	}
+
+	all := declaredNamesOf(t, generateForTest(t, &config.Config{
+		PackageName: "taggedtest",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/tagged"}},
+	}))
+	assert.True(t, all["Server"], "without a tag every exported symbol is generated")
+}
+
+func TestCollector_SkipDeprecated(t *testing.T) {
+	cfg := &config.Config{
+		PackageName: "deprecatedtest",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/deprecated"}},
+	}
+	all := declaredNamesOf(t, generateForTest(t, cfg))
+	for _, name := range []string{"Client", "OldClient", "NewClient", "Connect", "Timeout", "DefaultTimeout", "LegacyHost", "Host"} {
+		assert.True(t, all[name], "without skip_deprecated symbol %s must be generated", name)
+	}
+
+	cfg.SkipDeprecated = true
+	current := declaredNamesOf(t, generateForTest(t, cfg))
+	for _, name := range []string{"Client", "NewClient", "Timeout", "Host"} {
+		assert.True(t, current[name], "current symbol %s must be generated", name)
+	}
+	for _, name := range []string{"OldClient", "Connect", "DefaultTimeout", "LegacyHost", "LegacyPort"} {
+		assert.False(t, current[name], "deprecated symbol %s must not be generated", name)
+	}
+}
+
+func TestCollector_Ignores(t *testing.T) {
+	names := declaredNamesOf(t, generateForTest(t, &config.Config{
+		PackageName: "ignoretest",
+		Ignores:     []string{"Build"},
+		Constants:   []*config.ConstRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"re:Port$"}}}},
+		Packages: []*config.Package{{
+			Import:    "github.com/origadmin/adptool/testdata/pkgs/tagged",
+			Types:     []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"re:^Serv", "Helper"}}}},
+			Functions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"New*"}}}},
+		}},
+	}))
+	for _, name := range []string{"Build", "DefaultPort", "Server", "NewClient", "NewServer"} {
+		assert.False(t, names[name], "ignored symbol %s must not be generated", name)
+	}
+	for _, name := range []string{"Client", "DefaultHost", "Version"} {
+		assert.True(t, names[name], "symbol %s must be generated", name)
+	}
+	assert.True(t, names["Helper"], "type ignores must not apply to functions")
+}
+
+func TestCollector_FailOnEmptyPackage(t *testing.T) {
+	ignoreAll := config.RuleSet{Ignores: []string{"*"}}
+	compiledCfg, err := compiler.Compile(&config.Config{
+		PackageName: "emptytest",
+		Packages: []*config.Package{
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source1"},
+			{
+				Import:    "github.com/origadmin/adptool/testdata/pkgs/tagged",
+				Types:     []*config.TypeRule{{Name: "*", RuleSet: ignoreAll}},
+				Functions: []*config.FuncRule{{Name: "*", RuleSet: ignoreAll}},
+				Variables: []*config.VarRule{{Name: "*", RuleSet: ignoreAll}},
+				Constants: []*config.ConstRule{{Name: "*", RuleSet: ignoreAll}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	var packageInfos []*PackageInfo
+	for _, pkg := range compiledCfg.Packages {
+		packageInfos = append(packageInfos, &PackageInfo{ImportPath: pkg.ImportPath, ImportAlias: pkg.ImportAlias})
+	}
+
+	generate := func(fail bool) error {
+		gen := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").
+			WithIgnores(compiledCfg.IgnoresByPackageAndType).
+			WithFailOnEmptyPackage(fail).
+			WithWriter(io.Discard)
+		return gen.Generate(packageInfos)
+	}
+	require.NoError(t, generate(false), "an empty package is allowed by default")
+	err = generate(true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "github.com/origadmin/adptool/testdata/pkgs/tagged")
+	assert.NotContains(t, err.Error(), "source1", "packages with declarations are not reported")
+}
+
+func TestCollector_QualifiedIgnores(t *testing.T) {
+	// Both packages export CommonStruct; only the one of the package aliased "other" is ignored.
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "qualifiedignoretest",
+		Ignores:     []string{"other.CommonStruct"},
+		Packages: []*config.Package{
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"},
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source2", Alias: "other"},
+		},
+	}))
+	assert.Regexp(t, `CommonStruct\s+= source\.CommonStruct`, got)
+	assert.NotContains(t, got, "other.CommonStruct")
+	assert.Contains(t, got, "other.", "other symbols of the package are still generated")
+}
+
+func TestCollector_RuleSymbolNotFound(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	cfg := &config.Config{
+		PackageName: "buildtagtest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/buildtag",
+			Functions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{
+				{From: "Extra", To: "MoreExtra"},
+				{From: "Common", To: "Shared"},
+			}}}},
+		}},
+	}
+	compiledCfg, err := compiler.Compile(cfg)
+	require.NoError(t, err)
+	names := declaredNamesOf(t, generateForTest(t, cfg, func(g *Generator) {
+		g.WithRuleSymbols(compiler.RuleSymbols(compiledCfg))
+	}))
+	assert.True(t, names["Shared"])
+
+	assert.Contains(t, logs.String(), "check build tags")
+	assert.Contains(t, logs.String(), "symbol=Extra package=github.com/origadmin/adptool/testdata/pkgs/buildtag")
+	assert.NotContains(t, logs.String(), "symbol=Common")
+}
+
+func TestCollector_WarnGeneratedSource(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	cfg := &config.Config{
+		PackageName: "generatedtest",
+		Packages: []*config.Package{
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/generated"},
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source1"},
+		},
+	}
+	generateForTest(t, cfg)
+	assert.NotContains(t, logs.String(), "Adapting generated code", "the warning is optional")
+
+	generateForTest(t, cfg, func(g *Generator) { g.WithWarnGeneratedSource(true) })
+	assert.Contains(t, logs.String(), `msg="Adapting generated code, consider adapting the source it is generated from" package=github.com/origadmin/adptool/testdata/pkgs/generated files=[generated.go]`)
+	assert.NotContains(t, logs.String(), "pkgs/source1", "packages without generated files are not reported")
+}
+
+func TestCollector_VarAccessors(t *testing.T) {
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "accessortest",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source3", Alias: "source"}},
+	}, func(g *Generator) { g.WithVarAccessors(true) }))
+
+	assert.Contains(t, got, "func GetStatsCounter() int64 {\n\treturn source.StatsCounter\n}")
+	assert.Contains(t, got, "func SetStatsCounter(v int64) {\n\tsource.StatsCounter = v\n}")
+	assert.NotRegexp(t, `StatsCounter\s+= source\.StatsCounter`, got, "a value-typed variable must not be re-exported as a copy")
+	assert.Regexp(t, `Processors\s+= source\.Processors`, got, "reference-typed variables are still re-exported as variables")
+	assert.Regexp(t, `DefaultWorker\s+= source\.DefaultWorker`, got)
+}
+
+func TestCollector_VarAccessorsShareState(t *testing.T) {
+	out := runWithAdapter(t, map[string]string{
+		"lib/lib.go": "package lib\n\nvar Counter int64\n\nvar Limits struct{ Max int }\n",
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/app/adapters"
+	"example.com/app/lib"
+)
+
+func main() {
+	adapters.SetCounter(5)
+	lib.Counter++
+	limits := adapters.GetLimits()
+	limits.Max = 3
+	adapters.SetLimits(limits)
+	fmt.Print(adapters.GetCounter(), " ", lib.Limits.Max)
+}
+`,
+	}, func(g *Generator) { g.WithVarAccessors(true) })
+	assert.Equal(t, "6 3", out, "the accessors must read and write the source variables")
+}
+
+func TestCollector_SourceInitRunsFirst(t *testing.T) {
+	// Variables re-exported by the adapter are initialized after the source package, including
+	// its init functions, as the adapter imports it.
+	out := runWithAdapter(t, map[string]string{
+		"lib/lib.go": `package lib
+
+var Processors map[string]string
+
+var Ready bool
+
+func init() {
+	Processors = map[string]string{"default": "ok"}
+	Ready = true
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/app/adapters"
+)
+
+func main() {
+	fmt.Print(adapters.Processors["default"], " ", adapters.Ready)
+}
+`,
+	})
+	assert.Equal(t, "ok true", out)
+}
+
+func TestCollector_RenamedFuncTypeVariable(t *testing.T) {
+	// The re-exported map holds values of the renamed function type, in both directions.
+	out := runWithAdapterConfig(t, map[string]string{
+		"lib/lib.go": `package lib
+
+import "strings"
+
+type ProcessFunc func(string) string
+
+var Processors = map[string]ProcessFunc{"upper": strings.ToUpper}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/app/adapters"
+	"example.com/app/lib"
+)
+
+func main() {
+	var upper adapters.Handler = adapters.Processors["upper"]
+	adapters.Processors["lower"] = adapters.Handler(strings.ToLower)
+	fmt.Print(upper("a"), " ", lib.Processors["lower"]("B"))
+}
+`,
+	}, &config.Package{
+		Types: []*config.TypeRule{{
+			Name:    "ProcessFunc",
+			RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "ProcessFunc", To: "Handler"}}},
+		}},
+	})
+	assert.Equal(t, "A b", out)
+}
+
+func TestCollector_EmbedStructPromotesFields(t *testing.T) {
+	out := runWithAdapterConfig(t, map[string]string{
+		"lib/lib.go": `package lib
+
+type Config struct {
+	Name string
+}
+
+func (c Config) Hello() string { return "hello " + c.Name }
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/app/adapters"
+	"example.com/app/lib"
+)
+
+func main() {
+	cfg := adapters.Settings{Config: lib.Config{Name: "a"}}
+	cfg.Name = "b"
+	fmt.Print(cfg.Hello(), " ", cfg.Config.Name)
+}
+`,
+	}, &config.Package{
+		Types: []*config.TypeRule{{
+			Name:    "Config",
+			Pattern: "wrap",
+			Embed:   true,
+			RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Config", To: "Settings"}}},
+		}},
+	})
+	assert.Equal(t, "hello b b", out, "the fields and methods of the embedded type are promoted")
+}
+
+func TestCollector_StubDisabledMethods(t *testing.T) {
+	out := runWithAdapterConfig(t, map[string]string{
+		"lib/lib.go": `package lib
+
+type Conn struct{}
+
+func (c *Conn) Read(p []byte) (int, error) { return len(p), nil }
+
+func (c *Conn) Close() error { return nil }
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"io"
+
+	"example.com/app/adapters"
+)
+
+func main() {
+	var rc io.ReadCloser = &adapters.Conn{}
+	n, _ := rc.Read(make([]byte, 3))
+	defer func() { fmt.Print(n, " ", recover()) }()
+	rc.Close()
+}
+`,
+	}, &config.Package{
+		Types: []*config.TypeRule{{
+			Name:    "Conn",
+			Pattern: "wrap",
+			Embed:   true,
+			Methods: []*config.MemberRule{{Name: "Close", Disabled: true}},
+		}},
+	}, func(g *Generator) { g.WithStubDisabledMethods(true) })
+	assert.Equal(t, "3 not implemented", out, "the disabled method is stubbed, the others are still promoted")
+}
+
+func TestCollector_RenameEmbeddedFields(t *testing.T) {
+	out := runWithAdapterConfig(t, map[string]string{
+		"lib/lib.go": `package lib
+
+import "io"
+
+type Base struct{ ID int }
+
+type Meta struct{ Tag string }
+
+type Record struct {
+	Base
+	*Meta
+	io.Reader
+	Name string
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/app/adapters"
+	"example.com/app/lib"
+)
+
+func main() {
+	r := &adapters.Record{Record: lib.Record{Meta: &lib.Meta{Tag: "t"}, Reader: strings.NewReader("data")}}
+	r.Core().ID = 7
+	buf := make([]byte, 4)
+	n, _ := r.Input().Read(buf)
+	fmt.Print(r.ID, " ", r.Info().Tag, " ", string(buf[:n]), " ", r.Base.ID)
+}
+`,
+	}, &config.Package{
+		Types: []*config.TypeRule{{
+			Name:    "Record",
+			Pattern: "wrap",
+			Embed:   true,
+			Fields: []*config.MemberRule{
+				{Name: "Base", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Base", To: "Core"}}}},
+				{Name: "Meta", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Meta", To: "Info"}}}},
+				{Name: "Reader", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Reader", To: "Input"}}}},
+			},
+		}},
+	})
+	assert.Equal(t, "7 t data 7", out, "the accessors return the embedded fields, which are still promoted")
+}
+
+func TestQualifyConstraint(t *testing.T) {
+	// Parsed source always has a constraint, but a type parameter built without one must not be
+	// printed as [T].
+	var buf bytes.Buffer
+	require.NoError(t, format.Node(&buf, token.NewFileSet(), qualifyConstraint(nil, "source", nil)))
+	assert.Equal(t, "any", buf.String())
+
+	expr, err := parser.ParseExpr("Number | ~string")
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, format.Node(&buf, token.NewFileSet(), qualifyConstraint(expr, "source", nil)))
+	assert.Equal(t, "source.Number | ~string", buf.String())
+}
+
+// runWithAdapter writes files into a temporary module example.com/app, generates the adapters
+// package of the module's lib package, and returns the output of running its main package.
+func runWithAdapter(t *testing.T, files map[string]string, configure ...func(*Generator)) string {
+	t.Helper()
+	return runWithAdapterConfig(t, files, &config.Package{}, configure...)
+}
+
+// runWithAdapterConfig is runWithAdapter with the rules of pkg applied to the lib package.
+func runWithAdapterConfig(t *testing.T, files map[string]string, pkg *config.Package, configure ...func(*Generator)) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFiles(t, dir, files)
+	writeTestFiles(t, dir, map[string]string{"go.mod": "module example.com/app\n\ngo 1.24\n"})
+	t.Chdir(dir)
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+
+	pkg.Import = "example.com/app/lib"
+	got := generateForTest(t, &config.Config{
+		PackageName: "adapters",
+		Packages:    []*config.Package{pkg},
+	}, configure...)
+	writeTestFiles(t, dir, map[string]string{"adapters/adapters.go": string(got)})
+	// Imports of the source files are carried over and left to goimports, as when writing to a file.
+	require.NoError(t, util.RunGoImports(filepath.Join(dir, "adapters", "adapters.go")))
+
+	out, err := exec.Command("go", "run", ".").CombinedOutput()
+	require.NoError(t, err, string(out))
+	return string(out)
+}
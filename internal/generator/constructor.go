@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// isConstructorName reports whether name is that of a constructor, such as NewWorker.
+func isConstructorName(name string) bool {
+	return strings.HasPrefix(name, "New") && name != "New"
+}
+
+// localizeConstructorResults makes the wrappers of constructors return the local aliases of
+// the types they construct, so that NewWorker returns *Worker, or *SrcWorker if Worker is
+// renamed, rather than *source.Worker. Only non-generic aliases are used: a defined or
+// embedding type is distinct from the source type the wrapped function returns.
+func (c *Collector) localizeConstructorResults() {
+	for importPath, pkgDecls := range c.allPackageDecls {
+		alias := c.pathToAlias[importPath]
+
+		// Map the source names of the aliased types to the names of their specs, which the
+		// replacer has renamed in place.
+		localTypes := make(map[string]*ast.Ident)
+		for _, spec := range pkgDecls.typeSpecs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok || typeSpec.Assign == token.NoPos || typeSpec.TypeParams != nil {
+				continue
+			}
+			if sel, ok := typeSpec.Type.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == alias {
+					localTypes[sel.Sel.Name] = typeSpec.Name
+				}
+			}
+		}
+
+		for _, funcDecl := range pkgDecls.constructors {
+			if funcDecl.Type.Results == nil {
+				continue
+			}
+			// The signature is shared with the source syntax, which a loader may cache, so the
+			// results are copied rather than changed in place.
+			funcType := *funcDecl.Type
+			results := &ast.FieldList{Opening: funcType.Results.Opening, Closing: funcType.Results.Closing}
+			for _, field := range funcType.Results.List {
+				localized := *field
+				localized.Type = localizeType(field.Type, alias, localTypes, pkgDecls)
+				results.List = append(results.List, &localized)
+			}
+			funcType.Results = results
+			funcDecl.Type = &funcType
+		}
+	}
+}
+
+// localizeType returns expr with the references to aliased types of the package imported as
+// alias replaced by identifiers, recorded in pkgDecls.localTypeRefs to be given the final names
+// of the types by the builder. Nodes on the path to a replaced reference are copied.
+func localizeType(expr ast.Expr, alias string, localTypes map[string]*ast.Ident, pkgDecls *packageDecls) ast.Expr {
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		x, ok := t.X.(*ast.Ident)
+		if !ok || x.Name != alias {
+			return t
+		}
+		target, ok := localTypes[t.Sel.Name]
+		if !ok {
+			return t
+		}
+		ref := ast.NewIdent(target.Name)
+		if pkgDecls.localTypeRefs == nil {
+			pkgDecls.localTypeRefs = make(map[*ast.Ident]*ast.Ident)
+		}
+		pkgDecls.localTypeRefs[ref] = target
+		if pkgDecls.localTypeSources == nil {
+			pkgDecls.localTypeSources = make(map[*ast.Ident]string)
+		}
+		pkgDecls.localTypeSources[ref] = alias + "." + t.Sel.Name
+		return ref
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: localizeType(t.X, alias, localTypes, pkgDecls)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: t.Len, Elt: localizeType(t.Elt, alias, localTypes, pkgDecls)}
+	case *ast.MapType:
+		return &ast.MapType{
+			Key:   localizeType(t.Key, alias, localTypes, pkgDecls),
+			Value: localizeType(t.Value, alias, localTypes, pkgDecls),
+		}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: t.Dir, Value: localizeType(t.Value, alias, localTypes, pkgDecls)}
+	}
+	return expr
+}
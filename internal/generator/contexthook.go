@@ -0,0 +1,43 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// isContextType reports whether t is context.Context.
+func isContextType(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
+
+// contextHookStmts returns the statements a wrapper runs before forwarding ctx:
+//
+//	ctx, done := hook(ctx, "path/to/pkg.Func")
+//	defer done()
+//
+// The name of done is chosen not to collide with the names in paramNames.
+func contextHookStmts(hook string, ctx ast.Expr, name string, paramNames map[string]bool) []ast.Stmt {
+	done := "done"
+	for i := 1; paramNames[done]; i++ {
+		done = fmt.Sprintf("done%d", i)
+	}
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ctx, ast.NewIdent(done)},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun:  ast.NewIdent(hook),
+				Args: []ast.Expr{ctx, &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(name)}},
+			}},
+		},
+		&ast.DeferStmt{Call: &ast.CallExpr{Fun: ast.NewIdent(done)}},
+	}
+}
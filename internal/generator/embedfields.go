@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log/slog"
+	"strings"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
+// collectEmbeddedFieldAccessors adds, to each type of typesPkg re-exported as a struct embedding
+// the source type, an accessor for each embedded field of the source type that a field rule
+// renames. A promoted field cannot be renamed, so the accessor gives it its new name; the field
+// and its own members are still promoted under their source names.
+func (c *Collector) collectEmbeddedFieldAccessors(typesPkg *types.Package, importPath, importAlias string) {
+	pkgDecls := c.allPackageDecls[importPath]
+	if pkgDecls == nil {
+		return
+	}
+	pkgCtx := interfaces.NewContext().
+		WithValue(interfaces.PackagePathContextKey, c.configPath(importPath)).
+		WithValue(interfaces.PackageNameContextKey, c.pathToName[importPath])
+	for typeName, spec := range pkgDecls.embedSpecs {
+		obj := typesPkg.Scope().Lookup(typeName)
+		if obj == nil {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		st, ok := named.Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		fieldCtx := pkgCtx.WithValue(interfaces.TypeNameContextKey, typeName).Push(interfaces.RuleTypeField)
+		// The embedding type has a field named after the source type, and the accessors added so far.
+		taken := map[string]bool{typeName: true}
+		for i := 0; i < st.NumFields(); i++ {
+			field := st.Field(i)
+			if !field.Embedded() || !field.Exported() {
+				continue
+			}
+			ident := ast.NewIdent(field.Name())
+			c.replacer.Apply(fieldCtx, ident)
+			newName := ident.Name
+			if newName == field.Name() {
+				continue
+			}
+			if !token.IsIdentifier(newName) || token.IsKeyword(newName) {
+				slog.Warn("Cannot rename an embedded field to an invalid identifier", "type", typeName, "field", field.Name(), "name", newName, "package", importPath)
+				continue
+			}
+			if member, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, typesPkg, newName); member != nil || taken[newName] {
+				slog.Warn("Cannot rename an embedded field to the name of another member", "type", typeName, "field", field.Name(), "name", newName, "package", importPath)
+				continue
+			}
+			accessor, ok := c.fieldAccessor(field, typeName, newName, spec, typesPkg, importAlias, pkgDecls)
+			if !ok {
+				slog.Warn("Cannot rename an embedded field of an unexported or internal type", "type", typeName, "field", field.Name(), "package", importPath)
+				continue
+			}
+			taken[newName] = true
+			pkgDecls.embedMethods = append(pkgDecls.embedMethods, accessor)
+		}
+	}
+}
+
+// fieldAccessor returns a method named name of the type declared by spec that returns the
+// embedded field of its source type typeName. A field of pointer or interface type is returned
+// as is, any other field by its address, so that it can be modified through the accessor.
+func (c *Collector) fieldAccessor(field *types.Var, typeName, name string, spec *ast.TypeSpec, typesPkg *types.Package, importAlias string, pkgDecls *packageDecls) (*ast.FuncDecl, bool) {
+	b := newTypeExprBuilder(typesPkg, importAlias, c.importerPath)
+	result := b.expr(field.Type())
+	if b.invalid {
+		return nil, false
+	}
+	c.addImports(b.imports)
+
+	// The embedded source type is a field named after it, whatever the name of the embedding type.
+	recv := ast.NewIdent(strings.ToLower(typeName[:1]))
+	var value ast.Expr = &ast.SelectorExpr{
+		X:   &ast.SelectorExpr{X: ast.NewIdent(recv.Name), Sel: ast.NewIdent(typeName)},
+		Sel: ast.NewIdent(field.Name()),
+	}
+	_, isPointer := field.Type().(*types.Pointer)
+	if !isPointer && !types.IsInterface(field.Type()) {
+		result = &ast.StarExpr{X: result}
+		value = &ast.UnaryExpr{Op: token.AND, X: value}
+	}
+
+	return &ast.FuncDecl{
+		Recv: embedReceiver(spec, pkgDecls, recv, true),
+		Name: ast.NewIdent(name),
+		Type: &ast.FuncType{
+			Params:  &ast.FieldList{},
+			Results: &ast.FieldList{List: []*ast.Field{{Type: result}}},
+		},
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ReturnStmt{Results: []ast.Expr{value}}}},
+	}, true
+}
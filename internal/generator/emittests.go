@@ -0,0 +1,197 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"os"
+	"strings"
+)
+
+// testReference is a reference to a generated symbol in the test file.
+type testReference struct {
+	// name is the generated name of the symbol.
+	name string
+	// code is the reference, such as `_ = Sum[int]` in the var block or `func _[T Number]() {}`
+	// for a constraint, or empty if the symbol cannot be referenced.
+	code string
+	// constraint reports whether the symbol is a constraint interface, which can only be referred
+	// to as the constraint of a type parameter.
+	constraint bool
+}
+
+// testFilePath returns the path of the test file referencing the symbols of the output file at
+// outputFilePath, such as source.adapter_test.go for source.adapter.go.
+func testFilePath(outputFilePath string) string {
+	return strings.TrimSuffix(outputFilePath, ".go") + "_test.go"
+}
+
+// testReferences returns the references of the test file to the given declarations, in order.
+// Method stubs are referred to by their types.
+func testReferences(consts, vars, typeSpecs []sortedSpec, funcs []sortedDecl, c *Collector) []testReference {
+	var refs []testReference
+	for _, s := range append(consts, vars...) {
+		refs = append(refs, testReference{name: s.name, code: "_ = " + s.name})
+	}
+	for _, s := range typeSpecs {
+		spec, ok := s.spec.(*ast.TypeSpec)
+		if !ok {
+			continue
+		}
+		var source types.Object
+		// An interface literal is extracted from a method set, and declares no type parameters.
+		if _, isInterface := spec.Type.(*ast.InterfaceType); !isInterface {
+			source = c.sourceObject(s.importPath, sourceSymbol(spec.Type, c.pathToAlias[s.importPath]))
+		}
+		ref := testReference{name: s.name}
+		if typeName, ok := source.(*types.TypeName); ok {
+			if iface, ok := typeName.Type().Underlying().(*types.Interface); ok && !iface.IsMethodSet() {
+				ref.constraint = true
+			}
+		}
+		if instance, ok := instantiation(s.name, spec.TypeParams, source); ok && ref.constraint {
+			ref.code = fmt.Sprintf("func _[T %s]() {}", instance)
+		} else if ok {
+			ref.code = "_ " + instance
+		}
+		refs = append(refs, ref)
+	}
+	for _, s := range funcs {
+		funcDecl, ok := s.decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Recv != nil {
+			continue
+		}
+		source := c.sourceObject(s.importPath, sourceSymbol(funcDecl.Body, c.pathToAlias[s.importPath]))
+		ref := testReference{name: s.name}
+		if instance, ok := instantiation(s.name, funcDecl.Type.TypeParams, source); ok {
+			ref.code = "_ = " + instance
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// sourceObject returns the object declared as name by the source package at importPath, or nil.
+func (c *Collector) sourceObject(importPath, name string) types.Object {
+	pkg := c.pathToTypes[importPath]
+	if pkg == nil || name == "" {
+		return nil
+	}
+	return pkg.Scope().Lookup(name)
+}
+
+// instantiation returns name, with type arguments if typeParams declares any. The arguments
+// are found from the constraints of the type parameters of source, the generic symbol name is
+// generated from. ok is false if source is unknown or no arguments satisfy its constraints.
+func instantiation(name string, typeParams *ast.FieldList, source types.Object) (instance string, ok bool) {
+	if typeParams.NumFields() == 0 {
+		return name, true
+	}
+	if source == nil {
+		return "", false
+	}
+	generic, isGeneric := source.Type().(interface{ TypeParams() *types.TypeParamList })
+	if !isGeneric || generic.TypeParams().Len() != typeParams.NumFields() {
+		return "", false
+	}
+	args := make([]types.Type, generic.TypeParams().Len())
+	for i := range args {
+		if args[i] = typeArgument(generic.TypeParams().At(i).Constraint()); args[i] == nil {
+			return "", false
+		}
+	}
+	if _, err := types.Instantiate(nil, source.Type(), args, true); err != nil {
+		return "", false
+	}
+	names := make([]string, len(args))
+	for i, arg := range args {
+		names[i] = types.TypeString(arg, nil)
+	}
+	return fmt.Sprintf("%s[%s]", name, strings.Join(names, ", ")), true
+}
+
+// typeArgument returns a predeclared type in the type set of constraint: the first basic type
+// of its terms, or any if it has neither terms nor methods. It returns nil if there is none,
+// such as for a constraint with methods.
+func typeArgument(constraint types.Type) types.Type {
+	iface, ok := constraint.Underlying().(*types.Interface)
+	if !ok {
+		// A term of a defined type only admits that type, which may not be spelled here.
+		if basic, ok := constraint.(*types.Basic); ok {
+			return basic
+		}
+		return nil
+	}
+	if iface.NumMethods() > 0 {
+		return nil
+	}
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		switch embedded := iface.EmbeddedType(i).(type) {
+		case *types.Union:
+			for j := 0; j < embedded.Len(); j++ {
+				if arg := typeArgument(embedded.Term(j).Type()); arg != nil {
+					return arg
+				}
+			}
+			return nil
+		default:
+			if arg := typeArgument(embedded); arg != nil && !isAny(arg) {
+				return arg
+			}
+		}
+	}
+	return types.Universe.Lookup("any").Type()
+}
+
+// isAny reports whether t is the empty interface.
+func isAny(t types.Type) bool {
+	iface, ok := t.Underlying().(*types.Interface)
+	return ok && iface.Empty()
+}
+
+// testSource returns the source of the test file referencing the symbols of the last build.
+func (b *Builder) testSource() ([]byte, error) {
+	var buf bytes.Buffer
+	if b.header != "" {
+		fmt.Fprintf(&buf, "%s\n", b.header)
+	}
+	fmt.Fprintf(&buf, "package %s\n\n", b.aliasFile.Name.Name)
+	buf.WriteString("// The references below fail to compile if a generated symbol is removed or changes kind.\n")
+	buf.WriteString("var (\n")
+	var constraints []string
+	for _, ref := range b.testRefs {
+		switch {
+		case ref.code == "":
+			fmt.Fprintf(&buf, "\t// %s is not referenced: no type arguments satisfy its constraints.\n", ref.name)
+		case ref.constraint:
+			constraints = append(constraints, ref.code)
+		default:
+			fmt.Fprintf(&buf, "\t%s\n", ref.code)
+		}
+	}
+	buf.WriteString(")\n")
+	for _, code := range constraints {
+		fmt.Fprintf(&buf, "\n%s\n", code)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated test code for %s is not valid Go: %w", b.outputFilePath, err)
+	}
+	return src, nil
+}
+
+// writeTestFile writes the test file referencing the symbols of the last build next to the
+// output file.
+func (b *Builder) writeTestFile() error {
+	src, err := b.testSource()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(testFilePath(b.outputFilePath), b.lineEnding.convert(src), b.fileMode); err != nil {
+		return fmt.Errorf("failed to write test file: %w", err)
+	}
+	return nil
+}
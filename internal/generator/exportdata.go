@@ -0,0 +1,203 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log/slog"
+	"path"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
+// collectFromTypes collects re-exports from the type information of a package that has
+// no syntax, e.g. a package only distributed as compiled export data. The declarations
+// are synthesized as AST and then go through the same collection as source declarations.
+func (c *Collector) collectFromTypes(typesPkg *types.Package, importPath, importAlias string) {
+	scope := typesPkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+
+		switch o := obj.(type) {
+		case *types.TypeName:
+			typeSpec := &ast.TypeSpec{Name: ast.NewIdent(name)}
+			if named, ok := o.Type().(*types.Named); ok && !o.IsAlias() && named.TypeParams().Len() > 0 {
+				typeParams, imports, ok := typeParamsFromTypes(named.TypeParams(), typesPkg, importAlias, c.importerPath)
+				if !ok {
+					slog.Debug("Skipping type because its constraints use unexported or internal types", "func", "Collector.collectFromTypes", "type", name)
+					continue
+				}
+				typeSpec.TypeParams = typeParams
+				c.addImports(imports)
+			}
+			c.collectTypeDeclaration(typeSpec, importPath, importAlias)
+		case *types.Const:
+			c.collectValueDeclaration(valueDeclFromTypes(token.CONST, name), importPath, importAlias, token.CONST)
+		case *types.Var:
+			if c.isIgnored(interfaces.RuleTypeVar, importPath, name) {
+				continue
+			}
+			if c.varAccessors && c.collectVarAccessors(o, typesPkg, importPath, importAlias) {
+				continue
+			}
+			c.collectValueDeclaration(valueDeclFromTypes(token.VAR, name), importPath, importAlias, token.VAR)
+		case *types.Func:
+			if c.isIgnored(interfaces.RuleTypeFunc, importPath, name) {
+				continue
+			}
+			funcDecl, imports, ok := funcDeclFromTypes(o, typesPkg, importAlias, c.importerPath)
+			if !ok {
+				slog.Debug("Skipping function because it uses unexported or internal types", "func", "Collector.collectFromTypes", "function", name)
+				continue
+			}
+			c.addImports(imports)
+			params := o.Type().(*types.Signature).Params()
+			c.collectFunctionWrapper(funcDecl, importPath, importAlias, params.Len() > 0 && isContextType(params.At(0).Type()))
+		}
+	}
+}
+
+// addImports records imports needed by synthesized declarations, keeping existing specs.
+func (c *Collector) addImports(imports map[string]*ast.ImportSpec) {
+	for importPath, spec := range imports {
+		if _, exists := c.importSpecs[importPath]; !exists {
+			c.importSpecs[importPath] = spec
+		}
+	}
+}
+
+// valueDeclFromTypes builds a single-spec declaration of the given name, which is all
+// collectValueDeclaration needs to re-export a constant or variable.
+func valueDeclFromTypes(tok token.Token, name string) *ast.GenDecl {
+	return &ast.GenDecl{
+		Tok:   tok,
+		Specs: []ast.Spec{&ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent(name)}}},
+	}
+}
+
+// typeExprBuilder converts types.Type values into AST expressions qualified for the
+// generated file, recording the imports they need along the way.
+type typeExprBuilder struct {
+	sourcePkg   *types.Package
+	sourceAlias string
+	// importerPath is the import path of the generated package, or empty if unknown.
+	importerPath string
+	imports      map[string]*ast.ImportSpec
+	invalid      bool
+}
+
+func newTypeExprBuilder(sourcePkg *types.Package, sourceAlias, importerPath string) *typeExprBuilder {
+	return &typeExprBuilder{
+		sourcePkg:    sourcePkg,
+		sourceAlias:  sourceAlias,
+		importerPath: importerPath,
+		imports:      make(map[string]*ast.ImportSpec),
+	}
+}
+
+func (b *typeExprBuilder) qualifier(pkg *types.Package) string {
+	if pkg.Path() == b.sourcePkg.Path() {
+		return b.sourceAlias
+	}
+	if !canImportInternal(pkg.Path(), b.importerPath) {
+		b.invalid = true
+	}
+	spec := &ast.ImportSpec{
+		Path: &ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("\"%s\"", pkg.Path())},
+	}
+	if pkg.Name() != path.Base(pkg.Path()) {
+		spec.Name = ast.NewIdent(pkg.Name())
+	}
+	b.imports[pkg.Path()] = spec
+	return pkg.Name()
+}
+
+// expr returns the AST for t. Types referring to unexported names or internal packages
+// mark the builder as invalid, as they cannot be spelled in the generated file.
+func (b *typeExprBuilder) expr(t types.Type) ast.Expr {
+	expr, err := parser.ParseExpr(types.TypeString(t, b.qualifier))
+	if err != nil {
+		slog.Debug("Failed to convert type to an expression", "func", "typeExprBuilder.expr", "type", t, "error", err)
+		b.invalid = true
+		return ast.NewIdent("any")
+	}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && !sel.Sel.IsExported() {
+			b.invalid = true
+		}
+		return true
+	})
+	return expr
+}
+
+// typeParamsFromTypes converts a type parameter list into a field list.
+func typeParamsFromTypes(list *types.TypeParamList, sourcePkg *types.Package, sourceAlias, importerPath string) (*ast.FieldList, map[string]*ast.ImportSpec, bool) {
+	b := newTypeExprBuilder(sourcePkg, sourceAlias, importerPath)
+	fields := &ast.FieldList{}
+	for i := 0; i < list.Len(); i++ {
+		tp := list.At(i)
+		fields.List = append(fields.List, &ast.Field{
+			Names: []*ast.Ident{ast.NewIdent(tp.Obj().Name())},
+			Type:  b.expr(tp.Constraint()),
+		})
+	}
+	return fields, b.imports, !b.invalid
+}
+
+// funcDeclFromTypes builds a function declaration with the signature of fn.
+// Parameters keep the names recorded in the export data; unnamed ones are left for
+// collectFunctionWrapper to name.
+func funcDeclFromTypes(fn *types.Func, sourcePkg *types.Package, sourceAlias, importerPath string) (*ast.FuncDecl, map[string]*ast.ImportSpec, bool) {
+	sig := fn.Type().(*types.Signature)
+	b := newTypeExprBuilder(sourcePkg, sourceAlias, importerPath)
+
+	funcType := &ast.FuncType{Params: &ast.FieldList{}}
+	if sig.TypeParams().Len() > 0 {
+		typeParams, imports, ok := typeParamsFromTypes(sig.TypeParams(), sourcePkg, sourceAlias, importerPath)
+		if !ok {
+			return nil, nil, false
+		}
+		funcType.TypeParams = typeParams
+		for p, spec := range imports {
+			b.imports[p] = spec
+		}
+	}
+
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		param := params.At(i)
+		var typ ast.Expr
+		if sig.Variadic() && i == params.Len()-1 {
+			typ = &ast.Ellipsis{Elt: b.expr(param.Type().(*types.Slice).Elem())}
+		} else {
+			typ = b.expr(param.Type())
+		}
+		funcType.Params.List = append(funcType.Params.List, &ast.Field{Names: fieldNames(param), Type: typ})
+	}
+
+	if results := sig.Results(); results.Len() > 0 {
+		funcType.Results = &ast.FieldList{}
+		for i := 0; i < results.Len(); i++ {
+			result := results.At(i)
+			funcType.Results.List = append(funcType.Results.List, &ast.Field{Names: fieldNames(result), Type: b.expr(result.Type())})
+		}
+	}
+
+	if b.invalid {
+		return nil, nil, false
+	}
+	return &ast.FuncDecl{Name: ast.NewIdent(fn.Name()), Type: funcType}, b.imports, true
+}
+
+// fieldNames returns the identifier list for a parameter or result, or nil if it is unnamed.
+func fieldNames(v *types.Var) []*ast.Ident {
+	if v.Name() == "" {
+		return nil
+	}
+	return []*ast.Ident{ast.NewIdent(v.Name())}
+}
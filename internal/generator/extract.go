@@ -0,0 +1,122 @@
+package generator
+
+import (
+	"go/ast"
+	"go/types"
+	"log/slog"
+	"sort"
+)
+
+// collectExtractedInterfaces generates a local interface for every type of the package listed in
+// c.extractInterfaces. Each interface holds the exported method set of *T, with signatures
+// qualified for the generated file. The interface extracted from an interface keeps its embedded
+// interfaces and lists only the methods it adds, unless c.flattenInterfaces is set.
+func (c *Collector) collectExtractedInterfaces(typesPkg *types.Package, importPath, importAlias string) {
+	// Package-specific rules take precedence over global ones.
+	targets := make(map[string]string)
+	for typeName, ifaceName := range c.extractInterfaces[""] {
+		targets[typeName] = ifaceName
+	}
+	for typeName, ifaceName := range c.extractInterfaces[c.configPath(importPath)] {
+		targets[typeName] = ifaceName
+	}
+
+	typeNames := make([]string, 0, len(targets))
+	for typeName := range targets {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+
+	for _, typeName := range typeNames {
+		ifaceName := targets[typeName]
+		typeObj, ok := typesPkg.Scope().Lookup(typeName).(*types.TypeName)
+		if !ok {
+			// Global rules name types of any package, so a miss is expected.
+			continue
+		}
+		iface := &ast.InterfaceType{Methods: &ast.FieldList{}}
+		if source, ok := typeObj.Type().Underlying().(*types.Interface); ok {
+			if named, ok := typeObj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				slog.Warn("Skipping interface extraction for a generic interface", "type", typeName, "package", importPath)
+				continue
+			}
+			if c.flattenInterfaces {
+				iface.Methods.List = c.methodFields(types.NewMethodSet(typeObj.Type()), typesPkg, importAlias)
+			} else {
+				iface.Methods.List = c.embeddingInterfaceFields(source, typesPkg, importAlias)
+			}
+		} else {
+			iface.Methods.List = c.methodFields(types.NewMethodSet(types.NewPointer(typeObj.Type())), typesPkg, importAlias)
+		}
+
+		if c.allPackageDecls[importPath] == nil {
+			c.allPackageDecls[importPath] = &packageDecls{}
+		}
+		c.allPackageDecls[importPath].namedSpecs = append(c.allPackageDecls[importPath].namedSpecs, &ast.TypeSpec{
+			Name: ast.NewIdent(ifaceName),
+			Type: iface,
+		})
+	}
+}
+
+// methodFields returns the interface elements declaring the exported methods of methodSet.
+func (c *Collector) methodFields(methodSet *types.MethodSet, typesPkg *types.Package, importAlias string) []*ast.Field {
+	var fields []*ast.Field
+	for i := 0; i < methodSet.Len(); i++ {
+		if method, ok := methodSet.At(i).Obj().(*types.Func); ok {
+			if field, ok := c.methodField(method, typesPkg, importAlias); ok {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// methodField returns the interface element declaring method. It returns false if the method is
+// unexported or its signature cannot be spelled in the generated file.
+func (c *Collector) methodField(method *types.Func, typesPkg *types.Package, importAlias string) (*ast.Field, bool) {
+	if !method.Exported() {
+		return nil, false
+	}
+	funcDecl, imports, ok := funcDeclFromTypes(method, typesPkg, importAlias, c.importerPath)
+	if !ok {
+		slog.Debug("Skipping method because it uses unexported or internal types", "func", "Collector.methodField", "method", method.FullName())
+		return nil, false
+	}
+	c.addImports(imports)
+	return &ast.Field{
+		Names: []*ast.Ident{ast.NewIdent(method.Name())},
+		Type:  funcDecl.Type,
+	}, true
+}
+
+// embeddingInterfaceFields returns the interface elements of an interface extracted from source
+// that keeps its structure: the interfaces source embeds, followed by the methods it declares
+// itself. An embedded interface that cannot be referred to from the generated file, such as an
+// unexported one, is replaced by its methods.
+func (c *Collector) embeddingInterfaceFields(source *types.Interface, typesPkg *types.Package, importAlias string) []*ast.Field {
+	var fields []*ast.Field
+	for i := 0; i < source.NumEmbeddeds(); i++ {
+		embedded := source.EmbeddedType(i)
+		b := newTypeExprBuilder(typesPkg, importAlias, c.importerPath)
+		expr := b.expr(embedded)
+		if b.invalid {
+			fields = append(fields, c.methodFields(types.NewMethodSet(embedded), typesPkg, importAlias)...)
+			continue
+		}
+		c.addImports(b.imports)
+		fields = append(fields, &ast.Field{Type: expr})
+	}
+
+	explicit := make([]*types.Func, 0, source.NumExplicitMethods())
+	for i := 0; i < source.NumExplicitMethods(); i++ {
+		explicit = append(explicit, source.ExplicitMethod(i))
+	}
+	sort.Slice(explicit, func(i, j int) bool { return explicit[i].Name() < explicit[j].Name() })
+	for _, method := range explicit {
+		if field, ok := c.methodField(method, typesPkg, importAlias); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
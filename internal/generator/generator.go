@@ -1,13 +1,23 @@
 package generator
 
 import (
+	"go/token"
+	"io"
+	"os"
+	"time"
+
 	"github.com/origadmin/adptool/internal/interfaces"
 )
 
 // Generator holds the state and configuration for code generation.
+//
+// A Generator is not safe for concurrent use. It can be reused for several generations, e.g.
+// in a long-running process, by calling Reset between them; concurrent generations need one
+// Generator, and one replacer, each. A CompiledConfig is not modified and can be shared.
 type Generator struct {
 	collector *Collector
 	builder   *Builder
+	timings   Timings
 }
 
 // NewGenerator creates a new Generator instance.
@@ -25,14 +35,369 @@ func (g *Generator) RenderHeader(sourceFile string) error {
 
 // Generate generates the output code.
 func (g *Generator) Generate(packages []*PackageInfo) error {
+	return g.generate(packages, g.builder.Write)
+}
+
+// GenerateToString generates the output code like Generate and returns it instead of writing
+// the output file: the same bytes, formatted as if at the output file path and with the same
+// line endings. No file is written, so with WithSplitGenerics the code is that of the output
+// file, without the fallback file, and with WithEmitTests the test file is not generated. A
+// writer set with WithWriter is not written to.
+func (g *Generator) GenerateToString(packages []*PackageInfo) (string, error) {
+	var src []byte
+	err := g.generate(packages, func() (err error) {
+		src, err = g.builder.outputSource()
+		return err
+	})
+	return string(src), err
+}
+
+// generate collects the declarations of packages, builds the output file and calls write.
+func (g *Generator) generate(packages []*PackageInfo, write func() error) error {
+	start := time.Now()
 	if err := g.collector.Collect(packages); err != nil {
 		return err
 	}
+	g.timings.Collect = time.Since(start)
 
 	// Pass the collector to the builder.
+	start = time.Now()
 	g.builder.Build(g.collector)
+	if err := g.builder.checkIdentifiers(); err != nil {
+		return err
+	}
+	if err := g.builder.checkReservedNames(); err != nil {
+		return err
+	}
+	if err := g.builder.checkCollisions(); err != nil {
+		return err
+	}
+	err := write()
+	g.timings.Format = time.Since(start)
+	return err
+}
+
+// Reset clears the state of the last generation, keeping the configuration set by the With
+// methods, so that the generator can run again. The header must be rendered again as well.
+// Without Reset, a second generation would also write the declarations of the first.
+func (g *Generator) Reset() {
+	g.collector.Reset()
+	g.builder.Reset()
+	g.timings = Timings{}
+}
+
+// Timings returns the durations of the phases of the last generation.
+func (g *Generator) Timings() Timings {
+	return g.timings
+}
+
+// SkippedFunctions returns the exported functions left out of the last generation because
+// their signatures use unexported or internal types.
+func (g *Generator) SkippedFunctions() []SkippedFunction {
+	return g.collector.SkippedFunctions()
+}
+
+// Stats returns the number of declarations, by kind, in the last generated file.
+func (g *Generator) Stats() Stats {
+	return g.builder.stats()
+}
+
+// Manifest returns the symbols declared by the last call to Generate.
+func (g *Generator) Manifest() AdapterManifest {
+	return AdapterManifest{
+		Package: g.builder.aliasFile.Name.Name,
+		Symbols: g.builder.manifest,
+	}
+}
+
+// WithPackageLoader sets the loader of the source packages, which defaults to DefaultPackageLoader.
+func (g *Generator) WithPackageLoader(loader PackageLoader) *Generator {
+	g.collector.loader = loader
+	return g
+}
+
+// WithBaseline restricts generation to symbols not already declared in the baseline.
+func (g *Generator) WithBaseline(names map[string]bool) *Generator {
+	g.builder.WithBaseline(names)
+	return g
+}
+
+// WithExtractInterfaces sets the types for which a local interface is generated from their
+// method set, keyed by import path ("" for all packages) and then by type name.
+func (g *Generator) WithExtractInterfaces(extract map[string]map[string]string) *Generator {
+	g.collector.extractInterfaces = extract
+	return g
+}
+
+// WithEmbedTypes sets the types re-exported as a local struct embedding the source type instead
+// of an alias, keyed by import path ("" for all packages) and then by type name.
+func (g *Generator) WithEmbedTypes(embed map[string]map[string]bool) *Generator {
+	g.collector.embedTypes = embed
+	return g
+}
+
+// WithDisabledMethods sets the methods disabled by member rules, by import path ("" for all
+// packages) and type name. They are only used to stub methods, see WithStubDisabledMethods.
+func (g *Generator) WithDisabledMethods(methods map[string]map[string][]string) *Generator {
+	g.collector.disabledMethods = methods
+	return g
+}
+
+// WithStubDisabledMethods sets whether a type re-exported as a struct embedding the source type
+// gets, for each disabled method, a method panicking with "not implemented". The stub shadows
+// the promoted method, so the type still satisfies the interfaces of the source type, e.g.
+// while adopting an interface incrementally.
+func (g *Generator) WithStubDisabledMethods(stub bool) *Generator {
+	g.collector.stubDisabledMethods = stub
+	return g
+}
+
+// WithDefineTypes sets the types re-exported as a defined type of the source type instead of an
+// alias, keyed by import path ("" for all packages) and then by type name ("*" for all types).
+// A false value keeps a type an alias.
+func (g *Generator) WithDefineTypes(define map[string]map[string]bool) *Generator {
+	g.collector.defineTypes = define
+	return g
+}
+
+// WithIgnores sets the ignores matched against the source names of the symbols, keyed by import
+// path ("" for all packages) and then by rule type. Matching symbols are not generated.
+func (g *Generator) WithIgnores(ignores map[string]map[interfaces.RuleType][]interfaces.CompiledIgnore) *Generator {
+	g.collector.ignores = ignores
+	return g
+}
+
+// WithRuleSymbols sets the symbols named by the rules, keyed by import path. A warning is
+// logged for each of them that its loaded package does not declare.
+func (g *Generator) WithRuleSymbols(symbols map[string][]string) *Generator {
+	g.collector.ruleSymbols = symbols
+	return g
+}
+
+// WithFuncVarFallback sets whether exported functions whose signatures use unexported or internal
+// types are re-exported as function variables (var F = pkg.F) instead of being skipped.
+// Generic functions cannot be assigned without instantiation and are still skipped.
+func (g *Generator) WithFuncVarFallback(fallback bool) *Generator {
+	g.collector.funcVarFallback = fallback
+	return g
+}
+
+// WithFailOnEmptyPackage sets whether generation fails if a package contributes no declarations,
+// e.g. because all its symbols are ignored, which usually means it is misconfigured.
+func (g *Generator) WithFailOnEmptyPackage(fail bool) *Generator {
+	g.collector.failOnEmptyPackage = fail
+	return g
+}
+
+// WithFlattenInterfaces sets whether an interface extracted from a source interface lists its
+// full method set, including the methods of the interfaces it embeds, rather than embedding them
+// and listing only the methods it adds.
+func (g *Generator) WithFlattenInterfaces(flatten bool) *Generator {
+	g.collector.flattenInterfaces = flatten
+	return g
+}
+
+// WithWarnGeneratedSource sets whether a warning is logged for each source package with files
+// marked as generated, as adapters over generated code are fragile.
+func (g *Generator) WithWarnGeneratedSource(warn bool) *Generator {
+	g.collector.warnGeneratedSource = warn
+	return g
+}
+
+// WithAllowedImports restricts generation to the packages whose import path is, or is below, one
+// of prefixes. Generating an adapter of any other package fails. No prefixes allow all packages.
+func (g *Generator) WithAllowedImports(prefixes ...string) *Generator {
+	g.collector.allowedImports = prefixes
+	return g
+}
+
+// WithPlainImport sets whether the adapter of a single package imports it by its own name, without
+// the alias it is otherwise given, e.g. `import "github.com/example/lib"` and `lib.Client` rather
+// than `import source "github.com/example/lib"` and `source.Client`. A package whose name differs
+// from the last element of its import path keeps that name written in the import, as is usual.
+// Adapters of several packages are not affected.
+func (g *Generator) WithPlainImport(plain bool) *Generator {
+	g.collector.plainImport = plain
+	return g
+}
+
+// WithImporterPath sets the import path of the generated package, e.g. example.com/app/adapters.
+// Generated code may then use the types of internal packages the package can import, such as
+// those of example.com/app/internal/..., in the same module. Without it, functions using
+// internal types are left out, or re-exported as variables with WithFuncVarFallback.
+func (g *Generator) WithImporterPath(importPath string) *Generator {
+	g.collector.importerPath = importPath
+	return g
+}
+
+// WithOnlyTagged restricts generation to source symbols whose doc comment has a line starting
+// with tag. An empty tag generates all exported symbols.
+func (g *Generator) WithOnlyTagged(tag string) *Generator {
+	g.collector.onlyTagged = tag
+	return g
+}
+
+// WithSkipDeprecated sets whether source symbols whose doc comment has a "Deprecated:" paragraph
+// are left out of generation.
+func (g *Generator) WithSkipDeprecated(skip bool) *Generator {
+	g.collector.skipDeprecated = skip
+	return g
+}
+
+// WithVarAccessors sets whether exported variables whose type has value semantics, such as
+// numbers, strings and structs, are re-exported as GetX and SetX functions forwarding to the
+// source variable. A re-exported variable is a copy that does not share their state.
+func (g *Generator) WithVarAccessors(accessors bool) *Generator {
+	g.collector.varAccessors = accessors
+	return g
+}
+
+// WithContextHook sets the name of a function of the adapter package that wrappers of functions
+// taking a context.Context as their first parameter call before forwarding, to start a trace span
+// or log the call. It must have the signature func(context.Context, string) (context.Context, func()):
+// it is given the context and the qualified name of the source function, returns the context to
+// forward, and the returned function is deferred. An empty name forwards the context unchanged.
+func (g *Generator) WithContextHook(name string) *Generator {
+	g.collector.contextHook = name
+	return g
+}
+
+// WithFoldDecls sets whether consecutive const, var and type declarations are grouped into blocks.
+func (g *Generator) WithFoldDecls(fold bool) *Generator {
+	g.builder.WithFoldDecls(fold)
+	return g
+}
+
+// WithLineEnding sets the line terminator of the generated file.
+func (g *Generator) WithLineEnding(ending LineEnding) *Generator {
+	g.builder.WithLineEnding(ending)
+	return g
+}
+
+// WithGroupByPackage sets whether the declarations of each source package are listed together
+// under a "// from <import path>" comment, which eases navigating adapters of many packages.
+func (g *Generator) WithGroupByPackage(group bool) *Generator {
+	g.builder.WithGroupByPackage(group)
+	return g
+}
+
+// WithImportVersions sets whether the import of each source package is annotated with the version
+// of its module.
+func (g *Generator) WithImportVersions(annotate bool) *Generator {
+	g.builder.WithImportVersions(annotate)
+	return g
+}
+
+// WithRenameTable sets whether a comment below the header of the generated file maps the source
+// name of each renamed symbol to its generated name.
+func (g *Generator) WithRenameTable(table bool) *Generator {
+	g.builder.WithRenameTable(table)
+	return g
+}
 
-	return g.builder.Write()
+// WithOutputOrder sets the order of the sections of the generated file, among "imports",
+// "consts", "vars", "types" and "funcs". The sections left out follow in the default order.
+func (g *Generator) WithOutputOrder(sections ...string) *Generator {
+	g.builder.WithOutputOrder(sections...)
+	return g
+}
+
+// WithCollisionStrategy sets how symbols given the same name are handled: numbered with
+// CollisionSuffix (the default), reported with CollisionError, or left out with CollisionSkip.
+func (g *Generator) WithCollisionStrategy(strategy CollisionStrategy) *Generator {
+	g.builder.WithCollisionStrategy(strategy)
+	return g
+}
+
+// WithEmitTests sets whether a test file referencing every generated symbol is written next to
+// the generated file. It is not written when generating to a writer.
+func (g *Generator) WithEmitTests(emit bool) *Generator {
+	g.builder.WithEmitTests(emit)
+	return g
+}
+
+// WithToolVersion sets the version of adptool recorded in the header of the generated file.
+// It must be set before RenderHeader is called.
+func (g *Generator) WithToolVersion(version string) *Generator {
+	g.builder.WithToolVersion(version)
+	return g
+}
+
+// WithGenerateDirectives sets the commands written as //go:generate directives in the header of
+// the generated file.
+func (g *Generator) WithGenerateDirectives(commands ...string) *Generator {
+	g.builder.WithGenerateDirectives(commands...)
+	return g
+}
+
+// WithSplitGenerics sets whether declarations using generics are written only for Go 1.18 and
+// later: the output file gets a //go:build go1.18 constraint, and a fallback file with the other
+// declarations, such as source.adapter_nogenerics.go, is written for older toolchains.
+func (g *Generator) WithSplitGenerics(split bool) *Generator {
+	g.builder.WithSplitGenerics(split)
+	return g
+}
+
+// WithWriter sets a writer that receives the generated code instead of the output file, e.g. to
+// preview an adapter without writing it. The code written to it is not formatted with goimports.
+func (g *Generator) WithWriter(w io.Writer) *Generator {
+	g.builder.WithWriter(w)
+	return g
+}
+
+// WithNilInterfaceVars sets whether a typed nil variable, such as `var NilReader Reader`, is
+// declared for each generated interface, to simplify setting up mocks in tests.
+func (g *Generator) WithNilInterfaceVars(nilVars bool) *Generator {
+	g.builder.WithNilInterfaceVars(nilVars)
+	return g
+}
+
+// WithRegisterFunc sets the function that an init function of the generated file calls with
+// the name and zero value of each generated type, to register them with a codec or plugin
+// framework without reflection.
+func (g *Generator) WithRegisterFunc(fn RegisterFunc) *Generator {
+	g.builder.WithRegisterFunc(fn)
+	return g
+}
+
+// WithASTDump sets a writer that receives the node structure of the generated file, printed
+// with ast.Fprint before formatting, e.g. to find a malformed SelectorExpr.
+func (g *Generator) WithASTDump(w io.Writer) *Generator {
+	g.builder.WithASTDump(w)
+	return g
+}
+
+// WithStrictIdentifiers sets whether generation fails if a generated name is not an ASCII
+// exported identifier, e.g. because a rename target in the configuration has a smart quote.
+func (g *Generator) WithStrictIdentifiers(strict bool) *Generator {
+	g.builder.WithStrictIdentifiers(strict)
+	return g
+}
+
+// WithLenient sets whether a rule renaming a symbol to a Go keyword or predeclared identifier is
+// reported as a warning instead of failing generation.
+func (g *Generator) WithLenient(lenient bool) *Generator {
+	g.builder.WithLenient(lenient)
+	return g
+}
+
+// WithFileMode sets the permission of the generated file, DefaultFileMode unless set.
+func (g *Generator) WithFileMode(mode os.FileMode) *Generator {
+	g.builder.WithFileMode(mode)
+	return g
+}
+
+// WithNolint sets the linters suppressed on each generated declaration by a //nolint comment.
+func (g *Generator) WithNolint(linters ...string) *Generator {
+	g.builder.WithNolint(linters...)
+	return g
+}
+
+// WithBlockComment sets a comment written above the block of tok declarations, such as the
+// grouped constants. See Builder.WithBlockComment.
+func (g *Generator) WithBlockComment(tok token.Token, comment string) *Generator {
+	g.builder.WithBlockComment(tok, comment)
+	return g
 }
 
 // WithFormatCode sets whether to automatically format after generating code
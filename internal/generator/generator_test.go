@@ -3,13 +3,21 @@ package generator
 import (
 	"bytes"
 	"flag"
+	"fmt"
+	"go/ast"
 	"go/format"
+	"go/parser"
+	"go/token"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
 
 	"github.com/origadmin/adptool/internal/compiler"
 	"github.com/origadmin/adptool/internal/config"
@@ -71,7 +79,9 @@ func TestIssues(t *testing.T) {
 
 			outputBuffer := &bytes.Buffer{}
 			// Disable the builder's own formatter, as we will format it manually in the test.
-			generator := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").WithFormatCode(false)
+			generator := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").
+				WithIgnores(compiledCfg.IgnoresByPackageAndType).
+				WithFormatCode(false)
 			generator.builder.writer = outputBuffer
 
 			err = generator.Generate(packageInfos)
@@ -105,7 +115,9 @@ func TestGenerator_LegacyCases(t *testing.T) {
 
 		outputBuffer := &bytes.Buffer{}
 		// Disable the builder's own formatter, as we will format it manually in the test.
-		generator := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").WithFormatCode(false)
+		generator := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").
+			WithIgnores(compiledCfg.IgnoresByPackageAndType).
+			WithFormatCode(false)
 		generator.builder.writer = outputBuffer
 
 		err = generator.Generate(packageInfos)
@@ -186,7 +198,7 @@ func TestGenerator_LegacyCases(t *testing.T) {
 				Import: "github.com/origadmin/adptool/testdata/pkgs/source1",
 				Alias:  "source",
 				Types: []*config.TypeRule{{Name: "ExportedType", RuleSet: config.RuleSet{Regex: []*config.
-				RegexRule{{Pattern: "Exported(.*)", Replace: "My$1"}}}}},
+					RegexRule{{Pattern: "Exported(.*)", Replace: "My$1"}}}}},
 			}},
 		}
 		runLegacyGoldenTest(t, cfg)
@@ -222,3 +234,1323 @@ func TestGenerator_LegacyCases(t *testing.T) {
 		runLegacyGoldenTest(t, cfg)
 	})
 }
+
+// generateForTest compiles cfg, runs the generator into a buffer and returns the gofmt'ed output.
+// Each configure function can adjust the generator before generation starts.
+func generateForTest(t *testing.T, cfg *config.Config, configure ...func(*Generator)) []byte {
+	t.Helper()
+	compiledCfg, err := compiler.Compile(cfg)
+	require.NoError(t, err)
+
+	var packageInfos []*PackageInfo
+	for _, pkg := range compiledCfg.Packages {
+		packageInfos = append(packageInfos, &PackageInfo{
+			ImportPath:     pkg.ImportPath,
+			ImportAlias:    pkg.ImportAlias,
+			Registry:       pkg.Registry,
+			Instantiations: pkg.Instantiations,
+		})
+	}
+
+	outputBuffer := &bytes.Buffer{}
+	generator := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").
+		WithExtractInterfaces(compiledCfg.ExtractInterfaces).
+		WithEmbedTypes(compiledCfg.EmbedTypes).
+		WithDisabledMethods(compiledCfg.DisabledMethods).
+		WithDefineTypes(compiledCfg.DefineTypes).
+		WithIgnores(compiledCfg.IgnoresByPackageAndType).
+		WithOnlyTagged(compiledCfg.OnlyTagged).
+		WithSkipDeprecated(compiledCfg.SkipDeprecated).
+		WithFormatCode(false)
+	generator.builder.writer = outputBuffer
+	for _, fn := range configure {
+		fn(generator)
+	}
+
+	require.NoError(t, generator.Generate(packageInfos))
+
+	formatted, err := format.Source(outputBuffer.Bytes())
+	require.NoError(t, err, "generated code could not be formatted")
+	return formatted
+}
+
+func TestGenerator_Baseline(t *testing.T) {
+	// The baseline is an adapter generated before ExportedFunction was added to the source.
+	baselineFile := filepath.Join(t.TempDir(), "baseline.adapter.go")
+	baselineSrc := `package baselinetest
+
+import (
+	source "github.com/origadmin/adptool/testdata/pkgs/source1"
+)
+
+const (
+	ExportedConstant = source.ExportedConstant
+	MaxRetries       = source.MaxRetries
+)
+
+var (
+	ConfigValue      = source.ConfigValue
+	ExportedVariable = source.ExportedVariable
+)
+
+type (
+	CommonStruct      = source.CommonStruct
+	ExportedInterface = source.ExportedInterface
+	ExportedType      = source.ExportedType
+	MyStruct          = source.MyStruct
+)
+
+func CommonFunction() string {
+	return source.CommonFunction()
+}
+`
+	require.NoError(t, os.WriteFile(baselineFile, []byte(baselineSrc), 0644))
+
+	names, err := LoadBaseline(baselineFile)
+	require.NoError(t, err)
+	require.True(t, names["CommonFunction"])
+	require.False(t, names["ExportedFunction"])
+
+	cfg := &config.Config{
+		PackageName: "baselinetest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source1",
+			Alias:  "source",
+		}},
+	}
+	got := generateForTest(t, cfg, func(g *Generator) { g.WithBaseline(names) })
+
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_QualifiedRegex(t *testing.T) {
+	// Both source packages export CommonFunction; the qualified rule only renames the one in sourcepkg2.
+	cfg := &config.Config{
+		PackageName: "qualifiedtest",
+		Packages: []*config.Package{
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source1"},
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source2", Alias: "source2"},
+		},
+		Functions: []*config.FuncRule{{
+			Name: "*",
+			RuleSet: config.RuleSet{
+				Regex: []*config.RegexRule{{
+					Pattern:   `^sourcepkg2\.Common(.*)`,
+					Replace:   "Shared$1",
+					Qualified: true,
+				}},
+			},
+		}},
+	}
+	got := generateForTest(t, cfg)
+
+	assert.Contains(t, string(got), "func CommonFunction() string {\n\treturn source1.CommonFunction()")
+	assert.Contains(t, string(got), "func SharedFunction() string {\n\treturn source2.CommonFunction()")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_FoldDecls(t *testing.T) {
+	cfg := &config.Config{
+		PackageName: "foldtest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source1",
+			Alias:  "source",
+		}},
+	}
+
+	got := generateForTest(t, cfg)
+	assert.Contains(t, string(got), "const (\n\tExportedConstant = source.ExportedConstant\n\tMaxRetries       = source.MaxRetries\n)")
+	assert.Contains(t, string(got), "var (\n")
+	assert.Contains(t, string(got), "type (\n")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+
+	unfolded := string(generateForTest(t, cfg, func(g *Generator) { g.WithFoldDecls(false) }))
+	assert.Contains(t, unfolded, "const ExportedConstant = source.ExportedConstant\n")
+	assert.Contains(t, unfolded, "const MaxRetries = source.MaxRetries\n")
+	assert.NotContains(t, unfolded, "const (")
+	assert.NotContains(t, unfolded, "var (")
+	assert.NotContains(t, unfolded, "type (")
+}
+
+func TestGenerator_Nolint(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "nolinttest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source1",
+			Alias:  "source",
+		}},
+	}, func(g *Generator) { g.WithNolint("unused", "revive") })
+
+	file, err := parser.ParseFile(token.NewFileSet(), "", got, parser.ParseComments)
+	require.NoError(t, err)
+	require.NotEmpty(t, file.Decls)
+	for _, decl := range file.Decls {
+		var doc *ast.CommentGroup
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			doc = d.Doc
+		case *ast.FuncDecl:
+			doc = d.Doc
+		}
+		require.NotNil(t, doc, "declaration at %d has no comment", decl.Pos())
+		assert.Equal(t, "//nolint:unused,revive", doc.List[len(doc.List)-1].Text)
+	}
+
+	plain := generateForTest(t, &config.Config{
+		PackageName: "nolinttest",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"}},
+	})
+	assert.NotContains(t, string(plain), "//nolint")
+}
+
+func TestGenerator_RenamedFuncTypeVariable(t *testing.T) {
+	// Variables are re-exported without a type, so a variable whose type uses a renamed function
+	// type keeps the source type, which the local alias of the renamed type is identical to.
+	got := generateForTest(t, &config.Config{
+		PackageName: "functypetest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+			Alias:  "source",
+			Types: []*config.TypeRule{{
+				Name:    "ProcessFunc",
+				RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "ProcessFunc", To: "Handler"}}},
+			}},
+		}},
+	})
+	assert.Regexp(t, `Handler\s+= source\.ProcessFunc`, string(got))
+	assert.Regexp(t, `Processors\s+= source\.Processors`, string(got))
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_BlockComment(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "blockcommenttest",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"}},
+	}, func(g *Generator) { g.WithBlockComment(token.CONST, "Re-exported constants from source") })
+
+	assert.Equal(t, 1, strings.Count(string(got), "// Re-exported constants from source"))
+	file, err := parser.ParseFile(token.NewFileSet(), "", got, parser.ParseComments)
+	require.NoError(t, err)
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.CONST {
+			require.NotNil(t, genDecl.Doc, "the const block has no comment")
+			assert.Equal(t, "// Re-exported constants from source", genDecl.Doc.List[0].Text)
+		}
+	}
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_GroupByPackage(t *testing.T) {
+	cfg := &config.Config{
+		PackageName: "grouptest",
+		Packages: []*config.Package{
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"},
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source2", Alias: "other"},
+		},
+	}
+	got := generateForTest(t, cfg, func(g *Generator) { g.WithGroupByPackage(true) })
+	src := string(got)
+
+	first := strings.Index(src, "// from github.com/origadmin/adptool/testdata/pkgs/source1\n")
+	second := strings.Index(src, "// from github.com/origadmin/adptool/testdata/pkgs/source2\n")
+	require.NotEqual(t, -1, first)
+	require.NotEqual(t, -1, second)
+	require.Less(t, first, second, "packages are grouped in import path order")
+	assert.NotContains(t, src[first:second], "other.", "the group of source1 only holds its declarations")
+	assert.NotContains(t, src[second:], "source.", "the group of source2 only holds its declarations")
+
+	flat := string(generateForTest(t, cfg))
+	assert.NotContains(t, flat, "// from ")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_Manifest(t *testing.T) {
+	var gen *Generator
+	generateForTest(t, &config.Config{
+		PackageName: "manifesttest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+			Alias:  "source",
+			Types: []*config.TypeRule{{
+				Name:    "Worker",
+				RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Worker", To: "Processor"}}},
+			}},
+		}},
+	}, func(g *Generator) { gen = g })
+
+	manifest := &Manifest{Adapters: []AdapterManifest{gen.Manifest()}}
+	adapter := manifest.Adapters[0]
+	assert.Equal(t, "manifesttest", adapter.Package)
+	assert.Contains(t, adapter.Symbols, ManifestSymbol{
+		Name:          "Processor",
+		Kind:          "type",
+		Signature:     "Processor = source.Worker",
+		SourcePackage: "github.com/origadmin/adptool/testdata/pkgs/source3",
+		Source:        "Worker",
+	}, "a renamed symbol maps to its source symbol")
+	assert.Equal(t, gen.Stats().Types+gen.Stats().Functions+gen.Stats().Variables+gen.Stats().Constants, len(adapter.Symbols))
+
+	dir := t.TempDir()
+	require.NoError(t, manifest.WriteFile(filepath.Join(dir, "api.yaml"), DefaultFileMode))
+	yamlData, err := os.ReadFile(filepath.Join(dir, "api.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(yamlData), "source_package: github.com/origadmin/adptool/testdata/pkgs/source3")
+
+	require.NoError(t, manifest.WriteFile(filepath.Join(dir, "api.json"), DefaultFileMode))
+	got, err := os.ReadFile(filepath.Join(dir, "api.json"))
+	require.NoError(t, err)
+	// The manifest is not Go, so it is compared as is rather than with testutil.CompareWithGolden.
+	goldenFile := filepath.Join("..", "..", "testdata", "generator", t.Name()+".golden")
+	if *update {
+		require.NoError(t, os.WriteFile(goldenFile, got, 0644))
+	}
+	want, err := os.ReadFile(goldenFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestGenerator_Registry(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "registrytest",
+		Packages: []*config.Package{
+			{
+				Import:   "github.com/origadmin/adptool/testdata/pkgs/source1",
+				Alias:    "source",
+				Registry: "SourceTypes",
+				Types:    []*config.TypeRule{{Name: "MyStruct", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "MyStruct", To: "LocalStruct"}}}}},
+			},
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source2", Alias: "other"},
+		},
+	})
+	for _, name := range []string{"CommonStruct", "ExportedInterface", "ExportedType", "LocalStruct"} {
+		assert.Regexp(t, fmt.Sprintf(`"%s":\s+reflect\.TypeOf\(\(\*%s\)\(nil\)\)\.Elem\(\),`, name, name), string(got))
+	}
+	assert.NotContains(t, string(got), `"Worker":`, "packages without a registry are not registered")
+	assert.Contains(t, string(got), `"reflect"`)
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_Instantiate(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "instantiatetest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+			Alias:  "source",
+			Instantiate: []*config.Instantiation{
+				{Type: "GenericWorker", Args: []string{"string"}, As: "StringWorker"},
+				{Type: "ComplexGenericInterface", Args: []string{"*OutputData", "Status"}, As: "OutputAPI"},
+			},
+		}},
+	})
+	assert.Regexp(t, `StringWorker\s+= source\.GenericWorker\[string\]`, string(got))
+	assert.Regexp(t, `OutputAPI\s+= source\.ComplexGenericInterface\[\*source\.OutputData, source\.Status\]`, string(got))
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_InstantiateFunc(t *testing.T) {
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "instantiatefunc",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+			Alias:  "source",
+			Instantiate: []*config.Instantiation{
+				{Func: "Map", Args: []string{"string", "int"}, As: "MapStringInt"},
+				{Func: "Filter", Args: []string{"*OutputData"}, As: "FilterOutputs"},
+			},
+		}},
+		Functions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Prefix: "Source"}}},
+	}))
+	assert.Contains(t, got, "func MapStringInt(ts []string, fn func(string) int) []int {\n\treturn source.Map[string, int](ts, fn)\n}")
+	assert.Contains(t, got, "func FilterOutputs(ts []*source.OutputData, fn func(*source.OutputData) bool) []*source.OutputData {\n\treturn source.Filter[*source.OutputData](ts, fn)\n}")
+	assert.Contains(t, got, "func SourceMap[T, U any](", "the generic function is still re-exported")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, []byte(got))
+}
+
+func TestGenerator_AnyTypeArguments(t *testing.T) {
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "anyargs",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/anyargs",
+			Alias:  "source",
+			Instantiate: []*config.Instantiation{
+				{Type: "Pair", Args: []string{"string", "any"}, As: "AnyPair"},
+			},
+		}},
+	}))
+	assert.NotContains(t, got, "source.any", "any is a predeclared type")
+	assert.NotContains(t, got, "source.comparable", "comparable is a predeclared type")
+	assert.Regexp(t, `AnyPair\s+= source\.Pair\[string, any\]`, got)
+	assert.Contains(t, got, "func Wrap(v any) source.Box[any] {")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, []byte(got))
+}
+
+func TestGenerator_InstantiateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		inst    *config.Instantiation
+		wantErr string
+	}{
+		{"too few arguments", &config.Instantiation{Type: "ComplexGenericInterface", Args: []string{"string"}, As: "API"}, "expected 2 type arguments for [T, K], got 1"},
+		{"too many arguments", &config.Instantiation{Type: "GenericWorker", Args: []string{"string", "int"}, As: "Worker2"}, "expected 1 type arguments for [T], got 2"},
+		{"no arguments", &config.Instantiation{Type: "GenericWorker", As: "Worker"}, "expected 1 type arguments for [T], got 0"},
+		{"nested argument arity", &config.Instantiation{Type: "GenericWorker", Args: []string{"GenericWorker[string, int]"}, As: "NestedWorker"}, `type argument "GenericWorker[string, int]"`},
+		{"not generic", &config.Instantiation{Type: "Worker", Args: []string{"string"}, As: "StringWorker"}, "type is not generic"},
+		{"unknown type", &config.Instantiation{Type: "Missing", Args: []string{"string"}, As: "StringMissing"}, "no such type"},
+		{"unsatisfied constraint", &config.Instantiation{Type: "ComplexGenericInterface", Args: []string{"string", "[]int"}, As: "API"}, "does not satisfy comparable"},
+		{"function arity", &config.Instantiation{Func: "Map", Args: []string{"string"}, As: "MapString"}, "expected 2 type arguments for [T, U], got 1"},
+		{"function not generic", &config.Instantiation{Func: "NewWorker", Args: []string{"string"}, As: "StringWorker"}, "function is not generic"},
+		{"unknown function", &config.Instantiation{Func: "Missing", Args: []string{"string"}, As: "StringMissing"}, "no such function"},
+		{"type and function", &config.Instantiation{Type: "GenericWorker", Func: "Map", Args: []string{"string", "int"}, As: "MapStringInt"}, "set either type or func"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiledCfg, err := compiler.Compile(&config.Config{
+				PackageName: "instantiatetest",
+				Packages: []*config.Package{{
+					Import:      "github.com/origadmin/adptool/testdata/pkgs/source3",
+					Instantiate: []*config.Instantiation{tt.inst},
+				}},
+			})
+			require.NoError(t, err)
+			pkg := compiledCfg.Packages[0]
+			g := NewGenerator("instantiatetest", filepath.Join(t.TempDir(), "out.go"), compiler.NewReplacer(compiledCfg), "")
+			err = g.Generate([]*PackageInfo{{ImportPath: pkg.ImportPath, ImportAlias: pkg.ImportAlias, Instantiations: pkg.Instantiations}})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestGenerator_ContextHook(t *testing.T) {
+	cfg := &config.Config{
+		PackageName: "contexthooktest",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/ctxfirst", Alias: "source"}},
+	}
+
+	plain := string(generateForTest(t, cfg))
+	assert.Contains(t, plain, "return source.Run(ctx, done)", "the context is forwarded first")
+	assert.Contains(t, plain, "source.Do(p0, p1)")
+	assert.NotContains(t, plain, "defer", "no hook is called without one set")
+
+	got := generateForTest(t, cfg, func(g *Generator) { g.WithContextHook("traceCall") })
+	src := string(got)
+	assert.Contains(t, src, `ctx, done1 := traceCall(ctx, "github.com/origadmin/adptool/testdata/pkgs/ctxfirst.Run")`, "the hook result does not shadow a parameter")
+	assert.Contains(t, src, `p0, done := traceCall(p0, "github.com/origadmin/adptool/testdata/pkgs/ctxfirst.Do")`)
+	assert.Contains(t, src, `c, done := traceCall(c, "github.com/origadmin/adptool/testdata/pkgs/ctxfirst.Renamed")`, "a renamed context import is detected")
+	assert.Equal(t, 3, strings.Count(src, "traceCall("), "functions not taking a context first are forwarded as is")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_ContextHookInvalid(t *testing.T) {
+	g := NewGenerator("contexthooktest", filepath.Join(t.TempDir(), "out.go"), nil, "").WithContextHook("trace.Start")
+	err := g.Generate([]*PackageInfo{{ImportPath: "github.com/origadmin/adptool/testdata/pkgs/ctxfirst"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `context hook "trace.Start" is not a valid identifier`)
+}
+
+func TestGenerator_EmbedStruct(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "embedtest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source1",
+			Alias:  "source",
+			Types: []*config.TypeRule{{
+				Name:    "MyStruct",
+				Kind:    "struct",
+				Pattern: "wrap",
+				Embed:   true,
+				RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "MyStruct", To: "LocalStruct"}}},
+			}},
+		}},
+	})
+	assert.Regexp(t, `LocalStruct\s+struct\s*\{\s*source\.MyStruct\s*\}`, string(got), "the renamed type embeds the source type")
+	assert.Regexp(t, `ExportedType\s+= source\.ExportedType`, string(got), "other types stay aliases")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_TypeMode(t *testing.T) {
+	for _, tt := range []struct {
+		mode, pattern string
+		defined       string // a type re-exported as a defined type
+		aliased       string // a type kept as an alias
+	}{
+		// A type rule without a pattern takes the type mode; one with a pattern overrides it.
+		{mode: "define", pattern: "alias", defined: "ExportedType", aliased: "MyStruct"},
+		{mode: "alias", pattern: "define", defined: "MyStruct", aliased: "ExportedType"},
+	} {
+		t.Run(tt.mode, func(t *testing.T) {
+			got := generateForTest(t, &config.Config{
+				PackageName: "typemode",
+				TypeMode:    tt.mode,
+				Packages: []*config.Package{{
+					Import: "github.com/origadmin/adptool/testdata/pkgs/source1",
+					Alias:  "source",
+					Types:  []*config.TypeRule{{Name: "MyStruct", Pattern: tt.pattern}},
+				}},
+			})
+			assert.Regexp(t, tt.defined+`\s+source\.`+tt.defined+`\n`, string(got))
+			assert.Regexp(t, tt.aliased+`\s+= source\.`+tt.aliased+`\n`, string(got))
+			testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+		})
+	}
+}
+
+func TestGenerator_TypeModeGenerics(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "generics",
+		TypeMode:    "define",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+			Alias:  "source",
+		}},
+	})
+	assert.Regexp(t, `GenericWorker\[T any\]\s+source\.GenericWorker\[T\]`, string(got), "defined generic types keep the any constraint")
+	assert.Contains(t, string(got), "func Map[T, U any](", "grouped type parameters share their constraint")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_TypeModeSelfReference(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "linkedlist",
+		TypeMode:    "define",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/linkedlist",
+			Alias:  "source",
+		}},
+	})
+	// A defined type has the underlying type of the source type, so its Next field, like the
+	// results of the re-exported functions, is a *source.Node rather than a local *Node.
+	assert.Regexp(t, `Node\s+source\.Node\n`, string(got))
+	assert.Contains(t, string(got), "func NewNode(value int) *source.Node {")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_TypeModeInvalid(t *testing.T) {
+	_, err := compiler.Compile(&config.Config{TypeMode: "copy"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `type_mode must be "alias" or "define", got "copy"`)
+}
+
+func TestGenerator_ConstructorResults(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "constructors",
+		Packages: []*config.Package{
+			{
+				Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+				Alias:  "source",
+				Types: []*config.TypeRule{{
+					Name:    "Worker",
+					RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Worker", To: "Processor"}}},
+				}},
+			},
+			{
+				// Both packages declare NewWorker, so the one of source3, sorted last, is suffixed.
+				Import: "github.com/origadmin/adptool/testdata/pkgs/source2",
+				Alias:  "other",
+			},
+		},
+	})
+	assert.Contains(t, string(got), "func NewWorker1(name string, options ...source.WorkerOption) *Processor {",
+		"the constructor returns the renamed local alias")
+	assert.Contains(t, string(got), "func NewWorker(name string) *Worker {", "each constructor returns the alias of its own package")
+	assert.Contains(t, string(got), "func NewGenericWorker[T any](name string, data T, processor func(T) error) *source.GenericWorker[T] {",
+		"generic constructors keep the source type")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_ConstructorResultsDefinedType(t *testing.T) {
+	// A defined type is distinct from the source type the wrapped constructor returns.
+	got := generateForTest(t, &config.Config{
+		PackageName: "constructors",
+		TypeMode:    "define",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source2",
+			Alias:  "source",
+		}},
+	})
+	assert.Contains(t, string(got), "func NewWorker(name string) *source.Worker {")
+}
+
+func TestGenerator_TypedConstants(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "constants",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+			Alias:  "source",
+		}},
+	})
+	assert.Regexp(t, `StatusUnknown\s+source\.Status\s+= source\.StatusUnknown`, string(got), "typed constants keep their source type")
+	assert.Regexp(t, `DefaultTimeout\s+time\.Duration\s+= source\.DefaultTimeout`, string(got),
+		"types of other packages are imported")
+	assert.Regexp(t, `\n\tVersion\s+= source\.Version\n`, string(got), "untyped constants stay untyped")
+	assert.Regexp(t, `\n\tMaxRetries\s+= source\.MaxRetries\n`, string(got))
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_EmbedFieldRename(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "embedfield",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source4",
+			Alias:  "source",
+			Types: []*config.TypeRule{{
+				Name:    "Data",
+				Pattern: "wrap",
+				Embed:   true,
+				Fields: []*config.MemberRule{
+					{Name: "Model", RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Model", To: "Core"}}}},
+					{Name: "Value", RuleSet: config.RuleSet{Prefix: "Raw"}},
+				},
+			}},
+		}},
+	})
+	assert.Regexp(t, `Data\s+struct\s*\{\s*source\.Data\s*\}`, string(got))
+	assert.Contains(t, string(got), "func (d *Data) Core() *source.Model {", "the renamed embedded field has an accessor")
+	assert.NotContains(t, string(got), "RawValue", "only embedded fields are renamed")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_ExtractInterfaceEmbeds(t *testing.T) {
+	got := generateForTest(t, extractEmbeddedInterfaceConfig())
+	assert.Regexp(t, `(?s)Streamer\s+interface \{\s+io\.Reader\s+io\.Writer\s+source\.ComplexGenericInterface\[string, int\]\s+AdditionalMethod\(\) bool\s+\}`, string(got),
+		"the embedded interfaces are kept and only the added method is listed")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_ExtractInterfaceFlattened(t *testing.T) {
+	got := generateForTest(t, extractEmbeddedInterfaceConfig(), func(g *Generator) { g.WithFlattenInterfaces(true) })
+	assert.NotContains(t, string(got), "\tio.Reader\n", "no interface is embedded")
+	for _, method := range []string{"AdditionalMethod() bool", "Read(p []byte) (n int, err error)", "Write(p []byte) (n int, err error)", "MethodWithVariadic(items ...string) []int"} {
+		assert.Contains(t, string(got), method, "the full method set is listed")
+	}
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+// extractEmbeddedInterfaceConfig extracts Streamer from EmbeddedInterface, which embeds
+// io.Reader, io.Writer and an instantiated generic interface.
+func extractEmbeddedInterfaceConfig() *config.Config {
+	return &config.Config{
+		PackageName: "extracttest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+			Alias:  "source",
+			Types:  []*config.TypeRule{{Name: "EmbeddedInterface", ExtractInterface: "Streamer"}},
+		}},
+	}
+}
+
+func TestGenerator_ExtractInterface(t *testing.T) {
+	cfg := &config.Config{
+		PackageName: "extracttest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+			Alias:  "source",
+			Types: []*config.TypeRule{{
+				Name:             "Worker",
+				ExtractInterface: "WorkerAPI",
+				RuleSet:          config.RuleSet{Prefix: "Src"},
+			}},
+		}},
+	}
+	got := generateForTest(t, cfg)
+
+	// The extracted interface keeps its configured name; rename rules only apply to the alias.
+	assert.Regexp(t, `SrcWorker\s+= source.Worker`, string(got))
+	assert.Regexp(t, `WorkerAPI\s+interface \{`, string(got))
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_MultiParamGenerics(t *testing.T) {
+	// The type prefix renames the local alias of Number, so constraints must keep referring
+	// to the source package rather than to the generated names.
+	got := generateForTest(t, &config.Config{
+		PackageName: "generics",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source5",
+			Alias:  "source",
+		}},
+		Types: []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Prefix: "Gen"}}},
+	})
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_ConstraintImports(t *testing.T) {
+	// The constraints package is imported by name in one source file and with an alias in another.
+	got := generateForTest(t, &config.Config{
+		PackageName: "generics",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source6",
+			Alias:  "source",
+		}},
+	})
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_SourceVersions(t *testing.T) {
+	generate := func(t *testing.T, packageInfos []*PackageInfo) string {
+		t.Helper()
+		var buf bytes.Buffer
+		generator := NewGenerator("versions", "", nil, "").WithFormatCode(false)
+		generator.builder.writer = &buf
+		require.NoError(t, generator.RenderHeader("source.go"))
+		require.NoError(t, generator.Generate(packageInfos))
+		return buf.String()
+	}
+
+	t.Run("pinned", func(t *testing.T) {
+		got := generate(t, []*PackageInfo{{
+			ImportPath: "github.com/origadmin/adptool/testdata/pkgs/source1",
+			Version:    "v1.2.3",
+		}})
+		assert.Contains(t, got, "// Source: github.com/origadmin/adptool/testdata/pkgs/source1@v1.2.3\n")
+	})
+
+	t.Run("detected from module", func(t *testing.T) {
+		got := generate(t, []*PackageInfo{
+			{ImportPath: "golang.org/x/mod/semver"},
+			{ImportPath: "github.com/origadmin/adptool/testdata/pkgs/source1"},
+		})
+		assert.Regexp(t, `// Source: golang.org/x/mod/semver@v\d+\.\d+\.\d+\n`, got)
+		assert.NotContains(t, got, "source1@", "packages of the main module have no version")
+	})
+}
+
+func TestBuilder_HeaderOrder(t *testing.T) {
+	runWithAdapterConfig(t, map[string]string{
+		"lib/lib.go": "package lib\n\nfunc Hello() string { return \"hello\" }\n",
+		"main.go":    "package main\n\nimport \"example.com/app/adapters\"\n\nfunc main() { print(adapters.Hello()) }\n",
+	}, &config.Package{}, func(g *Generator) {
+		g.WithGenerateDirectives("adptool .")
+		g.builder.buildConstraint = "go1.18"
+		require.NoError(t, g.RenderHeader("lib.go"))
+	})
+	// runWithAdapterConfig has built the adapter, and left the working directory in the module.
+	src, err := os.ReadFile(filepath.Join("adapters", "adapters.go"))
+	require.NoError(t, err)
+
+	lines := strings.Split(string(src), "\n")
+	assert.Equal(t, []string{"//go:build go1.18", ""}, lines[:2], "the build constraint comes first, followed by a blank line")
+	file, err := parser.ParseFile(token.NewFileSet(), "adapters.go", src, parser.ParseComments)
+	require.NoError(t, err)
+	assert.True(t, ast.IsGenerated(file), "the no-edit banner is recognized")
+	require.NotNil(t, file.Doc)
+	assert.Equal(t, "Package adapters contains generated code by adptool.\n", file.Doc.Text(), "only the package doc documents the package")
+
+	order := []string{"//go:build go1.18", GeneratedBanner, "//go:generate adptool .", "// Package adapters", "package adapters"}
+	last := -1
+	for _, part := range order {
+		index := strings.Index(string(src), part)
+		require.GreaterOrEqual(t, index, 0, "missing %q", part)
+		assert.Greater(t, index, last, "%q is out of order", part)
+		last = index
+	}
+}
+
+func TestBuilder_ToolVersion(t *testing.T) {
+	b := NewBuilder("adapters", "", "")
+	require.NoError(t, b.RenderHeader("lib.go"))
+	assert.Contains(t, b.header, "// This file is generated from lib.go.\n", "no version is written by default")
+
+	b.WithToolVersion("v1.2.3")
+	require.NoError(t, b.RenderHeader("lib.go"))
+	assert.Contains(t, b.header, "// This file is generated from lib.go by adptool v1.2.3.\n")
+
+	b.WithHeaderTemplate("// adptool {{.ToolVersion}}\n")
+	require.NoError(t, b.RenderHeader("lib.go"))
+	assert.Equal(t, "// adptool v1.2.3\n", b.header, "custom templates can use the version")
+}
+
+func TestGenerator_PlainImport(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "plain",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/tagged",
+			Alias:  "source",
+		}},
+	}, func(g *Generator) { g.WithPlainImport(true) })
+	assert.Contains(t, string(got), "\t\"github.com/origadmin/adptool/testdata/pkgs/tagged\"\n", "the package is imported without alias")
+	assert.NotContains(t, string(got), "source.")
+	assert.Contains(t, string(got), "tagged.")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+
+	t.Run("name differs from path", func(t *testing.T) {
+		got := generateForTest(t, &config.Config{
+			PackageName: "plain",
+			Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"}},
+		}, func(g *Generator) { g.WithPlainImport(true) })
+		assert.Contains(t, string(got), `sourcepkg "github.com/origadmin/adptool/testdata/pkgs/source1"`)
+	})
+
+	t.Run("several packages", func(t *testing.T) {
+		got := generateForTest(t, &config.Config{
+			PackageName: "plain",
+			Packages: []*config.Package{
+				{Import: "github.com/origadmin/adptool/testdata/pkgs/tagged", Alias: "source"},
+				{Import: "github.com/origadmin/adptool/testdata/pkgs/source5"},
+			},
+		}, func(g *Generator) { g.WithPlainImport(true) })
+		assert.Contains(t, string(got), `source "github.com/origadmin/adptool/testdata/pkgs/tagged"`, "aliases are kept")
+	})
+}
+
+func TestGenerator_RenameTable(t *testing.T) {
+	got := generateForTest(t, &config.Config{
+		PackageName: "renames",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source3",
+			Alias:  "source",
+			Types: []*config.TypeRule{{
+				Name:    "Worker",
+				RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "Worker", To: "Processor"}}},
+			}},
+			Functions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Prefix: "Do"}}},
+		}},
+	}, func(g *Generator) { g.WithRenameTable(true) })
+	assert.Regexp(t, `(?m)^//\tsource\.Worker\s+-> Processor$`, string(got), "a renamed type is listed")
+	assert.Regexp(t, `(?m)^//\tsource\.Map\s+-> DoMap$`, string(got), "renamed functions are listed")
+	assert.NotContains(t, string(got), "//\tsource.Status", "symbols keeping their name are not listed")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_Passthrough(t *testing.T) {
+	// The zero-rule case of `adptool passthrough`: the package is re-exported under its own name.
+	const importPath = "github.com/origadmin/adptool/testdata/pkgs/source3"
+	got := generateForTest(t, &config.Config{
+		PackageName: "pkg3",
+		Packages:    []*config.Package{{Import: importPath}},
+	}, func(g *Generator) { g.WithPlainImport(true) })
+
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedTypes}, importPath)
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+	names := declaredNamesOf(t, got)
+	scope := pkgs[0].Types.Scope()
+	for _, name := range scope.Names() {
+		// A function taking an unexported type cannot be forwarded from another package.
+		if token.IsExported(name) && name != "ExportedFunctionWithUnexportedParam" {
+			assert.True(t, names[name], "exported symbol %s is re-exported", name)
+		}
+	}
+	assert.Contains(t, string(got), "package pkg3\n")
+	assert.Contains(t, string(got), `pkg3 "github.com/origadmin/adptool/testdata/pkgs/source3"`, "the package is imported by its own name")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, got)
+}
+
+func TestGenerator_ImportVersions(t *testing.T) {
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "versions",
+		Packages: []*config.Package{
+			{Import: "golang.org/x/mod/semver"},
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"},
+		},
+	}, func(g *Generator) { g.WithImportVersions(true) }))
+	assert.Regexp(t, `"golang.org/x/mod/semver" // v\d+\.\d+\.\d+\n`, got, "the import of a module dependency has its version")
+	assert.Contains(t, got, `source "github.com/origadmin/adptool/testdata/pkgs/source1"`+"\n", "packages of the main module have no version")
+}
+
+func TestBuilder_InvalidGeneratedCode(t *testing.T) {
+	dumpDir := t.TempDir()
+	t.Setenv("TMPDIR", dumpDir)
+	outputFile := filepath.Join(t.TempDir(), "invalid.adapter.go")
+
+	// A type name that is not an identifier, as a qualification bug could produce.
+	builder := NewBuilder("invalid", outputFile, "")
+	builder.aliasFile.Decls = []ast.Decl{&ast.GenDecl{
+		Tok:   token.TYPE,
+		Specs: []ast.Spec{&ast.TypeSpec{Name: ast.NewIdent("1Client"), Assign: 1, Type: ast.NewIdent("int")}},
+	}}
+
+	err := builder.Write()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "generated code for "+outputFile+" is not valid Go")
+	assert.NoFileExists(t, outputFile, "invalid code must not replace the output file")
+
+	dumps, globErr := filepath.Glob(filepath.Join(dumpDir, "adptool-*.go"))
+	require.NoError(t, globErr)
+	require.Len(t, dumps, 1)
+	assert.ErrorContains(t, err, "unformatted source written to "+dumps[0])
+	dumped, readErr := os.ReadFile(dumps[0])
+	require.NoError(t, readErr)
+	assert.Contains(t, string(dumped), "type 1Client = int")
+}
+
+func TestGenerator_LineEndings(t *testing.T) {
+	compiledCfg, err := compiler.Compile(&config.Config{
+		PackageName: "lineendings",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"}},
+	})
+	require.NoError(t, err)
+
+	generate := func(t *testing.T, ending LineEnding, configure func(*Generator)) {
+		t.Helper()
+		var packageInfos []*PackageInfo
+		for _, pkg := range compiledCfg.Packages {
+			packageInfos = append(packageInfos, &PackageInfo{ImportPath: pkg.ImportPath, ImportAlias: pkg.ImportAlias})
+		}
+		generator := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").
+			WithFormatCode(false).
+			WithLineEnding(ending)
+		configure(generator)
+		require.NoError(t, generator.RenderHeader("source.go"))
+		require.NoError(t, generator.Generate(packageInfos))
+	}
+
+	assertCRLF := func(t *testing.T, got []byte) {
+		t.Helper()
+		require.Contains(t, string(got), "\r\n")
+		assert.Equal(t, bytes.Count(got, []byte("\n")), bytes.Count(got, []byte("\r\n")), "every line must end with CRLF")
+		formatted, err := format.Source(got)
+		require.NoError(t, err, "CRLF output must still be valid Go")
+		assert.NotEmpty(t, formatted)
+	}
+
+	t.Run("writer", func(t *testing.T) {
+		lf := &bytes.Buffer{}
+		generate(t, LineEndingLF, func(g *Generator) { g.builder.writer = lf })
+		assert.NotContains(t, lf.String(), "\r")
+
+		crlf := &bytes.Buffer{}
+		generate(t, LineEndingCRLF, func(g *Generator) { g.builder.writer = crlf })
+		assertCRLF(t, crlf.Bytes())
+		assert.Equal(t, lf.String(), strings.ReplaceAll(crlf.String(), "\r\n", "\n"))
+	})
+
+	t.Run("file", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "source.adapter.go")
+		generate(t, LineEndingCRLF, func(g *Generator) { g.builder.outputFilePath = outputFile })
+		got, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assertCRLF(t, got)
+	})
+}
+
+func TestParseLineEnding(t *testing.T) {
+	ending, err := ParseLineEnding("CRLF")
+	require.NoError(t, err)
+	assert.Equal(t, LineEndingCRLF, ending)
+
+	ending, err = ParseLineEnding("lf")
+	require.NoError(t, err)
+	assert.Equal(t, LineEndingLF, ending)
+
+	_, err = ParseLineEnding("cr")
+	assert.Error(t, err)
+}
+
+func TestGenerator_FileMode(t *testing.T) {
+	compiledCfg, err := compiler.Compile(&config.Config{
+		PackageName: "filemode",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"}},
+	})
+	require.NoError(t, err)
+	packageInfos := []*PackageInfo{{ImportPath: compiledCfg.Packages[0].ImportPath, ImportAlias: compiledCfg.Packages[0].ImportAlias}}
+
+	for _, tt := range []struct {
+		name   string
+		mode   os.FileMode
+		ending LineEnding
+		want   os.FileMode
+	}{
+		{"default", 0, LineEndingLF, DefaultFileMode},
+		{"restricted", 0600, LineEndingLF, 0600},
+		{"group readable", 0640, LineEndingCRLF, 0640},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			outputFile := filepath.Join(t.TempDir(), "source.adapter.go")
+			generator := NewGenerator(compiledCfg.PackageName, outputFile, compiler.NewReplacer(compiledCfg), "").
+				WithFormatCode(false).
+				WithLineEnding(tt.ending).
+				WithFileMode(tt.mode)
+			require.NoError(t, generator.Generate(packageInfos))
+			info, err := os.Stat(outputFile)
+			require.NoError(t, err)
+			if runtime.GOOS != "windows" {
+				assert.Equal(t, tt.want, info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestGenerator_SplitGenerics(t *testing.T) {
+	generate := func(t *testing.T, importPath string) string {
+		t.Helper()
+		compiledCfg, err := compiler.Compile(&config.Config{
+			PackageName: "splittest",
+			Packages:    []*config.Package{{Import: importPath, Alias: "source"}},
+		})
+		require.NoError(t, err)
+		outputFile := filepath.Join(t.TempDir(), "source.adapter.go")
+		generator := NewGenerator(compiledCfg.PackageName, outputFile, compiler.NewReplacer(compiledCfg), "").
+			WithFormatCode(false).
+			WithSplitGenerics(true)
+		require.NoError(t, generator.RenderHeader("source.go"))
+		require.NoError(t, generator.Generate([]*PackageInfo{{ImportPath: importPath, ImportAlias: "source"}}))
+		return outputFile
+	}
+
+	t.Run("generics", func(t *testing.T) {
+		outputFile := generate(t, "github.com/origadmin/adptool/testdata/pkgs/source3")
+		full, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		fallback, err := os.ReadFile(filepath.Join(filepath.Dir(outputFile), "source.adapter_nogenerics.go"))
+		require.NoError(t, err)
+
+		assert.True(t, strings.HasPrefix(string(full), "//go:build go1.18\n\n"+GeneratedBanner), "the constraint precedes the header")
+		assert.Regexp(t, `GenericWorker\[T any\]\s+= source\.GenericWorker\[T\]`, string(full))
+		assert.Contains(t, string(full), "func Filter[T any]")
+
+		assert.True(t, strings.HasPrefix(string(fallback), "//go:build !go1.18\n\n"+GeneratedBanner))
+		assert.Regexp(t, `Worker\s+= source\.Worker`, string(fallback), "non-generic declarations are kept")
+		assert.Contains(t, string(fallback), "func NewWorker(")
+		assert.NotContains(t, string(fallback), "GenericWorker", "generic types are left out")
+		assert.NotContains(t, string(fallback), "func Filter", "generic functions are left out")
+		assert.NotContains(t, string(fallback), "func Execute(", "functions using instantiated types are left out")
+		for _, src := range [][]byte{full, fallback} {
+			_, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("no generics", func(t *testing.T) {
+		outputFile := generate(t, "github.com/origadmin/adptool/testdata/pkgs/source1")
+		full, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(full), GeneratedBanner), "no constraint is needed")
+		assert.NoFileExists(t, filepath.Join(filepath.Dir(outputFile), "source.adapter_nogenerics.go"))
+	})
+}
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := ParseFileMode("0640")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), mode)
+
+	mode, err = ParseFileMode("600")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), mode)
+
+	for _, s := range []string{"", "0999", "rw-r--r--", "01777"} {
+		_, err = ParseFileMode(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestGenerator_StrictIdentifiers(t *testing.T) {
+	cfg := &config.Config{
+		PackageName: "stricttest",
+		Packages: []*config.Package{{
+			Import: "github.com/origadmin/adptool/testdata/pkgs/source1",
+			Alias:  "source",
+			Types: []*config.TypeRule{{
+				Name:    "ExportedType",
+				RuleSet: config.RuleSet{Explicit: []*config.ExplicitRule{{From: "ExportedType", To: "Wörker"}}},
+			}},
+		}},
+	}
+
+	// Go accepts the Unicode name, so it is generated unless identifiers are strict.
+	got := generateForTest(t, cfg)
+	assert.Regexp(t, `Wörker\s+= source\.ExportedType`, string(got))
+
+	compiledCfg, err := compiler.Compile(cfg)
+	require.NoError(t, err)
+	g := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").WithStrictIdentifiers(true)
+	g.builder.writer = &bytes.Buffer{}
+	err = g.Generate([]*PackageInfo{{ImportPath: compiledCfg.Packages[0].ImportPath, ImportAlias: "source"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `type "Wörker" (from github.com/origadmin/adptool/testdata/pkgs/source1.ExportedType)`)
+}
+
+func TestGenerator_ReservedNames(t *testing.T) {
+	// The pattern is not anchored at its end, so the compiler cannot know the name it gives.
+	cfg := &config.Config{
+		PackageName: "reservedtest",
+		Packages: []*config.Package{{
+			Import:    "github.com/origadmin/adptool/testdata/pkgs/source6",
+			Alias:     "source",
+			Functions: []*config.FuncRule{{Name: "Max", RuleSet: config.RuleSet{Regex: []*config.RegexRule{{Pattern: "^M", Replace: "m"}}}}},
+		}},
+	}
+	compiledCfg, err := compiler.Compile(cfg)
+	require.NoError(t, err)
+
+	generate := func(lenient bool) error {
+		g := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").WithLenient(lenient)
+		g.builder.writer = &bytes.Buffer{}
+		return g.Generate([]*PackageInfo{{ImportPath: compiledCfg.Packages[0].ImportPath, ImportAlias: "source"}})
+	}
+	err = generate(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `func "max" (from github.com/origadmin/adptool/testdata/pkgs/source6.Max) is a Go predeclared identifier`)
+	assert.NoError(t, generate(true), "lenient mode only warns")
+}
+
+func TestGenerator_Reset(t *testing.T) {
+	compiledCfg, err := compiler.Compile(&config.Config{
+		PackageName: "resettest",
+		Packages: []*config.Package{
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"},
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source3", Alias: "other"},
+		},
+		Types: []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Prefix: "X"}}},
+	})
+	require.NoError(t, err)
+	runs := [][]*PackageInfo{
+		{{ImportPath: "github.com/origadmin/adptool/testdata/pkgs/source1", ImportAlias: "source"}},
+		{{ImportPath: "github.com/origadmin/adptool/testdata/pkgs/source3", ImportAlias: "other"}},
+	}
+
+	generate := func(t *testing.T, g *Generator, packages []*PackageInfo) string {
+		t.Helper()
+		var buf bytes.Buffer
+		g.builder.writer = &buf
+		require.NoError(t, g.RenderHeader("source.go"))
+		require.NoError(t, g.Generate(packages))
+		return buf.String()
+	}
+	newGenerator := func() *Generator {
+		return NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "")
+	}
+
+	reused := newGenerator()
+	for i, packages := range runs {
+		if i > 0 {
+			reused.Reset()
+		}
+		want := generate(t, newGenerator(), packages)
+		got := generate(t, reused, packages)
+		assert.Equal(t, want, got, "run %d of a reused generator must match a fresh generator", i+1)
+	}
+}
+
+func TestGenerator_EmitTests(t *testing.T) {
+	// The output is type-checked with its test file, so it is written inside the module.
+	dir, err := os.MkdirTemp(filepath.Join("..", "..", "testdata"), "emittests-")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	outputFile := filepath.Join(dir, "source5.adapter.go")
+
+	cfg := &config.Config{
+		PackageName: "emittests",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source5", Alias: "source5"}},
+	}
+	compiledCfg, err := compiler.Compile(cfg)
+	require.NoError(t, err)
+	generator := NewGenerator(compiledCfg.PackageName, outputFile, compiler.NewReplacer(compiledCfg), "").
+		WithFormatCode(false).
+		WithEmitTests(true)
+	require.NoError(t, generator.Generate([]*PackageInfo{{ImportPath: compiledCfg.Packages[0].ImportPath, ImportAlias: "source5"}}))
+
+	testFile := filepath.Join(dir, "source5.adapter_test.go")
+	require.FileExists(t, testFile)
+	src, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	parsed, err := parser.ParseFile(token.NewFileSet(), testFile, src, 0)
+	require.NoError(t, err)
+	referenced := make(map[string]bool)
+	ast.Inspect(parsed, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			referenced[ident.Name] = true
+		}
+		return true
+	})
+	symbols := generator.Manifest().Symbols
+	require.NotEmpty(t, symbols)
+	for _, symbol := range symbols {
+		assert.True(t, referenced[symbol.Name], "generated symbol %s is referenced", symbol.Name)
+	}
+	assert.Contains(t, string(src), "_ = Sum[int]", "a generic function is instantiated")
+	assert.Contains(t, string(src), "func _[T Number]() {}", "a constraint is referenced as one")
+
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedTypes, Tests: true, Dir: dir}, ".")
+	require.NoError(t, err)
+	for _, pkg := range pkgs {
+		assert.Empty(t, pkg.Errors, "package %s type-checks", pkg.ID)
+	}
+}
+
+// collisionConfig re-exports source2 and source3, which both declare Worker and NewWorker.
+func collisionConfig() *config.Config {
+	return &config.Config{
+		PackageName: "collisions",
+		Packages: []*config.Package{
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source2", Alias: "other"},
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source3", Alias: "source"},
+		},
+	}
+}
+
+func TestGenerator_CollisionStrategySuffix(t *testing.T) {
+	got := string(generateForTest(t, collisionConfig(), func(g *Generator) { g.WithCollisionStrategy(CollisionSuffix) }))
+	assert.Regexp(t, `\tWorker\s+= other\.Worker\n`, got)
+	assert.Regexp(t, `\tWorker1\s+= source\.Worker\n`, got, "the symbol of the later package is numbered")
+}
+
+func TestGenerator_CollisionStrategyError(t *testing.T) {
+	compiledCfg, err := compiler.Compile(collisionConfig())
+	require.NoError(t, err)
+	var packageInfos []*PackageInfo
+	for _, pkg := range compiledCfg.Packages {
+		packageInfos = append(packageInfos, &PackageInfo{ImportPath: pkg.ImportPath, ImportAlias: pkg.ImportAlias})
+	}
+	generator := NewGenerator(compiledCfg.PackageName, "", compiler.NewReplacer(compiledCfg), "").
+		WithCollisionStrategy(CollisionError).
+		WithWriter(&bytes.Buffer{})
+
+	err = generator.Generate(packageInfos)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Worker (github.com/origadmin/adptool/testdata/pkgs/source2, github.com/origadmin/adptool/testdata/pkgs/source3)")
+	assert.ErrorContains(t, err, "NewWorker (")
+}
+
+func TestGenerator_CollisionStrategySkip(t *testing.T) {
+	got := string(generateForTest(t, collisionConfig(), func(g *Generator) { g.WithCollisionStrategy(CollisionSkip) }))
+	assert.Contains(t, got, "= other.Worker\n", "the symbol of the first package is kept")
+	assert.NotContains(t, got, "= source.Worker\n", "the symbol of the later package is left out")
+	assert.NotContains(t, got, "Worker1")
+	assert.Contains(t, got, "func NewWorker(name string) *Worker {")
+	assert.NotContains(t, got, "source.NewWorker(")
+}
+
+func TestGenerator_CollisionStrategyInvalid(t *testing.T) {
+	_, err := compiler.Compile(&config.Config{CollisionStrategy: "rename"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `collision_strategy must be "suffix", "error" or "skip", got "rename"`)
+}
+
+func TestGenerator_GenerateToString(t *testing.T) {
+	cfg := &config.Config{
+		PackageName: "tostring",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source3", Alias: "source"}},
+	}
+	compiledCfg, err := compiler.Compile(cfg)
+	require.NoError(t, err)
+	newGenerator := func(outputFile string) *Generator {
+		g := NewGenerator(compiledCfg.PackageName, outputFile, compiler.NewReplacer(compiledCfg), "").
+			WithLineEnding(LineEndingCRLF)
+		require.NoError(t, g.RenderHeader("tostring.go"))
+		return g
+	}
+	packageInfos := []*PackageInfo{{ImportPath: compiledCfg.Packages[0].ImportPath, ImportAlias: "source"}}
+
+	outputFile := filepath.Join(t.TempDir(), "tostring.adapter.go")
+	require.NoError(t, newGenerator(outputFile).Generate(packageInfos))
+	written, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+
+	stringOutputFile := filepath.Join(t.TempDir(), "tostring.adapter.go")
+	got, err := newGenerator(stringOutputFile).GenerateToString(packageInfos)
+	require.NoError(t, err)
+	assert.Equal(t, string(written), got, "the string is the content of the output file")
+	assert.NoFileExists(t, stringOutputFile, "no output file is written")
+
+	// With split generics and emitted tests, the string is the code of the output file only.
+	split := func(g *Generator) *Generator { return g.WithSplitGenerics(true).WithEmitTests(true) }
+	require.NoError(t, split(newGenerator(outputFile)).Generate(packageInfos))
+	written, err = os.ReadFile(outputFile)
+	require.NoError(t, err)
+	got, err = split(newGenerator(stringOutputFile)).GenerateToString(packageInfos)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got, "//go:build go1.18\r\n"), "the string is the code for Go 1.18 and later")
+	assert.Equal(t, string(written), got)
+	assert.NoFileExists(t, genericsFallbackPath(stringOutputFile), "the fallback file is not written")
+	assert.NoFileExists(t, testFilePath(stringOutputFile), "the test file is not written")
+}
+
+func TestGenerator_OutputOrder(t *testing.T) {
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "ordered",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"}},
+	}, func(g *Generator) { g.WithOutputOrder("imports", "funcs", "types") }))
+
+	funcs, types := strings.Index(got, "\nfunc "), strings.Index(got, "\ntype ")
+	consts, vars := strings.Index(got, "\nconst "), strings.Index(got, "\nvar ")
+	require.True(t, funcs >= 0 && types >= 0 && consts >= 0 && vars >= 0, "every section is generated")
+	assert.Less(t, strings.Index(got, "\nimport "), funcs, "the imports come first")
+	assert.Less(t, funcs, types, "the listed sections come in the given order")
+	assert.Less(t, types, consts, "the sections left out follow the listed ones")
+	assert.Less(t, consts, vars, "the sections left out keep the default order")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, []byte(got))
+}
+
+func TestGenerator_OutputOrderInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		order   []string
+		message string
+	}{
+		{[]string{"funcs", "methods"}, `output_order has unknown section "methods"`},
+		{[]string{"types", "types"}, `output_order lists "types" more than once`},
+		{[]string{"types", "imports"}, `output_order must list "imports" first`},
+	} {
+		_, err := compiler.Compile(&config.Config{OutputOrder: tt.order})
+		require.Error(t, err, "order %v", tt.order)
+		assert.Contains(t, err.Error(), tt.message)
+	}
+}
+
+func TestGenerator_ASTDump(t *testing.T) {
+	var dump bytes.Buffer
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "dumped",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source1", Alias: "source"}},
+	}, func(g *Generator) { g.WithASTDump(&dump) }))
+
+	require.NotEmpty(t, got, "the code is still generated")
+	out := dump.String()
+	assert.True(t, strings.HasPrefix(out, "     0  *ast.File {"), "the dump starts with the file node")
+	assert.Contains(t, out, `Name: "dumped"`, "the package name is dumped")
+	assert.Regexp(t, `\*ast\.SelectorExpr \{\n.*X: \*ast\.Ident \{\n.*NamePos: -\n.*Name: "source"`, out,
+		"references to the source package are dumped as selector expressions")
+	assert.Contains(t, out, `Name: "ExportedFunction"`)
+}
+
+func TestGenerator_NilInterfaceVars(t *testing.T) {
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "nilvars",
+		Packages: []*config.Package{
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source3", Alias: "source"},
+			{Import: "github.com/origadmin/adptool/testdata/pkgs/source5", Alias: "generics"},
+		},
+	}, func(g *Generator) { g.WithNilInterfaceVars(true) }))
+
+	assert.Regexp(t, `NilEmbeddedInterface\s+EmbeddedInterface\n`, got)
+	assert.NotContains(t, got, "NilComplexGenericInterface", "a generic interface cannot be the type of a variable")
+	assert.NotContains(t, got, "NilNumber", "a constraint cannot be the type of a variable")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, []byte(got))
+}
+
+func TestGenerator_RegisterFunc(t *testing.T) {
+	got := string(generateForTest(t, &config.Config{
+		PackageName: "register",
+		Packages:    []*config.Package{{Import: "github.com/origadmin/adptool/testdata/pkgs/source3", Alias: "source"}},
+	}, func(g *Generator) {
+		g.WithRegisterFunc(RegisterFunc{ImportPath: "example.com/plugin/codec", Name: "Register"})
+	}))
+
+	assert.Contains(t, got, `codec "example.com/plugin/codec"`)
+	assert.Contains(t, got, "func init() {\n")
+	assert.Contains(t, got, `codec.Register("Worker", Worker{})`)
+	assert.Contains(t, got, `codec.Register("Status", *new(Status))`)
+	assert.NotContains(t, got, `codec.Register("EmbeddedInterface"`, "the zero value of an interface is nil")
+	assert.NotContains(t, got, `codec.Register("InputData"`, "a generic type has no value until instantiated")
+	testutil.CompareWithGolden(t, filepath.Join("..", "..", "testdata", "generator"), *update, []byte(got))
+}
+
+func TestParseRegisterFunc(t *testing.T) {
+	fn, err := ParseRegisterFunc("example.com/plugin/codec.Register")
+	require.NoError(t, err)
+	assert.Equal(t, RegisterFunc{ImportPath: "example.com/plugin/codec", Name: "Register"}, fn)
+
+	fn, err = ParseRegisterFunc("register")
+	require.NoError(t, err)
+	assert.Equal(t, RegisterFunc{Name: "register"}, fn, "a function of the adapter package may be unexported")
+
+	fn, err = ParseRegisterFunc("")
+	require.NoError(t, err)
+	assert.Zero(t, fn)
+
+	for _, s := range []string{"example.com/codec", "example.com/codec.register", "codec.Re-gister", "exa mple.com/codec.Register"} {
+		_, err = ParseRegisterFunc(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,155 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
+// collectInstantiations generates a type alias for every instantiation listed for pkg, e.g.
+// "type StringWorker = source.GenericWorker[string]", or a wrapper for the instantiations of
+// generic functions. Type arguments are resolved in the scope of the source package and checked
+// against the constraints of the type parameters.
+func (c *Collector) collectInstantiations(pkg *PackageInfo, typesPkg *types.Package) error {
+	if len(pkg.Instantiations) == 0 {
+		return nil
+	}
+	if typesPkg == nil {
+		return fmt.Errorf("cannot instantiate types of package %s: no type information", pkg.ImportPath)
+	}
+
+	for _, inst := range pkg.Instantiations {
+		if inst.Func != "" {
+			if inst.Type != "" {
+				return fmt.Errorf("instantiation of %s.%s: set either type or func, not both", pkg.ImportPath, inst.Func)
+			}
+			if err := c.collectFuncInstantiation(pkg, typesPkg, inst); err != nil {
+				return err
+			}
+			continue
+		}
+		if !token.IsIdentifier(inst.As) {
+			return fmt.Errorf("instantiation of %s.%s: alias name %q is not a valid identifier", pkg.ImportPath, inst.Type, inst.As)
+		}
+		typeObj, ok := typesPkg.Scope().Lookup(inst.Type).(*types.TypeName)
+		if !ok {
+			return fmt.Errorf("instantiation of %s.%s: no such type", pkg.ImportPath, inst.Type)
+		}
+		named, ok := typeObj.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() == 0 {
+			return fmt.Errorf("instantiation of %s.%s: type is not generic", pkg.ImportPath, inst.Type)
+		}
+		if want := named.TypeParams().Len(); len(inst.Args) != want {
+			return fmt.Errorf("instantiation of %s.%s: expected %d type arguments for [%s], got %d",
+				pkg.ImportPath, inst.Type, want, typeParamNames(named.TypeParams()), len(inst.Args))
+		}
+
+		args, err := typeArguments(typesPkg, inst.Args)
+		if err != nil {
+			return fmt.Errorf("instantiation of %s.%s: %w", pkg.ImportPath, inst.Type, err)
+		}
+		instance, err := types.Instantiate(nil, named, args, true)
+		if err != nil {
+			return fmt.Errorf("instantiation of %s.%s: %w", pkg.ImportPath, inst.Type, err)
+		}
+
+		exprBuilder := newTypeExprBuilder(typesPkg, pkg.ImportAlias, c.importerPath)
+		expr := exprBuilder.expr(instance)
+		if exprBuilder.invalid {
+			return fmt.Errorf("instantiation of %s.%s: type arguments use unexported or internal types", pkg.ImportPath, inst.Type)
+		}
+		c.addImports(exprBuilder.imports)
+
+		if c.allPackageDecls[pkg.ImportPath] == nil {
+			c.allPackageDecls[pkg.ImportPath] = &packageDecls{}
+		}
+		c.allPackageDecls[pkg.ImportPath].namedSpecs = append(c.allPackageDecls[pkg.ImportPath].namedSpecs, &ast.TypeSpec{
+			Name:   ast.NewIdent(inst.As),
+			Assign: 1,
+			Type:   expr,
+		})
+	}
+	return nil
+}
+
+// collectFuncInstantiation generates a wrapper named inst.As for the instantiation of the generic
+// function inst.Func, e.g. "func MapStringInt(ts []string, fn func(string) int) []int", that
+// forwards to source.Map[string, int]. Like the aliases of instantiated types, the wrapper keeps
+// its given name.
+func (c *Collector) collectFuncInstantiation(pkg *PackageInfo, typesPkg *types.Package, inst *interfaces.CompiledInstantiation) error {
+	if !token.IsIdentifier(inst.As) {
+		return fmt.Errorf("instantiation of %s.%s: function name %q is not a valid identifier", pkg.ImportPath, inst.Func, inst.As)
+	}
+	fn, ok := typesPkg.Scope().Lookup(inst.Func).(*types.Func)
+	if !ok {
+		return fmt.Errorf("instantiation of %s.%s: no such function", pkg.ImportPath, inst.Func)
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() == 0 {
+		return fmt.Errorf("instantiation of %s.%s: function is not generic", pkg.ImportPath, inst.Func)
+	}
+	if want := sig.TypeParams().Len(); len(inst.Args) != want {
+		return fmt.Errorf("instantiation of %s.%s: expected %d type arguments for [%s], got %d",
+			pkg.ImportPath, inst.Func, want, typeParamNames(sig.TypeParams()), len(inst.Args))
+	}
+
+	args, err := typeArguments(typesPkg, inst.Args)
+	if err != nil {
+		return fmt.Errorf("instantiation of %s.%s: %w", pkg.ImportPath, inst.Func, err)
+	}
+	instance, err := types.Instantiate(nil, sig, args, true)
+	if err != nil {
+		return fmt.Errorf("instantiation of %s.%s: %w", pkg.ImportPath, inst.Func, err)
+	}
+
+	instanceSig := instance.(*types.Signature)
+	funcDecl, imports, ok := funcDeclFromTypes(types.NewFunc(fn.Pos(), typesPkg, inst.As, instanceSig), typesPkg, pkg.ImportAlias, c.importerPath)
+	exprBuilder := newTypeExprBuilder(typesPkg, pkg.ImportAlias, c.importerPath)
+	typeArgs := make([]ast.Expr, len(args))
+	for i, arg := range args {
+		typeArgs[i] = exprBuilder.expr(arg)
+	}
+	if !ok || exprBuilder.invalid {
+		return fmt.Errorf("instantiation of %s.%s: signature or type arguments use unexported or internal types", pkg.ImportPath, inst.Func)
+	}
+	c.addImports(imports)
+	c.addImports(exprBuilder.imports)
+
+	params := instanceSig.Params()
+	takesContext := params.Len() > 0 && isContextType(params.At(0).Type())
+	if c.allPackageDecls[pkg.ImportPath] == nil {
+		c.allPackageDecls[pkg.ImportPath] = &packageDecls{}
+	}
+	c.allPackageDecls[pkg.ImportPath].namedFuncs = append(c.allPackageDecls[pkg.ImportPath].namedFuncs,
+		c.functionWrapper(funcDecl, pkg.ImportPath, pkg.ImportAlias, inst.Func, typeArgs, takesContext))
+	return nil
+}
+
+// typeArguments resolves the type arguments args in the scope of typesPkg.
+func typeArguments(typesPkg *types.Package, args []string) ([]types.Type, error) {
+	resolved := make([]types.Type, len(args))
+	for i, arg := range args {
+		tv, err := types.Eval(token.NewFileSet(), typesPkg, token.NoPos, arg)
+		if err != nil {
+			return nil, fmt.Errorf("type argument %q: %w", arg, err)
+		}
+		if !tv.IsType() {
+			return nil, fmt.Errorf("type argument %q is not a type", arg)
+		}
+		resolved[i] = tv.Type
+	}
+	return resolved, nil
+}
+
+// typeParamNames returns the names of the type parameters of list, e.g. "T, K".
+func typeParamNames(list *types.TypeParamList) string {
+	names := make([]string, list.Len())
+	for i := range names {
+		names[i] = list.At(i).Obj().Name()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,47 @@
+package generator
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// PackageLoader loads the source packages whose declarations a Collector re-exports.
+// Tests and embedders can provide their own, e.g. to serve synthetic or cached packages
+// without reading the module graph from disk.
+type PackageLoader interface {
+	// Load loads the package with the given import path. It returns nil, and no error, if
+	// there is no such package. The collector reads the package's Name, PkgPath, Module,
+	// Syntax, Types and TypesInfo; a package without Syntax is collected from its Types.
+	Load(importPath string) (*packages.Package, error)
+}
+
+// PackageLoaderFunc adapts an ordinary function to the PackageLoader interface.
+type PackageLoaderFunc func(importPath string) (*packages.Package, error)
+
+// Load calls f(importPath).
+func (f PackageLoaderFunc) Load(importPath string) (*packages.Package, error) {
+	return f(importPath)
+}
+
+// DefaultPackageLoader loads packages with golang.org/x/tools/go/packages, resolving import
+// paths from the module of the working directory. It is the loader of a new Collector.
+type DefaultPackageLoader struct{}
+
+// Load loads the syntax, types and module information of the package.
+func (DefaultPackageLoader) Load(importPath string) (*packages.Package, error) {
+	loadCfg := &packages.Config{
+		Mode: packages.LoadSyntax | packages.LoadTypes | packages.NeedModule,
+	}
+	pkgs, err := packages.Load(loadCfg, importPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package %s: %w", importPath, err)
+	}
+	if len(pkgs) == 0 {
+		return nil, nil // Package not found
+	}
+	if len(pkgs[0].Errors) > 0 {
+		return nil, fmt.Errorf("errors while loading package %s: %v", importPath, pkgs[0].Errors)
+	}
+	return pkgs[0], nil
+}
@@ -0,0 +1,162 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest describes the public API of generated adapters, as a snapshot to review and diff
+// across versions.
+type Manifest struct {
+	Adapters []AdapterManifest `json:"adapters" yaml:"adapters"`
+}
+
+// AdapterManifest lists the symbols declared by a generated adapter file.
+type AdapterManifest struct {
+	File    string           `json:"file,omitempty" yaml:"file,omitempty"`
+	Package string           `json:"package" yaml:"package"`
+	Symbols []ManifestSymbol `json:"symbols" yaml:"symbols"`
+}
+
+// ManifestSymbol describes a generated symbol and the source symbol it re-exports.
+type ManifestSymbol struct {
+	Name string `json:"name" yaml:"name"`
+	Kind string `json:"kind" yaml:"kind"` // "const", "var", "type" or "func"
+	// Signature is the declaration of a type or function, without the body of the function.
+	Signature     string `json:"signature,omitempty" yaml:"signature,omitempty"`
+	SourcePackage string `json:"source_package" yaml:"source_package"`
+	// Source is the name of the source symbol, or empty if the symbol is not a re-export of
+	// one, such as an extracted interface or a registry.
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+// WriteFile writes the manifest to path, as YAML if its extension is .yaml or .yml and as
+// JSON otherwise. Adapters are sorted by file.
+func (m *Manifest) WriteFile(path string, perm os.FileMode) error {
+	sort.SliceStable(m.Adapters, func(i, j int) bool { return m.Adapters[i].File < m.Adapters[j].File })
+
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(m)
+	default:
+		data, err = json.MarshalIndent(m, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// buildManifest lists the symbols of the given sorted declarations, in the order of the flat
+// output: constants, variables, types, then functions. pathToAlias maps import paths to the
+// aliases that qualify their symbols in the generated file.
+func (b *Builder) buildManifest(consts, vars, types []sortedSpec, funcs []sortedDecl, pathToAlias map[string]string) []ManifestSymbol {
+	var symbols []ManifestSymbol
+	for _, kind := range []struct {
+		name  string
+		specs []sortedSpec
+	}{{"const", consts}, {"var", vars}, {"type", types}} {
+		for _, s := range kind.specs {
+			symbol := ManifestSymbol{Name: s.name, Kind: kind.name, SourcePackage: s.importPath}
+			switch spec := s.spec.(type) {
+			case *ast.ValueSpec:
+				for _, value := range spec.Values {
+					symbol.Source = sourceSymbol(value, pathToAlias[s.importPath])
+				}
+			case *ast.TypeSpec:
+				symbol.Signature = b.printNode(spec)
+				// An interface literal is extracted from a method set rather than re-exported.
+				if _, isInterface := spec.Type.(*ast.InterfaceType); !isInterface {
+					symbol.Source = sourceSymbol(spec.Type, pathToAlias[s.importPath])
+				}
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, s := range funcs {
+		funcDecl, ok := s.decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		header := *funcDecl
+		header.Doc = nil
+		header.Body = nil
+		symbols = append(symbols, ManifestSymbol{
+			Name:          s.name,
+			Kind:          "func",
+			Signature:     b.printNode(&header),
+			SourcePackage: s.importPath,
+			Source:        sourceSymbol(funcDecl.Body, pathToAlias[s.importPath]),
+		})
+	}
+	return symbols
+}
+
+// renameTableLines returns the lines of the rename table of symbols: `alias.Source -> Name` for
+// each symbol whose name differs from that of its source symbol, sorted by source and with the
+// arrows aligned.
+func renameTableLines(symbols []ManifestSymbol, pathToAlias map[string]string) []string {
+	type rename struct{ source, name string }
+	var renames []rename
+	width := 0
+	for _, symbol := range symbols {
+		if symbol.Source == "" || symbol.Source == symbol.Name {
+			continue
+		}
+		source := pathToAlias[symbol.SourcePackage] + "." + symbol.Source
+		renames = append(renames, rename{source: source, name: symbol.Name})
+		width = max(width, len(source))
+	}
+	sort.SliceStable(renames, func(i, j int) bool { return renames[i].source < renames[j].source })
+	lines := make([]string, len(renames))
+	for i, r := range renames {
+		lines[i] = fmt.Sprintf("%-*s -> %s", width, r.source, r.name)
+	}
+	return lines
+}
+
+// printNode prints node on a single line.
+func (b *Builder) printNode(node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, b.fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// sourceSymbol returns the name of the first symbol of the package imported as alias that node
+// refers to, such as Config for "source.Config", or an empty string if there is none.
+func sourceSymbol(node ast.Node, alias string) string {
+	if node == nil || alias == "" {
+		return ""
+	}
+	var name string
+	ast.Inspect(node, func(n ast.Node) bool {
+		if name != "" {
+			return false
+		}
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == alias {
+				name = sel.Sel.Name
+				return false
+			}
+		}
+		return true
+	})
+	return name
+}
@@ -1,7 +1,48 @@
 package generator
 
+import (
+	"time"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
 // PackageInfo holds the minimal package information needed by the generator.
 type PackageInfo struct {
 	ImportPath  string // The import path of the package
 	ImportAlias string // The alias for the package import
+	ForceAlias  string // The exact alias for the package import, which other aliases give way to
+	Version     string // The version of the source, detected from its module if empty
+	Registry    string // The name of a generated map from type names to reflect.Type, if set
+	// Instantiations are the concrete instantiations of the package's generic types to re-export as aliases,
+	// and of its generic functions to re-export as wrappers
+	Instantiations []*interfaces.CompiledInstantiation
+}
+
+// SkippedFunction describes an exported function that could not be re-exported.
+type SkippedFunction struct {
+	ImportPath string // The import path of the package declaring the function
+	Name       string // The name of the function
+	Type       string // The offending type, qualified by its package path
+}
+
+// Timings holds the durations of the phases of a generation.
+type Timings struct {
+	Collect time.Duration // Loading the source packages and collecting their declarations
+	Format  time.Duration // Building the output, printing it and formatting it
+}
+
+// Stats counts the declarations of a generated file by kind.
+type Stats struct {
+	Constants int // The number of constants
+	Variables int // The number of variables
+	Types     int // The number of types
+	Functions int // The number of functions, including accessors
+}
+
+// Add adds the counts of other to s.
+func (s *Stats) Add(other Stats) {
+	s.Constants += other.Constants
+	s.Variables += other.Variables
+	s.Types += other.Types
+	s.Functions += other.Functions
 }
@@ -0,0 +1,55 @@
+package generator
+
+import (
+	"go/ast"
+	"go/types"
+	"log/slog"
+)
+
+// nilVarPrefix is prepended to the name of an interface to name its typed nil variable, e.g.
+// NilReader for Reader.
+const nilVarPrefix = "Nil"
+
+// buildNilVars builds a typed nil variable, such as `var NilReader Reader`, for each generated
+// interface in typeSpecs, to stand for an unset dependency when setting up mocks. Generic
+// interfaces and constraints, which cannot be the type of a variable, get none. A variable
+// whose name is taken by another symbol in taken is skipped with a warning.
+func (b *Builder) buildNilVars(typeSpecs []sortedSpec, c *Collector, taken map[string]bool) []sortedSpec {
+	var specs []sortedSpec
+	for _, s := range typeSpecs {
+		spec, ok := s.spec.(*ast.TypeSpec)
+		if !ok || spec.TypeParams != nil || !c.isMethodSetInterface(spec, s.importPath) {
+			continue
+		}
+		name := nilVarPrefix + s.name
+		if taken[name] {
+			slog.Warn("Typed nil variable name is taken by a generated symbol, skipping it", "name", name, "interface", s.name)
+			continue
+		}
+		if b.baseline[name] {
+			continue
+		}
+		taken[name] = true
+		specs = append(specs, sortedSpec{
+			spec:       &ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent(name)}, Type: ast.NewIdent(s.name)},
+			importPath: s.importPath,
+			name:       name,
+		})
+	}
+	return specs
+}
+
+// isMethodSetInterface reports whether spec, generated from the package at importPath, declares
+// an interface that is a method set, i.e. not a constraint with type terms.
+func (c *Collector) isMethodSetInterface(spec *ast.TypeSpec, importPath string) bool {
+	// An interface literal is extracted from a method set.
+	if _, ok := spec.Type.(*ast.InterfaceType); ok {
+		return true
+	}
+	typeName, ok := c.sourceObject(importPath, sourceSymbol(spec.Type, c.pathToAlias[importPath])).(*types.TypeName)
+	if !ok {
+		return false
+	}
+	iface, ok := typeName.Type().Underlying().(*types.Interface)
+	return ok && iface.IsMethodSet()
+}
@@ -0,0 +1,153 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// RegisterFunc names the function that an init function of the generated file calls with the
+// name and zero value of each generated type, such as `codec.Register("Client", Client{})`, to
+// register the types with a codec or plugin framework without reflection. The function must
+// accept a string and the value, e.g. func(string, any).
+type RegisterFunc struct {
+	// ImportPath is the import path of the package declaring the function, or empty for a
+	// function of the adapter package.
+	ImportPath string
+	// Name is the name of the function.
+	Name string
+}
+
+// ParseRegisterFunc parses a register function given as <import path>.<name>, such as
+// "example.com/codec.Register", or as the name of a function of the adapter package. An
+// empty string gives the zero RegisterFunc, which registers nothing.
+func ParseRegisterFunc(s string) (RegisterFunc, error) {
+	if s == "" {
+		return RegisterFunc{}, nil
+	}
+	var fn RegisterFunc
+	if i := strings.LastIndex(s, "."); i > strings.LastIndex(s, "/") {
+		fn = RegisterFunc{ImportPath: s[:i], Name: s[i+1:]}
+	} else {
+		fn = RegisterFunc{Name: s}
+	}
+	if !token.IsIdentifier(fn.Name) {
+		return RegisterFunc{}, fmt.Errorf("register function %q: %q is not a valid identifier", s, fn.Name)
+	}
+	if fn.ImportPath != "" {
+		if err := module.CheckImportPath(fn.ImportPath); err != nil {
+			return RegisterFunc{}, fmt.Errorf("register function %q: %w", s, err)
+		}
+		if !token.IsExported(fn.Name) {
+			return RegisterFunc{}, fmt.Errorf("register function %q: %s is not exported", s, fn.Name)
+		}
+	}
+	return fn, nil
+}
+
+// buildRegisterInit builds an init function calling the register function with the name and
+// zero value of each generated type in typeSpecs, and the import of the package of the function,
+// if it is not the adapter package. Generic types, which have no value until instantiated, and
+// interfaces, whose zero value is nil, are not registered. It returns a nil decl if no type is.
+func (b *Builder) buildRegisterInit(typeSpecs []sortedSpec, c *Collector) (decl *ast.FuncDecl, importKey string, importSpec *ast.ImportSpec) {
+	var args []string
+	for _, s := range typeSpecs {
+		spec, ok := s.spec.(*ast.TypeSpec)
+		if !ok || spec.TypeParams != nil {
+			continue
+		}
+		value, ok := c.zeroValue(spec, s.importPath)
+		if !ok {
+			slog.Debug("Not registering type without a zero value", "func", "Builder.buildRegisterInit", "type", s.name)
+			continue
+		}
+		args = append(args, fmt.Sprintf("%q, %s", s.name, value))
+	}
+	if len(args) == 0 {
+		return nil, "", nil
+	}
+
+	fn := b.registerFunc.Name
+	if b.registerFunc.ImportPath != "" {
+		var alias string
+		alias, importKey, importSpec = c.registerImport(b.registerFunc.ImportPath)
+		fn = alias + "." + fn
+	}
+
+	var src strings.Builder
+	src.WriteString("package p\n\nfunc init() {\n")
+	for _, arg := range args {
+		fmt.Fprintf(&src, "\t%s(%s)\n", fn, arg)
+	}
+	src.WriteString("}\n")
+	// The function is parsed rather than built, so that its positions put one call per line.
+	file, err := parser.ParseFile(b.fset, "", src.String(), 0)
+	if err != nil {
+		slog.Error("Failed to build init function registering types", "function", fn, "error", err)
+		return nil, "", nil
+	}
+	return file.Decls[0].(*ast.FuncDecl), importKey, importSpec
+}
+
+// zeroValue returns the expression of the zero value of the type spec declares, generated from
+// the package at importPath: a composite literal such as Client{} for structs, arrays, slices
+// and maps, or *new(T) for other types. ok is false for an interface.
+func (c *Collector) zeroValue(spec *ast.TypeSpec, importPath string) (value string, ok bool) {
+	switch spec.Type.(type) {
+	case *ast.StructType:
+		return spec.Name.Name + "{}", true
+	case *ast.InterfaceType:
+		return "", false
+	}
+	typeName, ok := c.sourceObject(importPath, sourceSymbol(spec.Type, c.pathToAlias[importPath])).(*types.TypeName)
+	if !ok {
+		return "*new(" + spec.Name.Name + ")", true
+	}
+	switch typeName.Type().Underlying().(type) {
+	case *types.Interface:
+		return "", false
+	case *types.Struct, *types.Array, *types.Slice, *types.Map:
+		return spec.Name.Name + "{}", true
+	default:
+		return "*new(" + spec.Name.Name + ")", true
+	}
+}
+
+// registerImport returns the name the generated file refers to the package at importPath by.
+// If the package is already imported, its alias or last element is used and spec is nil.
+// Otherwise spec imports it under a name derived from its last element that no other import
+// uses, and key is the key of spec in the import specs.
+func (c *Collector) registerImport(importPath string) (alias, key string, spec *ast.ImportSpec) {
+	if existing, ok := c.importSpecs[importPath]; ok {
+		if existing.Name != nil {
+			return existing.Name.Name, "", nil
+		}
+		if base := path.Base(importPath); token.IsIdentifier(base) {
+			return base, "", nil
+		}
+	}
+	aliasMgr := newAliasManager()
+	for key, spec := range c.importSpecs {
+		if spec.Name != nil {
+			aliasMgr.usedAliases[spec.Name.Name] = key
+		} else {
+			aliasMgr.usedAliases[path.Base(key)] = key
+		}
+	}
+	// A package imported without a name that is not its last element, such as gopkg.in/yaml.v3,
+	// is imported again under the derived one, whose key follows that of renamed imports.
+	alias = aliasMgr.generateAlias(importPath, path.Base(importPath))
+	spec = &ast.ImportSpec{Name: ast.NewIdent(alias), Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(importPath)}}
+	if _, ok := c.importSpecs[importPath]; ok {
+		return alias, alias + " " + importPath, spec
+	}
+	return alias, importPath, spec
+}
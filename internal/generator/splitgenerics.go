@@ -0,0 +1,116 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// genericsFallbackPath returns the path of the file generated without generics next to
+// outputFilePath, such as source.adapter_nogenerics.go for source.adapter.go.
+func genericsFallbackPath(outputFilePath string) string {
+	return strings.TrimSuffix(outputFilePath, ".go") + "_nogenerics.go"
+}
+
+// usesGenerics reports whether node declares type parameters or instantiates a generic type or
+// function, and so needs Go 1.18 or later.
+func usesGenerics(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IndexExpr, *ast.IndexListExpr:
+			found = true
+		case *ast.TypeSpec:
+			found = found || n.TypeParams != nil
+		case *ast.FuncType:
+			found = found || n.TypeParams != nil
+		}
+		return !found
+	})
+	return found
+}
+
+// writeSplitGenerics writes the output file for Go 1.18 and later, and a fallback file without
+// the declarations using generics for older toolchains. Without such declarations, the output
+// file is written without a build constraint and a stale fallback file is removed.
+func (b *Builder) writeSplitGenerics() error {
+	fallbackPath := genericsFallbackPath(b.outputFilePath)
+	decls, headings, split := b.nonGenericDecls()
+	if !split {
+		if err := os.Remove(fallbackPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale fallback file: %w", err)
+		}
+		return b.writeToFile()
+	}
+
+	b.buildConstraint = "go1.18"
+	defer func() { b.buildConstraint = "" }()
+	if err := b.writeToFile(); err != nil {
+		return err
+	}
+
+	// The fallback file is written by the same code, with the filtered declarations.
+	outputFilePath, allDecls, allHeadings := b.outputFilePath, b.aliasFile.Decls, b.packageHeadings
+	defer func() {
+		b.outputFilePath, b.aliasFile.Decls, b.packageHeadings = outputFilePath, allDecls, allHeadings
+	}()
+	b.outputFilePath, b.aliasFile.Decls, b.packageHeadings = fallbackPath, decls, headings
+	b.buildConstraint = "!go1.18"
+	return b.writeToFile()
+}
+
+// nonGenericDecls returns the declarations of the output file, and their package headings, with
+// the specs and functions using generics left out. split reports whether any were left out.
+func (b *Builder) nonGenericDecls() (decls []ast.Decl, headings map[ast.Decl]string, split bool) {
+	headings = make(map[ast.Decl]string)
+	// A heading whose declaration is left out moves to the next declaration kept.
+	pendingHeading := ""
+	keep := func(decl, original ast.Decl) {
+		if heading, ok := b.packageHeadings[original]; ok {
+			headings[decl] = heading
+		} else if pendingHeading != "" {
+			headings[decl] = pendingHeading
+		}
+		pendingHeading = ""
+		decls = append(decls, decl)
+	}
+	drop := func(original ast.Decl) {
+		if heading, ok := b.packageHeadings[original]; ok {
+			pendingHeading = heading
+		}
+		split = true
+	}
+
+	for _, decl := range b.aliasFile.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			var specs []ast.Spec
+			for _, spec := range d.Specs {
+				if usesGenerics(spec) {
+					split = true
+				} else {
+					specs = append(specs, spec)
+				}
+			}
+			if len(specs) == 0 {
+				drop(decl)
+				continue
+			}
+			filtered := *d
+			filtered.Specs = specs
+			keep(&filtered, decl)
+		case *ast.FuncDecl:
+			if usesGenerics(d) {
+				drop(decl)
+				continue
+			}
+			keep(decl, decl)
+		default:
+			keep(decl, decl)
+		}
+	}
+	return decls, headings, split
+}
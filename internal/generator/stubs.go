@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log/slog"
+	"strconv"
+)
+
+// collectMethodStubs adds, to each type of typesPkg re-exported as a struct embedding the source
+// type, a method panicking with "not implemented" for each of its disabled methods. The stub
+// shadows the promoted method, so the type keeps its method set and still satisfies the same
+// interfaces while the method is not forwarded.
+func (c *Collector) collectMethodStubs(typesPkg *types.Package, importPath, importAlias string) {
+	pkgDecls := c.allPackageDecls[importPath]
+	if pkgDecls == nil {
+		return
+	}
+	for typeName, spec := range pkgDecls.embedSpecs {
+		methods := append(append([]string(nil), c.disabledMethods[""][typeName]...), c.disabledMethods[c.configPath(importPath)][typeName]...)
+		if len(methods) == 0 {
+			continue
+		}
+		obj := typesPkg.Scope().Lookup(typeName)
+		if obj == nil {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		methodSet := types.NewMethodSet(types.NewPointer(named))
+		stubbed := make(map[string]bool)
+		for _, name := range methods {
+			if stubbed[name] {
+				continue
+			}
+			stubbed[name] = true
+			sel := methodSet.Lookup(typesPkg, name)
+			if sel == nil {
+				slog.Warn("Cannot stub a disabled method the type does not have", "type", typeName, "method", name, "package", importPath)
+				continue
+			}
+			stub, ok := c.methodStub(sel.Obj().(*types.Func), spec, typesPkg, importAlias, pkgDecls)
+			if !ok {
+				slog.Warn("Cannot stub a disabled method using unexported or internal types", "type", typeName, "method", name, "package", importPath)
+				continue
+			}
+			pkgDecls.embedMethods = append(pkgDecls.embedMethods, stub)
+		}
+	}
+}
+
+// methodStub returns a method of the type declared by spec with the signature of fn and a body
+// panicking with "not implemented". Like fn, it has a pointer receiver or a value receiver.
+func (c *Collector) methodStub(fn *types.Func, spec *ast.TypeSpec, typesPkg *types.Package, importAlias string, pkgDecls *packageDecls) (*ast.FuncDecl, bool) {
+	stub, imports, ok := funcDeclFromTypes(fn, typesPkg, importAlias, c.importerPath)
+	if !ok {
+		return nil, false
+	}
+	c.addImports(imports)
+
+	_, isPointer := fn.Type().(*types.Signature).Recv().Type().(*types.Pointer)
+	stub.Recv = embedReceiver(spec, pkgDecls, nil, isPointer)
+	stub.Body = &ast.BlockStmt{List: []ast.Stmt{&ast.ExprStmt{X: &ast.CallExpr{
+		Fun:  ast.NewIdent("panic"),
+		Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("not implemented")}},
+	}}}}
+	return stub, true
+}
+
+// embedReceiver returns the receiver, named name if it is not nil, of a method of the type
+// declared by spec. The receiver names the type by its final name, which the builder resolves.
+func embedReceiver(spec *ast.TypeSpec, pkgDecls *packageDecls, name *ast.Ident, pointer bool) *ast.FieldList {
+	ref := ast.NewIdent(spec.Name.Name)
+	if pkgDecls.localTypeRefs == nil {
+		pkgDecls.localTypeRefs = make(map[*ast.Ident]*ast.Ident)
+	}
+	pkgDecls.localTypeRefs[ref] = spec.Name
+	var recvType ast.Expr = ref
+	if pointer {
+		recvType = &ast.StarExpr{X: ref}
+	}
+	field := &ast.Field{Type: recvType}
+	if name != nil {
+		field.Names = []*ast.Ident{name}
+	}
+	return &ast.FieldList{List: []*ast.Field{field}}
+}
+
+// receiverTypeName returns the name of the receiver type of a method of an embedding type.
+func receiverTypeName(method *ast.FuncDecl) string {
+	recvType := method.Recv.List[0].Type
+	if star, ok := recvType.(*ast.StarExpr); ok {
+		recvType = star.X
+	}
+	return recvType.(*ast.Ident).Name
+}
@@ -37,18 +37,23 @@ func isBuiltinType(name string) bool {
 	return builtinTypes[name]
 }
 
+// qualifyConstraint qualifies the constraint of a type parameter like qualifyType. An omitted
+// constraint becomes any, since go/printer would print the parameter as [T], which does not parse.
+func qualifyConstraint(constraint ast.Expr, pkgAlias string, typeParams map[string]bool) ast.Expr {
+	if constraint == nil {
+		return ast.NewIdent("any")
+	}
+	return qualifyType(constraint, pkgAlias, typeParams)
+}
+
 // qualifyType recursively qualifies types with the given package alias.
 // It ensures that references to types from the source package use the correct alias.
-func qualifyType(expr ast.Expr, pkgAlias string, definedTypes map[string]bool, typeParams map[string]bool) ast.Expr {
+func qualifyType(expr ast.Expr, pkgAlias string, typeParams map[string]bool) ast.Expr {
 	switch t := expr.(type) {
 	case *ast.Ident:
 		if typeParams != nil && typeParams[t.Name] {
 			return t // It's a generic type parameter, don't qualify.
 		}
-		if definedTypes != nil && definedTypes[t.Name] {
-			slog.Debug("Using local type", "func", "qualifyType", "type", t.Name)
-			return t
-		}
 
 		if isBuiltinType(t.Name) {
 			slog.Debug("Using built-in type", "func", "qualifyType", "type", t.Name)
@@ -63,25 +68,25 @@ func qualifyType(expr ast.Expr, pkgAlias string, definedTypes map[string]bool, t
 	case *ast.StarExpr:
 		slog.Debug("Processing pointer type", "func", "qualifyType")
 		return &ast.StarExpr{
-			X: qualifyType(t.X, pkgAlias, definedTypes, typeParams),
+			X: qualifyType(t.X, pkgAlias, typeParams),
 		}
 	case *ast.ArrayType:
 		slog.Debug("Processing array type", "func", "qualifyType")
 		return &ast.ArrayType{
 			Len: t.Len, // Array length is an expression, should not be qualified in this context
-			Elt: qualifyType(t.Elt, pkgAlias, definedTypes, typeParams),
+			Elt: qualifyType(t.Elt, pkgAlias, typeParams),
 		}
 	case *ast.MapType:
 		slog.Debug("Processing map type", "func", "qualifyType")
 		return &ast.MapType{
-			Key:   qualifyType(t.Key, pkgAlias, definedTypes, typeParams),
-			Value: qualifyType(t.Value, pkgAlias, definedTypes, typeParams),
+			Key:   qualifyType(t.Key, pkgAlias, typeParams),
+			Value: qualifyType(t.Value, pkgAlias, typeParams),
 		}
 	case *ast.ChanType:
 		slog.Debug("Processing channel type", "func", "qualifyType")
 		return &ast.ChanType{
 			Dir:   t.Dir,
-			Value: qualifyType(t.Value, pkgAlias, definedTypes, typeParams),
+			Value: qualifyType(t.Value, pkgAlias, typeParams),
 		}
 	case *ast.FuncType:
 		slog.Debug("Processing function type", "func", "qualifyType")
@@ -101,35 +106,46 @@ func qualifyType(expr ast.Expr, pkgAlias string, definedTypes map[string]bool, t
 
 		if t.TypeParams != nil {
 			for _, field := range t.TypeParams.List {
-				field.Type = qualifyType(field.Type, pkgAlias, definedTypes, newTypeParams)
+				field.Type = qualifyConstraint(field.Type, pkgAlias, newTypeParams)
 			}
 		}
 		if t.Params != nil {
 			for _, field := range t.Params.List {
-				field.Type = qualifyType(field.Type, pkgAlias, definedTypes, newTypeParams)
+				field.Type = qualifyType(field.Type, pkgAlias, newTypeParams)
 			}
 		}
 		if t.Results != nil {
 			for _, field := range t.Results.List {
-				field.Type = qualifyType(field.Type, pkgAlias, definedTypes, newTypeParams)
+				field.Type = qualifyType(field.Type, pkgAlias, newTypeParams)
 			}
 		}
 		return t
 	case *ast.IndexExpr:
 		slog.Debug("Processing index expression", "func", "qualifyType")
-		t.X = qualifyType(t.X, pkgAlias, definedTypes, typeParams)
-		t.Index = qualifyType(t.Index, pkgAlias, definedTypes, typeParams)
+		t.X = qualifyType(t.X, pkgAlias, typeParams)
+		t.Index = qualifyType(t.Index, pkgAlias, typeParams)
 		return t
 	case *ast.IndexListExpr:
 		slog.Debug("Processing index list expression", "func", "qualifyType")
-		t.X = qualifyType(t.X, pkgAlias, definedTypes, typeParams)
+		t.X = qualifyType(t.X, pkgAlias, typeParams)
 		for i, index := range t.Indices {
-			t.Indices[i] = qualifyType(index, pkgAlias, definedTypes, typeParams)
+			t.Indices[i] = qualifyType(index, pkgAlias, typeParams)
 		}
 		return t
+	case *ast.UnaryExpr:
+		// A ~T term of a constraint.
+		slog.Debug("Processing constraint term", "func", "qualifyType")
+		t.X = qualifyType(t.X, pkgAlias, typeParams)
+		return t
+	case *ast.BinaryExpr:
+		// A union of constraint terms, e.g. ~int | Number.
+		slog.Debug("Processing constraint union", "func", "qualifyType")
+		t.X = qualifyType(t.X, pkgAlias, typeParams)
+		t.Y = qualifyType(t.Y, pkgAlias, typeParams)
+		return t
 	case *ast.Ellipsis:
 		slog.Debug("Processing ellipsis type", "func", "qualifyType")
-		t.Elt = qualifyType(t.Elt, pkgAlias, definedTypes, typeParams)
+		t.Elt = qualifyType(t.Elt, pkgAlias, typeParams)
 		return t
 	case *ast.InterfaceType, *ast.StructType, *ast.SelectorExpr:
 		return t // These types (and selectors) are already context-complete.
@@ -147,15 +163,17 @@ func getIdentName(expr ast.Expr) string {
 	return ""
 }
 
-// containsInvalidTypes checks if a function signature contains unexported or internal types.
-func containsInvalidTypes(info *types.Info, currentPkg *types.Package, f *ast.FuncType) bool {
+// findInvalidType returns the first unexported or internal type used in a function signature,
+// or nil if the signature only uses types that the generated package at importerPath can refer
+// to. Internal types are only valid if importerPath may import their packages.
+func findInvalidType(info *types.Info, importerPath string, f *ast.FuncType) *types.TypeName {
 	if f == nil {
-		return false
+		return nil
 	}
-	var isInvalid bool
+	var invalid *types.TypeName
 
 	ast.Inspect(f, func(n ast.Node) bool {
-		if isInvalid {
+		if invalid != nil {
 			return false // Stop walking if an invalid type has been found
 		}
 		ident, ok := n.(*ast.Ident)
@@ -169,22 +187,42 @@ func containsInvalidTypes(info *types.Info, currentPkg *types.Package, f *ast.Fu
 			if tn, ok := obj.(*types.TypeName); ok && tn.Pkg() != nil {
 				// This is a named type from an importable package.
 
-				// Rule 1: Check for internal packages.
-				if strings.Contains(tn.Pkg().Path(), "/internal/") || strings.HasSuffix(tn.Pkg().Path(), "/internal") {
+				// Rule 1: Check for internal packages the generated package cannot import.
+				if !canImportInternal(tn.Pkg().Path(), importerPath) {
 					slog.Debug("Skipping function because it uses an internal type", "type", tn.Name(), "package", tn.Pkg().Path())
-					isInvalid = true
+					invalid = tn
 					return false // Stop walking
 				}
 
 				// Rule 2: Check for unexported types.
 				if !tn.Exported() {
 					slog.Debug("Skipping function because it uses an unexported type", "type", tn.Name(), "package", tn.Pkg().Path())
-					isInvalid = true
+					invalid = tn
 					return false // Stop walking
 				}
 			}
 		}
 		return true // Continue walking
 	})
-	return isInvalid
+	return invalid
+}
+
+// canImportInternal reports whether the package at importerPath may import the package at
+// pkgPath as far as internal packages are concerned: pkgPath has no "internal" element, or
+// importerPath is within the tree rooted at the parent of its last one, as the go command
+// requires. No internal package can be imported if importerPath is empty, i.e. unknown.
+func canImportInternal(pkgPath, importerPath string) bool {
+	var parent string
+	switch {
+	case strings.HasSuffix(pkgPath, "/internal"):
+		parent = strings.TrimSuffix(pkgPath, "/internal")
+	case strings.Contains(pkgPath, "/internal/"):
+		parent = pkgPath[:strings.LastIndex(pkgPath, "/internal/")]
+	case pkgPath == "internal" || strings.HasPrefix(pkgPath, "internal/"):
+		// An internal package of the standard library.
+		return false
+	default:
+		return true
+	}
+	return importerPath != "" && (importerPath == parent || strings.HasPrefix(importerPath, parent+"/"))
 }
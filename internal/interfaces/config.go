@@ -2,31 +2,63 @@ package interfaces
 
 import (
 	"regexp"
+	"text/template"
 )
 
 // CompiledPackage holds the compiled information for a single source package.
 type CompiledPackage struct {
-	ImportPath  string
-	ImportAlias string
-	Types       interface{} // Types defined in this package
-	Functions   interface{} // Functions defined in this package
-	Variables   interface{} // Variables defined in this package
-	Constants   interface{} // Constants defined in this package
+	ImportPath     string
+	ImportAlias    string
+	Registry       string                   // Name of the generated map of the package's types, if set
+	Instantiations []*CompiledInstantiation // Concrete instantiations of generic types to re-export
+	Types          interface{}              // Types defined in this package
+	Functions      interface{}              // Functions defined in this package
+	Variables      interface{}              // Variables defined in this package
+	Constants      interface{}              // Constants defined in this package
+}
+
+// CompiledInstantiation names a generic type, the type arguments to instantiate it with,
+// and the name of the alias generated for the instance.
+type CompiledInstantiation struct {
+	Type string
+	Args []string
+	As   string
+	Func string // Generic function to wrap instead of Type, in a function named As
 }
 
 // CompiledRenameRule represents a fully compiled and ready-to-apply renaming rule.
 type CompiledRenameRule struct {
-	Type          string         // e.g., "prefix", "suffix", "explicit", "regex"
-	RuleType      RuleType       // The category of the rule (const, var, func, type)
-	OriginalName  string         // The original name from the config rule (e.g., "*", "Worker")
-	Value         string         // For prefix/suffix
-	From          string         // For explicit
-	To            string         // For explicit
+	Type          string              // e.g., "prefix", "suffix", "suffix_template", "explicit", "regex", "transform", "truncate"
+	RuleType      RuleType            // The category of the rule (const, var, func, type)
+	OriginalName  string              // The original name from the config rule (e.g., "*", "Worker")
+	Value         string              // For prefix/suffix, and the template source for suffix_template
+	Template      *template.Template  // Pre-parsed template for "suffix_template" type rules
+	Separator     string              // For prefix/suffix/suffix_template: inserted between the affix and the name
+	From          string              // For explicit
+	To            string              // For explicit
+	Pattern       string              // Original regex pattern string
+	Replace       string              // Replacement string for regex
+	CompiledRegex *regexp.Regexp      // Pre-compiled regex for "regex" type rules
+	Qualified     bool                // For regex: match against "pkg.Name" instead of the bare name
+	Transforms    []CompiledTransform // For transform: the ordered stages of the pipeline
+	MaxLength     int                 // For truncate: the maximum length of the name
+	Owner         string              // For method and field rules: the type the member belongs to ("*" for every type)
+	Priority      int                 // Priority of the rule
+	IsWildcard    bool                // Indicates if the rule applies to all packages (wildcard)
+	ID            int                 // Identifies the rule within its compiled configuration, starting at 1
+}
+
+// CompiledTransform is a single, pre-compiled stage of a transform pipeline.
+type CompiledTransform struct {
 	Pattern       string         // Original regex pattern string
-	Replace       string         // Replacement string for regex
-	CompiledRegex *regexp.Regexp // Pre-compiled regex for "regex" type rules
-	Priority      int            // Priority of the rule
-	IsWildcard    bool           // Indicates if the rule applies to all packages (wildcard)
+	Replace       string         // Replacement string for the pattern
+	CompiledRegex *regexp.Regexp // Pre-compiled pattern
+}
+
+// CompiledIgnore is a single, pre-compiled entry of an ignores list.
+type CompiledIgnore struct {
+	Pattern       string         // Original entry: a literal name, a glob, or a regex without its "re:" prefix
+	CompiledRegex *regexp.Regexp // Pre-compiled regex for "re:" entries (nil for literals and globs)
 }
 
 // CompiledConfig holds all the compiled information needed for generation.
@@ -39,4 +71,54 @@ type CompiledConfig struct {
 	// Inner map key: RuleType (e.g., RuleTypeType, RuleTypeFunc).
 	// Value: A slice of CompiledRenameRule, sorted by Priority.
 	RulesByPackageAndType map[string]map[RuleType][]CompiledRenameRule
+
+	// ReplacedGlobalRules lists, by import path, the kinds of rules ("prefix", "suffix", "explicit"
+	// or "regex") for which the rules of the package replace the global rules instead of merging with them.
+	ReplacedGlobalRules map[string]map[string]bool
+
+	// IgnoresByPackageAndType stores the compiled ignores, keyed like RulesByPackageAndType.
+	// Symbols whose source name matches one of them are not generated.
+	IgnoresByPackageAndType map[string]map[RuleType][]CompiledIgnore
+
+	// ExtractInterfaces lists the types for which a local interface is generated from their method set.
+	// Outer map key: import path (empty string for global rules).
+	// Inner map key: source type name; value: name of the generated interface.
+	ExtractInterfaces map[string]map[string]string
+
+	// EmbedTypes lists the types re-exported as a local struct embedding the source type.
+	// Outer map key: import path (empty string for global rules).
+	// Inner map key: source type name.
+	EmbedTypes map[string]map[string]bool
+
+	// DisabledMethods lists the methods disabled by the member rules of types re-exported as a
+	// local struct embedding the source type.
+	// Outer map key: import path (empty string for global rules).
+	// Inner map key: source type name.
+	DisabledMethods map[string]map[string][]string
+
+	// DefineTypes lists the types re-exported as a defined type of the source type instead of an alias.
+	// Outer map key: import path (empty string for global rules).
+	// Inner map key: source type name, or "*" for all types; a false value keeps the type an alias.
+	DefineTypes map[string]map[string]bool
+
+	// MaxNameLength is the maximum length of a renamed identifier (0 for no limit).
+	MaxNameLength int
+
+	// OnlyTagged is the doc-comment tag that source symbols must carry to be generated ("" for all).
+	OnlyTagged string
+
+	// SkipDeprecated leaves out source symbols whose doc comment has a "Deprecated:" paragraph.
+	SkipDeprecated bool
+
+	// AllowedImports lists the import path prefixes of the only packages that may be re-exported
+	// (empty for all packages).
+	AllowedImports []string
+
+	// CollisionStrategy is how symbols of different packages given the same name are handled:
+	// "suffix", "error" or "skip" ("" for "suffix").
+	CollisionStrategy string
+
+	// OutputOrder is the order of the sections of the generated file, among "imports", "consts",
+	// "vars", "types" and "funcs" (empty for the default order).
+	OutputOrder []string
 }
@@ -6,6 +6,12 @@ type ContextKey string
 // PackagePathContextKey is the context key for the package path.
 const PackagePathContextKey = ContextKey("packagePath")
 
+// PackageNameContextKey is the context key for the declared name of the source package.
+const PackageNameContextKey = ContextKey("packageName")
+
+// TypeNameContextKey is the context key for the source name of the type whose members are being renamed.
+const TypeNameContextKey = ContextKey("typeName")
+
 // Context defines the interface for passing context across calls.
 // It allows for carrying metadata in a key-value manner and managing a stack of node types.
 type Context interface {
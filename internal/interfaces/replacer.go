@@ -10,4 +10,6 @@ type Replacer interface {
 	Apply(ctx Context, node ast.Node) ast.Node
 }
 
-
+// RenameHook is called by a replacer after a rule renames a symbol, with the symbol's original
+// and final names, its kind and the import path of its package. It cannot change the name.
+type RenameHook func(original, final string, rt RuleType, pkg string)
@@ -295,10 +295,10 @@ func TestLoadAllFieldsConfigFile(t *testing.T) {
 					{
 						Name: "MyField",
 						RuleSet: config.RuleSet{
-							Transforms: &config.Transform{
+							Transforms: config.Transforms{{
 								Before: "(.*)",
 								After:  "New$1",
-							},
+							}},
 						},
 					},
 				},
@@ -17,6 +17,7 @@ type Directive struct {
 	IsJSON  bool     // True if the original command had a ":json" suffix.
 }
 
+// Root returns the directive with its full command split again into base and sub-commands.
 func (d Directive) Root() *Directive {
 	newDirective := d                                    // Copy the original directive
 	cmdParts := strings.Split(newDirective.Command, ":") // Use a local variable for cmdParts
@@ -25,10 +26,13 @@ func (d Directive) Root() *Directive {
 	return &newDirective
 }
 
+// HasSub reports whether the directive has sub-commands, e.g. "type:method" does.
 func (d Directive) HasSub() bool {
 	return len(d.SubCmds) > 0
 }
 
+// Sub returns the directive one level down, with the first sub-command as its base command.
+// It must only be called if HasSub reports true.
 func (d Directive) Sub() *Directive {
 	newDirective := d // Copy the original directive
 	newDirective.BaseCmd = d.SubCmds[0]
@@ -37,6 +41,7 @@ func (d Directive) Sub() *Directive {
 	return &newDirective
 }
 
+// ShouldUnmarshal reports whether the argument is JSON to be unmarshaled into the rule itself.
 func (d Directive) ShouldUnmarshal() bool {
 	return d.IsJSON && len(d.SubCmds) == 0
 }
@@ -7,6 +7,7 @@ import (
 	"strings"
 )
 
+// DirectiveIterator yields the adptool directives of a file in source order.
 type DirectiveIterator iter.Seq[*Directive]
 
 // directiveIterator iterates over comments and extracts adptool directives.
@@ -16,6 +17,18 @@ type directiveIterator struct {
 	index    int
 }
 
+// Directives returns the adptool directives of file in source order, without building a
+// configuration. It is meant for tools that inspect directives, such as a linter for their syntax.
+func Directives(file *goast.File, fset *gotoken.FileSet) iter.Seq[Directive] {
+	return func(yield func(Directive) bool) {
+		for directive := range NewDirectiveIterator(file, fset) {
+			if !yield(*directive) {
+				return
+			}
+		}
+	}
+}
+
 // NewDirectiveIterator creates a new directiveIterator.
 func NewDirectiveIterator(file *goast.File, fset *gotoken.FileSet) DirectiveIterator {
 	var comments []*goast.Comment
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectives(t *testing.T) {
+	filePath := filepath.Join(getModuleRoot(), "testdata", "parser", "directives.go")
+	file, fset, err := loadGoFile(filePath)
+	require.NoError(t, err)
+
+	var got []Directive
+	for directive := range Directives(file, fset) {
+		got = append(got, directive)
+	}
+
+	expected := []Directive{
+		{Line: 4, Command: "package", Argument: "github.com/example/lib lib", BaseCmd: "package", SubCmds: []string{}},
+		{Line: 5, Command: "type", Argument: "Client", BaseCmd: "type", SubCmds: []string{}},
+		{Line: 6, Command: "type:method:rename", Argument: "Close=Shutdown", BaseCmd: "type", SubCmds: []string{"method", "rename"}},
+		{Line: 8, Command: "function", Argument: `{"name":"New"}`, BaseCmd: "function", SubCmds: []string{}, IsJSON: true},
+	}
+	assert.Equal(t, expected, got)
+
+	sub := got[2].Sub()
+	assert.Equal(t, "method", sub.BaseCmd)
+	assert.True(t, sub.HasSub())
+	assert.Equal(t, "rename", sub.Sub().BaseCmd)
+	assert.False(t, sub.Sub().HasSub())
+}
+
+func TestDirectives_StopEarly(t *testing.T) {
+	filePath := filepath.Join(getModuleRoot(), "testdata", "parser", "directives.go")
+	file, fset, err := loadGoFile(filePath)
+	require.NoError(t, err)
+
+	var commands []string
+	for directive := range Directives(file, fset) {
+		commands = append(commands, directive.Command)
+		if len(commands) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"package", "type"}, commands)
+}
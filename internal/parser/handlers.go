@@ -2,7 +2,6 @@ package parser
 
 import (
 	"encoding/json"
-	"strings"
 
 	"github.com/origadmin/adptool/internal/config"
 )
@@ -42,10 +41,10 @@ func handleDefaultDirective(defaults *config.Defaults, directive *Directive) err
 		case "ignores":
 			defaults.Mode.Ignores = subCmd.Argument
 		default:
-			return NewParserErrorWithContext(subCmd, "unrecognized directive '%s' for mode", subCmd.BaseCmd)
+			return NewParserErrorWithContext(subCmd, "unrecognized directive '%s' for mode%s", subCmd.BaseCmd, didYouMean(subCmd.BaseCmd, modeDirectives))
 		}
 	default:
-		return NewParserErrorWithContext(directive, "unrecognized directive '%s' for Defaults", directive.BaseCmd)
+		return NewParserErrorWithContext(directive, "unrecognized directive '%s' for Defaults%s", directive.BaseCmd, didYouMean(directive.BaseCmd, defaultsDirectives))
 	}
 	return nil
 }
@@ -83,7 +82,11 @@ func handleIgnoreDirective(directive *Directive) ([]string, error) {
 		}
 		return ignores, nil
 	}
-	return strings.Split(directive.Argument, " "), nil
+	ignores, err := splitArguments(directive.Argument)
+	if err != nil {
+		return nil, NewParserErrorWithContext(directive, "invalid ignores directive argument: %w", err)
+	}
+	return ignores, nil
 }
 
 //func handlePackageDirective(builder *ConfigBuilder, d *Directive) error {
@@ -1,9 +1,15 @@
 package parser
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
 	goast "go/ast"
 	gotoken "go/token"
+	"io"
 	"log/slog"
+	"os"
+	"strings"
 
 	"github.com/origadmin/adptool/internal/config"
 	"github.com/origadmin/adptool/internal/interfaces"
@@ -12,6 +18,55 @@ import (
 // DirectivePrefix is the prefix used to identify adapter directives in Go source code comments
 const DirectivePrefix = "//go:adapter:"
 
+// DirectiveMarker, alone on a comment, marks a file to be processed with the configuration
+// defaults only, without any directive of its own.
+const DirectiveMarker = "//go:adapter"
+
+// LineHasDirective reports whether a line of Go source is a comment starting with a directive or
+// the bare marker: //go:adapter, after any indentation, followed by a colon, a space or the end of
+// the line. A //go:adapters comment, or prose mentioning //go:adapter, does not count.
+func LineHasDirective(line string) bool {
+	rest, ok := strings.CutPrefix(strings.TrimLeft(line, " \t"), DirectiveMarker)
+	if !ok {
+		return false
+	}
+	return rest == "" || rest[0] == ':' || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r'
+}
+
+// HasDirective reports whether any line read from r has a directive or the bare marker, as
+// checked by LineHasDirective.
+func HasDirective(r io.Reader) (bool, error) {
+	// Lines are read whole, as a generated file may have lines longer than a bufio.Scanner allows.
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if LineHasDirective(strings.TrimSuffix(line, "\n")) {
+			return true, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// FileHasDirective reports whether the file at filePath has a directive or the bare marker. It
+// decides which files are processed, for both the command line and the engine.
+func FileHasDirective(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	defer file.Close()
+	hasDirective, err := HasDirective(file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	return hasDirective, nil
+}
+
 // parser orchestrates the parsing of Go directives into a structured configuration.
 type parser struct {
 	rootConfig     *RootConfig // The root configuration object
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -315,6 +316,31 @@ func TestParseFunctions(t *testing.T) {
 	}
 }
 
+func TestParseCategoryIgnores(t *testing.T) {
+	filePath := filepath.Join(getModuleRoot(), "testdata", "parser", "category_ignores.go")
+	file, fset, err := loadGoFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to load Go file %s: %v", filePath, err)
+	}
+
+	cfg, err := ParseFileDirectives(config.New(), file, fset)
+	if err != nil {
+		t.Fatalf("Failed to parse directives: %v", parseErrorLog(err))
+	}
+
+	assert.Equal(t, []string{"pattern1"}, cfg.Ignores, "Global ignores mismatch")
+	if assert.Len(t, cfg.Types, 2, "Types count mismatch") {
+		assert.Equal(t, "MyType", cfg.Types[1].Name)
+		assert.Empty(t, cfg.Types[1].Ignores, "Scoped ignores must not be added to the active type rule")
+		assert.Equal(t, "*", cfg.Types[0].Name)
+		assert.Equal(t, []string{"Internal*"}, cfg.Types[0].Ignores)
+	}
+	if assert.Len(t, cfg.Functions, 1, "Functions count mismatch") {
+		assert.Equal(t, "*", cfg.Functions[0].Name)
+		assert.Equal(t, []string{"NewFoo", "Must*"}, cfg.Functions[0].Ignores)
+	}
+}
+
 func TestParseAllConfigDirectives(t *testing.T) {
 	filePath := filepath.Join(getModuleRoot(), "testdata", "parser", "all_config.go")
 	file, fset, err := loadGoFile(filePath)
@@ -374,10 +400,10 @@ func TestParseAllConfigDirectives(t *testing.T) {
 					{
 						Name: "MyField",
 						RuleSet: config.RuleSet{
-							Transforms: &config.Transform{
+							Transforms: config.Transforms{{
 								Before: "(.*)",
 								After:  "New$1",
-							},
+							}},
 						},
 					},
 				},
@@ -451,3 +477,71 @@ func parseErrorLog(err error) string {
 	}
 	return err.Error() // Fallback to standard Error() for other errors
 }
+
+func TestParseBareMarker(t *testing.T) {
+	filePath := filepath.Join(getModuleRoot(), "testdata", "parser", "marker.go")
+	file, fset, err := loadGoFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to load Go file %s: %v", filePath, err)
+	}
+
+	initialCfg := config.New()
+	initialCfg.Packages = []*config.Package{{Import: "github.com/example/pkg"}}
+	cfg, err := ParseFileDirectives(initialCfg, file, fset)
+	assert.NoError(t, err, "The bare marker should not be reported as a malformed directive")
+	assert.Equal(t, "parser", cfg.PackageName, "The package name should come from the file")
+	assert.Equal(t, []*config.Package{{Import: "github.com/example/pkg"}}, cfg.Packages)
+}
+
+func TestLineHasDirective(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{line: "//go:adapter", want: true},
+		{line: "//go:adapter\r", want: true},
+		{line: "//go:adapter // processed with the defaults", want: true},
+		{line: "//go:adapter:package github.com/example/pkg", want: true},
+		{line: "\t//go:adapter:type Client", want: true},
+		{line: "//go:adapters", want: false},
+		{line: "//go:adapterx:type Client", want: false},
+		{line: "// go:adapter", want: false},
+		{line: "// Files with a //go:adapter comment are processed.", want: false},
+		{line: "// See //go:adapter:type in the README.", want: false},
+		{line: "var s = \"//go:adapter\"", want: false},
+		{line: "package adapters", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, LineHasDirective(tt.line), "LineHasDirective(%q)", tt.line)
+	}
+}
+
+func TestFileHasDirective(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want bool
+	}{
+		{name: "bare marker", file: "marker.go", want: true},
+		{name: "full directive", file: "defaults.go", want: true},
+		{name: "no directive", file: "file.go", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FileHasDirective(filepath.Join(getModuleRoot(), "testdata", "parser", tt.file))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := FileHasDirective(filepath.Join(getModuleRoot(), "testdata", "parser", "missing.go"))
+	assert.Error(t, err, "A missing file should be reported")
+}
+
+func TestHasDirective_LongLine(t *testing.T) {
+	// A line longer than a bufio.Scanner accepts must not stop the search.
+	src := "package adapters\n\nvar data = \"" + strings.Repeat("x", 1<<17) + "\"\n\n//go:adapter\n"
+	got, err := HasDirective(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.True(t, got)
+}
@@ -6,17 +6,111 @@ import (
 	goast "go/ast"
 	goparser "go/parser"
 	gotoken "go/token"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // parseNameValue parses an argument string into a name and value.
-// Expected format: "name value"
+// Expected format: "name value". Either part may be double-quoted to include spaces.
 func parseNameValue(argument string) (name, value string, err error) {
-	parts := strings.SplitN(argument, " ", 2)
-	if len(parts) != 2 {
+	name, rest, err := cutArgument(argument)
+	if err != nil {
+		return "", "", err
+	}
+	if rest == "" {
 		return "", "", errors.New("argument must be in 'name value' format")
 	}
-	return parts[0], parts[1], nil
+	value, err = unquoteArgument(rest)
+	if err != nil {
+		return "", "", err
+	}
+	return name, value, nil
+}
+
+// splitPair splits an argument of the form "left<sep>right".
+// If the argument starts with a double quote, both sides may be quoted and may be
+// separated by whitespace instead of sep, e.g. `"Old Name" "New Name"`.
+func splitPair(argument, sep string) (left, right string, err error) {
+	if !strings.HasPrefix(argument, `"`) {
+		parts := strings.SplitN(argument, sep, 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("argument must be in 'left%sright' format", sep)
+		}
+		return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+	}
+	left, rest, err := cutArgument(argument)
+	if err != nil {
+		return "", "", err
+	}
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, sep))
+	if rest == "" {
+		return "", "", fmt.Errorf("argument must be in 'left%sright' format", sep)
+	}
+	right, err = unquoteArgument(rest)
+	if err != nil {
+		return "", "", err
+	}
+	return left, right, nil
+}
+
+// splitArguments splits an argument string into whitespace-separated fields.
+// Double-quoted fields may contain spaces and Go escape sequences.
+func splitArguments(argument string) ([]string, error) {
+	var fields []string
+	rest := strings.TrimSpace(argument)
+	for rest != "" {
+		var field string
+		var err error
+		field, rest, err = cutArgument(rest)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// cutArgument returns the first field of argument, unquoted if it is double-quoted,
+// and the remainder with surrounding whitespace removed.
+func cutArgument(argument string) (field, rest string, err error) {
+	argument = strings.TrimLeftFunc(argument, unicode.IsSpace)
+	if !strings.HasPrefix(argument, `"`) {
+		end := strings.IndexFunc(argument, unicode.IsSpace)
+		if end < 0 {
+			return argument, "", nil
+		}
+		return argument[:end], strings.TrimSpace(argument[end:]), nil
+	}
+
+	for i := 1; i < len(argument); i++ {
+		switch argument[i] {
+		case '\\':
+			i++ // Skip the escaped character
+		case '"':
+			field, err = strconv.Unquote(argument[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid quoted argument %s: %w", argument[:i+1], err)
+			}
+			return field, strings.TrimSpace(argument[i+1:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted argument %s", argument)
+}
+
+// unquoteArgument unquotes s if it is a single double-quoted string and returns it unchanged otherwise.
+func unquoteArgument(s string) (string, error) {
+	if len(s) < 2 || !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+	field, rest, err := cutArgument(s)
+	if err != nil {
+		return "", err
+	}
+	if rest != "" {
+		return s, nil
+	}
+	return field, nil
 }
 
 // loadGoFile loads a Go file and returns the AST and file set.
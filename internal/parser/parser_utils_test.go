@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitArguments(t *testing.T) {
+	tests := []struct {
+		name          string
+		argument      string
+		expected      []string
+		errorContains string
+	}{
+		{name: "plain fields", argument: "a b  c", expected: []string{"a", "b", "c"}},
+		{name: "quoted field with spaces", argument: `"Old Name" "New Name"`, expected: []string{"Old Name", "New Name"}},
+		{name: "escaped quote", argument: `"say \"hi\"" x`, expected: []string{`say "hi"`, "x"}},
+		{name: "empty quoted field", argument: `"" x`, expected: []string{"", "x"}},
+		{name: "empty argument", argument: "   ", expected: nil},
+		{name: "unterminated quote", argument: `"Old Name`, errorContains: "unterminated quoted argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitArguments(tt.argument)
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestParseNameValue_Quoted(t *testing.T) {
+	tests := []struct {
+		argument string
+		name     string
+		value    string
+	}{
+		{argument: "key value", name: "key", value: "value"},
+		{argument: "key value with spaces", name: "key", value: "value with spaces"},
+		{argument: `"my key" "my value"`, name: "my key", value: "my value"},
+		{argument: `key "{{.Name}} Adapter"`, name: "key", value: "{{.Name}} Adapter"},
+		{argument: `key "a" "b"`, name: "key", value: `"a" "b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.argument, func(t *testing.T) {
+			name, value, err := parseNameValue(tt.argument)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.name, name)
+			assert.Equal(t, tt.value, value)
+		})
+	}
+}
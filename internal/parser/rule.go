@@ -2,7 +2,6 @@ package parser
 
 import (
 	"encoding/json"
-	"strings"
 
 	"github.com/origadmin/adptool/internal/config"
 	"github.com/origadmin/adptool/internal/interfaces"
@@ -27,8 +26,9 @@ func NewContainerFactory(ruleType interfaces.RuleType) ContainerFactory {
 	}
 }
 
-// parseRuleSetDirective handles directives that apply to a config.RuleSet.
-func parseRuleSetDirective(rs *config.RuleSet, directive *Directive) error {
+// parseRuleSetDirective handles directives that apply to a config.RuleSet of a rule of type rt,
+// which selects the directives suggested in place of an unrecognized one.
+func parseRuleSetDirective(rt interfaces.RuleType, rs *config.RuleSet, directive *Directive) error {
 	if directive.ShouldUnmarshal() { // Handle JSON block for defaults
 		err := json.Unmarshal([]byte(directive.Argument), rs)
 		if err != nil {
@@ -55,35 +55,50 @@ func parseRuleSetDirective(rs *config.RuleSet, directive *Directive) error {
 	case "suffix_mode":
 		rs.SuffixMode = directive.Argument
 		return nil
+	case "suffix_template":
+		rs.SuffixTemplate = directive.Argument
+		return nil
+	case "affix_separator":
+		rs.AffixSeparator = directive.Argument
+		return nil
 	case "explicit":
 		// Explicit rules are key=value pairs, need to parse directive.Argument
 		if directive.Argument == "" {
 			return NewParserErrorWithContext(directive, "explicit directive requires an argument (from=to)")
 		}
-		parts := strings.SplitN(directive.Argument, "=", 2)
-		if len(parts) != 2 {
+		from, to, err := splitPair(directive.Argument, "=")
+		if err != nil {
 			return NewParserErrorWithContext(directive, "invalid explicit directive argument '%s', expected from=to", directive.Argument)
 		}
 		rs.Explicit = append(rs.Explicit, &config.ExplicitRule{
-			From: parts[0],
-			To:   parts[1],
+			From: from,
+			To:   to,
 		})
 		return nil
 	case "explicit_mode":
 		rs.ExplicitMode = directive.Argument
 		return nil
 	case "regex":
-		// Regex rules are pattern=replace pairs
+		// Regex rules are pattern=replace pairs; regex:qualified matches against "pkg.Name"
+		qualified := false
+		if directive.HasSub() {
+			sub := directive.Sub()
+			if sub.BaseCmd != "qualified" {
+				return NewParserErrorWithContext(sub, "unrecognized directive '%s' for regex%s", sub.BaseCmd, didYouMean(sub.BaseCmd, []string{"qualified"}))
+			}
+			qualified = true
+		}
 		if directive.Argument == "" {
 			return NewParserErrorWithContext(directive, "regex directive requires an argument (pattern=replace)")
 		}
-		parts := strings.SplitN(directive.Argument, "=", 2)
-		if len(parts) != 2 {
+		pattern, replace, err := splitPair(directive.Argument, "=")
+		if err != nil {
 			return NewParserErrorWithContext(directive, "invalid regex directive argument '%s', expected pattern=replace", directive.Argument)
 		}
 		rs.Regex = append(rs.Regex, &config.RegexRule{
-			Pattern: parts[0],
-			Replace: parts[1],
+			Pattern:   pattern,
+			Replace:   replace,
+			Qualified: qualified,
 		})
 		return nil
 	case "regex_mode":
@@ -112,35 +127,52 @@ func parseRuleSetDirective(rs *config.RuleSet, directive *Directive) error {
 		if !directive.HasSub() {
 			return NewParserErrorWithContext(directive, "transform directive requires a sub-command")
 		}
-		if rs.Transforms == nil {
-			rs.Transforms = &config.Transform{}
-		}
 		sub := directive.Sub()
 		if sub.ShouldUnmarshal() {
-			err := json.Unmarshal([]byte(directive.Argument), rs.Transforms)
+			var transforms config.Transforms
+			err := json.Unmarshal([]byte(directive.Argument), &transforms)
 			if err != nil {
 				return NewParserErrorWithContext(directive, "failed to unmarshal JSON for RuleSet.Transforms: %w", err)
 			}
+			rs.Transforms = append(rs.Transforms, transforms...)
 			return nil
 		}
 		switch sub.BaseCmd {
 		case "before":
-			rs.Transforms.Before = sub.Argument
+			setTransformBefore(rs, sub.Argument)
 		case "after":
-			rs.Transforms.After = sub.Argument
+			setTransformAfter(rs, sub.Argument)
 		default:
-			return NewParserErrorWithContext(sub, "unrecognized directive '%s' for RuleSet.Transforms", sub.BaseCmd)
+			return NewParserErrorWithContext(sub, "unrecognized directive '%s' for RuleSet.Transforms%s", sub.BaseCmd, didYouMean(sub.BaseCmd, []string{"before", "after"}))
 		}
 		return nil
 	case "transform_before":
 		rs.TransformBefore = directive.Argument
-		rs.Transforms.Before = directive.Argument
+		setTransformBefore(rs, directive.Argument)
 		return nil
 	case "transform_after":
 		rs.TransformAfter = directive.Argument
-		rs.Transforms.After = directive.Argument
+		setTransformAfter(rs, directive.Argument)
 		return nil
 	default:
-		return NewParserErrorWithContext(directive, "unrecognized directive '%s' for RuleSet", directive.BaseCmd)
+		return NewParserErrorWithContext(directive, "unrecognized directive '%s' for RuleSet%s", directive.BaseCmd, didYouMean(directive.BaseCmd, containerDirectives[rt]))
+	}
+}
+
+// setTransformBefore sets the pattern of the last transform step, starting a new step
+// if there is none yet or the last one already has a pattern.
+func setTransformBefore(rs *config.RuleSet, before string) {
+	if n := len(rs.Transforms); n == 0 || rs.Transforms[n-1].Before != "" {
+		rs.Transforms = append(rs.Transforms, &config.Transform{})
+	}
+	rs.Transforms[len(rs.Transforms)-1].Before = before
+}
+
+// setTransformAfter sets the replacement of the last transform step, starting a new step
+// if there is none yet or the last one already has a replacement.
+func setTransformAfter(rs *config.RuleSet, after string) {
+	if n := len(rs.Transforms); n == 0 || rs.Transforms[n-1].After != "" {
+		rs.Transforms = append(rs.Transforms, &config.Transform{})
 	}
+	rs.Transforms[len(rs.Transforms)-1].After = after
 }
@@ -41,7 +41,7 @@ func (r *ConstRule) ParseDirective(directive *Directive) error {
 	}
 
 	// Delegate to the common RuleSet parser
-	return parseRuleSetDirective(&r.RuleSet, directive.Sub())
+	return parseRuleSetDirective(r.Type(), &r.RuleSet, directive.Sub())
 }
 
 func (r *ConstRule) AddTypeRule(rule *TypeRule) error {
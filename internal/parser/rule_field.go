@@ -33,7 +33,7 @@ func (f *FieldRule) ParseDirective(directive *Directive) error {
 	}
 
 	// Delegate to the common RuleSet parser for generic rules
-	return parseRuleSetDirective(&f.RuleSet, subDirective)
+	return parseRuleSetDirective(f.Type(), &f.RuleSet, subDirective)
 }
 
 func (f *FieldRule) AddRule(rule any) error {
@@ -39,7 +39,7 @@ func (r *FuncRule) ParseDirective(directive *Directive) error {
 		return nil
 	default:
 		// Delegate to the common RuleSet parser for generic rules
-		return parseRuleSetDirective(&r.RuleSet, subDirective)
+		return parseRuleSetDirective(r.Type(), &r.RuleSet, subDirective)
 	}
 }
 
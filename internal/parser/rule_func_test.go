@@ -172,6 +172,83 @@ func TestFuncRule_ParseDirective(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "qualified regex directive",
+			directives: []string{
+				"//go:adapter:func:regex ^Get(.*)=Fetch$1",
+				`//go:adapter:func:regex:qualified ^foo\.Get(.*)=Fetch$1`,
+			},
+			expectedRuleSet: &config.RuleSet{
+				Regex: []*config.RegexRule{
+					{Pattern: "^Get(.*)", Replace: "Fetch$1"},
+					{Pattern: `^foo\.Get(.*)`, Replace: "Fetch$1", Qualified: true},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "quoted explicit directive",
+			directives: []string{
+				`//go:adapter:func:explicit "Old Name" "New Name"`,
+				`//go:adapter:func:explicit "a=b"="c d"`,
+				"//go:adapter:func:explicit Plain=Name",
+			},
+			expectedRuleSet: &config.RuleSet{
+				Explicit: []*config.ExplicitRule{
+					{From: "Old Name", To: "New Name"},
+					{From: "a=b", To: "c d"},
+					{From: "Plain", To: "Name"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "quoted regex directive",
+			directives: []string{
+				`//go:adapter:func:regex "^Get (.*)$" "Fetch $1"`,
+			},
+			expectedRuleSet: &config.RuleSet{
+				Regex: []*config.RegexRule{
+					{Pattern: "^Get (.*)$", Replace: "Fetch $1"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "quoted ignores directive",
+			directives: []string{
+				`//go:adapter:func:ignores "with space" plain "escaped \"quote\""`,
+			},
+			expectedRuleSet: &config.RuleSet{
+				Ignores: []string{"with space", "plain", `escaped "quote"`},
+			},
+			expectError: false,
+		},
+		{
+			name: "transform pipeline",
+			directives: []string{
+				"//go:adapter:func:transform:before ^Legacy(.*)$",
+				"//go:adapter:func:transform:after $1",
+				"//go:adapter:func:transform:before ^(.*)$",
+				"//go:adapter:func:transform:after ${1}V2",
+			},
+			expectedRuleSet: &config.RuleSet{
+				Transforms: config.Transforms{
+					{Before: "^Legacy(.*)$", After: "$1"},
+					{Before: "^(.*)$", After: "${1}V2"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "unknown regex sub-command",
+			directives: []string{
+				"//go:adapter:func:regex:fuzzy ^Get(.*)=Fetch$1",
+			},
+			expectedRuleSet: nil,
+			expectError:     true,
+			errorContains:   "unrecognized directive 'fuzzy' for regex",
+		},
 		{
 			name: "invalid directive",
 			directives: []string{
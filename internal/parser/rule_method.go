@@ -38,7 +38,7 @@ func (m *MethodRule) ParseDirective(directive *Directive) error {
 	}
 
 	// Delegate to the common RuleSet parser for generic rules
-	return parseRuleSetDirective(&m.RuleSet, subDirective)
+	return parseRuleSetDirective(m.Type(), &m.RuleSet, subDirective)
 }
 
 func (m *MethodRule) AddRule(rule any) error {
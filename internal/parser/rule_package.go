@@ -2,7 +2,6 @@ package parser
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/origadmin/adptool/internal/config"
 	"github.com/origadmin/adptool/internal/interfaces"
@@ -30,12 +29,17 @@ func (p *PackageRule) ParseDirective(directive *Directive) error {
 		if directive.Argument == "" {
 			return NewParserErrorWithContext(directive, "package directive requires an argument (import path)")
 		}
-		args := strings.SplitN(directive.Argument, " ", 2)
-		if len(args) >= 1 {
-			p.Package.Import = args[0]
+		importPath, alias, err := cutArgument(directive.Argument)
+		if err != nil {
+			return NewParserErrorWithContext(directive, "invalid package directive argument: %w", err)
 		}
-		if len(args) >= 2 {
-			p.Package.Alias = args[1]
+		p.Package.Import = importPath
+		if alias != "" {
+			alias, err = unquoteArgument(alias)
+			if err != nil {
+				return NewParserErrorWithContext(directive, "invalid package directive argument: %w", err)
+			}
+			p.Package.Alias = alias
 		}
 		return nil
 	}
@@ -52,6 +56,24 @@ func (p *PackageRule) ParseDirective(directive *Directive) error {
 	case "path":
 		p.Package.Path = subDirective.Argument
 		return nil
+	case "version":
+		p.Package.Version = subDirective.Argument
+		return nil
+	case "force-alias":
+		p.Package.ForceAlias = subDirective.Argument
+		return nil
+	case "registry":
+		p.Package.Registry = subDirective.Argument
+		return nil
+	case "default":
+		// Handles: //go:adapter:package:default:mode:<rule> <mode>
+		if !subDirective.HasSub() {
+			return NewParserErrorWithContext(subDirective, "default directive requires a sub-directive, e.g. default:mode:explicit")
+		}
+		if p.Package.Defaults == nil {
+			p.Package.Defaults = &config.Defaults{}
+		}
+		return handleDefaultDirective(p.Package.Defaults, subDirective.Sub())
 	case "property":
 		props, err := handlePropDirective(subDirective)
 		if err != nil {
@@ -66,7 +88,7 @@ func (p *PackageRule) ParseDirective(directive *Directive) error {
 	default:
 		// This allows structural directives like 'type' or 'function' to be ignored here
 		// as they are handled by the main parser's recursion.
-		return NewParserErrorWithContext(subDirective, "unrecognized sub-directive: %s", subDirective.BaseCmd)
+		return NewParserErrorWithContext(subDirective, "unrecognized sub-directive: %s%s", subDirective.BaseCmd, didYouMean(subDirective.BaseCmd, containerDirectives[interfaces.RuleTypePackage]))
 	}
 }
 
@@ -191,6 +191,47 @@ func TestPackageRule_ParseDirective(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "single version directive",
+			directives: []string{
+				"//go:adapter:package:version v1.2.3",
+			},
+			expectedPackage: &config.Package{
+				Version: "v1.2.3",
+			},
+			expectError: false,
+		},
+		{
+			name: "package default mode directives",
+			directives: []string{
+				"//go:adapter:package:default:mode:explicit merge",
+				"//go:adapter:package:default:mode:prefix replace",
+			},
+			expectedPackage: &config.Package{
+				Defaults: &config.Defaults{Mode: &config.Mode{Explicit: "merge", Prefix: "replace"}},
+			},
+			expectError: false,
+		},
+		{
+			name: "single force-alias directive",
+			directives: []string{
+				"//go:adapter:package:force-alias customname",
+			},
+			expectedPackage: &config.Package{
+				ForceAlias: "customname",
+			},
+			expectError: false,
+		},
+		{
+			name: "single registry directive",
+			directives: []string{
+				"//go:adapter:package:registry Types",
+			},
+			expectedPackage: &config.Package{
+				Registry: "Types",
+			},
+			expectError: false,
+		},
 		{
 			name: "single alias directive",
 			directives: []string{
@@ -318,6 +359,25 @@ func TestPackageRule_ParseDirective(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "quoted props directive",
+			directives: []string{
+				`//go:adapter:package:property "display name" "My Package"`,
+			},
+			expectedPackage: &config.Package{
+				Props: []*config.PropsEntry{{Name: "display name", Value: "My Package"}},
+			},
+			expectError: false,
+		},
+		{
+			name: "unterminated quote in package directive",
+			directives: []string{
+				`//go:adapter:package "github.com/my/package myalias`,
+			},
+			expectedPackage: nil,
+			expectError:     true,
+			errorContains:   "unterminated quoted argument",
+		},
 	}
 
 	for _, tt := range tests {
@@ -57,6 +57,20 @@ func (r *RootConfig) ParseDirective(directive *Directive) error {
 		}
 		r.Config.Ignores = append(r.Config.Ignores, ignores...)
 		return nil
+	case "type-mode":
+		switch directive.Argument {
+		case "alias", "define":
+			r.Config.TypeMode = directive.Argument
+			return nil
+		default:
+			return NewParserErrorWithContext(directive, "type-mode directive requires \"alias\" or \"define\", got %q", directive.Argument)
+		}
+	case "rename-file":
+		if directive.Argument == "" {
+			return NewParserErrorWithContext(directive, "rename-file directive requires an argument (\"package\" or a file name)")
+		}
+		r.Config.RenameFile = directive.Argument
+		return nil
 	case "property":
 		if directive.Argument == "" {
 			return fmt.Errorf("props directive requires an argument (key value)")
@@ -71,6 +85,12 @@ func (r *RootConfig) ParseDirective(directive *Directive) error {
 	// are handled by the parser's main loop (parseFile) via StartContext,
 	// not by ParseDirective of the current container.
 	case "packages", "types", "functions", "variables", "constants":
+		// The only exception are category-scoped ignores, e.g. //go:adapter:types:ignore Foo.
+		if directive.BaseCmd != "packages" && directive.HasSub() {
+			if sub := directive.Sub(); sub.BaseCmd == "ignore" || sub.BaseCmd == "ignores" {
+				return r.parseCategoryIgnores(directive.BaseCmd, sub)
+			}
+		}
 		return NewParserErrorWithContext(directive, "directive '%s' starts a new scope and should not be parsed by RootConfig.ParseDirective",
 			directive.BaseCmd)
 	//case "package_name":
@@ -80,7 +100,68 @@ func (r *RootConfig) ParseDirective(directive *Directive) error {
 	//	r.Config.PackageName = directive.Argument
 	//	return nil
 	default:
-		return NewParserErrorWithContext(directive, "unrecognized directive '%s' for RootConfig", directive.BaseCmd)
+		return NewParserErrorWithContext(directive, "unrecognized directive '%s' for RootConfig%s", directive.BaseCmd, didYouMean(directive.BaseCmd, containerDirectives[interfaces.RuleTypeRoot]))
+	}
+}
+
+// parseCategoryIgnores adds the patterns of an ignore or ignores directive to the wildcard
+// rule of a category, which is the directive form of e.g. `types: [{name: "*", ignores: [...]}]`.
+func (r *RootConfig) parseCategoryIgnores(category string, directive *Directive) error {
+	if directive.Argument == "" {
+		return NewParserErrorWithContext(directive, "%s:%s directive requires an argument (pattern)", category, directive.BaseCmd)
+	}
+	ignores := []string{directive.Argument}
+	if directive.BaseCmd == "ignores" {
+		var err error
+		ignores, err = handleIgnoreDirective(directive)
+		if err != nil {
+			return NewParserErrorWithContext(directive, "failed to handle %s:ignores directive: %w", category, err)
+		}
+	}
+	rs := r.categoryRuleSet(category)
+	rs.Ignores = append(rs.Ignores, ignores...)
+	return nil
+}
+
+// categoryRuleSet returns the rule set of the wildcard rule of a category, adding the rule if needed.
+func (r *RootConfig) categoryRuleSet(category string) *config.RuleSet {
+	switch category {
+	case "types":
+		for _, rule := range r.Config.Types {
+			if rule.Name == "*" {
+				return &rule.RuleSet
+			}
+		}
+		rule := &config.TypeRule{Name: "*"}
+		r.Config.Types = append(r.Config.Types, rule)
+		return &rule.RuleSet
+	case "functions":
+		for _, rule := range r.Config.Functions {
+			if rule.Name == "*" {
+				return &rule.RuleSet
+			}
+		}
+		rule := &config.FuncRule{Name: "*"}
+		r.Config.Functions = append(r.Config.Functions, rule)
+		return &rule.RuleSet
+	case "variables":
+		for _, rule := range r.Config.Variables {
+			if rule.Name == "*" {
+				return &rule.RuleSet
+			}
+		}
+		rule := &config.VarRule{Name: "*"}
+		r.Config.Variables = append(r.Config.Variables, rule)
+		return &rule.RuleSet
+	default: // "constants"
+		for _, rule := range r.Config.Constants {
+			if rule.Name == "*" {
+				return &rule.RuleSet
+			}
+		}
+		rule := &config.ConstRule{Name: "*"}
+		r.Config.Constants = append(r.Config.Constants, rule)
+		return &rule.RuleSet
 	}
 }
 
@@ -251,6 +251,136 @@ func TestRootConfigParseDirectiveIgnores(t *testing.T) {
 	}
 }
 
+func TestRootConfigParseDirectiveTypeMode(t *testing.T) {
+	tests := []struct {
+		name             string
+		directiveString  string
+		expectedTypeMode string
+		expectError      bool
+		errorContains    string
+	}{
+		{
+			name:             "Define mode",
+			directiveString:  "//go:adapter:type-mode define",
+			expectedTypeMode: "define",
+		},
+		{
+			name:             "Alias mode",
+			directiveString:  "//go:adapter:type-mode alias",
+			expectedTypeMode: "alias",
+		},
+		{
+			name:            "Missing argument",
+			directiveString: "//go:adapter:type-mode",
+			expectError:     true,
+			errorContains:   `type-mode directive requires "alias" or "define", got ""`,
+		},
+		{
+			name:            "Unknown mode",
+			directiveString: "//go:adapter:type-mode copy",
+			expectError:     true,
+			errorContains:   `got "copy"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &RootConfig{Config: config.New()}
+			dir := decodeTestDirective(tt.directiveString)
+			err := rc.ParseDirective(&dir)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedTypeMode, rc.Config.TypeMode)
+			}
+		})
+	}
+}
+
+func TestRootConfigParseDirectiveRenameFile(t *testing.T) {
+	rc := &RootConfig{Config: config.New()}
+	dir := decodeTestDirective("//go:adapter:rename-file package")
+	assert.NoError(t, rc.ParseDirective(&dir))
+	assert.Equal(t, "package", rc.Config.RenameFile)
+
+	dir = decodeTestDirective("//go:adapter:rename-file")
+	err := rc.ParseDirective(&dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "rename-file directive requires an argument")
+	}
+}
+
+func TestRootConfigParseDirectiveCategoryIgnores(t *testing.T) {
+	tests := []struct {
+		name              string
+		directiveStrings  []string
+		expectedTypes     []*config.TypeRule
+		expectedFunctions []*config.FuncRule
+		expectError       bool
+		errorContains     string
+	}{
+		{
+			name:             "Type-scoped ignore",
+			directiveStrings: []string{"//go:adapter:types:ignore Foo"},
+			expectedTypes:    []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"Foo"}}}},
+		},
+		{
+			name: "Function-scoped ignores",
+			directiveStrings: []string{
+				"//go:adapter:functions:ignores NewFoo Must*",
+				"//go:adapter:functions:ignore Deprecated*",
+			},
+			expectedFunctions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"NewFoo", "Must*", "Deprecated*"}}}},
+		},
+		{
+			name:              "Function-scoped ignores with JSON argument",
+			directiveStrings:  []string{`//go:adapter:functions:ignores:json ["NewFoo", "Must*"]`},
+			expectedFunctions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"NewFoo", "Must*"}}}},
+		},
+		{
+			name: "Scoped ignores stay within their category",
+			directiveStrings: []string{
+				"//go:adapter:types:ignores Foo Bar",
+				"//go:adapter:functions:ignore NewFoo",
+			},
+			expectedTypes:     []*config.TypeRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"Foo", "Bar"}}}},
+			expectedFunctions: []*config.FuncRule{{Name: "*", RuleSet: config.RuleSet{Ignores: []string{"NewFoo"}}}},
+		},
+		{
+			name:             "Missing argument",
+			directiveStrings: []string{"//go:adapter:types:ignore"},
+			expectError:      true,
+			errorContains:    "types:ignore directive requires an argument (pattern)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &RootConfig{Config: config.New()}
+			var err error
+			for _, directiveString := range tt.directiveStrings {
+				dir := decodeTestDirective(directiveString)
+				if err = rc.ParseDirective(&dir); err != nil {
+					break
+				}
+			}
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.ElementsMatch(t, tt.expectedTypes, rc.Config.Types)
+				assert.ElementsMatch(t, tt.expectedFunctions, rc.Config.Functions)
+				assert.Empty(t, rc.Config.Ignores, "category-scoped ignores must not be added to the global list")
+			}
+		})
+	}
+}
+
 func TestRootConfigParseDirectiveUnrecognized(t *testing.T) {
 	tests := []struct {
 		name            string
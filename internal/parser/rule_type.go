@@ -34,6 +34,12 @@ func (r *TypeRule) ParseDirective(directive *Directive) error {
 		r.TypeRule.Kind = "struct"
 		r.TypeRule.Pattern = subDirective.Argument
 		return nil
+	case "extract_interface":
+		if subDirective.Argument == "" {
+			return NewParserErrorWithContext(subDirective, "extract_interface directive requires an argument (interface name)")
+		}
+		r.TypeRule.ExtractInterface = subDirective.Argument
+		return nil
 	case "rename":
 		r.TypeRule.Explicit = append(r.TypeRule.Explicit, &config.ExplicitRule{
 			From: r.TypeRule.Name,
@@ -43,13 +49,16 @@ func (r *TypeRule) ParseDirective(directive *Directive) error {
 	case "disabled":
 		r.TypeRule.Disabled = subDirective.Argument == "true"
 		return nil
+	case "embed":
+		r.TypeRule.Embed = subDirective.Argument == "true"
+		return nil
 	case "method", "field":
 		// These are structural directives handled by the main parser's recursion.
 		// The TypeRule container should ignore them.
 		return nil
 	}
 	// Delegate to the common RuleSet parser
-	return parseRuleSetDirective(&r.RuleSet, subDirective)
+	return parseRuleSetDirective(r.Type(), &r.RuleSet, subDirective)
 }
 
 func (r *TypeRule) AddPackage(pkg *PackageRule) error {
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/origadmin/adptool/internal/config"
 )
@@ -286,3 +287,16 @@ func TestTypeRule_ParseDirective(t *testing.T) {
 		})
 	}
 }
+
+func TestTypeRule_ParseDirective_ExtractInterface(t *testing.T) {
+	typeRule := &TypeRule{TypeRule: &config.TypeRule{Name: "Worker"}}
+
+	dir := decodeTestDirective("//go:adapter:type:extract_interface WorkerAPI")
+	require.NoError(t, typeRule.ParseDirective(&dir))
+	assert.Equal(t, "WorkerAPI", typeRule.TypeRule.ExtractInterface)
+
+	dir = decodeTestDirective("//go:adapter:type:extract_interface")
+	err := typeRule.ParseDirective(&dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "extract_interface directive requires an argument")
+}
@@ -40,7 +40,7 @@ func (r *VarRule) ParseDirective(directive *Directive) error {
 	}
 
 	// Delegate to the common RuleSet parser for generic rules
-	return parseRuleSetDirective(&r.RuleSet, subDirective)
+	return parseRuleSetDirective(r.Type(), &r.RuleSet, subDirective)
 }
 
 func (r *VarRule) AddPackage(pkg *PackageRule) error {
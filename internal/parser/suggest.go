@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
+// ruleSetDirectives are the directives of a config.RuleSet, accepted by every rule container.
+var ruleSetDirectives = []string{
+	"strategy", "prefix", "prefix_mode", "suffix", "suffix_mode", "suffix_template", "affix_separator", "explicit",
+	"explicit_mode", "regex", "regex_mode", "ignore", "ignores", "ignores_mode", "transform", "transform_before",
+	"transform_after",
+}
+
+// containerDirectives maps each container type to the directives it accepts below its own, such
+// as "prefix" in //go:adapter:type:prefix. Unrecognized directives are compared against them.
+var containerDirectives = map[interfaces.RuleType][]string{
+	interfaces.RuleTypeRoot: {
+		"default", "ignore", "ignores", "type-mode", "rename-file", "property",
+		"package", "type", "func", "function", "var", "variable", "const", "constant",
+		"packages", "types", "functions", "variables", "constants",
+	},
+	interfaces.RuleTypePackage: {
+		"import", "alias", "path", "version", "force-alias", "registry", "default", "property",
+		"type", "func", "function", "var", "variable", "const", "constant", "method", "field",
+	},
+	interfaces.RuleTypeType:   withRuleSetDirectives("struct", "extract_interface", "rename", "disabled", "embed", "method", "field"),
+	interfaces.RuleTypeFunc:   withRuleSetDirectives("disabled", "rename"),
+	interfaces.RuleTypeVar:    withRuleSetDirectives("rename", "disabled"),
+	interfaces.RuleTypeConst:  withRuleSetDirectives("rename"),
+	interfaces.RuleTypeMethod: withRuleSetDirectives("rename"),
+	interfaces.RuleTypeField:  withRuleSetDirectives(),
+}
+
+// Directives accepted below //go:adapter:default and //go:adapter:default:mode.
+var (
+	defaultsDirectives = []string{"mode"}
+	modeDirectives     = []string{"strategy", "prefix", "suffix", "explicit", "regex", "ignores"}
+)
+
+// withRuleSetDirectives returns the directives of a rule set followed by names.
+func withRuleSetDirectives(names ...string) []string {
+	return append(append([]string(nil), ruleSetDirectives...), names...)
+}
+
+// didYouMean returns a suggestion to append to the error for the unrecognized directive name,
+// such as "; did you mean 'prefix'?" for "prefx", naming the closest of known. It is empty if
+// no known directive is close enough to be a likely typo.
+func didYouMean(name string, known []string) string {
+	best, bestDistance := "", 0
+	for _, candidate := range known {
+		if distance := levenshtein(name, candidate); best == "" || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	// Two edits cover a swap of adjacent letters; longer names may have more typos.
+	length := len([]rune(name))
+	if best == "" || bestDistance >= length || bestDistance > max(2, length/3) {
+		return ""
+	}
+	return fmt.Sprintf("; did you mean '%s'?", best)
+}
+
+// levenshtein returns the number of single-rune insertions, deletions and substitutions
+// turning a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/origadmin/adptool/internal/config"
+)
+
+func TestParseDirectiveSuggestions(t *testing.T) {
+	tests := []struct {
+		name            string
+		container       Container
+		directiveString string
+		errorContains   string
+	}{
+		{
+			name:            "rule set directive of a type",
+			container:       &TypeRule{TypeRule: &config.TypeRule{}},
+			directiveString: "//go:adapter:type:prefx Src",
+			errorContains:   "unrecognized directive 'prefx' for RuleSet; did you mean 'prefix'?",
+		},
+		{
+			name:            "type-specific directive",
+			container:       &TypeRule{TypeRule: &config.TypeRule{}},
+			directiveString: "//go:adapter:type:embd true",
+			errorContains:   "did you mean 'embed'?",
+		},
+		{
+			name:            "root directive",
+			container:       &RootConfig{Config: config.New()},
+			directiveString: "//go:adapter:ignor Foo",
+			errorContains:   "unrecognized directive 'ignor' for RootConfig; did you mean 'ignore'?",
+		},
+		{
+			name:            "package directive",
+			container:       &PackageRule{Package: &config.Package{}},
+			directiveString: "//go:adapter:package:alais src",
+			errorContains:   "unrecognized sub-directive: alais; did you mean 'alias'?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := decodeTestDirective(tt.directiveString)
+			err := tt.container.ParseDirective(&dir)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorContains)
+		})
+	}
+}
+
+func TestParseDirectiveNoSuggestion(t *testing.T) {
+	rc := &RootConfig{Config: config.New()}
+	dir := decodeTestDirective("//go:adapter:unknown-directive")
+	err := rc.ParseDirective(&dir)
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "did you mean", "a name unlike any directive gets no suggestion")
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("prefix", "prefix"))
+	assert.Equal(t, 1, levenshtein("prefx", "prefix"))
+	assert.Equal(t, 2, levenshtein("sufixx", "suffix"))
+	assert.Equal(t, 3, levenshtein("", "abc"))
+}
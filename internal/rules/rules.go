@@ -2,12 +2,21 @@ package rules
 
 import (
 	"fmt"
+	"go/token"
+	"hash/fnv"
+	"path"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/origadmin/adptool/internal/interfaces"
 )
 
 // ApplyRules applies a set of compiled rename rules to a given name and returns the result.
-func ApplyRules(name string, rules []interfaces.CompiledRenameRule) (string, error) {
+// pkgName is the declared name of the package the symbol belongs to; qualified regex rules
+// match against "pkgName.name" and leave the name untouched when the pattern does not match.
+func ApplyRules(name string, pkgName string, rules []interfaces.CompiledRenameRule) (string, error) {
 	currentName := name
 	for _, rule := range rules {
 		switch rule.Type {
@@ -16,16 +25,135 @@ func ApplyRules(name string, rules []interfaces.CompiledRenameRule) (string, err
 				return rule.To, nil // Explicit rule is final
 			}
 		case "prefix":
-			currentName = rule.Value + currentName
+			currentName = addPrefix(currentName, rule.Value, rule.Separator)
 		case "suffix":
-			currentName = currentName + rule.Value
+			currentName = currentName + rule.Separator + rule.Value
+		case "suffix_template":
+			if rule.Template == nil {
+				return "", fmt.Errorf("suffix template '%s' is not parsed", rule.Value)
+			}
+			suffix, err := renderSuffixTemplate(rule.Template, SuffixTemplateData{
+				Name:    name,
+				Package: pkgName,
+				Kind:    rule.RuleType.String(),
+			})
+			if err != nil {
+				return "", err
+			}
+			currentName = currentName + rule.Separator + suffix
 		case "regex":
 			// Use the pre-compiled regex
 			if rule.CompiledRegex == nil {
 				return "", fmt.Errorf("regex rule '%s' has no compiled regex", rule.Pattern)
 			}
+			if rule.Qualified {
+				currentName = applyQualifiedRegex(currentName, pkgName, rule)
+				continue
+			}
 			currentName = rule.CompiledRegex.ReplaceAllString(currentName, rule.Replace)
+		case "transform":
+			// Each stage is applied to the result of the previous one.
+			for i, stage := range rule.Transforms {
+				if stage.CompiledRegex == nil {
+					return "", fmt.Errorf("transform stage %d ('%s') has no compiled regex", i, stage.Pattern)
+				}
+				currentName = stage.CompiledRegex.ReplaceAllString(currentName, stage.Replace)
+			}
+		case "truncate":
+			currentName = TruncateName(currentName, rule.MaxLength)
 		}
 	}
 	return currentName, nil
-}
\ No newline at end of file
+}
+
+// addPrefix prepends prefix to name, separated by separator. The prefix of an exported name is
+// capitalized to keep it exported, so that "my" turns Foo into MyFoo, or into My_Foo with "_".
+func addPrefix(name, prefix, separator string) string {
+	if token.IsExported(name) {
+		prefix = title(prefix)
+	}
+	return prefix + separator + name
+}
+
+// IgnoreRegexPrefix marks an ignores entry as a regular expression, e.g. "re:^Test".
+const IgnoreRegexPrefix = "re:"
+
+// MatchIgnore reports whether name matches the ignores entry. Regex entries match anywhere in
+// the name unless anchored; other entries are glob patterns, which also match literal names.
+func MatchIgnore(name string, ignore interfaces.CompiledIgnore) bool {
+	if ignore.CompiledRegex != nil {
+		return ignore.CompiledRegex.MatchString(name)
+	}
+	matched, err := path.Match(ignore.Pattern, name)
+	return err == nil && matched
+}
+
+// NameHashLength is the length of the hash appended to truncated names.
+const NameHashLength = 8
+
+// TruncateName shortens name to at most maxLength characters. The name is cut and the last
+// NameHashLength characters are replaced by a hash of the full name, so names sharing a long
+// prefix stay distinct. The result keeps the first character of name, and so stays a valid
+// identifier with the same visibility. maxLength must be greater than NameHashLength.
+func TruncateName(name string, maxLength int) string {
+	runes := []rune(name)
+	if maxLength <= NameHashLength || len(runes) <= maxLength {
+		return name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return string(runes[:maxLength-NameHashLength]) + fmt.Sprintf("%08x", h.Sum32())
+}
+
+// applyQualifiedRegex runs a qualified regex rule against "pkgName.name".
+// The package qualifier is stripped from the result, so a replacement may produce
+// either a bare name or a qualified one.
+func applyQualifiedRegex(name, pkgName string, rule interfaces.CompiledRenameRule) string {
+	if pkgName == "" {
+		return name
+	}
+	qualified := pkgName + "." + name
+	if !rule.CompiledRegex.MatchString(qualified) {
+		return name
+	}
+	replaced := rule.CompiledRegex.ReplaceAllString(qualified, rule.Replace)
+	return strings.TrimPrefix(replaced, pkgName+".")
+}
+
+// SuffixTemplateData is the data a suffix template is rendered with.
+type SuffixTemplateData struct {
+	Name    string // Source name of the symbol, e.g. "Client"
+	Package string // Declared name of the package the symbol belongs to, e.g. "http"
+	Kind    string // Kind of the symbol: "type", "func", "var", "const", "method" or "field"
+}
+
+// suffixTemplateFuncs are the functions available to suffix templates, so that a lowercase
+// package name can be made part of an exported identifier, e.g. {{title .Package}}.
+var suffixTemplateFuncs = template.FuncMap{
+	"title": title,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// ParseSuffixTemplate parses the source of a suffix template, such as "From{{title .Package}}".
+func ParseSuffixTemplate(text string) (*template.Template, error) {
+	return template.New("suffix").Funcs(suffixTemplateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// renderSuffixTemplate renders tmpl with data into the suffix to append to a name.
+func renderSuffixTemplate(tmpl *template.Template, data SuffixTemplateData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render suffix template for '%s': %w", data.Name, err)
+	}
+	return b.String(), nil
+}
+
+// title returns s with its first letter in upper case.
+func title(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
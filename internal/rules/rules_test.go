@@ -0,0 +1,218 @@
+package rules
+
+import (
+	"go/token"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/origadmin/adptool/internal/interfaces"
+)
+
+func TestApplyRules_QualifiedRegex(t *testing.T) {
+	rule := interfaces.CompiledRenameRule{
+		Type:          "regex",
+		Pattern:       `^foo\.Get(.*)`,
+		Replace:       "Fetch$1",
+		CompiledRegex: regexp.MustCompile(`^foo\.Get(.*)`),
+		Qualified:     true,
+	}
+
+	tests := []struct {
+		name     string
+		pkgName  string
+		symbol   string
+		expected string
+	}{
+		{name: "matching package", pkgName: "foo", symbol: "GetUser", expected: "FetchUser"},
+		{name: "other package", pkgName: "bar", symbol: "GetUser", expected: "GetUser"},
+		{name: "matching package, name does not match", pkgName: "foo", symbol: "SetUser", expected: "SetUser"},
+		{name: "unknown package", pkgName: "", symbol: "GetUser", expected: "GetUser"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyRules(tt.symbol, tt.pkgName, []interfaces.CompiledRenameRule{rule})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestApplyRules_QualifiedRegexKeepsQualifiedReplacement(t *testing.T) {
+	rule := interfaces.CompiledRenameRule{
+		Type:          "regex",
+		CompiledRegex: regexp.MustCompile(`^foo\.Get(.*)`),
+		Replace:       "foo.Fetch$1",
+		Qualified:     true,
+	}
+	got, err := ApplyRules("GetUser", "foo", []interfaces.CompiledRenameRule{rule})
+	require.NoError(t, err)
+	assert.Equal(t, "FetchUser", got, "the package qualifier should be stripped from the result")
+}
+
+func TestApplyRules_UnqualifiedRegexIgnoresPackage(t *testing.T) {
+	rule := interfaces.CompiledRenameRule{
+		Type:          "regex",
+		CompiledRegex: regexp.MustCompile(`^Get(.*)`),
+		Replace:       "Fetch$1",
+	}
+	for _, pkgName := range []string{"foo", "bar"} {
+		got, err := ApplyRules("GetUser", pkgName, []interfaces.CompiledRenameRule{rule})
+		require.NoError(t, err)
+		assert.Equal(t, "FetchUser", got)
+	}
+}
+
+func TestApplyRules_TransformPipeline(t *testing.T) {
+	rule := interfaces.CompiledRenameRule{
+		Type: "transform",
+		Transforms: []interfaces.CompiledTransform{
+			{Pattern: "^Legacy(.*)$", Replace: "$1", CompiledRegex: regexp.MustCompile("^Legacy(.*)$")},
+			{Pattern: "^(.*)$", Replace: "${1}V2", CompiledRegex: regexp.MustCompile("^(.*)$")},
+		},
+	}
+
+	got, err := ApplyRules("LegacyClient", "", []interfaces.CompiledRenameRule{rule})
+	require.NoError(t, err)
+	assert.Equal(t, "ClientV2", got, "the prefix should be stripped before the suffix is added")
+
+	got, err = ApplyRules("Client", "", []interfaces.CompiledRenameRule{rule})
+	require.NoError(t, err)
+	assert.Equal(t, "ClientV2", got, "a stage that does not match leaves the name to the next stage")
+}
+
+func TestApplyRules_SuffixTemplate(t *testing.T) {
+	tmpl, err := ParseSuffixTemplate("From{{title .Package}}")
+	require.NoError(t, err)
+	rule := interfaces.CompiledRenameRule{Type: "suffix_template", RuleType: interfaces.RuleTypeType, Template: tmpl}
+
+	got, err := ApplyRules("Foo", "bar", []interfaces.CompiledRenameRule{rule})
+	require.NoError(t, err)
+	assert.Equal(t, "FooFromBar", got)
+
+	got, err = ApplyRules("Client", "http", []interfaces.CompiledRenameRule{rule})
+	require.NoError(t, err)
+	assert.Equal(t, "ClientFromHttp", got, "the suffix is rendered for each symbol")
+}
+
+func TestApplyRules_SuffixTemplateFields(t *testing.T) {
+	tmpl, err := ParseSuffixTemplate("_{{.Kind}}_{{upper .Package}}_{{lower .Name}}")
+	require.NoError(t, err)
+	rule := interfaces.CompiledRenameRule{Type: "suffix_template", RuleType: interfaces.RuleTypeFunc, Template: tmpl}
+
+	got, err := ApplyRules("NewClient", "http", []interfaces.CompiledRenameRule{rule})
+	require.NoError(t, err)
+	assert.Equal(t, "NewClient_func_HTTP_newclient", got)
+}
+
+func TestApplyRules_AffixSeparator(t *testing.T) {
+	tests := []struct {
+		name      string
+		symbol    string
+		prefix    string
+		suffix    string
+		separator string
+		want      string
+	}{
+		{name: "no separator", symbol: "Foo", prefix: "My", suffix: "V2", want: "MyFooV2"},
+		{name: "separator", symbol: "Foo", prefix: "My", suffix: "V2", separator: "_", want: "My_Foo_V2"},
+		{name: "lower case prefix of exported name", symbol: "Foo", prefix: "my", separator: "_", want: "My_Foo"},
+		{name: "lower case prefix of unexported name", symbol: "foo", prefix: "my", separator: "_", want: "my_foo"},
+		{name: "lower case prefix without separator", symbol: "Foo", prefix: "my", want: "MyFoo"},
+		{name: "unexported name without separator", symbol: "foo", prefix: "my", want: "myfoo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []interfaces.CompiledRenameRule
+			if tt.prefix != "" {
+				rules = append(rules, interfaces.CompiledRenameRule{Type: "prefix", Value: tt.prefix, Separator: tt.separator})
+			}
+			if tt.suffix != "" {
+				rules = append(rules, interfaces.CompiledRenameRule{Type: "suffix", Value: tt.suffix, Separator: tt.separator})
+			}
+			got, err := ApplyRules(tt.symbol, "", rules)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestApplyRules_SuffixTemplateSeparator(t *testing.T) {
+	tmpl, err := ParseSuffixTemplate("From{{title .Package}}")
+	require.NoError(t, err)
+	rule := interfaces.CompiledRenameRule{Type: "suffix_template", Template: tmpl, Separator: "_"}
+
+	got, err := ApplyRules("Foo", "bar", []interfaces.CompiledRenameRule{rule})
+	require.NoError(t, err)
+	assert.Equal(t, "Foo_FromBar", got)
+}
+
+func TestParseSuffixTemplate_Invalid(t *testing.T) {
+	_, err := ParseSuffixTemplate("From{{.Package")
+	assert.Error(t, err)
+
+	tmpl, err := ParseSuffixTemplate("From{{.Module}}")
+	require.NoError(t, err, "unknown fields are only found when the template is rendered")
+	_, err = ApplyRules("Foo", "bar", []interfaces.CompiledRenameRule{{Type: "suffix_template", Template: tmpl}})
+	assert.ErrorContains(t, err, "Module")
+}
+
+func TestApplyRules_Truncate(t *testing.T) {
+	pipeline := []interfaces.CompiledRenameRule{
+		{Type: "prefix", Value: "GeneratedAdapterFor"},
+		{Type: "truncate", MaxLength: 24},
+	}
+
+	short, err := ApplyRules("X", "", pipeline)
+	require.NoError(t, err)
+	assert.Equal(t, "GeneratedAdapterForX", short, "names within the limit are unchanged")
+
+	first, err := ApplyRules("ConnectionPoolSettings", "", pipeline)
+	require.NoError(t, err)
+	second, err := ApplyRules("ConnectionPoolStatistics", "", pipeline)
+	require.NoError(t, err)
+
+	for _, name := range []string{first, second} {
+		assert.Len(t, name, 24)
+		assert.True(t, strings.HasPrefix(name, "GeneratedAdapte"), name)
+		assert.True(t, token.IsIdentifier(name), name)
+		assert.True(t, token.IsExported(name), name)
+	}
+	assert.NotEqual(t, first, second, "names sharing the truncated prefix must stay distinct")
+
+	again, err := ApplyRules("ConnectionPoolSettings", "", pipeline)
+	require.NoError(t, err)
+	assert.Equal(t, first, again, "truncation must be deterministic")
+}
+
+func TestTruncateName(t *testing.T) {
+	assert.Equal(t, "Name", TruncateName("Name", 0), "a zero limit disables truncation")
+	assert.Equal(t, "LongName", TruncateName("LongName", NameHashLength), "limits leaving no room for the name are ignored")
+
+	got := TruncateName("ÄußerstLangerBezeichner", 12)
+	assert.Equal(t, 12, utf8.RuneCountInString(got))
+	assert.True(t, strings.HasPrefix(got, "Äuße"), got)
+	assert.True(t, token.IsIdentifier(got), got)
+}
+
+func TestMatchIgnore(t *testing.T) {
+	tests := []struct {
+		ignore interfaces.CompiledIgnore
+		name   string
+		want   bool
+	}{
+		{interfaces.CompiledIgnore{Pattern: "Debug"}, "Debug", true},
+		{interfaces.CompiledIgnore{Pattern: "Debug"}, "DebugMode", false},
+		{interfaces.CompiledIgnore{Pattern: "Must*"}, "MustParse", true},
+		{interfaces.CompiledIgnore{Pattern: "Must*"}, "Parse", false},
+		{interfaces.CompiledIgnore{Pattern: "^Test", CompiledRegex: regexp.MustCompile("^Test")}, "TestHelper", true},
+		{interfaces.CompiledIgnore{Pattern: "^Test", CompiledRegex: regexp.MustCompile("^Test")}, "NewTest", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchIgnore(tt.name, tt.ignore), "%q against %q", tt.name, tt.ignore.Pattern)
+	}
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"bytes"
 	"fmt"
 	"os/exec"
 )
@@ -8,17 +9,37 @@ import (
 // RunGoImports formats the Go file at the given path using goimports.
 // It returns an error if goimports fails or is not found.
 func RunGoImports(filePath string) error {
-	goimportsCmd := "goimports"
-	_, err := exec.LookPath(goimportsCmd)
-	if err != nil {
+	cmd := exec.Command("goimports", "-w", filePath)
+	if _, err := exec.LookPath("goimports"); err != nil {
 		// goimports not found in PATH, try go run
-		goimportsCmd = "go run golang.org/x/tools/cmd/goimports"
+		cmd = exec.Command("go", "run", "golang.org/x/tools/cmd/goimports", "-w", filePath)
 	}
 
-	cmd := exec.Command(goimportsCmd, "-w", filePath)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("goimports failed for %s: %s\n%s", filePath, err, string(output))
 	}
 	return nil
 }
+
+// FormatGoImports formats src using goimports as if it were the Go file at filePath, which need
+// not exist, so that imports resolve as they would for that file. An empty filePath formats src
+// as a file of the current directory.
+func FormatGoImports(src []byte, filePath string) ([]byte, error) {
+	args := []string{}
+	if filePath != "" {
+		args = append(args, "-srcdir", filePath)
+	}
+	cmd := exec.Command("goimports", args...)
+	if _, err := exec.LookPath("goimports"); err != nil {
+		// goimports not found in PATH, try go run
+		cmd = exec.Command("go", append([]string{"run", "golang.org/x/tools/cmd/goimports"}, args...)...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(src), &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("goimports failed for %s: %s\n%s", filePath, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
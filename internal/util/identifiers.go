@@ -0,0 +1,34 @@
+package util
+
+import (
+	"go/token"
+	"go/types"
+	"unicode/utf8"
+)
+
+// ReservedNameKind reports why name cannot safely be used as a declaration name, or returns an
+// empty string if it can. A keyword never compiles and a predeclared identifier silently
+// shadows the builtin.
+func ReservedNameKind(name string) string {
+	if token.IsKeyword(name) {
+		return "keyword"
+	}
+	if types.Universe.Lookup(name) != nil {
+		return "predeclared identifier"
+	}
+	return ""
+}
+
+// IsStrictIdentifier reports whether name is an exported identifier made of ASCII letters,
+// digits and underscores only.
+func IsStrictIdentifier(name string) bool {
+	if !token.IsIdentifier(name) || name[0] < 'A' || name[0] > 'Z' {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
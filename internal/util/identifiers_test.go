@@ -0,0 +1,22 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsStrictIdentifier(t *testing.T) {
+	for name, want := range map[string]bool{
+		"Worker":           true,
+		"HTTP_Client2":     true,
+		"worker":           false,
+		"_Worker":          false,
+		"Wörker":           false,
+		"Ωmega":            false,
+		"\u201cName\u201d": false,
+		"":                 false,
+	} {
+		assert.Equal(t, want, IsStrictIdentifier(name), name)
+	}
+}
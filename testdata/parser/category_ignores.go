@@ -0,0 +1,8 @@
+package parser
+
+// Test category-scoped ignore directives
+//go:adapter:type MyType
+//go:adapter:type:rename RenamedType
+//go:adapter:types:ignore Internal*
+//go:adapter:functions:ignores NewFoo Must*
+//go:adapter:ignore pattern1
@@ -0,0 +1,8 @@
+package parser
+
+// Directives enumerated by TestDirectives, in source order.
+//go:adapter:package github.com/example/lib lib
+//go:adapter:type Client // a trailing comment
+//go:adapter:type:method:rename Close=Shutdown
+// A regular comment, not a directive.
+//go:adapter:function:json {"name":"New"}
@@ -0,0 +1,3 @@
+//go:adapter
+
+package parser
@@ -0,0 +1,34 @@
+// Package anyargs instantiates generic types with any as a type argument, which adapters must
+// leave unqualified like the other predeclared types.
+package anyargs
+
+// Box holds a value of any type.
+type Box[T any] struct {
+	Value T
+}
+
+// Pair holds a keyed value.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// AnyBox is a Box of any value.
+type AnyBox = Box[any]
+
+// Boxes is a list of boxes of any values.
+var Boxes []Box[any]
+
+// Wrap boxes v.
+func Wrap(v any) Box[any] {
+	return Box[any]{Value: v}
+}
+
+// Index maps the keys of pairs to their values.
+func Index[K comparable](pairs []Pair[K, any]) map[K]any {
+	index := make(map[K]any, len(pairs))
+	for _, p := range pairs {
+		index[p.Key] = p.Value
+	}
+	return index
+}
@@ -0,0 +1,4 @@
+// Package buildtag declares some of its symbols only when building with a tag.
+package buildtag
+
+func Common() {}
@@ -0,0 +1,6 @@
+//go:build adptool_extra
+
+package buildtag
+
+// Extra is only declared when building with the adptool_extra tag.
+func Extra() {}
@@ -0,0 +1,13 @@
+// Package constraints defines constraints used by the generic fixtures, in the style of
+// golang.org/x/exp/constraints.
+package constraints
+
+// Integer is a constraint satisfied by the built-in integer types.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+// Ordered is a constraint satisfied by types that support the ordering operators.
+type Ordered interface {
+	Integer | ~float32 | ~float64 | ~string
+}
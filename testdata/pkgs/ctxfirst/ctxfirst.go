@@ -0,0 +1,30 @@
+// Package ctxfirst declares functions taking a context.Context in various positions.
+package ctxfirst
+
+import (
+	"context"
+	stdctx "context"
+)
+
+// Run takes a context first, and a parameter named like the deferred hook result.
+func Run(ctx context.Context, done chan struct{}) error {
+	return ctx.Err()
+}
+
+// Do takes an unnamed context first.
+func Do(context.Context, string) {}
+
+// Renamed takes a context through a renamed import.
+func Renamed(c stdctx.Context) stdctx.Context {
+	return c
+}
+
+// Later takes a context, but not first.
+func Later(name string, ctx context.Context) string {
+	return name
+}
+
+// Plain takes no context.
+func Plain(name string) string {
+	return name
+}
@@ -0,0 +1,34 @@
+// Package deprecated has current and deprecated symbols.
+package deprecated
+
+// Client is current.
+type Client struct{}
+
+// OldClient is kept for compatibility.
+//
+// Deprecated: use Client instead.
+type OldClient struct{}
+
+// NewClient returns a new client.
+func NewClient() *Client { return &Client{} }
+
+// Connect returns a new client.
+//
+// Deprecated: use NewClient instead.
+func Connect() *Client { return &Client{} }
+
+const (
+	// Timeout is the default timeout, in seconds.
+	Timeout = 30
+	// Deprecated: use Timeout instead.
+	DefaultTimeout = 30
+)
+
+// Deprecated: the legacy settings are no longer read.
+var (
+	LegacyHost = "localhost"
+	LegacyPort = 8080
+)
+
+// Host is the default host.
+var Host = "localhost"
@@ -0,0 +1,6 @@
+package generated
+
+// NewMessage returns a message of text.
+func NewMessage(text string) *Message {
+	return &Message{Text: text}
+}
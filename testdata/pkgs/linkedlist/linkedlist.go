@@ -0,0 +1,22 @@
+// Package linkedlist declares a type that refers to itself.
+package linkedlist
+
+// Node is an element of a singly linked list.
+type Node struct {
+	Value int
+	Next  *Node
+}
+
+// NewNode returns a node holding value.
+func NewNode(value int) *Node {
+	return &Node{Value: value}
+}
+
+// Len returns the number of nodes from n to the end of the list.
+func (n *Node) Len() int {
+	length := 0
+	for ; n != nil; n = n.Next {
+		length++
+	}
+	return length
+}
@@ -0,0 +1,14 @@
+package samename
+
+// Client has a method of its own name.
+type Client struct {
+	name string
+}
+
+// Client returns the name of the client.
+func (c *Client) Client() string {
+	return c.name
+}
+
+// Default is the default client.
+var Default = &Client{name: "default"}
@@ -0,0 +1,14 @@
+package samename
+
+// Name is declared both as a method and as a function.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Name returns the name of the default client.
+func Name() string {
+	return Default.name
+}
+
+// Version is the version of the package.
+const Version = "v1"
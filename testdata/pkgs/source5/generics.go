@@ -0,0 +1,36 @@
+// Package source5 contains generic types with multiple type parameters and named constraints.
+package source5
+
+import "cmp"
+
+// Number is a constraint satisfied by the built-in numeric types.
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+// Pair holds two values of independent types.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Table maps ordered keys to numeric values.
+type Table[K cmp.Ordered, V Number] map[K]V
+
+// Grid has two type parameters sharing one constraint.
+type Grid[R, C Number] struct {
+	Rows []R
+	Cols []C
+}
+
+// Scalar accepts integers and any Number.
+type Scalar[T ~int8 | Number] []T
+
+// Sum adds up the given values.
+func Sum[V Number](values ...V) V {
+	var total V
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,17 @@
+// Package source6 uses constraints declared in another package.
+package source6
+
+import "github.com/origadmin/adptool/testdata/pkgs/constraints"
+
+// Max returns the larger of a and b.
+func Max[T constraints.Ordered](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// SortedSet keeps ordered values.
+type SortedSet[T constraints.Ordered] struct {
+	items []T
+}
@@ -0,0 +1,12 @@
+package source6
+
+import num "github.com/origadmin/adptool/testdata/pkgs/constraints"
+
+// Sum adds up the given integers.
+func Sum[T num.Integer](values ...T) T {
+	var total T
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,37 @@
+// Package tagged marks some of its symbols for re-export with an "adapter:export" tag.
+package tagged
+
+// Client is exported through the adapter.
+//
+// adapter:export
+type Client struct{}
+
+// Server is not tagged.
+type Server struct{}
+
+// NewClient creates a client.
+//
+//adapter:export
+func NewClient() *Client { return &Client{} }
+
+// NewServer is not tagged.
+func NewServer() *Server { return &Server{} }
+
+// adapter:exported is a different tag and does not match.
+func Helper() {}
+
+// adapter:export
+const (
+	// DefaultPort is tagged through its declaration.
+	DefaultPort = 8080
+	// DefaultHost is tagged through its declaration.
+	DefaultHost = "localhost"
+)
+
+var (
+	// Version is exported through the adapter.
+	// adapter:export
+	Version = "v1"
+	// Build is not tagged.
+	Build = "dev"
+)
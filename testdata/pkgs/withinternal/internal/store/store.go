@@ -0,0 +1,6 @@
+package store
+
+// Store is a type of an internal package, which only its module tree can refer to.
+type Store struct {
+	Name string
+}
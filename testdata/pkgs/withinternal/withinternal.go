@@ -0,0 +1,13 @@
+package withinternal
+
+import "github.com/origadmin/adptool/testdata/pkgs/withinternal/internal/store"
+
+// Open returns a value of an internal type.
+func Open(name string) *store.Store {
+	return &store.Store{Name: name}
+}
+
+// Version uses no internal type.
+func Version() string {
+	return "v1"
+}